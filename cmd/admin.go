@@ -372,7 +372,9 @@ func runRepoSyncReleases(c *cli.Context) error {
 			}
 			log.Trace(" currentNumReleases is %d, running SyncReleasesWithTags", oldnum)
 
-			if err = models.SyncReleasesWithTags(repo, gitRepo); err != nil {
+			err = models.SyncReleasesWithTags(repo, gitRepo)
+			gitRepo.Close()
+			if err != nil {
 				log.Warn(" SyncReleasesWithTags: %v", err)
 				continue
 			}
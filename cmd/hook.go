@@ -135,6 +135,7 @@ func runHookPostReceive(c *cli.Context) error {
 	pusherID, _ := strconv.ParseInt(os.Getenv(models.EnvPusherID), 10, 64)
 	pusherName := os.Getenv(models.EnvPusherName)
 
+	pushedBranches := false
 	buf := bytes.NewBuffer(nil)
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
@@ -154,6 +155,9 @@ func runHookPostReceive(c *cli.Context) error {
 		oldCommitID := string(fields[0])
 		newCommitID := string(fields[1])
 		refFullName := string(fields[2])
+		if newCommitID != git.EmptySHA && strings.HasPrefix(refFullName, git.BranchPrefix) {
+			pushedBranches = true
+		}
 
 		res, err := private.HookPostReceive(repoUser, repoName, private.HookOptions{
 			OldCommitID: oldCommitID,
@@ -182,5 +186,12 @@ func runHookPostReceive(c *cli.Context) error {
 		fmt.Fprintln(os.Stderr, "")
 	}
 
+	if pushedBranches {
+		// the refs have been updated, so a failure only delays the tasks run after the push
+		if err := private.HookPushDone(repoUser, repoName); err != nil {
+			fmt.Fprintf(os.Stderr, "HookPushDone failed: %v\n", err)
+		}
+	}
+
 	return nil
 }
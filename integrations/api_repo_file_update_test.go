@@ -271,3 +271,52 @@ func TestAPIUpdateFile(t *testing.T) {
 		session.MakeRequest(t, req, http.StatusForbidden)
 	})
 }
+
+func TestAPIUpdateFileExecutable(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user2 := models.AssertExistsAndLoadBean(t, &models.User{ID: 2}).(*models.User)             // owner of the repo1
+		repo1 := models.AssertExistsAndLoadBean(t, &models.Repository{ID: 1}).(*models.Repository) // public repo
+		session := loginUser(t, user2.Name)
+		token2 := getTokenForLoggedInUser(t, session)
+
+		treePath := "update/script.sh"
+		createFile(user2, repo1, treePath)
+		url := fmt.Sprintf("/api/v1/repos/%s/%s/contents/%s?token=%s", user2.Name, repo1.Name, treePath, token2)
+		isExecutable := func() bool {
+			gitRepo, err := git.OpenRepository(repo1.RepoPath())
+			assert.NoError(t, err)
+			defer gitRepo.Close()
+			commit, err := gitRepo.GetBranchCommit(repo1.DefaultBranch)
+			assert.NoError(t, err)
+			entry, err := commit.GetTreeEntryByPath(treePath)
+			assert.NoError(t, err)
+			return entry.IsExecutable()
+		}
+
+		executable := true
+		updateFileOptions := getUpdateFileOptions()
+		updateFileOptions.Executable = &executable
+		req := NewRequestWithJSON(t, "PUT", url, &updateFileOptions)
+		resp := session.MakeRequest(t, req, http.StatusOK)
+		var fileResponse api.FileResponse
+		DecodeJSON(t, resp, &fileResponse)
+		assert.True(t, isExecutable())
+
+		// the mode is kept if executable is not given
+		updateFileOptions = getUpdateFileOptions()
+		updateFileOptions.SHA = fileResponse.Content.SHA
+		updateFileOptions.Content = base64.StdEncoding.EncodeToString([]byte("#!/bin/sh\n"))
+		req = NewRequestWithJSON(t, "PUT", url, &updateFileOptions)
+		resp = session.MakeRequest(t, req, http.StatusOK)
+		DecodeJSON(t, resp, &fileResponse)
+		assert.True(t, isExecutable())
+
+		executable = false
+		updateFileOptions = getUpdateFileOptions()
+		updateFileOptions.SHA = fileResponse.Content.SHA
+		updateFileOptions.Executable = &executable
+		req = NewRequestWithJSON(t, "PUT", url, &updateFileOptions)
+		session.MakeRequest(t, req, http.StatusOK)
+		assert.False(t, isExecutable())
+	})
+}
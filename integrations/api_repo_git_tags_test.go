@@ -35,7 +35,7 @@ func TestAPIGitTags(t *testing.T) {
 
 	aTagName := "annotatedTag"
 	aTagMessage := "my annotated message"
-	gitRepo.CreateAnnotatedTag(aTagName, aTagMessage, commit.ID.String())
+	gitRepo.CreateAnnotatedTag(aTagName, aTagMessage, commit.ID.String(), nil)
 	aTag, _ := gitRepo.GetTag(aTagName)
 
 	// SHOULD work for annotated tags
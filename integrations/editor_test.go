@@ -11,6 +11,9 @@ import (
 	"path"
 	"testing"
 
+	"code.gitea.io/gitea/models"
+	"code.gitea.io/gitea/modules/git"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -157,6 +160,53 @@ func TestEditFile(t *testing.T) {
 	})
 }
 
+func TestEditFileExecutable(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		session := loginUser(t, "user2")
+		editPath := path.Join("user2", "repo1", "_edit", "master", "README.md")
+		edit := func(executable bool) {
+			req := NewRequest(t, "GET", editPath)
+			resp := session.MakeRequest(t, req, http.StatusOK)
+			htmlDoc := NewHTMLParser(t, resp.Body)
+			values := map[string]string{
+				"_csrf":         htmlDoc.GetCSRF(),
+				"last_commit":   htmlDoc.GetInputValueByName("last_commit"),
+				"tree_path":     "README.md",
+				"content":       "#!/bin/sh\n",
+				"commit_choice": "direct",
+			}
+			if executable {
+				values["executable"] = "on"
+			}
+			req = NewRequestWithValues(t, "POST", editPath, values)
+			session.MakeRequest(t, req, http.StatusFound)
+		}
+		isChecked := func() bool {
+			req := NewRequest(t, "GET", editPath)
+			resp := session.MakeRequest(t, req, http.StatusOK)
+			_, checked := NewHTMLParser(t, resp.Body).doc.Find(`input[name="executable"]`).Attr("checked")
+			return checked
+		}
+
+		assert.False(t, isChecked())
+		edit(true)
+		assert.True(t, isChecked())
+
+		repo1 := models.AssertExistsAndLoadBean(t, &models.Repository{ID: 1}).(*models.Repository)
+		gitRepo, err := git.OpenRepository(repo1.RepoPath())
+		assert.NoError(t, err)
+		defer gitRepo.Close()
+		commit, err := gitRepo.GetBranchCommit("master")
+		assert.NoError(t, err)
+		entry, err := commit.GetTreeEntryByPath("README.md")
+		assert.NoError(t, err)
+		assert.True(t, entry.IsExecutable())
+
+		edit(false)
+		assert.False(t, isChecked())
+	})
+}
+
 func TestEditFileToNewBranch(t *testing.T) {
 	onGiteaRun(t, func(t *testing.T, u *url.URL) {
 		session := loginUser(t, "user2")
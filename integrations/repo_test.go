@@ -97,7 +97,8 @@ func TestViewRepoWithSymlinks(t *testing.T) {
 	assert.Equal(t, items[1], "link_b: octicon octicon-file-symlink-directory")
 	assert.Equal(t, items[2], "link_d: octicon octicon-file-symlink-file")
 	assert.Equal(t, items[3], "link_hi: octicon octicon-file-symlink-file")
-	assert.Equal(t, items[4], "link_link: octicon octicon-file-symlink-file")
+	// link_link points to link_b, the chain ends at a directory
+	assert.Equal(t, items[4], "link_link: octicon octicon-file-symlink-directory")
 }
 
 // TestViewAsRepoAdmin tests PR #2167
@@ -5,7 +5,9 @@
 package integrations
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -306,6 +308,42 @@ func TestCreateOrUpdateRepoFileWithoutBranchNames(t *testing.T) {
 	})
 }
 
+func TestCreateOrUpdateRepoFileRunsHooks(t *testing.T) {
+	// setup
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		ctx := test.MockContext(t, "user2/repo1")
+		ctx.SetParams(":id", "1")
+		test.LoadRepo(t, ctx, 1)
+		test.LoadRepoCommit(t, ctx)
+		test.LoadUser(t, ctx, 2)
+		test.LoadGitRepo(t, ctx)
+		repo := ctx.Repo.Repository
+		doer := ctx.User
+		opts := getUpdateRepoFileOptions(repo)
+
+		// the post-receive hook records the push of the commit
+		_, err := repofiles.CreateOrUpdateRepoFile(repo, doer, opts)
+		assert.NoError(t, err)
+		gitRepo, err := git.OpenRepository(repo.RepoPath())
+		assert.NoError(t, err)
+		defer gitRepo.Close()
+		commitID, err := gitRepo.GetBranchCommitID(repo.DefaultBranch)
+		assert.NoError(t, err)
+		action := models.AssertExistsAndLoadBean(t, &models.Action{RepoID: repo.ID, OpType: models.ActionCommitRepo, ActUserID: doer.ID}).(*models.Action)
+		assert.Contains(t, action.Content, commitID)
+
+		// a declining pre-receive hook keeps the branch where it is
+		hookPath := filepath.Join(repo.RepoPath(), "hooks", "pre-receive.d", "zz-decline")
+		assert.NoError(t, ioutil.WriteFile(hookPath, []byte("#!/bin/sh\nexit 1\n"), 0755))
+		defer os.Remove(hookPath)
+		_, err = repofiles.CreateOrUpdateRepoFile(repo, doer, getCreateRepoFileOptions(repo))
+		assert.True(t, git.IsErrPushRejected(err), "%v", err)
+		newCommitID, err := gitRepo.GetBranchCommitID(repo.DefaultBranch)
+		assert.NoError(t, err)
+		assert.Equal(t, commitID, newCommitID)
+	})
+}
+
 func TestCreateOrUpdateRepoFileErrors(t *testing.T) {
 	// setup
 	onGiteaRun(t, func(t *testing.T, u *url.URL) {
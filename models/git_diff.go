@@ -232,10 +232,16 @@ type DiffFile struct {
 	IsLFSFile          bool
 	IsRenamed          bool
 	IsSubmodule        bool
+	OldMode, NewMode   string
 	Sections           []*DiffSection
 	IsIncomplete       bool
 }
 
+// IsModeChanged returns true if the mode of the file changed, e.g. it was made executable
+func (diffFile *DiffFile) IsModeChanged() bool {
+	return diffFile.OldMode != "" && diffFile.NewMode != "" && diffFile.OldMode != diffFile.NewMode
+}
+
 // GetType returns type of diff file.
 func (diffFile *DiffFile) GetType() int {
 	return int(diffFile.Type)
@@ -540,6 +546,12 @@ func ParsePatch(maxLines, maxLineCharacters, maxFiles int, reader io.Reader) (*D
 		case strings.HasPrefix(line, "Binary"):
 			curFile.IsBin = true
 			continue
+		case strings.HasPrefix(line, "old mode "):
+			curFile.OldMode = strings.TrimPrefix(line, "old mode ")
+			continue
+		case strings.HasPrefix(line, "new mode "):
+			curFile.NewMode = strings.TrimPrefix(line, "new mode ")
+			continue
 		}
 
 		// Get new file.
@@ -611,6 +623,8 @@ func ParsePatch(maxLines, maxLineCharacters, maxFiles int, reader io.Reader) (*D
 				}
 
 				switch {
+				case strings.HasPrefix(line, "old mode "):
+					curFile.OldMode = strings.TrimSpace(strings.TrimPrefix(line, "old mode "))
 				case strings.HasPrefix(line, "new file"):
 					curFile.Type = DiffFileAdd
 					curFile.IsCreated = true
@@ -676,6 +690,7 @@ func GetDiffRangeWithWhitespaceBehavior(repoPath, beforeCommitID, afterCommitID
 	if err != nil {
 		return nil, err
 	}
+	defer gitRepo.Close()
 
 	commit, err := gitRepo.GetCommit(afterCommitID)
 	if err != nil {
@@ -748,6 +763,7 @@ func GetRawDiffForFile(repoPath, startCommit, endCommit string, diffType RawDiff
 	if err != nil {
 		return fmt.Errorf("OpenRepository: %v", err)
 	}
+	defer repo.Close()
 
 	commit, err := repo.GetCommit(endCommit)
 	if err != nil {
@@ -146,6 +146,30 @@ func TestParsePatch(t *testing.T) {
 		t.Errorf("ParsePatch failed: %s", err)
 	}
 	println(result)
+
+	var diff4 = `diff --git a/build.sh b/build.sh
+old mode 100644
+new mode 100755
+diff --git a/README.md b/README.md
+old mode 100644
+new mode 100755
+index 1234567..89abcde
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-# gitea
++# Gitea`
+	result, err = ParsePatch(setting.Git.MaxGitDiffLines, setting.Git.MaxGitDiffLineCharacters, setting.Git.MaxGitDiffFiles, strings.NewReader(diff4))
+	assert.NoError(t, err)
+	if assert.Len(t, result.Files, 2) {
+		assert.Equal(t, "build.sh", result.Files[0].Name)
+		assert.True(t, result.Files[0].IsModeChanged())
+		assert.Equal(t, "100755", result.Files[0].NewMode)
+		assert.Equal(t, 0, result.Files[0].Addition)
+		assert.Equal(t, "README.md", result.Files[1].Name)
+		assert.True(t, result.Files[1].IsModeChanged())
+		assert.Equal(t, 1, result.Files[1].Addition)
+	}
 }
 
 func setupDefaultDiff() *Diff {
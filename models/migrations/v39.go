@@ -44,7 +44,9 @@ func releaseAddColumnIsTagAndSyncTags(x *xorm.Engine) error {
 				continue
 			}
 
-			if err = models.SyncReleasesWithTags(repo, gitRepo); err != nil {
+			err = models.SyncReleasesWithTags(repo, gitRepo)
+			gitRepo.Close()
+			if err != nil {
 				log.Warn("SyncReleasesWithTags: %v", err)
 			}
 		}
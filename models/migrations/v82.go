@@ -44,6 +44,11 @@ func fixReleaseSha1OnReleaseTable(x *xorm.Engine) error {
 		repoCache    = make(map[int64]*Repository)
 		userCache    = make(map[int64]*User)
 	)
+	defer func() {
+		for _, gitRepo := range gitRepoCache {
+			gitRepo.Close()
+		}
+	}()
 
 	if err = sess.Begin(); err != nil {
 		return err
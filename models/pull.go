@@ -8,9 +8,7 @@ package models
 import (
 	"bufio"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -152,7 +150,7 @@ func (pr *PullRequest) GetDefaultSquashMessage() string {
 
 // GetGitRefName returns git ref for hidden pull request branch
 func (pr *PullRequest) GetGitRefName() string {
-	return fmt.Sprintf("refs/pull/%d/head", pr.Index)
+	return git.PullRequestHeadRef(pr.Index)
 }
 
 // APIFormat assumes following fields have been assigned with valid values:
@@ -338,6 +336,7 @@ func (pr *PullRequest) GetLastCommitStatus() (status *CommitStatus, err error) {
 	if err != nil {
 		return nil, err
 	}
+	defer headGitRepo.Close()
 
 	repo := pr.HeadRepo
 	lastCommitID, err := headGitRepo.GetBranchCommitID(pr.HeadBranch)
@@ -499,15 +498,17 @@ func (pr *PullRequest) getMergeCommit() (*git.Commit, error) {
 		return nil, fmt.Errorf("git merge-base --is-ancestor: %v %v", stderr, err)
 	}
 
-	commitIDBytes, err := ioutil.ReadFile(pr.BaseRepo.RepoPath() + "/" + headFile)
+	gitRepo, err := git.OpenRepository(pr.BaseRepo.RepoPath())
 	if err != nil {
-		return nil, fmt.Errorf("ReadFile(%s): %v", headFile, err)
+		return nil, fmt.Errorf("OpenRepository: %v", err)
 	}
-	commitID := string(commitIDBytes)
-	if len(commitID) < 40 {
-		return nil, fmt.Errorf(`ReadFile(%s): invalid commit-ID "%s"`, headFile, commitID)
+	defer gitRepo.Close()
+
+	commitID, err := gitRepo.GetPullRequestHead(pr.Index)
+	if err != nil {
+		return nil, fmt.Errorf("GetPullRequestHead: %v", err)
 	}
-	cmd := commitID[:40] + ".." + pr.BaseBranch
+	cmd := commitID + ".." + pr.BaseBranch
 
 	// Get the commit from BaseBranch where the pull request got merged
 	mergeCommit, stderr, err := process.GetManager().ExecDirEnv(-1, "", fmt.Sprintf("isMerged (git rev-list --ancestry-path --merges --reverse): %d", pr.BaseRepo.ID),
@@ -517,12 +518,7 @@ func (pr *PullRequest) getMergeCommit() (*git.Commit, error) {
 		return nil, fmt.Errorf("git rev-list --ancestry-path --merges --reverse: %v %v", stderr, err)
 	} else if len(mergeCommit) < 40 {
 		// PR was fast-forwarded, so just use last commit of PR
-		mergeCommit = commitID[:40]
-	}
-
-	gitRepo, err := git.OpenRepository(pr.BaseRepo.RepoPath())
-	if err != nil {
-		return nil, fmt.Errorf("OpenRepository: %v", err)
+		mergeCommit = commitID
 	}
 
 	commit, err := gitRepo.GetCommit(mergeCommit[:40])
@@ -568,15 +564,18 @@ func (pr *PullRequest) testPatch(e Engine) (err error) {
 
 	pr.Status = PullRequestStatusChecking
 
-	indexTmpPath := filepath.Join(os.TempDir(), "gitea-"+pr.BaseRepo.Name+"-"+strconv.Itoa(time.Now().Nanosecond()))
-	defer os.Remove(indexTmpPath)
+	// The patch is applied to the index of a temporary repository sharing the objects of the base repository
+	tmpRepo, err := git.NewTemporaryRepository(LocalCopyPath(), pr.BaseRepo.RepoPath())
+	if err != nil {
+		return fmt.Errorf("NewTemporaryRepository: %v", err)
+	}
+	defer tmpRepo.Close()
 
 	var stderr string
-	_, stderr, err = process.GetManager().ExecDirEnv(-1, "", fmt.Sprintf("testPatch (git read-tree): %d", pr.BaseRepo.ID),
-		[]string{"GIT_DIR=" + pr.BaseRepo.RepoPath(), "GIT_INDEX_FILE=" + indexTmpPath},
+	_, stderr, err = process.GetManager().ExecDir(-1, tmpRepo.Path, fmt.Sprintf("testPatch (git read-tree): %d", pr.BaseRepo.ID),
 		git.GitExecutable, "read-tree", pr.BaseBranch)
 	if err != nil {
-		return fmt.Errorf("git read-tree --index-output=%s %s: %v - %s", indexTmpPath, pr.BaseBranch, err, stderr)
+		return fmt.Errorf("git read-tree %s: %v - %s", pr.BaseBranch, err, stderr)
 	}
 
 	prUnit, err := pr.BaseRepo.getUnit(e, UnitTypePullRequests)
@@ -592,8 +591,7 @@ func (pr *PullRequest) testPatch(e Engine) (err error) {
 	args = append(args, patchPath)
 	pr.ConflictedFiles = []string{}
 
-	_, stderr, err = process.GetManager().ExecDirEnv(-1, "", fmt.Sprintf("testPatch (git apply --check): %d", pr.BaseRepo.ID),
-		[]string{"GIT_INDEX_FILE=" + indexTmpPath, "GIT_DIR=" + pr.BaseRepo.RepoPath()},
+	_, stderr, err = process.GetManager().ExecDir(-1, tmpRepo.Path, fmt.Sprintf("testPatch (git apply --check): %d", pr.BaseRepo.ID),
 		git.GitExecutable, args...)
 	if err != nil {
 		for i := range patchConflicts {
@@ -917,6 +915,7 @@ func (pr *PullRequest) UpdatePatch() (err error) {
 	if err != nil {
 		return fmt.Errorf("OpenRepository: %v", err)
 	}
+	defer headGitRepo.Close()
 
 	// Add a temporary remote.
 	tmpRemote := com.ToStr(time.Now().UnixNano())
@@ -958,6 +957,7 @@ func (pr *PullRequest) PushToBaseRepo() (err error) {
 	if err != nil {
 		return fmt.Errorf("OpenRepository: %v", err)
 	}
+	defer headGitRepo.Close()
 
 	tmpRemoteName := fmt.Sprintf("tmp-pull-%d", pr.ID)
 	if err = headGitRepo.AddRemote(tmpRemoteName, pr.BaseRepo.RepoPath(), false); err != nil {
@@ -970,12 +970,17 @@ func (pr *PullRequest) PushToBaseRepo() (err error) {
 		}
 	}()
 
-	headFile := pr.GetGitRefName()
-
+	baseGitRepo, err := git.OpenRepository(pr.BaseRepo.RepoPath())
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %v", err)
+	}
+	defer baseGitRepo.Close()
 	// Remove head in case there is a conflict.
-	file := path.Join(pr.BaseRepo.RepoPath(), headFile)
+	if err = baseGitRepo.UpdatePullRequestHead(pr.Index, git.EmptySHA, ""); err != nil {
+		return fmt.Errorf("UpdatePullRequestHead: %v", err)
+	}
 
-	_ = os.Remove(file)
+	headFile := pr.GetGitRefName()
 
 	if err = git.Push(headRepoPath, git.PushOptions{
 		Remote: tmpRemoteName,
@@ -1143,6 +1148,7 @@ func checkForInvalidation(requests PullRequestList, repoID int64, doer *User, br
 		return fmt.Errorf("git.OpenRepository: %v", err)
 	}
 	go func() {
+		defer gitRepo.Close()
 		err := requests.InvalidateCodeComments(doer, gitRepo, branch)
 		if err != nil {
 			log.Error("PullRequestList.InvalidateCodeComments: %v", err)
@@ -11,7 +11,6 @@ import (
 
 	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/log"
-	"code.gitea.io/gitea/modules/process"
 	"code.gitea.io/gitea/modules/setting"
 	api "code.gitea.io/gitea/modules/structs"
 	"code.gitea.io/gitea/modules/timeutil"
@@ -432,11 +431,13 @@ func DeleteReleaseByID(id int64, doer *User, delTag bool) error {
 	}
 
 	if delTag {
-		_, stderr, err := process.GetManager().ExecDir(-1, repo.RepoPath(),
-			fmt.Sprintf("DeleteReleaseByID (git tag -d): %d", rel.ID),
-			git.GitExecutable, "tag", "-d", rel.TagName)
-		if err != nil && !strings.Contains(stderr, "not found") {
-			return fmt.Errorf("git tag -d: %v - %s", err, stderr)
+		gitRepo, err := git.OpenRepository(repo.RepoPath())
+		if err != nil {
+			return fmt.Errorf("OpenRepository: %v", err)
+		}
+		defer gitRepo.Close()
+		if _, err = gitRepo.DeleteTag(rel.TagName); err != nil && !git.IsErrTagNotExist(err) {
+			return fmt.Errorf("DeleteTag: %v", err)
 		}
 
 		if _, err = x.ID(rel.ID).Delete(new(Release)); err != nil {
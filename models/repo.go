@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"code.gitea.io/gitea/modules/avatar"
+	"code.gitea.io/gitea/modules/cache"
 	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/markup"
@@ -660,17 +661,23 @@ func (repo *Repository) IsOwnedBy(userID int64) bool {
 }
 
 func (repo *Repository) updateSize(e Engine) error {
-	repoInfoSize, err := git.GetRepoSize(repo.repoPath(e))
+	gitRepo, err := git.OpenRepository(repo.repoPath(e))
 	if err != nil {
 		return fmt.Errorf("UpdateSize: %v", err)
 	}
+	defer gitRepo.Close()
 
-	repo.Size = repoInfoSize.Size + repoInfoSize.SizePack
+	size, err := gitRepo.SizeWithoutLFS()
+	if err != nil {
+		return fmt.Errorf("UpdateSize: %v", err)
+	}
+
+	repo.Size = size
 	_, err = e.ID(repo.ID).Cols("size").Update(repo)
 	return err
 }
 
-// UpdateSize updates the repository size, calculating it using git.GetRepoSize
+// UpdateSize updates the repository size, calculating it using git.Repository.SizeWithoutLFS
 func (repo *Repository) UpdateSize() error {
 	return repo.updateSize(x)
 }
@@ -941,6 +948,7 @@ func MigrateRepository(doer, u *User, opts MigrateRepoOptions) (*Repository, err
 	if err != nil {
 		return repo, fmt.Errorf("OpenRepository: %v", err)
 	}
+	defer gitRepo.Close()
 
 	repo.IsEmpty, err = gitRepo.IsEmpty()
 	if err != nil {
@@ -1545,9 +1553,11 @@ func TransferOwnership(doer *User, newOwnerName string, repo *Repository) error
 		return fmt.Errorf("Failed to create dir %s: %v", dir, err)
 	}
 
+	git.Repositories.Invalidate(RepoPath(owner.Name, repo.Name))
 	if err = os.Rename(RepoPath(owner.Name, repo.Name), RepoPath(newOwner.Name, repo.Name)); err != nil {
 		return fmt.Errorf("rename repository directory: %v", err)
 	}
+	removeLastCommits(RepoPath(owner.Name, repo.Name))
 
 	// Rename remote wiki repository to new path and delete local copy.
 	wikiPath := WikiPath(owner.Name, repo.Name)
@@ -1565,6 +1575,14 @@ func TransferOwnership(doer *User, newOwnerName string, repo *Repository) error
 	return sess.Commit()
 }
 
+// removeLastCommits drops the cached last commits of the repositories at path, which has been
+// deleted or moved, so a new repository created at the same path does not get them
+func removeLastCommits(path string) {
+	if err := cache.RemoveLastCommits(path); err != nil {
+		log.Error("RemoveLastCommits [%s]: %v", path, err)
+	}
+}
+
 // ChangeRepositoryName changes all corresponding setting from old repository name to new one.
 func ChangeRepositoryName(u *User, oldRepoName, newRepoName string) (err error) {
 	oldRepoName = strings.ToLower(oldRepoName)
@@ -1592,9 +1610,11 @@ func ChangeRepositoryName(u *User, oldRepoName, newRepoName string) (err error)
 	defer repoWorkingPool.CheckOut(com.ToStr(repo.ID))
 
 	newRepoPath := RepoPath(u.Name, newRepoName)
+	git.Repositories.Invalidate(repo.RepoPath())
 	if err = os.Rename(repo.RepoPath(), newRepoPath); err != nil {
 		return fmt.Errorf("rename repository directory: %v", err)
 	}
+	removeLastCommits(repo.RepoPath())
 
 	wikiPath := repo.WikiPath()
 	if com.IsExist(wikiPath) {
@@ -1869,7 +1889,9 @@ func DeleteRepository(doer *User, uid, repoID int64) error {
 
 	// FIXME: Remove repository files should be executed after transaction succeed.
 	repoPath := repo.repoPath(sess)
+	git.Repositories.Invalidate(repoPath)
 	removeAllWithNotice(sess, "Delete repository files", repoPath)
+	removeLastCommits(repoPath)
 
 	err = repo.deleteWiki(sess)
 	if err != nil {
@@ -2207,10 +2229,24 @@ func GitFsck() {
 				repo := bean.(*Repository)
 				repoPath := repo.RepoPath()
 				log.Trace("Running health check on repository %s", repoPath)
-				if err := git.Fsck(repoPath, setting.Cron.RepoHealthCheck.Timeout, setting.Cron.RepoHealthCheck.Args...); err != nil {
-					desc := fmt.Sprintf("Failed to health check repository (%s): %v", repoPath, err)
+				var desc string
+				if gitRepo, err := git.OpenRepository(repoPath); err != nil {
+					desc = fmt.Sprintf("Failed to health check repository (%s): %v", repoPath, err)
+				} else {
+					report, err := gitRepo.Fsck(git.FsckOptions{
+						Args:    setting.Cron.RepoHealthCheck.Args,
+						Timeout: setting.Cron.RepoHealthCheck.Timeout,
+					})
+					gitRepo.Close()
+					if err != nil {
+						desc = fmt.Sprintf("Failed to health check repository (%s): %v", repoPath, err)
+					} else if !report.Healthy() {
+						desc = fmt.Sprintf("Repository (%s) is damaged: %s", repoPath, fsckProblems(report))
+					}
+				}
+				if desc != "" {
 					log.Warn(desc)
-					if err = CreateRepositoryNotice(desc); err != nil {
+					if err := CreateRepositoryNotice(desc); err != nil {
 						log.Error("CreateRepositoryNotice: %v", err)
 					}
 				}
@@ -2221,9 +2257,24 @@ func GitFsck() {
 	log.Trace("Finished: GitFsck")
 }
 
+// fsckProblems describes the missing and corrupt objects and the errors of the report
+func fsckProblems(report *git.FsckReport) string {
+	var problems []string
+	for _, finding := range report.Findings {
+		if finding.Problem == git.FsckMissing || finding.Problem == git.FsckCorrupt {
+			problem := string(finding.Problem)
+			if finding.Type != "" {
+				problem += " " + string(finding.Type)
+			}
+			problems = append(problems, problem+" "+finding.ID.String())
+		}
+	}
+	problems = append(problems, report.Errors...)
+	return strings.Join(problems, ", ")
+}
+
 // GitGcRepos calls 'git gc' to remove unnecessary files and optimize the local repository
 func GitGcRepos() error {
-	args := append([]string{"gc"}, setting.Git.GCArgs...)
 	return x.
 		Where("id > 0").BufferSize(setting.Database.IterateBufferSize).
 		Iterate(new(Repository),
@@ -2232,12 +2283,34 @@ func GitGcRepos() error {
 				if err := repo.GetOwner(); err != nil {
 					return err
 				}
-				_, stderr, err := process.GetManager().ExecDir(
-					time.Duration(setting.Git.Timeout.GC)*time.Second,
-					RepoPath(repo.Owner.Name, repo.Name), "Repository garbage collection",
-					git.GitExecutable, args...)
+				gitRepo, err := git.OpenRepository(RepoPath(repo.Owner.Name, repo.Name))
 				if err != nil {
-					return fmt.Errorf("%v: %v", err, stderr)
+					return err
+				}
+				defer gitRepo.Close()
+
+				result, err := gitRepo.GC(git.GCOptions{
+					Args:    setting.Git.GCArgs,
+					Timeout: time.Duration(setting.Git.Timeout.GC) * time.Second,
+				})
+				if err != nil {
+					return fmt.Errorf("GC: %v", err)
+				}
+				log.Trace("Repository garbage collection of %s freed %d bytes in %v", repo.FullName(), result.Freed, result.Duration)
+				// gc replaces the packfiles
+				git.Repositories.Invalidate(RepoPath(repo.Owner.Name, repo.Name))
+
+				// gc only writes bitmaps if repack.writeBitmaps is enabled
+				if !gitRepo.HasBitmapIndex() {
+					if err := gitRepo.WriteBitmapIndex(time.Duration(setting.Git.Timeout.GC) * time.Second); err != nil {
+						return fmt.Errorf("WriteBitmapIndex: %v", err)
+					}
+					git.Repositories.Invalidate(RepoPath(repo.Owner.Name, repo.Name))
+				}
+
+				// gc.writeCommitGraph does not write the changed-path Bloom filters
+				if err := gitRepo.WriteCommitGraph(); err != nil {
+					return fmt.Errorf("WriteCommitGraph: %v", err)
 				}
 				return nil
 			})
@@ -5,7 +5,6 @@
 package models
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -143,9 +142,15 @@ func updateRepoIndexer(repo *Repository) error {
 		return nil
 	}
 
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
+	if err != nil {
+		return err
+	}
+	defer gitRepo.Close()
+
 	batch := indexer.RepoIndexerBatch()
 	for _, update := range changes.Updates {
-		if err := addUpdate(update, repo, batch); err != nil {
+		if err := addUpdate(gitRepo, update, repo, batch); err != nil {
 			return err
 		}
 	}
@@ -191,20 +196,15 @@ func getRepoChanges(repo *Repository, revision string) (*repoChanges, error) {
 	return nonGenesisChanges(repo, revision)
 }
 
-func addUpdate(update fileUpdate, repo *Repository, batch rupture.FlushingBatch) error {
-	stdout, err := git.NewCommand("cat-file", "-s", update.BlobSha).
-		RunInDir(repo.RepoPath())
+func addUpdate(gitRepo *git.Repository, update fileUpdate, repo *Repository, batch rupture.FlushingBatch) error {
+	info, err := gitRepo.GetObjectInfo(update.BlobSha)
 	if err != nil {
 		return err
-	}
-	if size, err := strconv.Atoi(strings.TrimSpace(stdout)); err != nil {
-		return fmt.Errorf("Misformatted git cat-file output: %v", err)
-	} else if int64(size) > setting.Indexer.MaxIndexerFileSize {
+	} else if info.Size > setting.Indexer.MaxIndexerFileSize {
 		return addDelete(update.Filename, repo, batch)
 	}
 
-	fileContents, err := git.NewCommand("cat-file", "blob", update.BlobSha).
-		RunInDirBytes(repo.RepoPath())
+	_, fileContents, err := gitRepo.ReadObject(update.BlobSha)
 	if err != nil {
 		return err
 	} else if !base.IsTextFile(fileContents) {
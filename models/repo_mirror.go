@@ -21,7 +21,6 @@ import (
 	"code.gitea.io/gitea/modules/util"
 
 	"github.com/go-xorm/xorm"
-	"github.com/mcuadros/go-version"
 	"github.com/unknwon/com"
 )
 
@@ -74,11 +73,7 @@ func (m *Mirror) ScheduleNextUpdate() {
 
 func remoteAddress(repoPath string) (string, error) {
 	var cmd *git.Command
-	binVersion, err := git.BinVersion()
-	if err != nil {
-		return "", err
-	}
-	if version.Compare(binVersion, "2.7", ">=") {
+	if git.GetCapabilities().SupportsRemoteGetURL {
 		cmd = git.NewCommand("remote", "get-url", "origin")
 	} else {
 		cmd = git.NewCommand("config", "--get", "remote.origin.url")
@@ -270,12 +265,15 @@ func (m *Mirror) runSync() ([]*mirrorSyncResult, bool) {
 		return nil, false
 	}
 	output := stderr
+	// the fetched objects are in new packfiles
+	git.Repositories.Invalidate(repoPath)
 
 	gitRepo, err := git.OpenRepository(repoPath)
 	if err != nil {
 		log.Error("OpenRepository: %v", err)
 		return nil, false
 	}
+	defer gitRepo.Close()
 	if err = SyncReleasesWithTags(m.Repo, gitRepo); err != nil {
 		log.Error("Failed to synchronize tags to releases for repository: %v", err)
 	}
@@ -457,6 +455,9 @@ func SyncMirrors() {
 				continue
 			}
 		}
+		if gitRepo != nil {
+			gitRepo.Close()
+		}
 
 		// Get latest commit date and update to current repository updated time
 		commitDate, err := git.GetLatestCommitTime(m.Repo.RepoPath())
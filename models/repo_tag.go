@@ -14,8 +14,10 @@ func GetTagsByPath(path string) ([]*git.Tag, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer gitRepo.Close()
 
-	return gitRepo.GetTagInfos()
+	tags, _, err := gitRepo.GetTagInfos(0, 0)
+	return tags, err
 }
 
 // GetTags return repo's tags
@@ -964,6 +964,7 @@ func ChangeUserName(u *User, newUserName string) (err error) {
 	if err = os.Rename(UserPath(u.Name), UserPath(newUserName)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("Rename user directory: %v", err)
 	}
+	removeLastCommits(UserPath(u.Name))
 
 	return nil
 }
@@ -399,7 +399,7 @@ func IsAudioFile(data []byte) bool {
 func EntryIcon(entry *git.TreeEntry) string {
 	switch {
 	case entry.IsLink():
-		te, err := entry.FollowLink()
+		te, err := entry.FollowLinks()
 		if err != nil {
 			log.Debug(err.Error())
 			return "file-symlink-file"
@@ -27,7 +27,10 @@ func NewContext() error {
 		AdapterConfig: setting.CacheService.Conn,
 		Interval:      setting.CacheService.Interval,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return openLastCommitDB()
 }
 
 // GetInt returns key value from cache with callback when no key exists in cache
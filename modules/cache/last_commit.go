@@ -0,0 +1,104 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"crypto/sha1"
+	"fmt"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+)
+
+// lastCommitCache stores the IDs of the last commits of tree entries in the cache service,
+// so instances sharing a redis or memcache cache share the results too.
+type lastCommitCache struct {
+	repo *git.Repository
+	ttl  int64
+}
+
+// NewLastCommitCache returns a git.LastCommitCache for the repository, backed by the cache service
+// or the database on disk depending on the configuration. It returns nil if the cache is disabled.
+func NewLastCommitCache(repo *git.Repository) git.LastCommitCache {
+	if setting.CacheService == nil {
+		return nil
+	}
+	cfg := setting.CacheService.LastCommit
+	if !cfg.Enabled || cfg.TTL == 0 {
+		return nil
+	}
+
+	if cfg.Adapter == "disk" {
+		if lastCommitDB == nil {
+			return nil
+		}
+		return &diskLastCommitCache{
+			repo:     repo,
+			db:       lastCommitDB,
+			ttl:      cfg.TTL,
+			maxItems: cfg.MaxItems,
+		}
+	}
+	if conn == nil {
+		return nil
+	}
+	return &lastCommitCache{
+		repo: repo,
+		ttl:  int64(cfg.TTL.Seconds()),
+	}
+}
+
+// lastCommitCacheKey returns the key of an entry. Paths are hashed as memcache keys
+// may neither contain spaces nor be longer than 250 bytes, the hash of the repository
+// path keeps the keys of different repositories apart.
+func lastCommitCacheKey(repoPath, ref, entryPath string) string {
+	return fmt.Sprintf("last_commit:%x:%s:%x", sha1.Sum([]byte(repoPath)), ref, sha1.Sum([]byte(entryPath)))
+}
+
+// Get returns the cached last commit of the entry, or nil if it is not cached
+func (c *lastCommitCache) Get(repoPath, ref, entryPath string) (*git.Commit, error) {
+	var commitID string
+	switch v := conn.Get(lastCommitCacheKey(repoPath, ref, entryPath)).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		commitID = v
+	case []byte:
+		commitID = string(v)
+	default:
+		return nil, fmt.Errorf("Unsupported cached value type: %v", v)
+	}
+	return c.repo.GetCommit(commitID)
+}
+
+// Put stores the ID of the last commit of the entry
+func (c *lastCommitCache) Put(repoPath, ref, entryPath string, commit *git.Commit) error {
+	return conn.Put(lastCommitCacheKey(repoPath, ref, entryPath), commit.ID.String(), c.ttl)
+}
+
+// bloomFilterCacheKey returns the key of the Bloom filter of a commit. Commits with the same ID
+// have the same changes, so the key does not depend on the repository.
+func bloomFilterCacheKey(commitID string) string {
+	return "bloom_filter:" + commitID
+}
+
+// GetBloomFilter returns the stored changed-path Bloom filter of the commit, or nil if there is none
+func (c *lastCommitCache) GetBloomFilter(commitID string) ([]byte, error) {
+	switch v := conn.Get(bloomFilterCacheKey(commitID)).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("Unsupported cached value type: %v", v)
+	}
+}
+
+// PutBloomFilter stores the changed-path Bloom filter of the commit
+func (c *lastCommitCache) PutBloomFilter(commitID string, filter []byte) error {
+	return conn.Put(bloomFilterCacheKey(commitID), string(filter), c.ttl)
+}
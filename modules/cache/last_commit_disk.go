@@ -0,0 +1,267 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+var (
+	lastCommitDB *bolt.DB
+
+	lastCommitBucket     = []byte("last_commit")
+	lastCommitMetaBucket = []byte("meta")
+	lastCommitCountKey   = []byte("count")
+
+	bloomFilterBucket   = []byte("bloom_filter")
+	bloomFilterCountKey = []byte("bloom_filter_count")
+)
+
+// openLastCommitDB opens the database of the last commit cache if it is stored on disk
+func openLastCommitDB() error {
+	cfg := setting.CacheService.LastCommit
+	if lastCommitDB != nil || !cfg.Enabled || cfg.Adapter != "disk" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), os.ModePerm); err != nil {
+		return err
+	}
+	db, err := bolt.Open(cfg.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(lastCommitBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(bloomFilterBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(lastCommitMetaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	lastCommitDB = db
+	return nil
+}
+
+// diskLastCommitCache stores the IDs of the last commits of tree entries in a database on disk,
+// so they survive restarts. Once there are more than MaxItems entries the oldest ones are evicted.
+// The changed-path Bloom filters of commits are stored and evicted alike.
+type diskLastCommitCache struct {
+	repo     *git.Repository
+	db       *bolt.DB
+	ttl      time.Duration
+	maxItems int
+}
+
+// Every value is the binary commit ID followed by the unix time it was stored at
+const lastCommitValueLen = 20 + 8
+
+// storedAt returns the unix time a value has been stored at, which is at its end
+func storedAt(value []byte) int64 {
+	if len(value) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value[len(value)-8:]))
+}
+
+func diskLastCommitKey(repoPath, ref, entryPath string) []byte {
+	return []byte(repoPath + "\x00" + ref + "\x00" + entryPath)
+}
+
+// RemoveLastCommits removes the last commits stored on disk for the repository at path, or for all
+// the repositories in the directory at path, e.g. that of a user. It is called when repositories are
+// deleted or moved, so a new repository at the same path does not get their entries. Entries stored
+// in the cache service expire with their TTL.
+func RemoveLastCommits(path string) error {
+	if lastCommitDB == nil {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	prefixes := [][]byte{[]byte(path + "\x00"), []byte(path + string(filepath.Separator))}
+	return lastCommitDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(lastCommitBucket)
+		var removed uint64
+		for _, prefix := range prefixes {
+			cursor := bucket.Cursor()
+			for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); {
+				// k points into the page the item is deleted from
+				deleted := append([]byte(nil), k...)
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				removed++
+				k, _ = cursor.Seek(deleted)
+			}
+		}
+		if removed == 0 {
+			return nil
+		}
+
+		meta := tx.Bucket(lastCommitMetaBucket)
+		var count uint64
+		if v := meta.Get(lastCommitCountKey); len(v) == 8 {
+			count = binary.BigEndian.Uint64(v)
+		}
+		if count < removed {
+			count = removed
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, count-removed)
+		return meta.Put(lastCommitCountKey, v)
+	})
+}
+
+// Get returns the cached last commit of the entry, or nil if it is not cached or has expired
+func (c *diskLastCommitCache) Get(repoPath, ref, entryPath string) (*git.Commit, error) {
+	var value []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		// the value is only valid during the transaction
+		value = append(value, tx.Bucket(lastCommitBucket).Get(diskLastCommitKey(repoPath, ref, entryPath))...)
+		return nil
+	})
+	if err != nil || len(value) != lastCommitValueLen {
+		return nil, err
+	}
+
+	stored := time.Unix(storedAt(value), 0)
+	if c.ttl > 0 && time.Since(stored) > c.ttl {
+		return nil, nil
+	}
+	var id git.SHA1
+	copy(id[:], value[:20])
+	return c.repo.GetCommit(id.String())
+}
+
+// Put stores the ID of the last commit of the entry
+func (c *diskLastCommitCache) Put(repoPath, ref, entryPath string, commit *git.Commit) error {
+	value := make([]byte, lastCommitValueLen)
+	copy(value, commit.ID[:])
+	binary.BigEndian.PutUint64(value[20:], uint64(time.Now().Unix()))
+
+	return c.put(lastCommitBucket, lastCommitCountKey, diskLastCommitKey(repoPath, ref, entryPath), value)
+}
+
+// GetBloomFilter returns the stored changed-path Bloom filter of the commit, or nil if there is none
+// or it has expired
+func (c *diskLastCommitCache) GetBloomFilter(commitID string) ([]byte, error) {
+	var value []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value = append(value, tx.Bucket(bloomFilterBucket).Get([]byte(commitID))...)
+		return nil
+	})
+	if err != nil || len(value) <= 8 {
+		return nil, err
+	}
+
+	stored := time.Unix(storedAt(value), 0)
+	if c.ttl > 0 && time.Since(stored) > c.ttl {
+		return nil, nil
+	}
+	return value[:len(value)-8], nil
+}
+
+// PutBloomFilter stores the changed-path Bloom filter of the commit
+func (c *diskLastCommitCache) PutBloomFilter(commitID string, filter []byte) error {
+	value := make([]byte, len(filter)+8)
+	copy(value, filter)
+	binary.BigEndian.PutUint64(value[len(filter):], uint64(time.Now().Unix()))
+
+	return c.put(bloomFilterBucket, bloomFilterCountKey, []byte(commitID), value)
+}
+
+// put stores the value in the bucket, whose number of entries is kept in the meta bucket at countKey
+func (c *diskLastCommitCache) put(bucketName, countKey, key, value []byte) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		isNew := bucket.Get(key) == nil
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+		if !isNew {
+			return nil
+		}
+
+		meta := tx.Bucket(lastCommitMetaBucket)
+		var count uint64
+		if v := meta.Get(countKey); len(v) == 8 {
+			count = binary.BigEndian.Uint64(v)
+		}
+		count++
+		if c.maxItems > 0 && count > uint64(c.maxItems) {
+			var err error
+			if count, err = evictOldest(bucket, c.maxItems-c.maxItems/10); err != nil {
+				return err
+			}
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, count)
+		return meta.Put(countKey, v)
+	})
+}
+
+// evictOldest deletes the oldest entries of the bucket until at most keep entries are left,
+// it returns the number of remaining entries.
+func evictOldest(bucket *bolt.Bucket, keep int) (uint64, error) {
+	var times []int64
+	err := bucket.ForEach(func(k, v []byte) error {
+		times = append(times, storedAt(v))
+		return nil
+	})
+	if err != nil || len(times) <= keep {
+		return uint64(len(times)), err
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	evict := len(times) - keep
+	cutoff := times[evict-1]
+	// all entries older than the cutoff are evicted, those stored at the same second
+	// only as long as needed
+	atCutoff := evict
+	for _, t := range times[:evict] {
+		if t < cutoff {
+			atCutoff--
+		}
+	}
+
+	remaining := uint64(len(times))
+	cursor := bucket.Cursor()
+	for k, v := cursor.First(); k != nil; {
+		stored := storedAt(v)
+		if stored < cutoff || (stored == cutoff && atCutoff > 0) {
+			if stored == cutoff {
+				atCutoff--
+			}
+			// k points into the page the item is deleted from
+			deleted := append([]byte(nil), k...)
+			if err := cursor.Delete(); err != nil {
+				return 0, err
+			}
+			remaining--
+			k, v = cursor.Seek(deleted)
+			continue
+		}
+		k, v = cursor.Next()
+	}
+	return remaining, nil
+}
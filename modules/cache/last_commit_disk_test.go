@@ -0,0 +1,83 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+
+	bolt "github.com/etcd-io/bbolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveLastCommits(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "last-commit-db")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	setting.CacheService = &setting.Cache{}
+	setting.CacheService.LastCommit.Enabled = true
+	setting.CacheService.LastCommit.TTL = time.Hour
+	setting.CacheService.LastCommit.Adapter = "disk"
+	setting.CacheService.LastCommit.Path = filepath.Join(tmpDir, "last_commit.db")
+	require.NoError(t, openLastCommitDB())
+	defer func() {
+		lastCommitDB.Close()
+		lastCommitDB = nil
+	}()
+
+	c := &diskLastCommitCache{db: lastCommitDB, ttl: time.Hour}
+	commit := &git.Commit{ID: git.MustIDFromString("65f1bf27bc3bf70f64657658635e66094edbcb4d")}
+	repos := map[string]string{
+		"repo":       filepath.Join(tmpDir, "user1", "repo.git"),
+		"other repo": filepath.Join(tmpDir, "user1", "repo.git.git"),
+		"other user": filepath.Join(tmpDir, "user10", "repo.git"),
+	}
+	for _, repoPath := range repos {
+		require.NoError(t, c.Put(repoPath, "master", "README.md", commit))
+		require.NoError(t, c.Put(repoPath, "master", "docs", commit))
+	}
+	isCached := func(repoPath string) bool {
+		var cached bool
+		require.NoError(t, lastCommitDB.View(func(tx *bolt.Tx) error {
+			cached = tx.Bucket(lastCommitBucket).Get(diskLastCommitKey(repoPath, "master", "README.md")) != nil
+			return nil
+		}))
+		return cached
+	}
+	count := func() uint64 {
+		var count uint64
+		require.NoError(t, lastCommitDB.View(func(tx *bolt.Tx) error {
+			count = binary.BigEndian.Uint64(tx.Bucket(lastCommitMetaBucket).Get(lastCommitCountKey))
+			return nil
+		}))
+		return count
+	}
+	assert.EqualValues(t, 6, count())
+
+	// the entries of the repository only
+	assert.NoError(t, RemoveLastCommits(repos["repo"]))
+	assert.False(t, isCached(repos["repo"]))
+	assert.True(t, isCached(repos["other repo"]))
+	assert.True(t, isCached(repos["other user"]))
+	assert.EqualValues(t, 4, count())
+
+	// the entries of all repositories of the user
+	assert.NoError(t, RemoveLastCommits(filepath.Join(tmpDir, "user1")))
+	assert.False(t, isCached(repos["other repo"]))
+	assert.True(t, isCached(repos["other user"]))
+	assert.EqualValues(t, 2, count())
+
+	assert.NoError(t, RemoveLastCommits(filepath.Join(tmpDir, "user2")))
+	assert.EqualValues(t, 2, count())
+}
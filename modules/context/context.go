@@ -17,6 +17,7 @@ import (
 	"code.gitea.io/gitea/models"
 	"code.gitea.io/gitea/modules/auth"
 	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/util"
@@ -328,5 +329,13 @@ func Contexter() macaron.Handler {
 		ctx.Data["EnableOpenIDSignIn"] = setting.Service.EnableOpenIDSignIn
 
 		c.Map(ctx)
+
+		// the repository opened by RepoAssignment or RepoRef is only used while handling the request
+		defer func() {
+			if ctx.Repo.GitRepo != nil {
+				git.Repositories.Release(ctx.Repo.GitRepo)
+			}
+		}()
+		c.Next()
 	}
 }
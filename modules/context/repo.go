@@ -146,18 +146,16 @@ func (r *Repository) FileExists(path string, branch string) (bool, error) {
 // GetEditorconfig returns the .editorconfig definition if found in the
 // HEAD of the default repo branch.
 func (r *Repository) GetEditorconfig() (*editorconfig.Editorconfig, error) {
-	commit, err := r.GitRepo.GetBranchCommit(r.Repository.DefaultBranch)
-	if err != nil {
-		return nil, err
-	}
-	treeEntry, err := commit.GetTreeEntryByPath(".editorconfig")
-	if err != nil {
+	blob, err := r.GitRepo.GetBlobByRefPath(git.BranchPrefix+r.Repository.DefaultBranch, ".editorconfig")
+	if git.IsErrRefNotExist(err) || git.IsErrNotBlob(err) {
+		return nil, git.ErrNotExist{ID: "", RelPath: ".editorconfig"}
+	} else if err != nil {
 		return nil, err
 	}
-	if treeEntry.Blob().Size() >= setting.UI.MaxDisplayFileSize {
+	if blob.Size() >= setting.UI.MaxDisplayFileSize {
 		return nil, git.ErrNotExist{ID: "", RelPath: ".editorconfig"}
 	}
-	reader, err := treeEntry.Blob().DataAsync()
+	reader, err := blob.DataAsync()
 	if err != nil {
 		return nil, err
 	}
@@ -354,7 +352,7 @@ func RepoAssignment() macaron.Handler {
 			return
 		}
 
-		gitRepo, err := git.OpenRepository(models.RepoPath(userName, repoName))
+		gitRepo, err := git.Repositories.Open(models.RepoPath(userName, repoName))
 		if err != nil {
 			ctx.ServerError("RepoAssignment Invalid repo "+models.RepoPath(userName, repoName), err)
 			return
@@ -572,7 +570,7 @@ func RepoRefByType(refType RepoRefType) macaron.Handler {
 		// For API calls.
 		if ctx.Repo.GitRepo == nil {
 			repoPath := models.RepoPath(ctx.Repo.Owner.Name, ctx.Repo.Repository.Name)
-			ctx.Repo.GitRepo, err = git.OpenRepository(repoPath)
+			ctx.Repo.GitRepo, err = git.Repositories.Open(repoPath)
 			if err != nil {
 				ctx.ServerError("RepoRef Invalid repo "+repoPath, err)
 				return
@@ -6,13 +6,21 @@
 package git
 
 import (
+	"bytes"
 	"encoding/base64"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"unicode/utf8"
 
+	"github.com/gogits/chardet"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
+// BlobSniffLen is the number of bytes read from the start of a blob to guess its content type,
+// encoding and whether it is binary. It matches the heuristic used by git itself.
+var BlobSniffLen = 8000
+
 // Blob represents a Git object.
 type Blob struct {
 	ID SHA1
@@ -27,6 +35,51 @@ func (b *Blob) DataAsync() (io.ReadCloser, error) {
 	return b.gogitEncodedObj.Reader()
 }
 
+// DataReader gets a ReadCloser for the contents of a blob like DataAsync, but refuses
+// to read blobs larger than maxSize bytes with ErrBlobTooLarge. A maxSize <= 0 disables the limit.
+func (b *Blob) DataReader(maxSize int64) (io.ReadCloser, error) {
+	if maxSize > 0 && b.Size() > maxSize {
+		return nil, ErrBlobTooLarge{b.ID.String(), b.Size(), maxSize}
+	}
+
+	rc, err := b.DataAsync()
+	if err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		return rc, nil
+	}
+	return &limitedReadCloser{rc: rc, id: b.ID.String(), maxSize: maxSize, remaining: maxSize}, nil
+}
+
+// limitedReadCloser fails with ErrBlobTooLarge once more than maxSize bytes are read
+type limitedReadCloser struct {
+	rc        io.ReadCloser
+	id        string
+	maxSize   int64
+	remaining int64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrBlobTooLarge{l.id, l.maxSize - l.remaining, l.maxSize}
+	}
+	// Allow reading one byte past the limit so an oversized stream can be detected
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrBlobTooLarge{l.id, l.maxSize - l.remaining, l.maxSize}
+	}
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
+
 // Size returns the uncompressed size of the blob
 func (b *Blob) Size() int64 {
 	return b.gogitEncodedObj.Size()
@@ -78,3 +131,99 @@ func (b *Blob) GetBlobContentBase64() (string, error) {
 	}
 	return string(out), nil
 }
+
+// sniff reads up to BlobSniffLen bytes from the start of the blob
+func (b *Blob) sniff() ([]byte, error) {
+	dataRc, err := b.DataAsync()
+	if err != nil {
+		return nil, err
+	}
+	defer dataRc.Close()
+
+	buf := make([]byte, BlobSniffLen)
+	n, err := io.ReadFull(dataRc, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// GuessContentType guesses the MIME type of the blob from its first bytes
+func (b *Blob) GuessContentType() (string, error) {
+	buf, err := b.sniff()
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf), nil
+}
+
+// IsBinary reports whether the blob contains binary data. Like git, a blob is
+// considered binary if a NUL byte appears in its first BlobSniffLen bytes,
+// UTF-16 text recognized by its byte order mark is not treated as binary.
+func (b *Blob) IsBinary() (bool, error) {
+	buf, err := b.sniff()
+	if err != nil {
+		return false, err
+	}
+	return isBinary(buf), nil
+}
+
+// DetectEncoding detects the character encoding of the blob. It returns an empty
+// string for binary content.
+func (b *Blob) DetectEncoding() (string, error) {
+	buf, err := b.sniff()
+	if err != nil {
+		return "", err
+	}
+	if isBinary(buf) {
+		return "", nil
+	}
+	return detectEncoding(buf, len(buf) == BlobSniffLen)
+}
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+func isBinary(buf []byte) bool {
+	if bytes.HasPrefix(buf, utf16LEBOM) || bytes.HasPrefix(buf, utf16BEBOM) {
+		return false
+	}
+	return bytes.IndexByte(buf, 0) != -1
+}
+
+// detectEncoding detects the encoding of buf, truncated is set when buf is only
+// the beginning of the content and may end in the middle of a character.
+func detectEncoding(buf []byte, truncated bool) (string, error) {
+	switch {
+	case bytes.HasPrefix(buf, utf8BOM):
+		return "UTF-8", nil
+	case bytes.HasPrefix(buf, utf16LEBOM):
+		return "UTF-16LE", nil
+	case bytes.HasPrefix(buf, utf16BEBOM):
+		return "UTF-16BE", nil
+	}
+
+	if truncated {
+		// Drop an incomplete multi-byte sequence at the end of the sample
+		for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(buf[i]) {
+				if !utf8.FullRune(buf[i:]) {
+					buf = buf[:i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(buf) {
+		return "UTF-8", nil
+	}
+
+	result, err := chardet.NewTextDetector().DetectBest(buf)
+	if err != nil {
+		return "", err
+	}
+	return result.Charset, nil
+}
@@ -7,6 +7,8 @@ package git
 
 import (
 	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,6 +52,91 @@ THE SOFTWARE.
 	assert.Equal(t, output, string(data))
 }
 
+func TestBlob_DataReader(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	repo, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+	testBlob, err := repo.GetBlob("e2129701f1a4d54dc44f03c93bca0a2aec7c5449")
+	assert.NoError(t, err)
+
+	r, err := testBlob.DataReader(6)
+	assert.NoError(t, err)
+	require.NotNil(t, r)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "file1\n", string(data))
+	assert.NoError(t, r.Close())
+
+	r, err = testBlob.DataReader(0)
+	assert.NoError(t, err)
+	require.NotNil(t, r)
+	data, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "file1\n", string(data))
+	assert.NoError(t, r.Close())
+
+	_, err = testBlob.DataReader(5)
+	assert.True(t, IsErrBlobTooLarge(err))
+}
+
+func TestLimitedReadCloser(t *testing.T) {
+	r := &limitedReadCloser{rc: ioutil.NopCloser(strings.NewReader("0123456789")), maxSize: 4, remaining: 4}
+	data, err := ioutil.ReadAll(r)
+	assert.True(t, IsErrBlobTooLarge(err))
+	assert.Equal(t, "0123", string(data))
+}
+
+func TestBlob_Detection(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	repo, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+	testBlob, err := repo.GetBlob("e2129701f1a4d54dc44f03c93bca0a2aec7c5449")
+	assert.NoError(t, err)
+
+	contentType, err := testBlob.GuessContentType()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", contentType)
+
+	binary, err := testBlob.IsBinary()
+	assert.NoError(t, err)
+	assert.False(t, binary)
+
+	encoding, err := testBlob.DetectEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, "UTF-8", encoding)
+}
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, isBinary([]byte("plain text")))
+	assert.True(t, isBinary([]byte("PK\x03\x04\x00\x00")))
+	assert.False(t, isBinary([]byte("\xff\xfeh\x00i\x00")))
+	assert.False(t, isBinary(nil))
+}
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		Input     string
+		Truncated bool
+		Expected  string
+	}{
+		{"hello", false, "UTF-8"},
+		{"\xef\xbb\xbfhello", false, "UTF-8"},
+		{"\xff\xfeh\x00i\x00", false, "UTF-16LE"},
+		{"\xfe\xff\x00h\x00i", false, "UTF-16BE"},
+		// "hé" cut in the middle of the "é"
+		{"h\xc3", true, "UTF-8"},
+	}
+	for _, testCase := range testCases {
+		encoding, err := detectEncoding([]byte(testCase.Input), testCase.Truncated)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.Expected, encoding, testCase.Input)
+	}
+
+	encoding, err := detectEncoding([]byte(strings.Repeat("\xe4\xf6\xfc Gr\xfc\xdfe aus K\xf6ln. ", 20)), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "ISO-8859-1", encoding)
+}
+
 func Benchmark_Blob_Data(b *testing.B) {
 	repo, err := OpenRepository("../../.git")
 	if err != nil {
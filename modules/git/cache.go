@@ -9,3 +9,11 @@ type LastCommitCache interface {
 	Get(repoPath, ref, entryPath string) (*Commit, error)
 	Put(repoPath, ref, entryPath string, commit *Commit) error
 }
+
+// BloomFilterCache stores the changed-path Bloom filters of commits which are not in the commit-graph,
+// keyed by commit ID. A LastCommitCache implementing it keeps the filters computed while walking the
+// history, so the walks of later requests reuse them.
+type BloomFilterCache interface {
+	GetBloomFilter(commitID string) ([]byte, error)
+	PutBloomFilter(commitID string, filter []byte) error
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"sync/atomic"
+
+	version "github.com/mcuadros/go-version"
+)
+
+// Capabilities are the features supported by the git executable. The features are gated on them
+// instead of comparing the version of git everywhere.
+type Capabilities struct {
+	// Version is the version of git, empty if it could not be determined
+	Version string
+
+	// SupportsLFSFilterOverride means the LFS filters can be disabled with -c filter.lfs.*= (2.1.2)
+	SupportsLFSFilterOverride bool
+	// SupportsForEachRefContains means for-each-ref supports --contains (2.7)
+	SupportsForEachRefContains bool
+	// SupportsRemoteGetURL means the URL of a remote can be read with remote get-url (2.7)
+	SupportsRemoteGetURL bool
+	// SupportsEmptyCredentialHelper means the credential helpers can be disabled with -c credential.helper= (2.9)
+	SupportsEmptyCredentialHelper bool
+	// SupportsCommitGraph means the commit-graph file can be written and used (2.18)
+	SupportsCommitGraph bool
+	// SupportsProtocolV2 means the wire protocol version 2 is supported (2.18)
+	SupportsProtocolV2 bool
+	// SupportsCommitGraphSplit means commit-graph can write incremental chains of files with --split (2.24)
+	SupportsCommitGraphSplit bool
+	// SupportsCommitGraphChangedPaths means commit-graph writes changed-path Bloom filters (2.27)
+	SupportsCommitGraphChangedPaths bool
+	// SupportsConfigEnv means configuration can be passed in the environment with GIT_CONFIG_COUNT (2.31)
+	SupportsConfigEnv bool
+	// SupportsGeometricRepack means repack keeps the number of packfiles bounded with --geometric (2.32)
+	SupportsGeometricRepack bool
+	// SupportsMergeTreeWriteTree means merge-tree can merge without a worktree with --write-tree (2.38)
+	SupportsMergeTreeWriteTree bool
+	// SupportsMergeTreeMergeBase means merge-tree --write-tree takes an explicit --merge-base, e.g. to cherry-pick (2.40)
+	SupportsMergeTreeMergeBase bool
+	// SupportsCheckAttrSource means check-attr reads the attributes of a tree without an index with --source (2.40)
+	SupportsCheckAttrSource bool
+	// SupportsAheadBehind means for-each-ref computes the divergence of refs with %(ahead-behind) (2.41)
+	SupportsAheadBehind bool
+}
+
+// CapabilitiesForVersion returns the capabilities of the given version of git
+func CapabilitiesForVersion(gitVersion string) Capabilities {
+	atLeast := func(v string) bool {
+		return len(gitVersion) > 0 && version.Compare(gitVersion, v, ">=")
+	}
+	return Capabilities{
+		Version:                         gitVersion,
+		SupportsLFSFilterOverride:       atLeast("2.1.2"),
+		SupportsForEachRefContains:      atLeast("2.7.0"),
+		SupportsRemoteGetURL:            atLeast("2.7"),
+		SupportsEmptyCredentialHelper:   atLeast("2.9"),
+		SupportsCommitGraph:             atLeast("2.18"),
+		SupportsProtocolV2:              atLeast("2.18"),
+		SupportsCommitGraphSplit:        atLeast("2.24"),
+		SupportsCommitGraphChangedPaths: atLeast("2.27"),
+		SupportsConfigEnv:               atLeast("2.31"),
+		SupportsGeometricRepack:         atLeast("2.32"),
+		SupportsMergeTreeWriteTree:      atLeast("2.38"),
+		SupportsMergeTreeMergeBase:      atLeast("2.40"),
+		SupportsCheckAttrSource:         atLeast("2.40"),
+		SupportsAheadBehind:             atLeast("2.41"),
+	}
+}
+
+// capabilities holds the Capabilities of the git executable
+var capabilities atomic.Value
+
+// GetCapabilities returns the capabilities of the git executable. They are detected once by
+// SetExecutablePath at startup, or on first use if it has not been called. None are supported
+// if the version of git cannot be determined.
+func GetCapabilities() Capabilities {
+	if caps, ok := capabilities.Load().(Capabilities); ok {
+		return caps
+	}
+	gitVersion, err := BinVersion()
+	if err != nil {
+		return Capabilities{}
+	}
+	caps := CapabilitiesForVersion(gitVersion)
+	capabilities.Store(caps)
+	return caps
+}
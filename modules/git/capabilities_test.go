@@ -0,0 +1,49 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitiesForVersion(t *testing.T) {
+	caps := CapabilitiesForVersion("2.7.0")
+	assert.True(t, caps.SupportsLFSFilterOverride)
+	assert.True(t, caps.SupportsForEachRefContains)
+	assert.True(t, caps.SupportsRemoteGetURL)
+	assert.False(t, caps.SupportsEmptyCredentialHelper)
+	assert.False(t, caps.SupportsCommitGraph)
+
+	caps = CapabilitiesForVersion("2.18.0")
+	assert.True(t, caps.SupportsCommitGraph)
+	assert.True(t, caps.SupportsProtocolV2)
+	assert.False(t, caps.SupportsCommitGraphSplit)
+	assert.False(t, caps.SupportsCommitGraphChangedPaths)
+
+	caps = CapabilitiesForVersion("2.39.0")
+	assert.True(t, caps.SupportsConfigEnv)
+	assert.True(t, caps.SupportsGeometricRepack)
+	assert.True(t, caps.SupportsMergeTreeWriteTree)
+	assert.False(t, caps.SupportsMergeTreeMergeBase)
+	assert.False(t, caps.SupportsCheckAttrSource)
+
+	caps = CapabilitiesForVersion("2.41.0")
+	assert.True(t, caps.SupportsCommitGraphSplit)
+	assert.True(t, caps.SupportsMergeTreeWriteTree)
+	assert.True(t, caps.SupportsMergeTreeMergeBase)
+	assert.True(t, caps.SupportsCheckAttrSource)
+	assert.True(t, caps.SupportsAheadBehind)
+
+	// nothing is supported if the version is unknown
+	assert.Equal(t, Capabilities{}, CapabilitiesForVersion(""))
+}
+
+func TestGetCapabilities(t *testing.T) {
+	gitVersion, err := BinVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, CapabilitiesForVersion(gitVersion), GetCapabilities())
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// CodeOwnersPaths are the locations CODEOWNERS is looked up at, in order
+var CodeOwnersPaths = []string{".gitea/CODEOWNERS", ".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwnersRule assigns owners to the paths matching a pattern
+type CodeOwnersRule struct {
+	Pattern string
+	Owners  []string
+	Line    int
+
+	// glob is the pattern rewritten for MatchPathGlob
+	glob     string
+	dirOnly  bool
+	children bool
+}
+
+// CodeOwners represents a parsed CODEOWNERS file
+type CodeOwners struct {
+	Path  string
+	Rules []*CodeOwnersRule
+}
+
+// ParseCodeOwners parses the content of a CODEOWNERS file. Each line consists of a
+// pattern followed by the owners of the matching paths, `#` starts a comment.
+func ParseCodeOwners(content []byte) (*CodeOwners, error) {
+	codeOwners := &CodeOwners{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		rule, err := newCodeOwnersRule(fields[0], fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("CODEOWNERS line %d: %v", lineNum, err)
+		}
+		rule.Line = lineNum
+		codeOwners.Rules = append(codeOwners.Rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return codeOwners, nil
+}
+
+func newCodeOwnersRule(pattern string, owners []string) (*CodeOwnersRule, error) {
+	rule := &CodeOwnersRule{
+		Pattern: pattern,
+		Owners:  owners,
+	}
+
+	glob := pattern
+	if strings.HasSuffix(glob, "/") {
+		rule.dirOnly = true
+		glob = strings.TrimRight(glob, "/")
+	}
+	// Patterns containing a slash are relative to the repository root,
+	// all others match at any depth
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimLeft(glob, "/")
+	} else {
+		glob = "**/" + glob
+	}
+	if glob == "" || glob == "**/" {
+		return nil, fmt.Errorf("invalid pattern: %s", pattern)
+	}
+	rule.glob = glob
+	// Like on GitHub, `docs/*` only matches the direct children of docs
+	rule.children = !strings.HasSuffix(glob, "/*")
+
+	if _, err := MatchPathGlob(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	for _, owner := range owners {
+		if !strings.HasPrefix(owner, "@") && !strings.Contains(owner, "@") {
+			return nil, fmt.Errorf("invalid owner: %s", owner)
+		}
+	}
+	return rule, nil
+}
+
+// Match reports whether the file at path is matched by the rule
+func (r *CodeOwnersRule) Match(path string) bool {
+	path = strings.Trim(path, "/")
+	if !r.dirOnly {
+		if ok, _ := MatchPathGlob(r.glob, path); ok {
+			return true
+		}
+	}
+	if !r.children {
+		return false
+	}
+	// A pattern matching a directory matches everything below it
+	for i := strings.LastIndexByte(path, '/'); i > 0; i = strings.LastIndexByte(path[:i], '/') {
+		if ok, _ := MatchPathGlob(r.glob, path[:i]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the rule which applies to the file at path, that is the last matching one.
+// It returns nil if no rule matches.
+func (c *CodeOwners) Match(path string) *CodeOwnersRule {
+	for i := len(c.Rules) - 1; i >= 0; i-- {
+		if c.Rules[i].Match(path) {
+			return c.Rules[i]
+		}
+	}
+	return nil
+}
+
+// OwnersOf returns the owners of the given files, mapped to the files each of them owns.
+func (c *CodeOwners) OwnersOf(paths []string) map[string][]string {
+	owners := make(map[string][]string)
+	for _, path := range paths {
+		rule := c.Match(path)
+		if rule == nil {
+			continue
+		}
+		for _, owner := range rule.Owners {
+			owners[owner] = append(owners[owner], path)
+		}
+	}
+	return owners
+}
+
+// GetCodeOwners loads and parses the CODEOWNERS file of the commit from the first
+// of CodeOwnersPaths which exists. It returns nil if there is none.
+func (c *Commit) GetCodeOwners() (*CodeOwners, error) {
+	for _, treePath := range CodeOwnersPaths {
+		entry, err := c.GetTreeEntryByPath(treePath)
+		if err != nil {
+			if IsErrNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if entry.IsDir() || entry.IsSubModule() {
+			continue
+		}
+
+		rd, err := entry.Blob().DataAsync()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		codeOwners, err := ParseCodeOwners(content)
+		if err != nil {
+			return nil, err
+		}
+		codeOwners.Path = treePath
+		return codeOwners, nil
+	}
+	return nil, nil
+}
+
+// GetCodeOwners loads and parses the CODEOWNERS file of the given ref.
+// It returns nil if there is none.
+func (repo *Repository) GetCodeOwners(ref string) (*CodeOwners, error) {
+	commit, err := repo.GetCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	return commit.GetCodeOwners()
+}
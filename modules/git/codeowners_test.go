@@ -0,0 +1,92 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCodeOwners = `# Default owners
+*                @global-owner
+
+*.js             @js-owner # inline comment
+/build/logs/     @doctocat
+docs/*           docs@example.com
+apps/            @octocat
+/scripts/        @doctocat @octocat
+/scripts/gen.sh
+`
+
+func TestParseCodeOwners(t *testing.T) {
+	codeOwners, err := ParseCodeOwners([]byte(testCodeOwners))
+	assert.NoError(t, err)
+	if assert.Len(t, codeOwners.Rules, 7) {
+		assert.Equal(t, "*.js", codeOwners.Rules[1].Pattern)
+		assert.Equal(t, []string{"@js-owner"}, codeOwners.Rules[1].Owners)
+		assert.Equal(t, 4, codeOwners.Rules[1].Line)
+		assert.Empty(t, codeOwners.Rules[6].Owners)
+	}
+
+	_, err = ParseCodeOwners([]byte("src/[ @owner\n"))
+	assert.Error(t, err)
+	_, err = ParseCodeOwners([]byte("src/ owner\n"))
+	assert.Error(t, err)
+}
+
+func TestCodeOwners_Match(t *testing.T) {
+	codeOwners, err := ParseCodeOwners([]byte(testCodeOwners))
+	assert.NoError(t, err)
+
+	for path, owners := range map[string][]string{
+		"README.md":                {"@global-owner"},
+		"web/app.js":               {"@js-owner"},
+		"build/logs/today.log":     {"@doctocat"},
+		"build/logs/old/today.log": {"@doctocat"},
+		"sub/build/logs/today.log": {"@global-owner"},
+		"docs/index.md":            {"docs@example.com"},
+		"docs/api/index.md":        {"@global-owner"},
+		"src/apps/main.go":         {"@octocat"},
+		"apps":                     {"@global-owner"},
+		"scripts/run.sh":           {"@doctocat", "@octocat"},
+		"scripts/gen.sh":           {},
+	} {
+		rule := codeOwners.Match(path)
+		if assert.NotNil(t, rule, path) {
+			assert.Equal(t, owners, rule.Owners, path)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"@global-owner": {"README.md"},
+		"@doctocat":     {"scripts/run.sh"},
+		"@octocat":      {"apps/x/main.go", "scripts/run.sh"},
+	}, codeOwners.OwnersOf([]string{"README.md", "apps/x/main.go", "scripts/run.sh", "scripts/gen.sh"}))
+}
+
+func TestRepository_GetCodeOwners(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{
+		"CODEOWNERS":         "* @root-owner\n",
+		".github/CODEOWNERS": "*.go @go-owner\n",
+		"main.go":            "package main\n",
+	}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	codeOwners, err := repo.GetCodeOwners("master")
+	assert.NoError(t, err)
+	if assert.NotNil(t, codeOwners) {
+		assert.Equal(t, ".github/CODEOWNERS", codeOwners.Path)
+		assert.Len(t, codeOwners.Rules, 1)
+	}
+
+	bareRepo1, err := OpenRepository(filepath.Join(testReposDir, "repo1_bare"))
+	assert.NoError(t, err)
+	codeOwners, err = bareRepo1.GetCodeOwners("master")
+	assert.NoError(t, err)
+	assert.Nil(t, codeOwners)
+}
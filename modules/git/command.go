@@ -5,10 +5,13 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -28,13 +31,26 @@ var (
 type Command struct {
 	name string
 	args []string
+	// env are the environment variables set by SetEnv
+	env []string
+	// brokenArg is the first argument rejected by the builder, the command is not run then
+	brokenArg string
 }
 
 func (c *Command) String() string {
 	if len(c.args) == 0 {
 		return c.name
 	}
-	return fmt.Sprintf("%s %s", c.name, strings.Join(c.args, " "))
+	return fmt.Sprintf("%s %s", c.name, joinArguments(c.args))
+}
+
+// joinArguments joins the arguments quoted by quoteArgument
+func joinArguments(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArgument(arg)
+	}
+	return strings.Join(quoted, " ")
 }
 
 // NewCommand creates and returns a new Git Command based on given command and arguments.
@@ -54,6 +70,108 @@ func (c *Command) AddArguments(args ...string) *Command {
 	return c
 }
 
+// AddDynamicArguments adds positional arguments whose values come from the user, e.g. revisions
+// or branch names. An argument starting with "-" would be parsed as an option by git, it is
+// rejected and the command fails with ErrUnsafeArgument instead of being run.
+func (c *Command) AddDynamicArguments(args ...string) *Command {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && len(c.brokenArg) == 0 {
+			c.brokenArg = arg
+		}
+	}
+	c.args = append(c.args, args...)
+	return c
+}
+
+// AddOptionValues adds the option once for every value as "opt=value", e.g. AddOptionValues("--grep",
+// keywords...). The values may start with "-" as they are bound to the option.
+func (c *Command) AddOptionValues(opt string, values ...string) *Command {
+	for _, value := range values {
+		c.args = append(c.args, opt+"="+value)
+	}
+	return c
+}
+
+// AddDashesAndList adds "--" and the list after it, so the list is never parsed as options or
+// revisions. It is used for pathspecs and has to be the last call of the builder.
+func (c *Command) AddDashesAndList(list ...string) *Command {
+	c.args = append(c.args, "--")
+	c.args = append(c.args, list...)
+	return c
+}
+
+// checkArguments returns the error of an argument rejected by the builder
+func (c *Command) checkArguments() error {
+	if len(c.brokenArg) > 0 {
+		return ErrUnsafeArgument{c.brokenArg}
+	}
+	return nil
+}
+
+// CommandEnvOptions are the environment variables which control the identities and the object
+// storage used by a git command
+type CommandEnvOptions struct {
+	// Author and Committer set GIT_AUTHOR_* and GIT_COMMITTER_*, the date only if When is set
+	Author    *Signature
+	Committer *Signature
+	// ObjectDirectory, AlternateObjectDirectories and QuarantinePath are the object storage
+	// of a push which is being received, as passed to the hooks by git
+	ObjectDirectory            string
+	AlternateObjectDirectories string
+	QuarantinePath             string
+	// NoSystemConfig ignores the system wide git configuration
+	NoSystemConfig bool
+}
+
+// environ returns the environment variables of the options
+func (opts CommandEnvOptions) environ() []string {
+	var env []string
+	if opts.Author != nil {
+		env = append(env, "GIT_AUTHOR_NAME="+opts.Author.Name, "GIT_AUTHOR_EMAIL="+opts.Author.Email)
+		if !opts.Author.When.IsZero() {
+			env = append(env, "GIT_AUTHOR_DATE="+opts.Author.When.Format(time.RFC3339))
+		}
+	}
+	if opts.Committer != nil {
+		env = append(env, "GIT_COMMITTER_NAME="+opts.Committer.Name, "GIT_COMMITTER_EMAIL="+opts.Committer.Email)
+		if !opts.Committer.When.IsZero() {
+			env = append(env, "GIT_COMMITTER_DATE="+opts.Committer.When.Format(time.RFC3339))
+		}
+	}
+	if opts.ObjectDirectory != "" {
+		env = append(env, "GIT_OBJECT_DIRECTORY="+opts.ObjectDirectory)
+	}
+	if opts.AlternateObjectDirectories != "" {
+		env = append(env, "GIT_ALTERNATE_OBJECT_DIRECTORIES="+opts.AlternateObjectDirectories)
+	}
+	if opts.QuarantinePath != "" {
+		env = append(env, "GIT_QUARANTINE_PATH="+opts.QuarantinePath)
+	}
+	if opts.NoSystemConfig {
+		env = append(env, "GIT_CONFIG_NOSYSTEM=1")
+	}
+	return env
+}
+
+// SetEnv sets the environment variables of the options for the command. They are added to
+// the environment given when running it, or to the environment of Gitea if none is given.
+func (c *Command) SetEnv(opts CommandEnvOptions) *Command {
+	c.env = opts.environ()
+	return c
+}
+
+// environ returns the environment of the command: env, or that of Gitea if it is nil,
+// followed by the variables set on the command, which take precedence
+func (c *Command) environ(env []string) []string {
+	if len(c.env) == 0 {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(append(make([]string, 0, len(env)+len(c.env)), env...), c.env...)
+}
+
 // RunInDirTimeoutEnvPipeline executes the command in given directory with given timeout,
 // it pipes stdout and stderr to given io.Writer.
 func (c *Command) RunInDirTimeoutEnvPipeline(env []string, timeout time.Duration, dir string, stdout, stderr io.Writer) error {
@@ -63,6 +181,16 @@ func (c *Command) RunInDirTimeoutEnvPipeline(env []string, timeout time.Duration
 // RunInDirTimeoutEnvFullPipeline executes the command in given directory with given timeout,
 // it pipes stdout and stderr to given io.Writer and passes in an io.Reader as stdin.
 func (c *Command) RunInDirTimeoutEnvFullPipeline(env []string, timeout time.Duration, dir string, stdout, stderr io.Writer, stdin io.Reader) error {
+	return c.RunInDirTimeoutEnvFullPipelineWithContext(context.Background(), env, timeout, dir, stdout, stderr, stdin)
+}
+
+// RunInDirTimeoutEnvFullPipelineWithContext executes the command in given directory with given timeout,
+// it pipes stdout and stderr to given io.Writer and passes in an io.Reader as stdin. The command is
+// killed once the timeout expires or ctx is done, the error of ctx is returned then.
+func (c *Command) RunInDirTimeoutEnvFullPipelineWithContext(ctx context.Context, env []string, timeout time.Duration, dir string, stdout, stderr io.Writer, stdin io.Reader) error {
+	if err := c.checkArguments(); err != nil {
+		return err
+	}
 	if timeout == -1 {
 		timeout = DefaultCommandExecutionTimeout
 	}
@@ -73,23 +201,44 @@ func (c *Command) RunInDirTimeoutEnvFullPipeline(env []string, timeout time.Dura
 		log("%s: %v", dir, c)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, span := c.startSpan(ctx, dir)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, c.name, c.args...)
-	cmd.Env = env
-	cmd.Dir = dir
-	cmd.Stdout = stdout
+	start := time.Now()
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Env = c.environ(env)
+	cmd.Dir = longPath(dir)
+	var output *countingWriter
+	if stdout != nil && isCommandObserved() {
+		output = &countingWriter{w: stdout}
+		cmd.Stdout = output
+	} else {
+		cmd.Stdout = stdout
+	}
 	cmd.Stderr = stderr
 	cmd.Stdin = stdin
-	if err := cmd.Start(); err != nil {
+	exited, err := startProcess(ctx, cmd)
+	if err != nil {
+		c.observe(dir, start, span, err, 0)
 		return err
 	}
 
 	pid := process.GetManager().Add(fmt.Sprintf("%s %s %s [repo_path: %s]", GitExecutable, c.name, strings.Join(c.args, " "), dir), cmd)
 	defer process.GetManager().Remove(pid)
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	exited()
+	var outputSize int64
+	if output != nil {
+		outputSize = output.n
+	}
+	c.observe(dir, start, span, err, outputSize)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the command has been killed
+			return ctx.Err()
+		}
 		return err
 	}
 
@@ -117,30 +266,393 @@ func (c *Command) RunInDirTimeout(timeout time.Duration, dir string) ([]byte, er
 // RunInDirTimeoutEnv executes the command in given directory with given timeout,
 // and returns stdout in []byte and error (combined with stderr).
 func (c *Command) RunInDirTimeoutEnv(env []string, timeout time.Duration, dir string) ([]byte, error) {
+	return c.RunInDirTimeoutEnvWithContext(context.Background(), env, timeout, dir)
+}
+
+// RunInDirTimeoutEnvWithContext executes the command in given directory with given timeout,
+// and returns stdout in []byte and error (combined with stderr). The command is killed once
+// the timeout expires or ctx is done.
+func (c *Command) RunInDirTimeoutEnvWithContext(ctx context.Context, env []string, timeout time.Duration, dir string) ([]byte, error) {
+	return c.runInDirTimeoutEnv(ctx, env, timeout, dir, nil)
+}
+
+// RunInDirTimeoutEnvWithStdin executes the command in given directory with given timeout, passes
+// in stdin and returns stdout in []byte and error (combined with stderr).
+func (c *Command) RunInDirTimeoutEnvWithStdin(env []string, timeout time.Duration, dir string, stdin io.Reader) ([]byte, error) {
+	return c.runInDirTimeoutEnv(context.Background(), env, timeout, dir, stdin)
+}
+
+func (c *Command) runInDirTimeoutEnv(ctx context.Context, env []string, timeout time.Duration, dir string, stdin io.Reader) ([]byte, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
-	if err := c.RunInDirTimeoutEnvPipeline(env, timeout, dir, stdout, stderr); err != nil {
-		return nil, concatenateError(err, stderr.String())
+	if err := c.RunInDirTimeoutEnvFullPipelineWithContext(ctx, env, timeout, dir, stdout, stderr, stdin); err != nil {
+		return nil, newCommandError(err, stderr.String())
 	}
 
 	if stdout.Len() > 0 {
-		log("stdout:\n%s", stdout.Bytes()[:1024])
+		out := stdout.Bytes()
+		if len(out) > 1024 {
+			out = out[:1024]
+		}
+		log("stdout:\n%s", out)
 	}
 	return stdout.Bytes(), nil
 }
 
+// RunInDirReader starts the command in given directory and returns its stdout as a stream,
+// so large outputs are not buffered in memory. If maxSize is positive, reading more than maxSize
+// bytes kills the command and fails with ErrOutputTooLarge. A failing command is reported by
+// Read instead of io.EOF. The reader must be closed, which also kills the command if it is
+// still running.
+func (c *Command) RunInDirReader(dir string, maxSize int64) (io.ReadCloser, error) {
+	return c.RunInDirReaderWithContext(context.Background(), dir, maxSize)
+}
+
+// RunInDirReaderWithContext works like RunInDirReader, but also kills the command once ctx is done.
+func (c *Command) RunInDirReaderWithContext(ctx context.Context, dir string, maxSize int64) (io.ReadCloser, error) {
+	if err := c.checkArguments(); err != nil {
+		return nil, err
+	}
+	log("%s: %v", dir, c)
+
+	ctx, span := c.startSpan(ctx, dir)
+	ctx, cancel := context.WithTimeout(ctx, DefaultCommandExecutionTimeout)
+	start := time.Now()
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Env = c.environ(nil)
+	cmd.Dir = longPath(dir)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		span.End(err)
+		return nil, err
+	}
+	exited, err := startProcess(ctx, cmd)
+	if err != nil {
+		cancel()
+		c.observe(dir, start, span, err, 0)
+		return nil, err
+	}
+
+	pid := process.GetManager().Add(fmt.Sprintf("%s %s %s [repo_path: %s]", GitExecutable, c.name, strings.Join(c.args, " "), dir), cmd)
+	return &commandReader{
+		command: c,
+		dir:     dir,
+		start:   start,
+		span:    span,
+		cmd:     cmd,
+		exited:  exited,
+		ctx:     ctx,
+		cancel:  cancel,
+		pid:     pid,
+		stdout:  stdout,
+		stderr:  stderr,
+		maxSize: maxSize,
+	}, nil
+}
+
+// commandReader is the stdout of a command started by RunInDirReader
+type commandReader struct {
+	command *Command
+	dir     string
+	start   time.Time
+	span    Span
+
+	cmd    *exec.Cmd
+	exited func()
+	ctx    context.Context
+	cancel context.CancelFunc
+	pid    int64
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+
+	maxSize int64
+	read    int64
+	err     error
+	done    bool
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.stdout.Read(p)
+	r.read += int64(n)
+	if r.maxSize > 0 && r.read > r.maxSize {
+		n -= int(r.read - r.maxSize)
+		r.err = ErrOutputTooLarge{r.maxSize}
+		r.finish()
+		return n, r.err
+	}
+	if err == io.EOF {
+		r.err = io.EOF
+		if waitErr := r.finish(); waitErr != nil {
+			r.err = waitErr
+		}
+		return n, r.err
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// finish waits for the command to exit and releases its resources
+func (r *commandReader) finish() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	defer process.GetManager().Remove(r.pid)
+	defer r.cancel()
+
+	if r.err != io.EOF {
+		// the output is not read to the end, so the command has to be stopped
+		r.cancel()
+	}
+	err := r.cmd.Wait()
+	r.exited()
+	r.command.observe(r.dir, r.start, r.span, err, r.read)
+	if err != nil {
+		if err := r.ctx.Err(); err == context.DeadlineExceeded || (err == context.Canceled && r.err == io.EOF) {
+			// the command has been killed by the timeout or by the context of the caller
+			return err
+		}
+		return newCommandError(err, r.stderr.String())
+	}
+	return r.ctx.Err()
+}
+
+func (r *commandReader) Close() error {
+	if r.err == nil {
+		r.err = errors.New("read from closed command output")
+	}
+	r.finish()
+	return nil
+}
+
+// pipedCommandExitTimeout is the time a piped command has to exit once its stdin is closed
+// before it is killed
+var pipedCommandExitTimeout = 10 * time.Second
+
+// pipedCommand is a long-running git command, like `cat-file --batch`, which reads requests
+// from its stdin and answers them on its stdout until its stdin is closed
+type pipedCommand struct {
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// stderr is written by the process and must only be read once it has exited
+	stderr *bytes.Buffer
+
+	c      *Command
+	dir    string
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	exited func()
+	pid    int64
+	start  time.Time
+	span   Span
+	output *countingReader
+}
+
+// startPiped starts the command in dir like RunInDirTimeoutEnvFullPipelineWithContext does, but
+// without a timeout, and returns at once. close or kill must be called once it is not needed.
+func (c *Command) startPiped(env []string, dir string) (*pipedCommand, error) {
+	if err := c.checkArguments(); err != nil {
+		return nil, err
+	}
+	log("%s: %v", dir, c)
+
+	ctx, span := c.startSpan(context.Background(), dir)
+	ctx, cancel := context.WithCancel(ctx)
+
+	start := time.Now()
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Env = c.environ(env)
+	cmd.Dir = longPath(dir)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		c.observe(dir, start, span, err, 0)
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		c.observe(dir, start, span, err, 0)
+		return nil, err
+	}
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	exited, err := startProcess(ctx, cmd)
+	if err != nil {
+		cancel()
+		c.observe(dir, start, span, err, 0)
+		return nil, err
+	}
+
+	output := &countingReader{r: stdout}
+	return &pipedCommand{
+		stdin:  stdin,
+		stdout: bufio.NewReader(output),
+		stderr: stderr,
+		c:      c,
+		dir:    dir,
+		cmd:    cmd,
+		cancel: cancel,
+		exited: exited,
+		pid:    process.GetManager().Add(fmt.Sprintf("%s %s %s [repo_path: %s]", GitExecutable, c.name, strings.Join(c.args, " "), dir), cmd),
+		start:  start,
+		span:   span,
+		output: output,
+	}, nil
+}
+
+// close closes the stdin of the command and waits for it to exit. It is killed if it does not
+// exit within pipedCommandExitTimeout, e.g. because it is wedged.
+func (p *pipedCommand) close() error {
+	_ = p.stdin.Close()
+	timer := time.AfterFunc(pipedCommandExitTimeout, p.cancel)
+	defer timer.Stop()
+	return p.wait()
+}
+
+// kill kills the command, e.g. after its output got out of step with the requests, and waits
+// for it to exit
+func (p *pipedCommand) kill() error {
+	p.cancel()
+	_ = p.stdin.Close()
+	return p.wait()
+}
+
+func (p *pipedCommand) wait() error {
+	err := p.cmd.Wait()
+	p.exited()
+	p.cancel()
+	process.GetManager().Remove(p.pid)
+	p.c.observe(p.dir, p.start, p.span, err, p.output.n)
+	return err
+}
+
 // RunInDirPipeline executes the command in given directory,
 // it pipes stdout and stderr to given io.Writer.
 func (c *Command) RunInDirPipeline(dir string, stdout, stderr io.Writer) error {
 	return c.RunInDirFullPipeline(dir, stdout, stderr, nil)
 }
 
+// RunInDirPipelineWithContext executes the command in given directory and streams its stdout
+// and stderr to the given io.Writer while it runs, e.g. to serve archives, raw files or packs.
+// The command is killed once ctx is done or writing to stdout or stderr fails, e.g. because
+// the client has gone away, and the error of ctx or of the writer is returned. A failing command
+// is reported together with the beginning of its stderr. There is no timeout besides ctx, as
+// streaming large outputs takes long.
+func (c *Command) RunInDirPipelineWithContext(ctx context.Context, dir string, stdout, stderr io.Writer) error {
+	if err := c.checkArguments(); err != nil {
+		return err
+	}
+	log("%s: %v", dir, c)
+
+	ctx, span := c.startSpan(ctx, dir)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	errOutput := &limitedBuffer{limit: pipelineStderrLimit}
+	stdoutWriter := &pipelineWriter{cancel: cancel}
+	stderrWriter := &pipelineWriter{w: errOutput, cancel: cancel}
+	if stderr != nil {
+		stderrWriter.w = io.MultiWriter(errOutput, stderr)
+	}
+
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Env = c.environ(nil)
+	cmd.Dir = longPath(dir)
+	if stdout != nil {
+		stdoutWriter.w = stdout
+		cmd.Stdout = stdoutWriter
+	}
+	cmd.Stderr = stderrWriter
+	exited, err := startProcess(ctx, cmd)
+	if err != nil {
+		c.observe(dir, start, span, err, 0)
+		return err
+	}
+
+	pid := process.GetManager().Add(fmt.Sprintf("%s %s %s [repo_path: %s]", GitExecutable, c.name, strings.Join(c.args, " "), dir), cmd)
+	defer process.GetManager().Remove(pid)
+
+	err = cmd.Wait()
+	exited()
+	c.observe(dir, start, span, err, stdoutWriter.n)
+	switch {
+	case stdoutWriter.err != nil:
+		return stdoutWriter.err
+	case stderrWriter.err != nil:
+		return stderrWriter.err
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return newCommandError(err, errOutput.String())
+	}
+	return nil
+}
+
+// pipelineStderrLimit is the number of bytes of the stderr of a streaming command kept for its error
+const pipelineStderrLimit = 4096
+
+// pipelineWriter kills a streaming command by cancelling its context once writing its output fails
+type pipelineWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+	err    error
+	// n is the number of bytes written
+	n int64
+}
+
+func (p *pipelineWriter) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	if err != nil {
+		p.err = err
+		p.cancel()
+	}
+	return n, err
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.limit - b.Len(); left > 0 {
+		if len(p) > left {
+			b.Buffer.Write(p[:left])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
 // RunInDirFullPipeline executes the command in given directory,
 // it pipes stdout and stderr to given io.Writer.
 func (c *Command) RunInDirFullPipeline(dir string, stdout, stderr io.Writer, stdin io.Reader) error {
 	return c.RunInDirTimeoutFullPipeline(-1, dir, stdout, stderr, stdin)
 }
 
+// RunInDirFullPipelineWithContext executes the command in given directory, it pipes stdout
+// and stderr to given io.Writer. The command is killed once ctx is done.
+func (c *Command) RunInDirFullPipelineWithContext(ctx context.Context, dir string, stdout, stderr io.Writer, stdin io.Reader) error {
+	return c.RunInDirTimeoutEnvFullPipelineWithContext(ctx, nil, -1, dir, stdout, stderr, stdin)
+}
+
 // RunInDirBytes executes the command in given directory
 // and returns stdout in []byte and error (combined with stderr).
 func (c *Command) RunInDirBytes(dir string) ([]byte, error) {
@@ -153,6 +665,38 @@ func (c *Command) RunInDir(dir string) (string, error) {
 	return c.RunInDirWithEnv(dir, nil)
 }
 
+// RunInDirBytesWithContext executes the command in given directory and returns stdout in []byte
+// and error (combined with stderr). The command is killed once ctx is done.
+func (c *Command) RunInDirBytesWithContext(ctx context.Context, dir string) ([]byte, error) {
+	return c.RunInDirTimeoutEnvWithContext(ctx, nil, -1, dir)
+}
+
+// RunInDirWithContext executes the command in given directory and returns stdout in string
+// and error (combined with stderr). The command is killed once ctx is done.
+func (c *Command) RunInDirWithContext(ctx context.Context, dir string) (string, error) {
+	stdout, err := c.RunInDirTimeoutEnvWithContext(ctx, nil, -1, dir)
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
+
+// RunInDirBytesWithStdin executes the command in given directory, passes in stdin
+// and returns stdout in []byte and error (combined with stderr).
+func (c *Command) RunInDirBytesWithStdin(dir string, stdin io.Reader) ([]byte, error) {
+	return c.RunInDirTimeoutEnvWithStdin(nil, -1, dir, stdin)
+}
+
+// RunInDirWithStdin executes the command in given directory, passes in stdin
+// and returns stdout in string and error (combined with stderr).
+func (c *Command) RunInDirWithStdin(dir string, stdin io.Reader) (string, error) {
+	stdout, err := c.RunInDirTimeoutEnvWithStdin(nil, -1, dir, stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
+
 // RunInDirWithEnv executes the command in given directory
 // and returns stdout in string and error (combined with stderr).
 func (c *Command) RunInDirWithEnv(dir string, env []string) (string, error) {
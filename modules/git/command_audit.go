@@ -0,0 +1,40 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	gitealog "code.gitea.io/gitea/modules/log"
+)
+
+// AuditCommands logs every git command run through a Command at debug level together with its
+// directory, duration and exit code. Credentials in the arguments are redacted.
+var AuditCommands = false
+
+// urlCredentialsRegexp matches the user information of the URLs in an argument
+var urlCredentialsRegexp = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/@\s]+@`)
+
+// audit logs the finished command run in dir if AuditCommands is set
+func (c *Command) audit(dir string, duration time.Duration, err error) {
+	if !AuditCommands {
+		return
+	}
+	gitealog.Debug("git %s [dir: %s, duration: %v, exit code: %d]", joinArguments(redactArguments(c.args)), dir, duration, exitCode(err))
+}
+
+// redactArguments returns the arguments with the credentials of URLs and authorization headers replaced
+func redactArguments(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if idx := strings.Index(strings.ToLower(arg), "extraheader="); idx >= 0 {
+			arg = arg[:idx+len("extraheader=")] + "<redacted>"
+		}
+		redacted[i] = urlCredentialsRegexp.ReplaceAllString(arg, "${1}<credentials>@")
+	}
+	return redacted
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_RunInDirWithContext(t *testing.T) {
+	stdout, err := NewCommand("--version").RunInDirWithContext(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "git version")
+
+	// 'git cat-file --batch' blocks on stdin until the context is cancelled
+	stdin, stdinWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer stdin.Close()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err = NewCommand("cat-file", "--batch").RunInDirFullPipelineWithContext(ctx, "", nil, nil, stdin)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < DefaultCommandExecutionTimeout)
+
+	// a context which is done already does not start the command at all
+	_, err = NewCommand("--version").RunInDirBytesWithContext(ctx, "")
+	assert.Error(t, err)
+}
+
+func TestCommand_RunInDirTimeoutWithContext(t *testing.T) {
+	stdin, stdinWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer stdin.Close()
+	defer stdinWriter.Close()
+
+	// the timeout of the command applies as well
+	err = NewCommand("cat-file", "--batch").RunInDirTimeoutEnvFullPipelineWithContext(context.Background(), nil, 100*time.Millisecond, "", nil, nil, stdin)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCommand_RunInDirReaderWithContext(t *testing.T) {
+	bareRepo1Path := testReposDir + "repo1_bare"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, err := NewCommand("log", "--pretty=%H").RunInDirReaderWithContext(ctx, bareRepo1Path, 0)
+	require.NoError(t, err)
+	cancel()
+	_, err = ioutil.ReadAll(reader)
+	assert.Error(t, err)
+	assert.NoError(t, reader.Close())
+}
+
+func TestCommand_RunInDirWithStdin(t *testing.T) {
+	stdout, err := NewCommand("hash-object", "--stdin").RunInDirWithStdin("", strings.NewReader("test\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "9daeafb9864cf43055ae93beb0afd6c7d144bfa4\n", stdout)
+
+	// the error contains stderr
+	_, err = NewCommand("rev-list", "--stdin").RunInDirBytesWithStdin(testReposDir+"repo1_bare", strings.NewReader("does-not-exist\n"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does-not-exist")
+	}
+}
+
+func TestCommand_RunInDirPipelineWithContext(t *testing.T) {
+	bareRepo1Path := testReposDir + "repo1_bare"
+
+	stdout := new(bytes.Buffer)
+	assert.NoError(t, NewCommand("rev-parse", "master").RunInDirPipelineWithContext(context.Background(), bareRepo1Path, stdout, nil))
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2\n", stdout.String())
+
+	// a failing command is reported with its stderr, which is streamed as well
+	stderr := new(bytes.Buffer)
+	err := NewCommand("rev-parse", "--verify", "does-not-exist").RunInDirPipelineWithContext(context.Background(), bareRepo1Path, nil, stderr)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fatal")
+	}
+	assert.Contains(t, stderr.String(), "fatal")
+
+	// writing the output fails, e.g. because the client has gone away
+	errGone := errors.New("client has gone away")
+	err = NewCommand("log", "--pretty=%H").RunInDirPipelineWithContext(context.Background(), bareRepo1Path, failingWriter{errGone}, nil)
+	assert.Equal(t, errGone, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, NewCommand("log", "--pretty=%H").RunInDirPipelineWithContext(ctx, bareRepo1Path, stdout, nil))
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestCommandError(t *testing.T) {
+	bareRepo1Path := testReposDir + "repo1_bare"
+
+	_, err := NewCommand("rev-parse", "--verify", "does-not-exist").RunInDir(bareRepo1Path)
+	if assert.True(t, IsErrCommand(err)) {
+		cmdErr := err.(CommandError)
+		assert.Equal(t, 128, cmdErr.ExitCode)
+		assert.Contains(t, cmdErr.Stderr, "Needed a single revision")
+		assert.Equal(t, CommandErrorNotExist, cmdErr.Kind)
+		// the message is the same as before the error was typed
+		assert.Equal(t, "exit status 128 - "+cmdErr.Stderr, err.Error())
+	}
+
+	_, err = NewCommand("update-ref", "refs/heads/master", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", EmptySHA).RunInDir(bareRepo1Path)
+	assert.True(t, IsErrCommandKind(err, CommandErrorLockFailed))
+
+	_, err = NewCommand("config", "--get", "does.not-exist").RunInDir(bareRepo1Path)
+	if assert.True(t, IsErrCommandKind(err, CommandErrorOther)) {
+		assert.Equal(t, 1, err.(CommandError).ExitCode)
+	}
+
+	_, err = NewCommand("--version").RunInDir(bareRepo1Path)
+	assert.NoError(t, err)
+}
+
+func TestCommand_SetEnv(t *testing.T) {
+	when := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	stdout, err := NewCommand("var", "GIT_COMMITTER_IDENT").
+		SetEnv(CommandEnvOptions{Committer: &Signature{Name: "Gitea", Email: "gitea@example.com", When: when}, NoSystemConfig: true}).
+		RunInDir("")
+	assert.NoError(t, err)
+	assert.Equal(t, "Gitea <gitea@example.com> 1569931200 +0000\n", stdout)
+
+	// the variables of the command take precedence over the given environment
+	stdout, err = NewCommand("var", "GIT_AUTHOR_IDENT").
+		SetEnv(CommandEnvOptions{Author: &Signature{Name: "Gitea", Email: "gitea@example.com", When: when}}).
+		RunInDirWithEnv("", append(os.Environ(), "GIT_AUTHOR_NAME=Other"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stdout, "Gitea <gitea@example.com>"))
+}
+
+func TestCommand_AddDynamicArguments(t *testing.T) {
+	bareRepo1Path := testReposDir + "repo1_bare"
+
+	stdout, err := NewCommand("rev-parse").AddDynamicArguments("master").RunInDir(bareRepo1Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2\n", stdout)
+
+	// an argument which would be parsed as an option is never passed to git
+	_, err = NewCommand("log").AddDynamicArguments("--output=/tmp/gitea-injected").RunInDir(bareRepo1Path)
+	assert.Equal(t, ErrUnsafeArgument{"--output=/tmp/gitea-injected"}, err)
+	_, err = NewCommand("log").AddDynamicArguments("-p").RunInDirReader(bareRepo1Path, 0)
+	assert.True(t, IsErrUnsafeArgument(err))
+	assert.True(t, IsErrUnsafeArgument(NewCommand("log").AddDynamicArguments("-p").RunInDirPipelineWithContext(context.Background(), bareRepo1Path, nil, nil)))
+
+	// values bound to an option and paths after the dashes may start with "-"
+	cmd := NewCommand("log").AddOptionValues("--grep", "-x", "y").AddDashesAndList("-file")
+	assert.Equal(t, []string{"log", "--grep=-x", "--grep=y", "--", "-file"}, cmd.args[len(cmd.args)-5:])
+	_, err = cmd.RunInDir(bareRepo1Path)
+	assert.NoError(t, err)
+}
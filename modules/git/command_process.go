@@ -0,0 +1,33 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"context"
+	"os/exec"
+)
+
+// startProcess starts cmd in a process group of its own and kills the whole group once ctx is done,
+// so the helpers spawned by git, like ssh, gpg or filters, do not linger and keep holding the locks
+// of the repository. The returned function must be called once cmd has exited.
+func startProcess(ctx context.Context, cmd *exec.Cmd) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd.Process)
+		case <-exited:
+		}
+	}()
+	return func() { close(exited) }, nil
+}
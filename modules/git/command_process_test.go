@@ -0,0 +1,48 @@
+// +build !windows
+
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand_KillProcessGroup(t *testing.T) {
+	// the alias runs a shell, whose sleep keeps stdout open unless it is killed as well
+	cmd := NewCommand("-c", "alias.linger=!sleep 10; echo done", "linger")
+
+	start := time.Now()
+	_, err := cmd.RunInDirTimeoutEnvWithContext(context.Background(), nil, 100*time.Millisecond, "")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	reader, err := cmd.RunInDirReaderWithContext(ctx, "", 0)
+	if assert.NoError(t, err) {
+		_, err = reader.Read(make([]byte, 1))
+		assert.Error(t, err)
+		assert.NoError(t, reader.Close())
+	}
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestPipedCommand_CloseWedged(t *testing.T) {
+	defer func(timeout time.Duration) { pipedCommandExitTimeout = timeout }(pipedCommandExitTimeout)
+	pipedCommandExitTimeout = 100 * time.Millisecond
+
+	// the sleep does not read its stdin, so closing it does not make the command exit
+	proc, err := NewCommand("-c", "alias.linger=!sleep 10; echo done", "linger").startPiped(nil, "")
+	assert.NoError(t, err)
+	start := time.Now()
+	assert.Error(t, proc.close())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
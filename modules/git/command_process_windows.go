@@ -0,0 +1,37 @@
+// +build windows
+
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the length of paths from which Windows needs the long path prefix
+const maxPath = 248
+
+// setProcessGroup does nothing, killing the children of a process on Windows needs job objects
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(p *os.Process) {
+	_ = p.Kill()
+}
+
+// longPath prefixes long absolute directories with \\?\ (or \\?\UNC\ for network shares),
+// so repositories nested deeply can be used as the working directory of git
+func longPath(dir string) string {
+	if len(dir) < maxPath || !filepath.IsAbs(dir) || strings.HasPrefix(dir, `\\?\`) {
+		return dir
+	}
+	dir = filepath.Clean(dir)
+	if strings.HasPrefix(dir, `\\`) {
+		return `\\?\UNC\` + dir[2:]
+	}
+	return `\\?\` + dir
+}
@@ -0,0 +1,25 @@
+// +build windows
+
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongPath(t *testing.T) {
+	assert.Equal(t, `C:\repos\user\repo.git`, longPath(`C:\repos\user\repo.git`))
+
+	long := `C:\repos\` + strings.Repeat("a", maxPath) + `\repo.git`
+	assert.Equal(t, `\\?\`+long, longPath(long))
+	assert.Equal(t, `\\?\`+long, longPath(`\\?\`+long))
+
+	unc := `\\server\share\` + strings.Repeat("a", maxPath) + `\repo.git`
+	assert.Equal(t, `\\?\UNC\server\share\`+strings.Repeat("a", maxPath)+`\repo.git`, longPath(unc))
+}
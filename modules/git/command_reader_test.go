@@ -0,0 +1,48 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInDirReader(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	rd, err := NewCommand("cat-file", "-p", "master:file1.txt").RunInDirReader(bareRepo1Path, 0)
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.Equal(t, "file1\n", string(data))
+	assert.NoError(t, rd.Close())
+
+	// the output is cut at the limit
+	rd, err = NewCommand("log", "--format=%H").RunInDirReader(bareRepo1Path, 10)
+	assert.NoError(t, err)
+	data, err = ioutil.ReadAll(rd)
+	assert.True(t, IsErrOutputTooLarge(err))
+	assert.Equal(t, "feaf4ba6bc", string(data))
+	assert.NoError(t, rd.Close())
+
+	// failing commands are reported instead of the end of the output
+	rd, err = NewCommand("cat-file", "-p", "master:does-not-exist").RunInDirReader(bareRepo1Path, 0)
+	assert.NoError(t, err)
+	_, err = ioutil.ReadAll(rd)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does-not-exist")
+	}
+	assert.NoError(t, rd.Close())
+
+	// commands may be stopped before their output is read
+	rd, err = NewCommand("log", "--format=%H").RunInDirReader(bareRepo1Path, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, rd.Close())
+	_, err = rd.Read(make([]byte, 10))
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CommandStats describes a finished git command
+type CommandStats struct {
+	// Verb is the git subcommand, e.g. "log"
+	Verb     string
+	Duration time.Duration
+	// ExitCode is the exit code of the command, -1 if it has been killed or could not be run
+	ExitCode int
+	// OutputSize is the number of bytes written to stdout
+	OutputSize int64
+}
+
+// commandObserver holds the func(CommandStats) set by SetCommandObserver
+var commandObserver atomic.Value
+
+// SetCommandObserver sets the function called after every git command run through a Command,
+// e.g. to record metrics
+func SetCommandObserver(observer func(CommandStats)) {
+	commandObserver.Store(observer)
+}
+
+func isCommandObserved() bool {
+	observer, _ := commandObserver.Load().(func(CommandStats))
+	return observer != nil
+}
+
+// observe ends the span of the command, audits it and reports the command which has been started
+// at start to the command observer
+func (c *Command) observe(dir string, start time.Time, span Span, err error, outputSize int64) {
+	duration := time.Since(start)
+	span.End(err)
+	c.audit(dir, duration, err)
+	observer, _ := commandObserver.Load().(func(CommandStats))
+	if observer == nil {
+		return
+	}
+	observer(CommandStats{
+		Verb:       commandVerb(c.args),
+		Duration:   duration,
+		ExitCode:   exitCode(err),
+		OutputSize: outputSize,
+	})
+}
+
+// commandVerb returns the subcommand of the git arguments, skipping the global options
+func commandVerb(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-c" || args[i] == "-C":
+			i++
+		case strings.HasPrefix(args[i], "-"):
+		default:
+			return args[i]
+		}
+	}
+	return ""
+}
+
+func exitCode(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *exec.ExitError:
+		return err.ExitCode()
+	case CommandError:
+		return err.ExitCode
+	}
+	return -1
+}
+
+// countingWriter counts the bytes written to the command output
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from the output of a piped command
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -93,6 +93,15 @@ func convertCommit(c *object.Commit) *Commit {
 	}
 }
 
+// LoadTree reads the tree object of the commit. This is done when the entries of the tree are
+// first needed, so it only has to be called to check the tree can be read.
+func (c *Commit) LoadTree() error {
+	if c.Tree.gogitTree != nil {
+		return nil
+	}
+	return c.Tree.loadTreeObject()
+}
+
 // Message returns the commit message. Same as retrieving CommitMessage directly.
 func (c *Commit) Message() string {
 	return c.CommitMessage
@@ -200,25 +209,78 @@ func CommitChanges(repoPath string, opts CommitChangesOptions) error {
 
 	_, err := cmd.RunInDir(repoPath)
 	// No stderr but exit status 1 means nothing to commit.
-	if err != nil && err.Error() == "exit status 1" {
+	if cmdErr, ok := err.(CommandError); ok && cmdErr.ExitCode == 1 && cmdErr.Stderr == "" {
 		return nil
 	}
 	return err
 }
 
-func commitsCount(repoPath, revision, relpath string) (int64, error) {
+// CommitsCountOptions options when counting commits
+type CommitsCountOptions struct {
+	// Revisions are the revisions, or revision ranges like a..b, whose history is counted
+	Revisions []string
+	// Not are the revisions whose history is excluded
+	Not []string
+	// Paths limit the count to the commits changing any of the paths
+	Paths       []string
+	FirstParent bool
+	NoMerges    bool
+}
+
+// CommitsCountWithOptions returns the number of commits matching the options. Git counts them
+// itself, so the commits are never listed.
+func CommitsCountWithOptions(repoPath string, opts CommitsCountOptions) (int64, error) {
+	if len(opts.Revisions) == 0 {
+		return 0, fmt.Errorf("no revision to count the commits of")
+	}
+
 	cmd := NewCommand("rev-list", "--count")
-	cmd.AddArguments(revision)
-	if len(relpath) > 0 {
-		cmd.AddArguments("--", relpath)
+	// the reachability bitmaps can only count whole histories
+	if !opts.FirstParent && !opts.NoMerges && len(opts.Paths) == 0 {
+		cmd.AddArguments("--use-bitmap-index")
+	}
+	if opts.FirstParent {
+		cmd.AddArguments("--first-parent")
+	}
+	if opts.NoMerges {
+		cmd.AddArguments("--no-merges")
+	}
+	for _, revision := range opts.Revisions {
+		if strings.HasPrefix(revision, "-") {
+			return 0, fmt.Errorf("invalid revision: %s", revision)
+		}
+		cmd.AddArguments(revision)
+	}
+	for _, revision := range opts.Not {
+		if strings.HasPrefix(revision, "-") {
+			return 0, fmt.Errorf("invalid revision: %s", revision)
+		}
+		cmd.AddArguments("^" + revision)
+	}
+	if len(opts.Paths) > 0 {
+		cmd.AddArguments("--")
+		cmd.AddArguments(opts.Paths...)
 	}
 
-	stdout, err := cmd.RunInDir(repoPath)
+	count, _, err := queries.Do(queryKey(repoPath, "rev-list", cmd.args...), func() (interface{}, error) {
+		stdout, err := cmd.RunInDir(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+	})
 	if err != nil {
 		return 0, err
 	}
+	return count.(int64), nil
+}
 
-	return strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+func commitsCount(repoPath, revision, relpath string) (int64, error) {
+	opts := CommitsCountOptions{Revisions: []string{revision}}
+	if len(relpath) > 0 {
+		opts.Paths = []string{relpath}
+	}
+	return CommitsCountWithOptions(repoPath, opts)
 }
 
 // CommitsCount returns number of total commits of until given revision.
@@ -421,7 +483,7 @@ func GetCommitFileStatus(repoPath, commitID string) (*CommitFileStatus, error) {
 	}()
 
 	stderr := new(bytes.Buffer)
-	err := NewCommand("show", "--name-status", "--pretty=format:''", commitID).RunInDirPipeline(repoPath, w, stderr)
+	err := NewCommand("show", "--name-status", "--pretty=format:''").AddDynamicArguments(commitID).RunInDirPipeline(repoPath, w, stderr)
 	w.Close() // Close writer to exit parsing goroutine
 	if err != nil {
 		return nil, concatenateError(err, stderr.String())
@@ -437,9 +499,9 @@ func GetFullCommitID(repoPath, shortID string) (string, error) {
 		return shortID, nil
 	}
 
-	commitID, err := NewCommand("rev-parse", shortID).RunInDir(repoPath)
+	commitID, err := NewCommand("rev-parse").AddDynamicArguments(shortID).RunInDir(repoPath)
 	if err != nil {
-		if strings.Contains(err.Error(), "exit status 128") {
+		if cmdErr, ok := err.(CommandError); (ok && cmdErr.ExitCode == 128) || IsErrUnsafeArgument(err) {
 			return "", ErrNotExist{shortID, ""}
 		}
 		return "", err
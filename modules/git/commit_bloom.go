@@ -0,0 +1,134 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+const (
+	// bloomBitsPerEntry is the number of bits of a filter per changed path, as written by git
+	bloomBitsPerEntry = 10
+	// bloomMaxChangedPaths is the number of changed paths above which git writes a filter
+	// containing every path
+	bloomMaxChangedPaths = 512
+)
+
+// computedBloomSettings are the settings of the filters computed for commits which are not
+// in the commit-graph, the defaults of git
+var computedBloomSettings = bloomSettings{hashVersion: 2, numHashes: 7}
+
+// computeBloomFilter returns the changed-path Bloom filter of the commit compared to its first parent
+// like git writes it to the commit-graph, using computedBloomSettings
+func computeBloomFilter(s storer.EncodedObjectStorer, id plumbing.Hash) ([]byte, error) {
+	commit, err := object.GetCommit(s, id)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changed := make(map[string]bool)
+	if err := addChangedPaths(s, changed, "", parentTree, tree); err != nil {
+		return nil, err
+	}
+	if len(changed) > bloomMaxChangedPaths {
+		// every bit is set, so the filter contains all paths
+		return []byte{0xff}, nil
+	}
+
+	numBytes := (len(changed)*bloomBitsPerEntry + 7) / 8
+	if numBytes == 0 {
+		// no bit is set, so the filter contains no path
+		numBytes = 1
+	}
+	filter := make([]byte, numBytes)
+	numBits := uint64(numBytes) * 8
+	for p := range changed {
+		hash0 := murmur3([]byte(p), 0x293ae76f, false)
+		hash1 := murmur3([]byte(p), 0x7e646e2c, false)
+		for i := uint32(0); i < computedBloomSettings.numHashes; i++ {
+			pos := uint64(hash0+i*hash1) % numBits
+			filter[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	return filter, nil
+}
+
+// addChangedPaths adds the paths below prefix which differ between the trees to changed, and the
+// directories leading to them. A nil tree is empty. It stops once there are more than
+// bloomMaxChangedPaths paths.
+func addChangedPaths(s storer.EncodedObjectStorer, changed map[string]bool, prefix string, from, to *object.Tree) error {
+	fromEntries := make(map[string]object.TreeEntry)
+	if from != nil {
+		for _, entry := range from.Entries {
+			fromEntries[entry.Name] = entry
+		}
+	}
+	toEntries := make(map[string]object.TreeEntry)
+	if to != nil {
+		for _, entry := range to.Entries {
+			toEntries[entry.Name] = entry
+			if fromEntry, ok := fromEntries[entry.Name]; !ok || fromEntry.Hash != entry.Hash || fromEntry.Mode != entry.Mode {
+				if err := addChangedPath(s, changed, prefix, fromEntries[entry.Name], entry); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, entry := range fromEntries {
+		if _, ok := toEntries[name]; !ok {
+			if err := addChangedPath(s, changed, prefix, entry, object.TreeEntry{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addChangedPath adds the path of the changed entry, which is missing on one side if it has a zero hash,
+// and recurses into the changed directories
+func addChangedPath(s storer.EncodedObjectStorer, changed map[string]bool, prefix string, from, to object.TreeEntry) error {
+	if len(changed) > bloomMaxChangedPaths {
+		return nil
+	}
+	name := from.Name
+	if name == "" {
+		name = to.Name
+	}
+	changed[prefix+name] = true
+
+	var fromTree, toTree *object.Tree
+	var err error
+	if from.Mode == filemode.Dir {
+		if fromTree, err = object.GetTree(s, from.Hash); err != nil {
+			return err
+		}
+	}
+	if to.Mode == filemode.Dir {
+		if toTree, err = object.GetTree(s, to.Hash); err != nil {
+			return err
+		}
+	}
+	if fromTree == nil && toTree == nil {
+		return nil
+	}
+	return addChangedPaths(s, changed, prefix+name+"/", fromTree, toTree)
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"container/list"
+	"sync"
+)
+
+var (
+	// CommitCacheMaxItems is the maximum number of parsed commits kept in the commit cache,
+	// the cache is disabled if it is 0
+	CommitCacheMaxItems = 10000
+	// CommitCacheMaxMemory is the approximate maximum number of bytes used by the commit cache,
+	// it is not limited if it is 0
+	CommitCacheMaxMemory int64 = 32 * 1024 * 1024
+)
+
+// commitCacheEntryOverhead approximates the memory used by a cached commit besides its strings
+const commitCacheEntryOverhead = 512
+
+// commitCache is a LRU cache of parsed commits shared by all repositories. Commits are
+// immutable, so entries are keyed by the repository path and the object ID and never invalidated.
+type commitCache struct {
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+type commitCacheEntry struct {
+	key    string
+	commit *Commit
+	size   int64
+}
+
+var globalCommitCache = newCommitCache()
+
+func newCommitCache() *commitCache {
+	return &commitCache{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func commitCacheKey(repoPath string, id SHA1) string {
+	return repoPath + "\x00" + string(id[:])
+}
+
+// Get returns a copy of the cached commit bound to repo, or nil if it is not cached
+func (c *commitCache) Get(repo *Repository, id SHA1) *Commit {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[commitCacheKey(repo.Path, id)]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+
+	commit := *elem.Value.(*commitCacheEntry).commit
+	commit.repo = repo
+	commit.Tree.repo = repo
+	return &commit
+}
+
+// Set stores a copy of the commit, evicting the least recently used commits if needed
+func (c *commitCache) Set(repo *Repository, id SHA1, commit *Commit) {
+	maxItems, maxMemory := CommitCacheMaxItems, CommitCacheMaxMemory
+	if maxItems <= 0 {
+		return
+	}
+
+	// the tree object and the repository are not kept, the tree is loaded again when needed
+	cached := &Commit{
+		Tree:          Tree{ID: commit.Tree.ID, ResolvedID: commit.Tree.ResolvedID},
+		ID:            commit.ID,
+		Author:        commit.Author,
+		Committer:     commit.Committer,
+		CommitMessage: commit.CommitMessage,
+		Signature:     commit.Signature,
+		parents:       commit.parents,
+	}
+	entry := &commitCacheEntry{
+		key:    commitCacheKey(repo.Path, id),
+		commit: cached,
+		size:   commitSize(cached),
+	}
+	if maxMemory > 0 && entry.size > maxMemory {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.lru.Len() > maxItems || (maxMemory > 0 && c.size > maxMemory) {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *commitCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*commitCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// Len returns the number of cached commits
+func (c *commitCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// commitSize approximates the number of bytes used by the commit
+func commitSize(commit *Commit) int64 {
+	size := commitCacheEntryOverhead + len(commit.CommitMessage) + len(commit.parents)*len(SHA1{})
+	for _, sig := range []*Signature{commit.Author, commit.Committer} {
+		if sig != nil {
+			size += len(sig.Name) + len(sig.Email)
+		}
+	}
+	if commit.Signature != nil {
+		size += len(commit.Signature.Signature) + len(commit.Signature.Payload)
+	}
+	return int64(size)
+}
@@ -5,62 +5,132 @@
 package git
 
 import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/emirpasic/gods/trees/binaryheap"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	cgobject "gopkg.in/src-d/go-git.v4/plumbing/object/commitgraph"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
 
+// CommitsInfoWorkers is the maximum number of commits whose parent trees are
+// hashed concurrently while looking up the last commits of tree entries
+var CommitsInfoWorkers = 4
+
+// LastCommitsLimits caps the history walked to look up the last commits of tree entries,
+// zero values mean no limit
+type LastCommitsLimits struct {
+	// MaxCommits is the maximum number of commits examined
+	MaxCommits int
+	// MaxDuration is the maximum time spent walking the history
+	MaxDuration time.Duration
+	// Deadline is the time at which the walk stops, e.g. when the page showing the results is due
+	Deadline time.Time
+}
+
+// DefaultLastCommitsLimits are the limits of the history walk of GetCommitsInfo
+var DefaultLastCommitsLimits LastCommitsLimits
+
+func (l LastCommitsLimits) reached(examined int, start time.Time) bool {
+	return (l.MaxCommits > 0 && examined >= l.MaxCommits) ||
+		(l.MaxDuration > 0 && time.Since(start) >= l.MaxDuration) ||
+		(!l.Deadline.IsZero() && !time.Now().Before(l.Deadline))
+}
+
+// CommitInfo describes the last commit that touched a tree entry
+type CommitInfo struct {
+	Entry         *TreeEntry
+	Commit        *Commit
+	SubModuleFile *SubModuleFile
+	// Unknown is set if the last commit has not been found within the limits of the history walk
+	Unknown bool
+}
+
 // GetCommitsInfo gets information of all commits that are corresponding to these entries
-func (tes Entries) GetCommitsInfo(commit *Commit, treePath string, cache LastCommitCache) ([][]interface{}, *Commit, error) {
-	entryPaths := make([]string, len(tes)+1)
-	// Get the commit for the treePath itself
-	entryPaths[0] = ""
-	for i, entry := range tes {
-		entryPaths[i+1] = entry.Name()
-	}
+func (tes Entries) GetCommitsInfo(commit *Commit, treePath string, cache LastCommitCache) ([]CommitInfo, *Commit, error) {
+	return tes.GetCommitsInfoSubset(commit, treePath, nil, cache)
+}
 
-	commitNodeIndex, commitGraphFile := commit.repo.CommitNodeIndex()
-	if commitGraphFile != nil {
-		defer commitGraphFile.Close()
-	}
+// GetCommitsInfoSubset works like GetCommitsInfo, but only looks up the last commits
+// for the entries named in names (e.g. the currently rendered page of a huge directory).
+// All entries are still returned, those not in names are left without commit information.
+// A nil names looks up the commits for all entries.
+func (tes Entries) GetCommitsInfoSubset(commit *Commit, treePath string, names []string, cache LastCommitCache) ([]CommitInfo, *Commit, error) {
+	return tes.GetCommitsInfoContext(context.Background(), commit, treePath, names, cache)
+}
 
-	c, err := commitNodeIndex.Get(commit.ID)
-	if err != nil {
-		return nil, nil, err
+// GetCommitsInfoContext works like GetCommitsInfoSubset, but gives up walking the history
+// and returns the context error once ctx is cancelled or its deadline is exceeded.
+func (tes Entries) GetCommitsInfoContext(ctx context.Context, commit *Commit, treePath string, names []string, cache LastCommitCache) ([]CommitInfo, *Commit, error) {
+	return tes.GetCommitsInfoLimits(ctx, commit, treePath, names, cache, DefaultLastCommitsLimits)
+}
+
+// GetCommitsInfoLimits works like GetCommitsInfoContext, but stops walking the history once
+// the limits are reached. The entries whose last commit has not been found by then are marked
+// as Unknown, the returned commit of the tree itself is nil if it has not been found.
+func (tes Entries) GetCommitsInfoLimits(ctx context.Context, commit *Commit, treePath string, names []string, cache LastCommitCache, limits LastCommitsLimits) ([]CommitInfo, *Commit, error) {
+	ctx, span := startSpan(ctx, "GetCommitsInfo", "git.repo_path", commit.repo.Path, "git.commit", commit.ID.String(), "git.tree_path", treePath)
+	commitsInfo, treeCommit, err := tes.getCommitsInfoLimits(ctx, commit, treePath, names, cache, limits)
+	span.End(err)
+	return commitsInfo, treeCommit, err
+}
+
+func (tes Entries) getCommitsInfoLimits(ctx context.Context, commit *Commit, treePath string, names []string, cache LastCommitCache, limits LastCommitsLimits) ([]CommitInfo, *Commit, error) {
+	// Get the commit for the treePath itself
+	entryPaths := []string{""}
+	if names == nil {
+		for _, entry := range tes {
+			entryPaths = append(entryPaths, entry.Name())
+		}
+	} else {
+		entryPaths = append(entryPaths, names...)
 	}
 
-	revs, err := getLastCommitForPaths(c, treePath, entryPaths)
+	revs, complete, err := getLastCommitsCached(ctx, commit, treePath, entryPaths, cache, limits)
 	if err != nil {
 		return nil, nil, err
 	}
+	var requested map[string]bool
+	if names != nil {
+		requested = make(map[string]bool, len(names))
+		for _, name := range names {
+			requested[name] = true
+		}
+	}
 
-	commit.repo.gogitStorage.Close()
-
-	commitsInfo := make([][]interface{}, len(tes))
+	commitsInfo := make([]CommitInfo, len(tes))
 	for i, entry := range tes {
-		if rev, ok := revs[entry.Name()]; ok {
-			entryCommit := convertCommit(rev)
-			if entry.IsSubModule() {
-				subModuleURL := ""
-				var fullPath string
-				if len(treePath) > 0 {
-					fullPath = treePath + "/" + entry.Name()
-				} else {
-					fullPath = entry.Name()
-				}
-				if subModule, err := commit.GetSubModule(fullPath); err != nil {
-					return nil, nil, err
-				} else if subModule != nil {
-					subModuleURL = subModule.URL
-				}
-				subModuleFile := NewSubModuleFile(entryCommit, subModuleURL, entry.ID.String())
-				commitsInfo[i] = []interface{}{entry, subModuleFile}
+		commitsInfo[i] = CommitInfo{
+			Entry: entry,
+		}
+		entryCommit, ok := revs[entry.Name()]
+		if ok {
+			commitsInfo[i].Commit = entryCommit
+		} else if !complete && (requested == nil || requested[entry.Name()]) {
+			commitsInfo[i].Unknown = true
+		}
+		// The URL and commit of a submodule are known from the tree commit, even if the
+		// last commit of the entry is not
+		if entry.IsSubModule() {
+			subModuleURL := ""
+			var fullPath string
+			if len(treePath) > 0 {
+				fullPath = treePath + "/" + entry.Name()
 			} else {
-				commitsInfo[i] = []interface{}{entry, entryCommit}
+				fullPath = entry.Name()
+			}
+			if subModule, err := commit.GetSubModule(fullPath); err != nil {
+				return nil, nil, err
+			} else if subModule != nil {
+				subModuleURL = subModule.URL
 			}
-		} else {
-			commitsInfo[i] = []interface{}{entry, nil}
+			commitsInfo[i].SubModuleFile = NewSubModuleFile(entryCommit, subModuleURL, entry.ID.String())
 		}
 	}
 
@@ -71,17 +141,119 @@ func (tes Entries) GetCommitsInfo(commit *Commit, treePath string, cache LastCom
 	if treePath == "" {
 		treeCommit = commit
 	} else if rev, ok := revs[""]; ok {
-		treeCommit = convertCommit(rev)
+		treeCommit = rev
 	}
 	return commitsInfo, treeCommit, nil
 }
 
+// lastCommitsResult is the result of a lookup of the last commits of paths
+type lastCommitsResult struct {
+	commits  map[string]*object.Commit
+	complete bool
+	// deadline is the deadline the lookup ran with
+	deadline time.Time
+}
+
+// lastCommitsQueryKey returns the key of concurrent lookups of the last commits which share their
+// result. The deadline differs for every caller, so it is not part of the key and the result of a
+// lookup is shared with the callers arriving before it finishes, see getLastCommitsCached.
+func lastCommitsQueryKey(commit *Commit, treePath string, paths []string, limits LastCommitsLimits) string {
+	return queryKey(commit.repo.Path, "last-commits", append([]string{commit.ID.String(), treePath,
+		strconv.Itoa(limits.MaxCommits), limits.MaxDuration.String()}, paths...)...)
+}
+
+// getLastCommitsCached returns the last commits of the paths below treePath and whether all of them
+// have been found within the limits. The commits found in the cache are used as they are, only the
+// remaining ones are looked up and then added to it.
+func getLastCommitsCached(ctx context.Context, commit *Commit, treePath string, paths []string, cache LastCommitCache, limits LastCommitsLimits) (map[string]*Commit, bool, error) {
+	revs := make(map[string]*Commit, len(paths))
+	missing := paths
+	if cache != nil {
+		missing = make([]string, 0, len(paths))
+		for _, p := range paths {
+			// a failing cache is treated like a cache miss
+			cached, err := cache.Get(commit.repo.Path, commit.ID.String(), path.Join(treePath, p))
+			if err != nil {
+				log("LastCommitCache.Get: %v", err)
+			}
+			if err == nil && cached != nil {
+				cached.repo = commit.repo
+				revs[p] = cached
+			} else {
+				missing = append(missing, p)
+			}
+		}
+		if len(missing) == 0 {
+			return revs, true, nil
+		}
+	}
+
+	lookup := func() (interface{}, error) {
+		commitNodeIndex, graph := commit.repo.commitNodeIndex()
+		var bloom *commitGraph
+		if graph != nil {
+			defer graph.Close()
+
+			if graph.hasBloomFilters() {
+				bloom = graph
+			}
+		}
+		// the filters of the commits missing from the commit-graph are kept in the cache
+		filters, _ := cache.(BloomFilterCache)
+
+		c, err := commitNodeIndex.Get(commit.ID)
+		if err != nil {
+			return nil, err
+		}
+		commits, complete, err := getLastCommitForPaths(ctx, commit.repo, c, bloom, filters, treePath, missing, limits)
+		if err != nil {
+			return nil, err
+		}
+		return &lastCommitsResult{commits: commits, complete: complete, deadline: limits.Deadline}, nil
+	}
+
+	found, shared, err := queries.Do(lastCommitsQueryKey(commit, treePath, missing, limits), lookup)
+	if shared && err != nil && ctx.Err() == nil && (err == context.Canceled || err == context.DeadlineExceeded) {
+		// the lookup ran with the context of another caller, which has been cancelled
+		found, err = lookup()
+	} else if shared && err == nil && limits.Deadline.IsZero() {
+		if result := found.(*lastCommitsResult); !result.complete && !result.deadline.IsZero() {
+			// the lookup stopped at the deadline of another caller, but this one has none. Callers
+			// with a deadline take the shared result, which is due no later than the deadlines of
+			// the callers arriving after the one running the lookup.
+			found, err = lookup()
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := found.(*lastCommitsResult)
+	for p, rev := range result.commits {
+		revs[p] = convertCommit(rev)
+		revs[p].repo = commit.repo
+		if cache != nil {
+			if err := cache.Put(commit.repo.Path, commit.ID.String(), path.Join(treePath, p), revs[p]); err != nil {
+				log("LastCommitCache.Put: %v", err)
+			}
+		}
+	}
+	return revs, result.complete, nil
+}
+
 type commitAndPaths struct {
 	commit cgobject.CommitNode
 	// Paths that are still on the branch represented by commit
 	paths []string
 	// Set of hashes for the paths
 	hashes map[string]plumbing.Hash
+	// Hash of the tree at the tree path, zero if it is not known
+	treeHash plumbing.Hash
+	// Parents of commit, their tree hashes and the hashes of paths in them, set once resolved
+	parents          []cgobject.CommitNode
+	parentTreeHashes []plumbing.Hash
+	parentHashes     []map[string]plumbing.Hash
+	resolved         bool
 }
 
 func getCommitTree(c cgobject.CommitNode, treePath string) (*object.Tree, error) {
@@ -101,32 +273,234 @@ func getCommitTree(c cgobject.CommitNode, treePath string) (*object.Tree, error)
 	return tree, nil
 }
 
-func getFileHashes(c cgobject.CommitNode, treePath string, paths []string) (map[string]plumbing.Hash, error) {
+func getFileHashes(c cgobject.CommitNode, treePath string, paths []string) (plumbing.Hash, map[string]plumbing.Hash, error) {
 	tree, err := getCommitTree(c, treePath)
+	return getTreeFileHashes(tree, err, plumbing.ZeroHash, nil, paths)
+}
+
+// getTreeFileHashes returns the hash of the tree and the hashes of the paths in it. If the tree of
+// a child commit and the hashes of the paths in it are given, the tree is compared against it the way
+// a diff restricted to the tree path does: identical trees have identical entries, so they are not
+// looked at. Otherwise all paths are looked up in a single pass over the entries of the tree.
+func getTreeFileHashes(tree *object.Tree, err error, childTreeHash plumbing.Hash, childHashes map[string]plumbing.Hash, paths []string) (plumbing.Hash, map[string]plumbing.Hash, error) {
 	if err == object.ErrDirectoryNotFound {
 		// The whole tree didn't exist, so return empty map
-		return make(map[string]plumbing.Hash), nil
+		return plumbing.ZeroHash, make(map[string]plumbing.Hash), nil
 	}
 	if err != nil {
-		return nil, err
+		return plumbing.ZeroHash, nil, err
+	}
+	if childHashes != nil && !childTreeHash.IsZero() && tree.Hash == childTreeHash {
+		return tree.Hash, childHashes, nil
 	}
 
-	hashes := make(map[string]plumbing.Hash)
+	hashes := make(map[string]plumbing.Hash, len(paths))
+	names := make(map[string]bool, len(paths))
 	for _, path := range paths {
-		if path != "" {
-			entry, err := tree.FindEntry(path)
-			if err == nil {
+		switch {
+		case path == "":
+			hashes[path] = tree.Hash
+		case strings.Contains(path, "/"):
+			// not an entry of the tree itself
+			if entry, err := tree.FindEntry(path); err == nil {
 				hashes[path] = entry.Hash
 			}
-		} else {
-			hashes[path] = tree.Hash
+		default:
+			names[path] = true
 		}
 	}
+	if len(names) > 0 {
+		for _, entry := range tree.Entries {
+			if names[entry.Name] {
+				hashes[entry.Name] = entry.Hash
+			}
+		}
+	}
+
+	return tree.Hash, hashes, nil
+}
 
-	return hashes, nil
+// parentHasher computes the hashes of paths in parent commits. The go-git storage
+// of a repository serializes the object reads, so every worker reads the objects
+// through a storage of its own.
+type parentHasher struct {
+	treePath string
+	storages chan *filesystem.Storage
+	// bloom are the changed-path Bloom filters of the commit-graph, if any
+	bloom *commitGraph
+	// filters stores the filters computed for the commits without one in the commit-graph, if any
+	filters BloomFilterCache
 }
 
-func getLastCommitForPaths(c cgobject.CommitNode, treePath string, paths []string) (map[string]*object.Commit, error) {
+func newParentHasher(repo *Repository, treePath string, workers int, bloom *commitGraph, filters BloomFilterCache) *parentHasher {
+	h := &parentHasher{
+		treePath: treePath,
+		storages: make(chan *filesystem.Storage, workers),
+		bloom:    bloom,
+		filters:  filters,
+	}
+	for i := 0; i < workers; i++ {
+		h.storages <- newStorage(repo.gogitStorage.Filesystem(), WalkObjectCacheMaxMemory)
+	}
+	return h
+}
+
+// fileHashes returns the tree hash and the hashes of the paths of the parent id of child
+func (h *parentHasher) fileHashes(id plumbing.Hash, child *commitAndPaths) (plumbing.Hash, map[string]plumbing.Hash, error) {
+	storage := <-h.storages
+	defer func() { h.storages <- storage }()
+
+	commit, err := object.GetCommit(storage, id)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+	tree, err := commit.Tree()
+	if err == nil && h.treePath != "" {
+		tree, err = tree.Tree(h.treePath)
+	}
+	return getTreeFileHashes(tree, err, child.treeHash, child.hashes, child.paths)
+}
+
+// pathsUnchanged returns true if the Bloom filter of the commit shows that none of its paths
+// differ from the first parent. The filter is read from the commit-graph, or else from the filters
+// computed before. If there is none it is computed and stored, so the next walks can use it.
+func (h *parentHasher) pathsUnchanged(current *commitAndPaths) (bool, error) {
+	id := current.commit.ID()
+	if h.bloom != nil {
+		filter, settings, err := h.bloom.filter(id)
+		if err != nil || filter != nil {
+			return err == nil && settings.pathsUnchanged(filter, h.treePath, current.paths), err
+		}
+	}
+	if h.filters == nil {
+		return false, nil
+	}
+
+	// a failing cache is treated like a cache miss
+	filter, err := h.filters.GetBloomFilter(id.String())
+	if err != nil {
+		log("BloomFilterCache.GetBloomFilter: %v", err)
+	}
+	if len(filter) == 0 {
+		storage := <-h.storages
+		filter, err = computeBloomFilter(storage, id)
+		h.storages <- storage
+		if err != nil {
+			return false, err
+		}
+		if err := h.filters.PutBloomFilter(id.String(), filter); err != nil {
+			log("BloomFilterCache.PutBloomFilter: %v", err)
+		}
+	}
+	return computedBloomSettings.pathsUnchanged(filter, h.treePath, current.paths), nil
+}
+
+// resolve loads the parents of the given commits and hashes their paths, spreading
+// the tree lookups over the workers.
+func (h *parentHasher) resolve(ctx context.Context, batch []*commitAndPaths) error {
+	// The filters are read or computed concurrently, they do not load commit nodes
+	unchanged := make([]bool, len(batch))
+	if h.bloom != nil || h.filters != nil {
+		var wg sync.WaitGroup
+		errs := make([]error, len(batch))
+		for i, current := range batch {
+			if current.commit.NumParents() == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, current *commitAndPaths) {
+				defer wg.Done()
+				if ctx.Err() != nil {
+					return
+				}
+				unchanged[i], errs[i] = h.pathsUnchanged(current)
+			}(i, current)
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Commit nodes share the storage of the repository, so load them here
+	for b, current := range batch {
+		numParents := current.commit.NumParents()
+		if unchanged[b] {
+			// None of the paths differ from the first parent, so its hashes are known and
+			// the search continues there only.
+			parent, err := current.commit.ParentNode(0)
+			if err != nil {
+				return err
+			}
+			current.parents = []cgobject.CommitNode{parent}
+			current.parentTreeHashes = []plumbing.Hash{plumbing.ZeroHash}
+			current.parentHashes = []map[string]plumbing.Hash{current.hashes}
+			current.resolved = true
+			continue
+		}
+		for i := 0; i < numParents; i++ {
+			parent, err := current.commit.ParentNode(i)
+			if err != nil {
+				break
+			}
+			current.parents = append(current.parents, parent)
+		}
+		current.parentTreeHashes = make([]plumbing.Hash, len(current.parents))
+		current.parentHashes = make([]map[string]plumbing.Hash, len(current.parents))
+		current.resolved = true
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	for _, current := range batch {
+		for j, parent := range current.parents {
+			if current.parentHashes[j] != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(current *commitAndPaths, j int, id plumbing.Hash) {
+				defer wg.Done()
+				if ctx.Err() != nil {
+					return
+				}
+				treeHash, hashes, err := h.fileHashes(id, current)
+				lock.Lock()
+				defer lock.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				current.parentTreeHashes[j] = treeHash
+				current.parentHashes[j] = hashes
+			}(current, j, parent.ID())
+		}
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+func (h *parentHasher) Close() {
+	close(h.storages)
+	for storage := range h.storages {
+		storage.Close()
+	}
+}
+
+// getLastCommitForPaths walks the history from c to find the last commits of the paths below treePath.
+// The walk stops once the limits are reached, it then returns the commits found so far and false.
+func getLastCommitForPaths(ctx context.Context, repo *Repository, c cgobject.CommitNode, bloom *commitGraph, filters BloomFilterCache, treePath string, paths []string, limits LastCommitsLimits) (map[string]*object.Commit, bool, error) {
 	// We do a tree traversal with nodes sorted by commit time
 	heap := binaryheap.NewWith(func(a, b interface{}) int {
 		if a.(*commitAndPaths).commit.CommitTime().Before(b.(*commitAndPaths).commit.CommitTime()) {
@@ -135,49 +509,72 @@ func getLastCommitForPaths(c cgobject.CommitNode, treePath string, paths []strin
 		return -1
 	})
 
+	workers := CommitsInfoWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	hasher := newParentHasher(repo, treePath, workers, bloom, filters)
+	defer hasher.Close()
+
 	resultNodes := make(map[string]cgobject.CommitNode)
-	initialHashes, err := getFileHashes(c, treePath, paths)
+	initialTreeHash, initialHashes, err := getFileHashes(c, treePath, paths)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// Start search from the root commit and with full set of paths
-	heap.Push(&commitAndPaths{c, paths, initialHashes})
+	heap.Push(&commitAndPaths{commit: c, paths: paths, hashes: initialHashes, treeHash: initialTreeHash})
 
+	start := time.Now()
+	examined := 0
+	complete := true
 	for {
-		cIn, ok := heap.Pop()
-		if !ok {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		if heap.Empty() {
 			break
 		}
+		if limits.reached(examined, start) {
+			complete = false
+			break
+		}
+		cIn, _ := heap.Pop()
 		current := cIn.(*commitAndPaths)
+		examined++
 
-		// Load the parent commits for the one we are currently examining
-		numParents := current.commit.NumParents()
-		var parents []cgobject.CommitNode
-		for i := 0; i < numParents; i++ {
-			parent, err := current.commit.ParentNode(i)
-			if err != nil {
-				break
+		if !current.resolved {
+			// Resolve the parents of the next few commits in the queue at once. They are
+			// put back and examined in order, so the result does not change.
+			batch := []*commitAndPaths{current}
+			for len(batch) < workers {
+				next, ok := heap.Peek()
+				if !ok || next.(*commitAndPaths).resolved {
+					break
+				}
+				heap.Pop()
+				batch = append(batch, next.(*commitAndPaths))
+			}
+			if err := hasher.resolve(ctx, batch); err != nil {
+				return nil, false, err
+			}
+			for _, other := range batch[1:] {
+				heap.Push(other)
 			}
-			parents = append(parents, parent)
 		}
+		parents := current.parents
+		parentHashes := current.parentHashes
 
 		// Examine the current commit and set of interesting paths
 		pathUnchanged := make([]bool, len(current.paths))
-		parentHashes := make([]map[string]plumbing.Hash, len(parents))
-		for j, parent := range parents {
-			parentHashes[j], err = getFileHashes(parent, treePath, current.paths)
-			if err != nil {
-				break
-			}
-
+		for j := range parents {
 			for i, path := range current.paths {
 				if parentHashes[j][path] == current.hashes[path] {
 					pathUnchanged[i] = true
 				}
 			}
 		}
-
 		var remainingPaths []string
 		for i, path := range current.paths {
 			// The results could already contain some newer change for the same path,
@@ -218,7 +615,7 @@ func getLastCommitForPaths(c cgobject.CommitNode, treePath string, paths []strin
 				}
 
 				if remainingPathsForParent != nil {
-					heap.Push(&commitAndPaths{parent, remainingPathsForParent, parentHashes[j]})
+					heap.Push(&commitAndPaths{commit: parent, paths: remainingPathsForParent, hashes: parentHashes[j], treeHash: current.parentTreeHashes[j]})
 				}
 
 				if len(newRemainingPaths) == 0 {
@@ -236,9 +633,9 @@ func getLastCommitForPaths(c cgobject.CommitNode, treePath string, paths []strin
 		var err error
 		result[path], err = commitNode.Commit()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
-	return result, nil
+	return result, complete, nil
 }
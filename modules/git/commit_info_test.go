@@ -1,12 +1,18 @@
 package git
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 const testReposDir = "tests/repos/"
@@ -26,6 +32,11 @@ func cloneRepo(url, dir, name string) (string, error) {
 }
 
 func testGetCommitsInfo(t *testing.T, repo1 *Repository) {
+	testGetCommitsInfoCache(t, repo1, func() LastCommitCache { return nil })
+}
+
+// testGetCommitsInfoCache looks up the last commits of every test case with a cache returned by newCache
+func testGetCommitsInfoCache(t *testing.T, repo1 *Repository, newCache func() LastCommitCache) {
 	// these test case are specific to the repo1 test repo
 	testCases := []struct {
 		CommitID           string
@@ -57,13 +68,13 @@ func testGetCommitsInfo(t *testing.T, repo1 *Repository) {
 		assert.NoError(t, err)
 		entries, err := tree.ListEntries()
 		assert.NoError(t, err)
-		commitsInfo, treeCommit, err := entries.GetCommitsInfo(commit, testCase.Path, nil)
+		commitsInfo, treeCommit, err := entries.GetCommitsInfo(commit, testCase.Path, newCache())
 		assert.Equal(t, testCase.ExpectedTreeCommit, treeCommit.ID.String())
 		assert.NoError(t, err)
 		assert.Len(t, commitsInfo, len(testCase.ExpectedIDs))
 		for _, commitInfo := range commitsInfo {
-			entry := commitInfo[0].(*TreeEntry)
-			commit := commitInfo[1].(*Commit)
+			entry := commitInfo.Entry
+			commit := commitInfo.Commit
 			expectedID, ok := testCase.ExpectedIDs[entry.Name()]
 			if !assert.True(t, ok) {
 				continue
@@ -87,6 +98,290 @@ func TestEntries_GetCommitsInfo(t *testing.T) {
 	testGetCommitsInfo(t, clonedRepo1)
 }
 
+func TestEntries_GetCommitsInfoWorkers(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	defer func(workers int) {
+		CommitsInfoWorkers = workers
+	}(CommitsInfoWorkers)
+	for _, workers := range []int{0, 1, 8} {
+		CommitsInfoWorkers = workers
+		testGetCommitsInfo(t, bareRepo1)
+	}
+}
+
+func TestEntries_GetCommitsInfoLimits(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	entries, err := commit.Tree.ListEntries()
+	assert.NoError(t, err)
+
+	// only feaf4ba and its parent 37991de, which changed foo, are examined
+	commitsInfo, treeCommit, err := entries.GetCommitsInfoLimits(context.Background(), commit, "", nil, nil, LastCommitsLimits{MaxCommits: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, commit.ID, treeCommit.ID)
+	for _, commitInfo := range commitsInfo {
+		switch commitInfo.Entry.Name() {
+		case "foo":
+			assert.False(t, commitInfo.Unknown)
+			if assert.NotNil(t, commitInfo.Commit) {
+				assert.Equal(t, "37991dec2c8e592043f47155ce4808d4580f9123", commitInfo.Commit.ID.String())
+			}
+		default:
+			assert.True(t, commitInfo.Unknown)
+			assert.Nil(t, commitInfo.Commit)
+		}
+	}
+
+	// entries which have not been asked for are not unknown
+	commitsInfo, _, err = entries.GetCommitsInfoLimits(context.Background(), commit, "", []string{"file1.txt"}, nil, LastCommitsLimits{MaxCommits: 2})
+	assert.NoError(t, err)
+	for _, commitInfo := range commitsInfo {
+		assert.Equal(t, commitInfo.Entry.Name() == "file1.txt", commitInfo.Unknown)
+	}
+
+	commitsInfo, _, err = entries.GetCommitsInfoLimits(context.Background(), commit, "", nil, nil, LastCommitsLimits{MaxDuration: time.Hour})
+	assert.NoError(t, err)
+	for _, commitInfo := range commitsInfo {
+		assert.False(t, commitInfo.Unknown)
+		assert.NotNil(t, commitInfo.Commit)
+	}
+
+	// a passed deadline returns what has been found so far instead of an error
+	commitsInfo, _, err = entries.GetCommitsInfoLimits(context.Background(), commit, "", nil, nil, LastCommitsLimits{Deadline: time.Now().Add(-time.Second)})
+	assert.NoError(t, err)
+	unknown := 0
+	for _, commitInfo := range commitsInfo {
+		if commitInfo.Unknown {
+			unknown++
+			assert.Nil(t, commitInfo.Commit)
+		}
+	}
+	assert.NotZero(t, unknown)
+
+	commitsInfo, _, err = entries.GetCommitsInfoLimits(context.Background(), commit, "", nil, nil, LastCommitsLimits{Deadline: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	for _, commitInfo := range commitsInfo {
+		assert.False(t, commitInfo.Unknown)
+		assert.NotNil(t, commitInfo.Commit)
+	}
+}
+
+func TestEntries_GetCommitsInfoSubModuleUnknown(t *testing.T) {
+	repoPath, err := ioutil.TempDir("", "repo-submodule")
+	assert.NoError(t, err)
+	defer os.RemoveAll(repoPath)
+	assert.NoError(t, InitRepository(repoPath, true))
+	repo, err := OpenRepository(repoPath)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	gitmodules, err := repo.HashObject(strings.NewReader("[submodule \"sub\"]\n\tpath = sub\n\turl = https://example.com/org/sub.git\n"))
+	assert.NoError(t, err)
+	subCommitID := MustIDFromString("2839944139e0de9737a044f78b0e4b40d989a9e3")
+	builder := repo.NewTreeBuilder(SHA1{})
+	assert.NoError(t, builder.Add(".gitmodules", EntryModeBlob, gitmodules))
+	assert.NoError(t, builder.Add("sub", EntryModeCommit, subCommitID))
+	treeID, err := builder.Write()
+	assert.NoError(t, err)
+	sig := &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	commitID, err := repo.writeCommit(treeID, nil, "add submodule", sig, sig, nil)
+	assert.NoError(t, err)
+
+	commit, err := repo.GetCommit(commitID.String())
+	assert.NoError(t, err)
+	entries, err := commit.Tree.ListEntries()
+	assert.NoError(t, err)
+
+	// the last commit of the submodule is not looked up before the deadline
+	commitsInfo, _, err := entries.GetCommitsInfoLimits(context.Background(), commit, "", nil, nil, LastCommitsLimits{Deadline: time.Now().Add(-time.Second)})
+	assert.NoError(t, err)
+	if assert.Len(t, commitsInfo, 2) {
+		commitInfo := commitsInfo[1]
+		assert.Equal(t, "sub", commitInfo.Entry.Name())
+		assert.True(t, commitInfo.Unknown)
+		assert.Nil(t, commitInfo.Commit)
+		if assert.NotNil(t, commitInfo.SubModuleFile) {
+			assert.Equal(t, subCommitID.String(), commitInfo.SubModuleFile.RefID())
+			assert.Equal(t, "https://example.com/org/sub", commitInfo.SubModuleFile.RefURL("https://try.gitea.io/", "/user/repo/src/branch/master"))
+		}
+	}
+}
+
+func TestEntries_GetCommitsInfoConcurrent(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	// identical lookups running at the same time share their result
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bareRepo1, err := OpenRepository(bareRepo1Path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer bareRepo1.Close()
+			testGetCommitsInfo(t, bareRepo1)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLastCommitsQueryKey(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+
+	// lookups with different deadlines are shared, the caps are part of the key
+	paths := []string{"", "foo"}
+	key := lastCommitsQueryKey(commit, "", paths, LastCommitsLimits{MaxCommits: 10, Deadline: time.Now()})
+	assert.Equal(t, key, lastCommitsQueryKey(commit, "", paths, LastCommitsLimits{MaxCommits: 10, Deadline: time.Now().Add(time.Second)}))
+	assert.Equal(t, key, lastCommitsQueryKey(commit, "", paths, LastCommitsLimits{MaxCommits: 10}))
+	assert.NotEqual(t, key, lastCommitsQueryKey(commit, "", paths, LastCommitsLimits{MaxCommits: 20}))
+	assert.NotEqual(t, key, lastCommitsQueryKey(commit, "", []string{"", "file1.txt"}, LastCommitsLimits{MaxCommits: 10}))
+}
+
+func TestEntries_GetCommitsInfoContext(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	entries, err := commit.Tree.ListEntries()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = entries.GetCommitsInfoContext(ctx, commit, "", nil, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestEntries_GetCommitsInfoSubset(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	entries, err := commit.Tree.ListEntries()
+	assert.NoError(t, err)
+
+	commitsInfo, treeCommit, err := entries.GetCommitsInfoSubset(commit, "", []string{"file2.txt"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, commit.ID, treeCommit.ID)
+	if assert.Len(t, commitsInfo, 3) {
+		for _, commitInfo := range commitsInfo {
+			if commitInfo.Entry.Name() == "file2.txt" {
+				if assert.NotNil(t, commitInfo.Commit) {
+					assert.Equal(t, "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", commitInfo.Commit.ID.String())
+				}
+			} else {
+				assert.Nil(t, commitInfo.Commit)
+			}
+		}
+	}
+}
+
+type mapLastCommitCache map[string]*Commit
+
+func (c mapLastCommitCache) Get(repoPath, ref, entryPath string) (*Commit, error) {
+	return c[ref+":"+entryPath], nil
+}
+
+func (c mapLastCommitCache) Put(repoPath, ref, entryPath string, commit *Commit) error {
+	c[ref+":"+entryPath] = commit
+	return nil
+}
+
+func TestEntries_GetCommitsInfoCache(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	tree, err := commit.Tree.SubTree("foo")
+	assert.NoError(t, err)
+	entries, err := tree.ListEntries()
+	assert.NoError(t, err)
+
+	cache := mapLastCommitCache{}
+	_, treeCommit, err := entries.GetCommitsInfo(commit, "foo", cache)
+	assert.NoError(t, err)
+	assert.Equal(t, "37991dec2c8e592043f47155ce4808d4580f9123", treeCommit.ID.String())
+	// the paths are relative to the repository root
+	assert.Len(t, cache, len(entries)+1)
+	assert.Contains(t, cache, commit.ID.String()+":foo")
+	for _, entry := range entries {
+		assert.Contains(t, cache, commit.ID.String()+":foo/"+entry.Name())
+	}
+
+	// cached commits are used without walking the history
+	cached := &Commit{ID: MustIDFromString("95bb4d39648ee7e325106df01a621c530863a653")}
+	for key := range cache {
+		cache[key] = cached
+	}
+	commitsInfo, treeCommit, err := entries.GetCommitsInfo(commit, "foo", cache)
+	assert.NoError(t, err)
+	assert.Equal(t, cached.ID, treeCommit.ID)
+	for _, commitInfo := range commitsInfo {
+		assert.Equal(t, cached.ID, commitInfo.Commit.ID)
+	}
+}
+
+// mapBloomFilterCache is used by the workers of the history walk concurrently
+type mapBloomFilterCache struct {
+	mapLastCommitCache
+	lock    *sync.Mutex
+	filters map[string][]byte
+}
+
+func (c mapBloomFilterCache) GetBloomFilter(commitID string) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.filters[commitID], nil
+}
+
+func (c mapBloomFilterCache) PutBloomFilter(commitID string, filter []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.filters[commitID] = filter
+	return nil
+}
+
+func TestEntries_GetCommitsInfoBloomFilterCache(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	// the last commits are looked up again for every test case, the filters are shared
+	lock := &sync.Mutex{}
+	filters := map[string][]byte{}
+	newCache := func() LastCommitCache {
+		return mapBloomFilterCache{mapLastCommitCache: mapLastCommitCache{}, lock: lock, filters: filters}
+	}
+	testGetCommitsInfoCache(t, bareRepo1, newCache)
+	assert.NotEmpty(t, filters)
+	computed := make(map[string][]byte, len(filters))
+	for id, filter := range filters {
+		computed[id] = filter
+	}
+
+	// the stored filters are used instead of computing them again
+	testGetCommitsInfoCache(t, bareRepo1, newCache)
+	assert.Equal(t, computed, filters)
+}
+
 func BenchmarkEntries_GetCommitsInfo(b *testing.B) {
 	benchmarks := []struct {
 		url  string
@@ -122,3 +417,49 @@ func BenchmarkEntries_GetCommitsInfo(b *testing.B) {
 		})
 	}
 }
+
+func TestGetTreeFileHashes(t *testing.T) {
+	bareRepo1, err := OpenRepository(filepath.Join(testReposDir, "repo1_bare"))
+	assert.NoError(t, err)
+	defer bareRepo1.Close()
+
+	getTree := func(id string) *object.Tree {
+		commit, err := bareRepo1.gogitRepo.CommitObject(plumbing.NewHash(id))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		tree, err := commit.Tree()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return tree
+	}
+	paths := []string{"", "file1.txt", "file2.txt"}
+
+	// 8d92fc9 added file2.txt to the tree of its parent 95bb4d3
+	child := getTree("8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2")
+	childTreeHash, childHashes, err := getTreeFileHashes(child, nil, plumbing.ZeroHash, nil, paths)
+	assert.NoError(t, err)
+	assert.Equal(t, child.Hash, childTreeHash)
+	assert.Equal(t, child.Hash, childHashes[""])
+	assert.Equal(t, "e2129701f1a4d54dc44f03c93bca0a2aec7c5449", childHashes["file1.txt"].String())
+	assert.Equal(t, "6c493ff740f9380390d5c9ddef4af18697ac9375", childHashes["file2.txt"].String())
+
+	parent := getTree("95bb4d39648ee7e325106df01a621c530863a653")
+	parentTreeHash, parentHashes, err := getTreeFileHashes(parent, nil, childTreeHash, childHashes, paths)
+	assert.NoError(t, err)
+	assert.Equal(t, parent.Hash, parentTreeHash)
+	assert.Equal(t, childHashes["file1.txt"], parentHashes["file1.txt"])
+	assert.NotContains(t, parentHashes, "file2.txt")
+
+	// the hashes of the child are reused for an identical tree
+	_, sameHashes, err := getTreeFileHashes(child, nil, childTreeHash, childHashes, paths)
+	assert.NoError(t, err)
+	assert.Equal(t, childHashes, sameHashes)
+
+	// a tree which does not exist has no entries
+	treeHash, hashes, err := getTreeFileHashes(nil, object.ErrDirectoryNotFound, childTreeHash, childHashes, paths)
+	assert.NoError(t, err)
+	assert.True(t, treeHash.IsZero())
+	assert.Empty(t, hashes)
+}
@@ -19,6 +19,31 @@ func TestCommitsCount(t *testing.T) {
 	assert.Equal(t, int64(3), commitsCount)
 }
 
+func TestCommitsCountWithOptions(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	commitsCount, err := CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{Revisions: []string{"master"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), commitsCount)
+
+	commitsCount, err = CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{Revisions: []string{"master"}, Not: []string{"branch1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), commitsCount)
+
+	commitsCount, err = CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{Revisions: []string{"master", "branch1"}, NoMerges: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), commitsCount)
+
+	commitsCount, err = CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{Revisions: []string{"master"}, Paths: []string{"file2.txt"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), commitsCount)
+
+	_, err = CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{Revisions: []string{"--all"}})
+	assert.Error(t, err)
+	_, err = CommitsCountWithOptions(bareRepo1Path, CommitsCountOptions{})
+	assert.Error(t, err)
+}
+
 func TestGetFullCommitID(t *testing.T) {
 	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
 
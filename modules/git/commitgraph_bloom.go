@@ -0,0 +1,186 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// bloomDataHeaderSize is the size of the settings at the start of the BDAT chunk
+const bloomDataHeaderSize = 12
+
+// bloomSettings are the settings of the changed-path Bloom filters of a commit-graph layer
+// written by `git commit-graph write --changed-paths`. The filter of a commit contains the paths,
+// and their leading directories, which differ from its first parent, so paths which are not in
+// the filter are known to be unchanged.
+type bloomSettings struct {
+	hashVersion uint32
+	numHashes   uint32
+}
+
+// readBloomSettings reads the settings of the Bloom filters of the layer. The filters are
+// ignored if the layer has none or they are written in an unsupported version.
+func (l *commitGraphLayer) readBloomSettings() error {
+	if l.bloomIndexOffset < 0 || l.bloomDataOffset < 0 || l.bloomDataSize < bloomDataHeaderSize {
+		l.bloomIndexOffset = -1
+		return nil
+	}
+
+	settings := make([]byte, bloomDataHeaderSize)
+	if _, err := l.file.ReadAt(settings, l.bloomDataOffset); err != nil {
+		return err
+	}
+	l.bloomSettings.hashVersion = binary.BigEndian.Uint32(settings[0:4])
+	l.bloomSettings.numHashes = binary.BigEndian.Uint32(settings[4:8])
+	if (l.bloomSettings.hashVersion != 1 && l.bloomSettings.hashVersion != 2) || l.bloomSettings.numHashes == 0 {
+		l.bloomIndexOffset = -1
+	}
+	return nil
+}
+
+// hasBloomFilters returns true if any layer of the commit-graph has changed-path Bloom filters
+func (g *commitGraph) hasBloomFilters() bool {
+	for _, layer := range g.layers {
+		if layer.bloomIndexOffset >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the Bloom filter of the commit and the settings of its layer, nil if there is none
+func (g *commitGraph) filter(id plumbing.Hash) ([]byte, *bloomSettings, error) {
+	idx, err := g.GetIndexByHash(id)
+	if err != nil {
+		if err == plumbing.ErrObjectNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	layer, err := g.layerOf(idx)
+	if err != nil || layer.bloomIndexOffset < 0 {
+		return nil, nil, err
+	}
+	pos := idx - layer.base
+
+	// BIDX holds the end offset of the filter of every commit of the layer
+	var start, end uint32
+	buf := make([]byte, 4)
+	if pos > 0 {
+		if _, err := layer.file.ReadAt(buf, layer.bloomIndexOffset+int64(pos-1)*4); err != nil {
+			return nil, nil, err
+		}
+		start = binary.BigEndian.Uint32(buf)
+	}
+	if _, err := layer.file.ReadAt(buf, layer.bloomIndexOffset+int64(pos)*4); err != nil {
+		return nil, nil, err
+	}
+	end = binary.BigEndian.Uint32(buf)
+	if end < start || int64(end) > layer.bloomDataSize-bloomDataHeaderSize {
+		return nil, nil, errors.New("corrupt commit-graph Bloom filter index")
+	}
+	if start == end {
+		return nil, nil, nil
+	}
+
+	filter := make([]byte, end-start)
+	if _, err := layer.file.ReadAt(filter, layer.bloomDataOffset+bloomDataHeaderSize+int64(start)); err != nil {
+		return nil, nil, err
+	}
+	return filter, &layer.bloomSettings, nil
+}
+
+// pathsUnchanged returns true if none of the paths below treePath may have been changed by the commit
+// compared to its first parent. It returns false if the commit has no filter.
+func (g *commitGraph) pathsUnchanged(id plumbing.Hash, treePath string, paths []string) (bool, error) {
+	filter, settings, err := g.filter(id)
+	if err != nil || filter == nil {
+		return false, err
+	}
+	return settings.pathsUnchanged(filter, treePath, paths), nil
+}
+
+// pathsUnchanged returns true if none of the paths below treePath are in the filter
+func (s *bloomSettings) pathsUnchanged(filter []byte, treePath string, paths []string) bool {
+	for _, p := range paths {
+		fullPath := strings.Trim(treePath+"/"+p, "/")
+		// the root tree changes with every path, so it is never in the filter
+		if fullPath == "" || s.mayContain(filter, fullPath) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *bloomSettings) mayContain(filter []byte, path string) bool {
+	hash0 := murmur3([]byte(path), 0x293ae76f, s.hashVersion == 1)
+	hash1 := murmur3([]byte(path), 0x7e646e2c, s.hashVersion == 1)
+	numBits := uint64(len(filter)) * 8
+	for i := uint32(0); i < s.numHashes; i++ {
+		pos := uint64(hash0+i*hash1) % numBits
+		if filter[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// murmur3 computes the 32-bit MurmurHash3 of data like git does. The hash version 1 of the
+// Bloom filters sign extends bytes with the high bit set.
+func murmur3(data []byte, seed uint32, signExtend bool) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	b := func(i int) uint32 {
+		if signExtend {
+			return uint32(int32(int8(data[i])))
+		}
+		return uint32(data[i])
+	}
+
+	h := seed
+	numBlocks := len(data) / 4
+	for i := 0; i < numBlocks; i++ {
+		k := b(4*i) | b(4*i+1)<<8 | b(4*i+2)<<16 | b(4*i+3)<<24
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := numBlocks * 4
+	switch len(data) & 3 {
+	case 3:
+		k ^= b(tail+2) << 16
+		fallthrough
+	case 2:
+		k ^= b(tail+1) << 8
+		fallthrough
+	case 1:
+		k ^= b(tail)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
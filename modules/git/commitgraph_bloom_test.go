@@ -0,0 +1,113 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMurmur3(t *testing.T) {
+	// the values git uses to test its implementation
+	assert.EqualValues(t, 0x00000000, murmur3([]byte(""), 0, false))
+	assert.EqualValues(t, 0x627b0c2c, murmur3([]byte("Hello world!"), 0, false))
+	assert.EqualValues(t, 0x2e4ff723, murmur3([]byte("The quick brown fox jumps over the lazy dog"), 0, false))
+
+	// both versions only differ for bytes with the high bit set
+	assert.Equal(t, murmur3([]byte("abc"), 1, false), murmur3([]byte("abc"), 1, true))
+	assert.NotEqual(t, murmur3([]byte("\xe2\x98\x83"), 1, false), murmur3([]byte("\xe2\x98\x83"), 1, true))
+}
+
+func TestCommitGraphBloom(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath := filepath.Join(testReposDir, "repo1_TestCommitGraphBloom")
+	assert.NoError(t, Clone(bareRepo1Path, clonedPath, CloneRepoOptions{Bare: true, Quiet: true, Timeout: time.Minute}))
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.WriteCommitGraph())
+
+	graph, err := openCommitGraph(clonedPath)
+	if os.IsNotExist(err) {
+		t.Skip("git does not support commit-graph files")
+	}
+	assert.NoError(t, err)
+	defer graph.Close()
+	if !graph.hasBloomFilters() {
+		t.Skip("git does not support changed-path Bloom filters")
+	}
+
+	// every changed path and its directories are in the filter of the commit
+	skippable := 0
+	for _, id := range graph.Hashes() {
+		if unchanged, _ := graph.pathsUnchanged(id, "", []string{"does/not/exist"}); unchanged {
+			skippable++
+		}
+
+		stdout, err := NewCommand("diff-tree", "-r", "--root", "--name-only", "--no-commit-id", id.String()+"^", id.String()).RunInDir(clonedPath)
+		if err != nil {
+			// root commits are compared with the empty tree
+			stdout, err = NewCommand("diff-tree", "-r", "--root", "--name-only", "--no-commit-id", id.String()).RunInDir(clonedPath)
+		}
+		assert.NoError(t, err)
+		filter, settings, err := graph.filter(id)
+		assert.NoError(t, err)
+		for _, changed := range strings.Fields(stdout) {
+			for p := changed; p != "."; p = path.Dir(p) {
+				assert.True(t, settings.mayContain(filter, p), "%s: %s", id, p)
+				unchanged, err := graph.pathsUnchanged(id, "", []string{p})
+				assert.NoError(t, err)
+				assert.False(t, unchanged)
+			}
+		}
+	}
+
+	assert.NotZero(t, skippable)
+
+	// the results do not change when the filters are used
+	testGetCommitsInfo(t, repo)
+}
+
+func TestComputeBloomFilter(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	repo, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	stdout, err := NewCommand("rev-list", "--all").RunInDir(bareRepo1Path)
+	assert.NoError(t, err)
+	skippable := 0
+	for _, id := range strings.Fields(stdout) {
+		filter, err := computeBloomFilter(repo.gogitRepo.Storer, MustIDFromString(id))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if computedBloomSettings.pathsUnchanged(filter, "", []string{"does/not/exist"}) {
+			skippable++
+		}
+
+		// every changed path and its directories are in the filter of the commit
+		changed, err := NewCommand("diff-tree", "-r", "--root", "--name-only", "--no-commit-id", id+"^", id).RunInDir(bareRepo1Path)
+		if err != nil {
+			// root commits are compared with the empty tree
+			changed, err = NewCommand("diff-tree", "-r", "--root", "--name-only", "--no-commit-id", id).RunInDir(bareRepo1Path)
+		}
+		assert.NoError(t, err)
+		for _, p := range strings.Fields(changed) {
+			for ; p != "."; p = path.Dir(p) {
+				assert.True(t, computedBloomSettings.mayContain(filter, p), "%s: %s", id, p)
+				assert.False(t, computedBloomSettings.pathsUnchanged(filter, "", []string{p}))
+			}
+		}
+	}
+
+	assert.NotZero(t, skippable)
+}
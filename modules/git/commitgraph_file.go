@@ -0,0 +1,333 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+)
+
+var (
+	errMalformedCommitGraph = errors.New("malformed commit-graph file")
+
+	commitGraphSignature  = []byte{'C', 'G', 'P', 'H'}
+	oidFanoutSignature    = []byte{'O', 'I', 'D', 'F'}
+	oidLookupSignature    = []byte{'O', 'I', 'D', 'L'}
+	commitDataSignature   = []byte{'C', 'D', 'A', 'T'}
+	extraEdgesSignature   = []byte{'E', 'D', 'G', 'E'}
+	bloomIndexesSignature = []byte{'B', 'I', 'D', 'X'}
+	bloomDataSignature    = []byte{'B', 'D', 'A', 'T'}
+)
+
+const (
+	commitDataSize = 36
+
+	parentNone        = uint32(0x70000000)
+	parentOctopusUsed = uint32(0x80000000)
+	parentOctopusMask = uint32(0x7fffffff)
+	parentLast        = uint32(0x80000000)
+)
+
+// commitGraph reads the commit-graph of a repository, which is either the single
+// objects/info/commit-graph file or the chain of incremental files written by
+// `git commit-graph write --split`. The positions of the commits are global over the chain
+// like in git, the commits of a layer follow the commits of all its base layers.
+type commitGraph struct {
+	// layers are ordered from the base to the tip of the chain
+	layers []*commitGraphLayer
+}
+
+type commitGraphLayer struct {
+	file *os.File
+
+	// base is the number of commits in the layers below this one
+	base       int
+	fanout     [256]int
+	numCommits int
+
+	oidLookupOffset  int64
+	commitDataOffset int64
+	extraEdgesOffset int64
+
+	// the changed-path Bloom filters of the layer, bloomIndexOffset is negative if there are none
+	bloomIndexOffset int64
+	bloomDataOffset  int64
+	bloomDataSize    int64
+	bloomSettings    bloomSettings
+}
+
+// openCommitGraph opens the commit-graph of the repository. It returns an error satisfying
+// os.IsNotExist if the repository has no commit-graph.
+func openCommitGraph(repoPath string) (*commitGraph, error) {
+	infoPath := filepath.Join(repoPath, "objects", "info")
+
+	// like git, prefer the single file over the chain if both exist
+	graph := &commitGraph{}
+	if err := graph.addLayer(filepath.Join(infoPath, "commit-graph")); err == nil {
+		return graph, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	chain, err := os.Open(filepath.Join(infoPath, "commit-graphs", "commit-graph-chain"))
+	if err != nil {
+		return nil, err
+	}
+	defer chain.Close()
+
+	scanner := bufio.NewScanner(chain)
+	for scanner.Scan() {
+		hash := strings.TrimSpace(scanner.Text())
+		if hash == "" {
+			continue
+		}
+		if err := graph.addLayer(filepath.Join(infoPath, "commit-graphs", "graph-"+hash+".graph")); err != nil {
+			graph.Close()
+			if os.IsNotExist(err) {
+				// the chain has been replaced while it was read
+				return nil, errMalformedCommitGraph
+			}
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		graph.Close()
+		return nil, err
+	}
+	if len(graph.layers) == 0 {
+		return nil, errMalformedCommitGraph
+	}
+	return graph, nil
+}
+
+// Close closes the files of the commit-graph
+func (g *commitGraph) Close() error {
+	var firstErr error
+	for _, layer := range g.layers {
+		if err := layer.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (g *commitGraph) addLayer(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	layer := &commitGraphLayer{file: file, bloomIndexOffset: -1, bloomDataOffset: -1}
+	if len(g.layers) > 0 {
+		top := g.layers[len(g.layers)-1]
+		layer.base = top.base + top.numCommits
+	}
+	if err := layer.read(len(g.layers)); err != nil {
+		file.Close()
+		return err
+	}
+	g.layers = append(g.layers, layer)
+	return nil
+}
+
+func (l *commitGraphLayer) read(numBaseLayers int) error {
+	header := make([]byte, 8)
+	if _, err := l.file.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:4], commitGraphSignature) || header[4] != 1 || header[5] != 1 || int(header[7]) != numBaseLayers {
+		return errMalformedCommitGraph
+	}
+	numChunks := int64(header[6])
+
+	// the chunk table has a terminating entry holding the end of the last chunk
+	table := make([]byte, (numChunks+1)*12)
+	if _, err := l.file.ReadAt(table, 8); err != nil {
+		return err
+	}
+	var oidFanoutOffset int64
+	for i := int64(0); i < numChunks; i++ {
+		entry := table[i*12:]
+		offset := int64(binary.BigEndian.Uint64(entry[4:12]))
+		next := int64(binary.BigEndian.Uint64(entry[16:24]))
+		switch {
+		case bytes.Equal(entry[:4], oidFanoutSignature):
+			oidFanoutOffset = offset
+		case bytes.Equal(entry[:4], oidLookupSignature):
+			l.oidLookupOffset = offset
+		case bytes.Equal(entry[:4], commitDataSignature):
+			l.commitDataOffset = offset
+		case bytes.Equal(entry[:4], extraEdgesSignature):
+			l.extraEdgesOffset = offset
+		case bytes.Equal(entry[:4], bloomIndexesSignature):
+			l.bloomIndexOffset = offset
+		case bytes.Equal(entry[:4], bloomDataSignature):
+			l.bloomDataOffset = offset
+			l.bloomDataSize = next - offset
+		}
+	}
+	if oidFanoutOffset <= 0 || l.oidLookupOffset <= 0 || l.commitDataOffset <= 0 {
+		return errMalformedCommitGraph
+	}
+
+	fanout := make([]byte, 256*4)
+	if _, err := l.file.ReadAt(fanout, oidFanoutOffset); err != nil {
+		return err
+	}
+	for i := range l.fanout {
+		value := binary.BigEndian.Uint32(fanout[i*4:])
+		if value > 0x7fffffff {
+			return errMalformedCommitGraph
+		}
+		l.fanout[i] = int(value)
+	}
+	l.numCommits = l.fanout[0xff]
+
+	return l.readBloomSettings()
+}
+
+// layerOf returns the layer holding the commit at the global position
+func (g *commitGraph) layerOf(idx int) (*commitGraphLayer, error) {
+	i := sort.Search(len(g.layers), func(i int) bool {
+		return idx < g.layers[i].base+g.layers[i].numCommits
+	})
+	if idx < 0 || i == len(g.layers) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	return g.layers[i], nil
+}
+
+// hash returns the hash of the commit at the position in the layer
+func (l *commitGraphLayer) hash(pos int) (plumbing.Hash, error) {
+	var h plumbing.Hash
+	_, err := l.file.ReadAt(h[:], l.oidLookupOffset+int64(pos)*20)
+	return h, err
+}
+
+// position returns the position of the commit in the layer
+func (l *commitGraphLayer) position(h plumbing.Hash) (int, error) {
+	var low int
+	if h[0] > 0 {
+		low = l.fanout[h[0]-1]
+	}
+	high := l.fanout[h[0]]
+	for low < high {
+		mid := (low + high) >> 1
+		oid, err := l.hash(mid)
+		if err != nil {
+			return 0, err
+		}
+		switch cmp := bytes.Compare(h[:], oid[:]); {
+		case cmp < 0:
+			high = mid
+		case cmp == 0:
+			return mid, nil
+		default:
+			low = mid + 1
+		}
+	}
+	return 0, plumbing.ErrObjectNotFound
+}
+
+// GetIndexByHash returns the global position of the commit
+func (g *commitGraph) GetIndexByHash(h plumbing.Hash) (int, error) {
+	for _, layer := range g.layers {
+		pos, err := layer.position(h)
+		if err == nil {
+			return layer.base + pos, nil
+		} else if err != plumbing.ErrObjectNotFound {
+			return 0, err
+		}
+	}
+	return 0, plumbing.ErrObjectNotFound
+}
+
+// GetCommitDataByIndex returns the data of the commit at the global position
+func (g *commitGraph) GetCommitDataByIndex(idx int) (*commitgraph.CommitData, error) {
+	layer, err := g.layerOf(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, commitDataSize)
+	if _, err := layer.file.ReadAt(data, layer.commitDataOffset+int64(idx-layer.base)*commitDataSize); err != nil {
+		return nil, err
+	}
+	var treeHash plumbing.Hash
+	copy(treeHash[:], data[:20])
+	parent1 := binary.BigEndian.Uint32(data[20:24])
+	parent2 := binary.BigEndian.Uint32(data[24:28])
+	genAndTime := binary.BigEndian.Uint64(data[28:36])
+
+	var parentIndexes []int
+	if parent2&parentOctopusUsed == parentOctopusUsed {
+		if layer.extraEdgesOffset <= 0 {
+			return nil, errMalformedCommitGraph
+		}
+		parentIndexes = []int{int(parent1 & parentOctopusMask)}
+		buf := make([]byte, 4)
+		for offset := layer.extraEdgesOffset + 4*int64(parent2&parentOctopusMask); ; offset += 4 {
+			if _, err := layer.file.ReadAt(buf, offset); err != nil {
+				return nil, err
+			}
+			parent := binary.BigEndian.Uint32(buf)
+			parentIndexes = append(parentIndexes, int(parent&parentOctopusMask))
+			if parent&parentLast == parentLast {
+				break
+			}
+		}
+	} else if parent2 != parentNone {
+		parentIndexes = []int{int(parent1 & parentOctopusMask), int(parent2 & parentOctopusMask)}
+	} else if parent1 != parentNone {
+		parentIndexes = []int{int(parent1 & parentOctopusMask)}
+	}
+
+	// parents are in the same layer or in one of its base layers
+	parentHashes := make([]plumbing.Hash, len(parentIndexes))
+	for i, parentIdx := range parentIndexes {
+		parentLayer, err := g.layerOf(parentIdx)
+		if err != nil || parentLayer.base > layer.base {
+			return nil, errMalformedCommitGraph
+		}
+		if parentHashes[i], err = parentLayer.hash(parentIdx - parentLayer.base); err != nil {
+			return nil, err
+		}
+	}
+
+	return &commitgraph.CommitData{
+		TreeHash:      treeHash,
+		ParentIndexes: parentIndexes,
+		ParentHashes:  parentHashes,
+		Generation:    int(genAndTime >> 34),
+		When:          time.Unix(int64(genAndTime&0x3FFFFFFFF), 0),
+	}, nil
+}
+
+// Hashes returns the hashes of all commits in the commit-graph
+func (g *commitGraph) Hashes() []plumbing.Hash {
+	var hashes []plumbing.Hash
+	for _, layer := range g.layers {
+		for pos := 0; pos < layer.numCommits; pos++ {
+			h, err := layer.hash(pos)
+			if err != nil {
+				return nil
+			}
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
+
+var _ commitgraph.Index = &commitGraph{}
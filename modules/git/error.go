@@ -6,6 +6,8 @@ package git
 
 import (
 	"fmt"
+	"os/exec"
+	"strings"
 	"time"
 )
 
@@ -24,6 +26,124 @@ func (err ErrExecTimeout) Error() string {
 	return fmt.Sprintf("execution is timeout [duration: %v]", err.Duration)
 }
 
+// ErrOutputTooLarge error when the output of a command exceeds its limit
+type ErrOutputTooLarge struct {
+	Limit int64
+}
+
+// IsErrOutputTooLarge if some error is ErrOutputTooLarge
+func IsErrOutputTooLarge(err error) bool {
+	_, ok := err.(ErrOutputTooLarge)
+	return ok
+}
+
+func (err ErrOutputTooLarge) Error() string {
+	return fmt.Sprintf("command output is too large [limit: %d]", err.Limit)
+}
+
+// ErrUnsafeArgument error when an argument from the user would be parsed as an option by git
+type ErrUnsafeArgument struct {
+	Arg string
+}
+
+// IsErrUnsafeArgument if some error is ErrUnsafeArgument
+func IsErrUnsafeArgument(err error) bool {
+	_, ok := err.(ErrUnsafeArgument)
+	return ok
+}
+
+func (err ErrUnsafeArgument) Error() string {
+	return fmt.Sprintf("argument must not start with '-' [arg: %s]", err.Arg)
+}
+
+// ErrRetriesExhausted error when an operation has failed transiently on every attempt
+type ErrRetriesExhausted struct {
+	Attempts int
+	Err      error
+}
+
+// IsErrRetriesExhausted if some error is ErrRetriesExhausted
+func IsErrRetriesExhausted(err error) bool {
+	_, ok := err.(ErrRetriesExhausted)
+	return ok
+}
+
+func (err ErrRetriesExhausted) Error() string {
+	return fmt.Sprintf("%v [attempts: %d]", err.Err, err.Attempts)
+}
+
+// CommandErrorKind classifies the failures of git commands
+type CommandErrorKind int
+
+// The kinds of failures of git commands
+const (
+	// CommandErrorOther is any failure not classified below
+	CommandErrorOther CommandErrorKind = iota
+	// CommandErrorNotExist means a revision, path or object does not exist
+	CommandErrorNotExist
+	// CommandErrorNotFastForward means an update of a ref was rejected as it is not a fast-forward
+	CommandErrorNotFastForward
+	// CommandErrorLockFailed means a ref or the index could not be locked
+	CommandErrorLockFailed
+)
+
+// commandErrorPatterns are the stderr messages of git which classify a failure
+var commandErrorPatterns = []struct {
+	kind     CommandErrorKind
+	messages []string
+}{
+	{CommandErrorNotExist, []string{"unknown revision or path", "Needed a single revision", "bad revision", "not a valid object name", "Not a valid object name", "bad object", "invalid object name"}},
+	{CommandErrorNotFastForward, []string{"non-fast-forward", "Not possible to fast-forward", "not a fast-forward"}},
+	{CommandErrorLockFailed, []string{"cannot lock ref", ".lock': File exists", "Unable to create '"}},
+}
+
+// CommandError is the error of a git command which exited with a non-zero status
+type CommandError struct {
+	// Err is the error returned by the process, e.g. "exit status 128"
+	Err error
+	// ExitCode is the exit code of the command, -1 if it has been killed
+	ExitCode int
+	Stderr   string
+	Kind     CommandErrorKind
+}
+
+func newCommandError(err error, stderr string) error {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return concatenateError(err, stderr)
+	}
+	cmdErr := CommandError{
+		Err:      err,
+		ExitCode: exitErr.ExitCode(),
+		Stderr:   stderr,
+	}
+	for _, pattern := range commandErrorPatterns {
+		for _, message := range pattern.messages {
+			if strings.Contains(stderr, message) {
+				cmdErr.Kind = pattern.kind
+				return cmdErr
+			}
+		}
+	}
+	return cmdErr
+}
+
+// IsErrCommand if some error is CommandError
+func IsErrCommand(err error) bool {
+	_, ok := err.(CommandError)
+	return ok
+}
+
+// IsErrCommandKind if some error is a CommandError of the given kind
+func IsErrCommandKind(err error, kind CommandErrorKind) bool {
+	cmdErr, ok := err.(CommandError)
+	return ok && cmdErr.Kind == kind
+}
+
+func (err CommandError) Error() string {
+	return concatenateError(err.Err, err.Stderr).Error()
+}
+
 // ErrNotExist commit not exist error
 type ErrNotExist struct {
 	ID      string
@@ -46,6 +166,12 @@ type ErrBadLink struct {
 	Message string
 }
 
+// IsErrBadLink if some error is ErrBadLink
+func IsErrBadLink(err error) bool {
+	_, ok := err.(ErrBadLink)
+	return ok
+}
+
 func (err ErrBadLink) Error() string {
 	return fmt.Sprintf("%s: %s", err.Name, err.Message)
 }
@@ -79,3 +205,263 @@ func IsErrBranchNotExist(err error) bool {
 func (err ErrBranchNotExist) Error() string {
 	return fmt.Sprintf("branch does not exist [name: %s]", err.Name)
 }
+
+// ErrBlobTooLarge represents a blob that exceeds the allowed size
+type ErrBlobTooLarge struct {
+	ID      string
+	Size    int64
+	MaxSize int64
+}
+
+// IsErrBlobTooLarge checks if an error is a ErrBlobTooLarge.
+func IsErrBlobTooLarge(err error) bool {
+	_, ok := err.(ErrBlobTooLarge)
+	return ok
+}
+
+func (err ErrBlobTooLarge) Error() string {
+	return fmt.Sprintf("blob is too large [id: %s, size: %d, max_size: %d]", err.ID, err.Size, err.MaxSize)
+}
+
+// ErrRefNotExist represents a ref which cannot be resolved to a commit
+type ErrRefNotExist struct {
+	Ref string
+}
+
+// IsErrRefNotExist checks if an error is a ErrRefNotExist.
+func IsErrRefNotExist(err error) bool {
+	_, ok := err.(ErrRefNotExist)
+	return ok
+}
+
+func (err ErrRefNotExist) Error() string {
+	return fmt.Sprintf("ref does not exist [ref: %s]", err.Ref)
+}
+
+// ErrNotBlob represents a path which exists, but is not a file
+type ErrNotBlob struct {
+	Path string
+	Type ObjectType
+}
+
+// IsErrNotBlob checks if an error is a ErrNotBlob.
+func IsErrNotBlob(err error) bool {
+	_, ok := err.(ErrNotBlob)
+	return ok
+}
+
+func (err ErrNotBlob) Error() string {
+	return fmt.Sprintf("path is not a blob [path: %s, type: %s]", err.Path, err.Type)
+}
+
+// ErrBranchAlreadyExists represents an error that a branch with such name already exists.
+type ErrBranchAlreadyExists struct {
+	Name string
+}
+
+// IsErrBranchAlreadyExists checks if an error is an ErrBranchAlreadyExists.
+func IsErrBranchAlreadyExists(err error) bool {
+	_, ok := err.(ErrBranchAlreadyExists)
+	return ok
+}
+
+func (err ErrBranchAlreadyExists) Error() string {
+	return fmt.Sprintf("branch already exists [name: %s]", err.Name)
+}
+
+// ErrNotFastForward represents a branch update to a commit which does not descend from the current one.
+type ErrNotFastForward struct {
+	Name        string
+	OldCommitID string
+	NewCommitID string
+}
+
+// IsErrNotFastForward checks if an error is an ErrNotFastForward.
+func IsErrNotFastForward(err error) bool {
+	_, ok := err.(ErrNotFastForward)
+	return ok
+}
+
+func (err ErrNotFastForward) Error() string {
+	return fmt.Sprintf("update is not a fast-forward [name: %s, old_commit_id: %s, new_commit_id: %s]", err.Name, err.OldCommitID, err.NewCommitID)
+}
+
+// ErrRefChanged represents a ref update which was rejected because the ref did not have the expected old value.
+type ErrRefChanged struct {
+	Ref    string
+	Reason string
+}
+
+// IsErrRefChanged checks if an error is an ErrRefChanged.
+func IsErrRefChanged(err error) bool {
+	_, ok := err.(ErrRefChanged)
+	return ok
+}
+
+func (err ErrRefChanged) Error() string {
+	return fmt.Sprintf("ref has been changed concurrently [ref: %s, reason: %s]", err.Ref, err.Reason)
+}
+
+// ErrTagAlreadyExists represents an error that a tag with such name already exists.
+type ErrTagAlreadyExists struct {
+	Name string
+}
+
+// IsErrTagAlreadyExists checks if an error is an ErrTagAlreadyExists.
+func IsErrTagAlreadyExists(err error) bool {
+	_, ok := err.(ErrTagAlreadyExists)
+	return ok
+}
+
+func (err ErrTagAlreadyExists) Error() string {
+	return fmt.Sprintf("tag already exists [name: %s]", err.Name)
+}
+
+// ErrTagNotExist represents an error that a tag with such name does not exist.
+type ErrTagNotExist struct {
+	Name string
+}
+
+// IsErrTagNotExist checks if an error is an ErrTagNotExist.
+func IsErrTagNotExist(err error) bool {
+	_, ok := err.(ErrTagNotExist)
+	return ok
+}
+
+func (err ErrTagNotExist) Error() string {
+	return fmt.Sprintf("tag does not exist [name: %s]", err.Name)
+}
+
+// ErrTagProtected represents an error that a tag matches one of the protected tag patterns.
+type ErrTagProtected struct {
+	Name string
+}
+
+// IsErrTagProtected checks if an error is an ErrTagProtected.
+func IsErrTagProtected(err error) bool {
+	_, ok := err.(ErrTagProtected)
+	return ok
+}
+
+func (err ErrTagProtected) Error() string {
+	return fmt.Sprintf("tag is protected [name: %s]", err.Name)
+}
+
+// ErrMergeConflict error when the revisions merged conflict
+type ErrMergeConflict struct {
+	Files []string
+	// conflicts are the details of the files, only collected for GetConflictFiles
+	conflicts []*ConflictFile
+}
+
+func newMergeConflict(conflicts []*ConflictFile) ErrMergeConflict {
+	files := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		files = append(files, conflict.Path)
+	}
+	return ErrMergeConflict{Files: files, conflicts: conflicts}
+}
+
+// IsErrMergeConflict if some error is ErrMergeConflict
+func IsErrMergeConflict(err error) bool {
+	_, ok := err.(ErrMergeConflict)
+	return ok
+}
+
+func (err ErrMergeConflict) Error() string {
+	return fmt.Sprintf("merge conflict [files: %s]", strings.Join(err.Files, ", "))
+}
+
+// ErrMergeUnrelatedHistories error when the revisions merged have no common ancestor
+type ErrMergeUnrelatedHistories struct {
+	Base string
+	Head string
+}
+
+// IsErrMergeUnrelatedHistories if some error is ErrMergeUnrelatedHistories
+func IsErrMergeUnrelatedHistories(err error) bool {
+	_, ok := err.(ErrMergeUnrelatedHistories)
+	return ok
+}
+
+func (err ErrMergeUnrelatedHistories) Error() string {
+	return fmt.Sprintf("merge of unrelated histories [base: %s, head: %s]", err.Base, err.Head)
+}
+
+// ErrRebaseConflict error when a commit conflicts with the commits it is replayed onto
+type ErrRebaseConflict struct {
+	Commit string
+	Files  []string
+}
+
+// IsErrRebaseConflict if some error is ErrRebaseConflict
+func IsErrRebaseConflict(err error) bool {
+	_, ok := err.(ErrRebaseConflict)
+	return ok
+}
+
+func (err ErrRebaseConflict) Error() string {
+	return fmt.Sprintf("rebase conflict [commit: %s, files: %s]", err.Commit, strings.Join(err.Files, ", "))
+}
+
+// ErrFileAlreadyExists error when a file is created at a path which is already taken
+type ErrFileAlreadyExists struct {
+	Path string
+}
+
+// IsErrFileAlreadyExists if some error is ErrFileAlreadyExists
+func IsErrFileAlreadyExists(err error) bool {
+	_, ok := err.(ErrFileAlreadyExists)
+	return ok
+}
+
+func (err ErrFileAlreadyExists) Error() string {
+	return fmt.Sprintf("file already exists [path: %s]", err.Path)
+}
+
+// ErrPushRejected error when the remote rejects the update of some refs of a push
+type ErrPushRejected struct {
+	Remote string
+	Refs   []string
+}
+
+// IsErrPushRejected if some error is ErrPushRejected
+func IsErrPushRejected(err error) bool {
+	_, ok := err.(ErrPushRejected)
+	return ok
+}
+
+func (err ErrPushRejected) Error() string {
+	return fmt.Sprintf("push rejected [remote: %s, refs: %s]", err.Remote, strings.Join(err.Refs, ", "))
+}
+
+// ErrPushLeaseFailed error when refs pushed with a lease no longer point at the expected commits
+type ErrPushLeaseFailed struct {
+	Remote string
+	Refs   []string
+}
+
+// IsErrPushLeaseFailed if some error is ErrPushLeaseFailed
+func IsErrPushLeaseFailed(err error) bool {
+	_, ok := err.(ErrPushLeaseFailed)
+	return ok
+}
+
+func (err ErrPushLeaseFailed) Error() string {
+	return fmt.Sprintf("push lease failed [remote: %s, refs: %s]", err.Remote, strings.Join(err.Refs, ", "))
+}
+
+// ErrGCRunning error when git gc is started while another gc of the repository still runs
+type ErrGCRunning struct {
+	Path string
+}
+
+// IsErrGCRunning if some error is ErrGCRunning
+func IsErrGCRunning(err error) bool {
+	_, ok := err.(ErrGCRunning)
+	return ok
+}
+
+func (err ErrGCRunning) Error() string {
+	return fmt.Sprintf("gc is already running [path: %s]", err.Path)
+}
@@ -96,6 +96,7 @@ func SetExecutablePath(path string) error {
 	if version.Compare(gitVersion, GitVersionRequired, "<") {
 		return fmt.Errorf("Git version not supported. Requires version > %v", GitVersionRequired)
 	}
+	capabilities.Store(CapabilitiesForVersion(gitVersion))
 
 	return nil
 }
@@ -122,7 +123,7 @@ func Init() error {
 		return fmt.Errorf("Failed to execute 'git config --global core.quotepath false': %s", stderr)
 	}
 
-	if version.Compare(gitVersion, "2.18", ">=") {
+	if GetCapabilities().SupportsCommitGraph {
 		if _, stderr, err := process.GetManager().Exec("git.Init(git config --global core.commitGraph true)",
 			GitExecutable, "config", "--global", "core.commitGraph", "true"); err != nil {
 			return fmt.Errorf("Failed to execute 'git config --global core.commitGraph true': %s", stderr)
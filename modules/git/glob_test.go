@@ -0,0 +1,43 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPathGlob(t *testing.T) {
+	testCases := []struct {
+		Pattern  string
+		Name     string
+		Expected bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "modules/git/glob.go", true},
+		{"modules/**", "modules/git/glob.go", true},
+		{"modules/**", "modules", false},
+		{"modules/**/glob.go", "modules/glob.go", true},
+		{"modules/**/glob.go", "modules/git/glob.go", true},
+		{"modules/**/**/glob.go", "modules/a/b/glob.go", true},
+		{"modules/**/glob.go", "models/git/glob.go", false},
+		{"file?.txt", "file1.txt", true},
+		{"file[0-9].txt", "filex.txt", false},
+		{"refs/heads/release/*", "refs/heads/release/1.0", true},
+		{"refs/heads/release/*", "refs/heads/release/1.0/fix", false},
+	}
+	for _, testCase := range testCases {
+		matched, err := MatchPathGlob(testCase.Pattern, testCase.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.Expected, matched, "%s ~ %s", testCase.Pattern, testCase.Name)
+	}
+
+	_, err := MatchPathGlob("file[.txt", "file.txt")
+	assert.Error(t, err)
+}
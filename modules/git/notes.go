@@ -5,6 +5,7 @@
 package git
 
 import (
+	"context"
 	"io/ioutil"
 )
 
@@ -58,7 +59,7 @@ func GetNote(repo *Repository, commitID string, note *Note) error {
 		return nil
 	}
 
-	lastCommits, err := getLastCommitForPaths(commitNode, "", []string{commitID})
+	lastCommits, _, err := getLastCommitForPaths(context.Background(), repo, commitNode, nil, nil, "", []string{commitID}, LastCommitsLimits{})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,19 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+
+	"code.gitea.io/gitea/modules/sync"
+)
+
+// queries deduplicates expensive queries which run concurrently for the same repository
+// and arguments, e.g. when many users view the home page of a popular repository at once
+var queries = sync.NewSingleFlight()
+
+func queryKey(repoPath, query string, args ...string) string {
+	return repoPath + "\x00" + query + "\x00" + strings.Join(args, "\x00")
+}
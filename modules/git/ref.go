@@ -10,6 +10,8 @@ type Reference struct {
 	repo   *Repository
 	Object SHA1 // The id of this commit object
 	Type   string
+	// Peeled is the id of the object an annotated tag points to, otherwise the same as Object
+	Peeled SHA1
 }
 
 // Commit return the commit of the reference
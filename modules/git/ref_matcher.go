@@ -0,0 +1,66 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RefMatcher matches full ref names against a list of patterns the way `git for-each-ref` does,
+// so that rules like branch and tag protections select the same refs as git itself.
+// A pattern containing wildcards is matched as described by MatchPathGlob: `*`, `?` and `[...]`
+// never match a `/`, while a `**` segment matches any number of segments, e.g. `refs/heads/release/*`
+// matches `refs/heads/release/1.0` but not `refs/heads/release/1.0/fix`, which is matched by
+// `refs/heads/release/**`. A pattern without wildcards matches the ref of that name and all refs
+// below it, so `refs/tags` matches every tag.
+type RefMatcher struct {
+	patterns []string
+}
+
+// NewRefMatcher creates a RefMatcher matching the refs selected by any of the patterns
+func NewRefMatcher(patterns ...string) (*RefMatcher, error) {
+	m := &RefMatcher{patterns: make([]string, 0, len(patterns))}
+	for _, pattern := range patterns {
+		pattern = strings.TrimRight(pattern, "/")
+		if pattern == "" {
+			return nil, fmt.Errorf("empty ref pattern")
+		}
+		if _, err := MatchPathGlob(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ref pattern %s: %v", pattern, err)
+		}
+		m.patterns = append(m.patterns, pattern)
+	}
+	return m, nil
+}
+
+// Match reports whether the full ref name is matched by any of the patterns
+func (m *RefMatcher) Match(ref string) bool {
+	for _, pattern := range m.patterns {
+		if matchRefPattern(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the refs matched by any of the patterns, keeping their order
+func (m *RefMatcher) Filter(refs []string) []string {
+	matched := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if m.Match(ref) {
+			matched = append(matched, ref)
+		}
+	}
+	return matched
+}
+
+func matchRefPattern(pattern, ref string) bool {
+	if !strings.ContainsAny(pattern, "*?[\\") {
+		return ref == pattern || strings.HasPrefix(ref, pattern+"/")
+	}
+	matched, _ := MatchPathGlob(pattern, ref)
+	return matched
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefMatcher(t *testing.T) {
+	testCases := []struct {
+		Pattern  string
+		Ref      string
+		Expected bool
+	}{
+		{"refs/heads/master", "refs/heads/master", true},
+		{"refs/heads/master", "refs/heads/master2", false},
+		{"refs/heads/master", "refs/heads/master/fix", true},
+		{"refs/tags", "refs/tags/v1.0", true},
+		{"refs/tags/", "refs/tags/v1.0", true},
+		{"refs/tags", "refs/tagsv1.0", false},
+		{"refs/heads/release/*", "refs/heads/release/1.0", true},
+		{"refs/heads/release/*", "refs/heads/release/1.0/fix", false},
+		{"refs/heads/release/*", "refs/heads/release", false},
+		{"refs/heads/release/**", "refs/heads/release/1.0/fix", true},
+		{"refs/heads/**/fix", "refs/heads/fix", true},
+		{"refs/heads/**/fix", "refs/heads/release/1.0/fix", true},
+		{"refs/heads/v?.*", "refs/heads/v1.0", true},
+		{"refs/heads/v[0-9]*", "refs/heads/vx", false},
+		{"refs/*/master", "refs/heads/master", true},
+		{"refs/*/master", "refs/remotes/origin/master", false},
+	}
+	for _, testCase := range testCases {
+		m, err := NewRefMatcher(testCase.Pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.Expected, m.Match(testCase.Ref), "%s ~ %s", testCase.Pattern, testCase.Ref)
+	}
+
+	m, err := NewRefMatcher("refs/heads/release/*", "refs/tags/v*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"refs/heads/release/1.0", "refs/tags/v1.0"},
+		m.Filter([]string{"refs/heads/master", "refs/heads/release/1.0", "refs/tags/v1.0", "refs/tags/test"}))
+
+	_, err = NewRefMatcher("refs/heads/[")
+	assert.Error(t, err)
+	_, err = NewRefMatcher("")
+	assert.Error(t, err)
+}
+
+// TestRefMatcher_ForEachRef checks that the matcher selects the same refs as git
+func TestRefMatcher_ForEachRef(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	refs, err := bareRepo1.ListRefs()
+	assert.NoError(t, err)
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+
+	for _, pattern := range []string{"refs/heads", "refs/heads/branch*", "refs/*/test", "refs/heads/master"} {
+		expected, err := bareRepo1.ListRefs(pattern)
+		assert.NoError(t, err)
+		expectedNames := make([]string, 0, len(expected))
+		for _, ref := range expected {
+			expectedNames = append(expectedNames, ref.Name)
+		}
+
+		m, err := NewRefMatcher(pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedNames, m.Filter(names), pattern)
+	}
+}
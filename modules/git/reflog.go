@@ -0,0 +1,102 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ReflogEntry represents a single update of a ref recorded in its reflog
+type ReflogEntry struct {
+	OldCommitID SHA1
+	NewCommitID SHA1
+	// Actor is the identity which updated the ref, at the time of the update
+	Actor *Signature
+	// Action is the kind of update like `commit`, `push` or `branch`, it is empty if unknown
+	Action  string
+	Message string
+}
+
+// GetReflog returns at most limit entries of the reflog of the full ref name (or HEAD), newest first,
+// after skipping the first skip ones. A limit <= 0 returns all remaining entries. The total number of
+// entries is returned as well. Refs without a reflog, e.g. because reflogs are disabled in bare
+// repositories by default, have no entries.
+func (repo *Repository) GetReflog(ref string, skip, limit int) ([]*ReflogEntry, int, error) {
+	if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") || strings.Contains(ref, "..") {
+		return nil, 0, fmt.Errorf("invalid ref name: %s", ref)
+	}
+
+	f, err := repo.gogitStorage.Filesystem().Open(path.Join("logs", ref))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*ReflogEntry{}, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lines := splitLines(data)
+	total := len(lines)
+	entries := make([]*ReflogEntry, 0)
+	// New entries are appended to the file
+	for i := total - 1 - skip; i >= 0 && (limit <= 0 || len(entries) < limit); i-- {
+		entry, err := parseReflogEntry(lines[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, nil
+}
+
+// parseReflogEntry parses a line of a reflog, which has the form
+// `<old id> <new id> <name> <<email>> <timestamp> <timezone>\t<action>: <message>`
+func parseReflogEntry(line []byte) (*ReflogEntry, error) {
+	tab := bytes.IndexByte(line, '\t')
+	if tab < 0 {
+		tab = len(line)
+	}
+	header := line[:tab]
+	if len(header) < 82 || header[40] != ' ' || header[81] != ' ' ||
+		bytes.IndexByte(header, '<') < 83 || bytes.IndexByte(header, '>') < 0 {
+		return nil, fmt.Errorf("invalid reflog entry: %s", line)
+	}
+
+	oldID, err := NewIDFromString(string(header[:40]))
+	if err != nil {
+		return nil, err
+	}
+	newID, err := NewIDFromString(string(header[41:81]))
+	if err != nil {
+		return nil, err
+	}
+	actor, err := newSignatureFromCommitline(header[82:])
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &ReflogEntry{
+		OldCommitID: oldID,
+		NewCommitID: newID,
+		Actor:       actor,
+	}
+	if tab < len(line) {
+		message := string(line[tab+1:])
+		if i := strings.Index(message, ": "); i >= 0 {
+			entry.Action, message = message[:i], message[i+2:]
+		}
+		entry.Message = message
+	}
+	return entry, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_GetReflog(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"README.md": "readme"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	head, err := repo.GetRefCommitID("refs/heads/master")
+	assert.NoError(t, err)
+	_, err = NewCommand("branch", "feature").RunInDir(tmpDir)
+	assert.NoError(t, err)
+	// an unrelated commit with the same tree
+	stdout, err := NewCommand("commit-tree", "-m", "rewritten", "HEAD^{tree}").RunInDir(tmpDir)
+	assert.NoError(t, err)
+	rewritten := strings.TrimSpace(stdout)
+	_, err = NewCommand("update-ref", "-m", "push: forced update", "refs/heads/feature", rewritten).RunInDir(tmpDir)
+	assert.NoError(t, err)
+
+	entries, total, err := repo.GetReflog("refs/heads/feature", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, head, entries[0].OldCommitID.String())
+		assert.Equal(t, rewritten, entries[0].NewCommitID.String())
+		assert.Equal(t, "push", entries[0].Action)
+		assert.Equal(t, "forced update", entries[0].Message)
+
+		assert.Equal(t, EmptySHA, entries[1].OldCommitID.String())
+		assert.Equal(t, head, entries[1].NewCommitID.String())
+		assert.Equal(t, "branch", entries[1].Action)
+		assert.Equal(t, "Created from master", entries[1].Message)
+		assert.NotEmpty(t, entries[1].Actor.Email)
+		assert.False(t, entries[1].Actor.When.IsZero())
+	}
+
+	entries, total, err = repo.GetReflog("refs/heads/feature", 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "branch", entries[0].Action)
+	}
+
+	entries, total, err = repo.GetReflog("refs/heads/no-such-branch", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, entries)
+
+	_, _, err = repo.GetReflog("../config", 0, 0)
+	assert.Error(t, err)
+}
@@ -6,7 +6,6 @@
 package git
 
 import (
-	"bytes"
 	"container/list"
 	"errors"
 	"fmt"
@@ -20,18 +19,28 @@ import (
 	"github.com/unknwon/com"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	gogit "gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing/cache"
-	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
 // Repository represents a Git repository.
+//
+// A Repository may be used by several goroutines at once: the object reads through go-git are
+// serialized by its storage, and the long-lived git processes and the caches have locks of their own.
+// This includes Close, which only releases resources which are acquired again when needed.
+// The Commit, Tree and TreeEntry values read from it are not synchronized, and must not be
+// modified by one goroutine while others use them.
 type Repository struct {
 	Path string
 
-	tagCache *ObjectCache
+	// ProtectedTags are glob patterns, see MatchPathGlob, of the tags DeleteTag refuses to delete
+	ProtectedTags []string
+
+	tagCache  *ObjectCache
+	catFile   catFileProcesses
+	checkAttr checkAttrProcesses
 
 	gogitRepo    *gogit.Repository
-	gogitStorage *filesystem.Storage
+	gogitStorage *lockedStorage
 }
 
 const prettyLogFormat = `--pretty=format:%H`
@@ -42,7 +51,7 @@ func (repo *Repository) parsePrettyFormatLogToList(logs []byte) (*list.List, err
 		return l, nil
 	}
 
-	parts := bytes.Split(logs, []byte{'\n'})
+	parts := splitLines(logs)
 
 	for _, commitID := range parts {
 		commit, err := repo.GetCommit(string(commitID))
@@ -76,6 +85,52 @@ func InitRepository(repoPath string, bare bool) error {
 	return err
 }
 
+// InitRepositoryOptions are the options of InitRepositoryWithOptions
+type InitRepositoryOptions struct {
+	Bare bool
+	// DefaultBranch is the branch HEAD points to, the default of git is kept if it is empty
+	DefaultBranch string
+	// InitialCommit seeds the repository with a commit, e.g. of the README, .gitignore and
+	// LICENSE chosen from templates, which is signed if its KeyID is set. The commit is made on
+	// the default branch if its Branch is empty.
+	InitialCommit *CreateCommitOptions
+}
+
+// InitRepositoryWithOptions initializes a new Git repository like InitRepository, with the
+// default branch and the initial commit of the options.
+func InitRepositoryWithOptions(repoPath string, opts InitRepositoryOptions) error {
+	if err := InitRepository(repoPath, opts.Bare); err != nil {
+		return err
+	}
+	if opts.DefaultBranch == "" && opts.InitialCommit == nil {
+		return nil
+	}
+
+	repo, err := OpenRepository(repoPath)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	if opts.DefaultBranch != "" {
+		if err := repo.SetDefaultBranch(opts.DefaultBranch); err != nil {
+			return err
+		}
+	}
+	if opts.InitialCommit != nil {
+		commitOpts := *opts.InitialCommit
+		if commitOpts.Branch == "" {
+			if commitOpts.Branch, err = repo.GetDefaultBranch(); err != nil {
+				return err
+			}
+		}
+		if _, err := repo.CreateCommit(commitOpts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // OpenRepository opens the repository at the given path.
 func OpenRepository(repoPath string) (*Repository, error) {
 	repoPath, err := filepath.Abs(repoPath)
@@ -93,7 +148,7 @@ func OpenRepository(repoPath string) (*Repository, error) {
 			return nil, err
 		}
 	}
-	storage := filesystem.NewStorageWithOptions(fs, cache.NewObjectLRUDefault(), filesystem.Options{KeepDescriptors: true})
+	storage := newLockedStorage(newStorage(fs, ObjectCacheMaxMemory))
 	gogitRepo, err := gogit.Open(storage, fs)
 	if err != nil {
 		return nil, err
@@ -107,18 +162,71 @@ func OpenRepository(repoPath string) (*Repository, error) {
 	}, nil
 }
 
+// Close closes the files kept open by the repository. It may still be used afterwards,
+// the files are opened again when needed.
+func (repo *Repository) Close() {
+	repo.catFile.lock.Lock()
+	repo.catFile.stop()
+	repo.catFile.lock.Unlock()
+	repo.checkAttr.lock.Lock()
+	repo.checkAttr.stop()
+	repo.checkAttr.lock.Unlock()
+
+	if err := repo.gogitStorage.Close(); err != nil {
+		log("Close %s: %v", repo.Path, err)
+	}
+}
+
 // IsEmpty Check if repository is empty.
 func (repo *Repository) IsEmpty() (bool, error) {
-	var errbuf strings.Builder
-	if err := NewCommand("log", "-1").RunInDirPipeline(repo.Path, nil, &errbuf); err != nil {
-		if strings.Contains(errbuf.String(), "fatal: bad default revision 'HEAD'") ||
-			strings.Contains(errbuf.String(), "fatal: your current branch 'master' does not have any commits yet") {
-			return true, nil
-		}
-		return true, fmt.Errorf("check empty: %v - %s", err, errbuf.String())
+	head, err := repo.HeadState()
+	if err != nil {
+		return true, fmt.Errorf("check empty: %v", err)
 	}
+	return head.Unborn, nil
+}
 
-	return false, nil
+// HeadState describes what HEAD of a repository points to
+type HeadState struct {
+	// Detached is true if HEAD points to a commit directly instead of a branch
+	Detached bool
+	// Branch is the name of the branch HEAD points to if it is not detached
+	Branch string
+	// Unborn is true if the branch HEAD points to does not exist yet, like in an empty repository
+	Unborn bool
+	// CommitID is the commit HEAD resolves to, it is zero if HEAD is unborn
+	CommitID SHA1
+}
+
+// HeadState returns whether HEAD is detached, the branch it points to otherwise and whether
+// that branch exists yet.
+func (repo *Repository) HeadState() (*HeadState, error) {
+	head, err := repo.gogitRepo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &HeadState{}
+	if head.Type() != plumbing.SymbolicReference {
+		state.Detached = true
+		state.CommitID = head.Hash()
+		return state, nil
+	}
+
+	target := head.Target().String()
+	if !strings.HasPrefix(target, BranchPrefix) {
+		return nil, fmt.Errorf("invalid HEAD branch: %v", target)
+	}
+	state.Branch = target[len(BranchPrefix):]
+	ref, err := repo.gogitRepo.Reference(head.Target(), true)
+	if err == plumbing.ErrReferenceNotFound {
+		state.Unborn = true
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	state.CommitID = ref.Hash()
+	return state, nil
 }
 
 // CloneRepoOptions options when clone a repository
@@ -204,16 +312,25 @@ type PushOptions struct {
 	Branch string
 	Force  bool
 	Env    []string
+	// Username and Token authenticate the push to remotes over HTTP. The token is passed to git
+	// in the environment, never in the URL or the arguments.
+	Username string
+	Token    string
+	Timeout  time.Duration
+	// ForceWithLease maps full names of refs of the remote to the IDs of the commits they are
+	// expected to point at, empty if they must not exist. The push forces their update unless
+	// they moved since, e.g. because of a push which raced the server.
+	ForceWithLease map[string]string
 }
 
 // Push pushs local commits to given remote branch.
+// Unlike Repository.Push, rejected refs are not reported as typed errors.
 func Push(repoPath string, opts PushOptions) error {
-	cmd := NewCommand("push")
-	if opts.Force {
-		cmd.AddArguments("-f")
+	cmd, env := newPushCommand(false, opts.Remote, []string{opts.Branch}, opts)
+	if opts.Timeout <= 0 {
+		opts.Timeout = -1
 	}
-	cmd.AddArguments("--", opts.Remote, opts.Branch)
-	_, err := cmd.RunInDirWithEnv(repoPath, opts.Env)
+	_, err := cmd.RunInDirTimeoutEnv(env, opts.Timeout, repoPath)
 	return err
 }
 
@@ -261,25 +378,35 @@ func MoveFile(repoPath, oldTreeName, newTreeName string) error {
 	return err
 }
 
-// CountObject represents repository count objects report
+// CountObject represents repository count objects report, sizes are in bytes
 type CountObject struct {
-	Count       int64
-	Size        int64
-	InPack      int64
-	Packs       int64
-	SizePack    int64
-	PrunePack   int64
+	// Count and Size are the number and the size of the loose objects
+	Count int64
+	Size  int64
+	// InPack is the number of packed objects, Packs the number of packfiles and SizePack their size
+	InPack   int64
+	Packs    int64
+	SizePack int64
+	// PrunePack is the number of loose objects which are also packed
+	PrunePack int64
+	// Garbage and SizeGarbage are the number and the size of the files in the object database
+	// which are neither objects nor packfiles
 	Garbage     int64
 	SizeGarbage int64
 }
 
+// ObjectsSize returns the disk space taken by the loose and the packed objects
+func (c *CountObject) ObjectsSize() int64 {
+	return c.Size + c.SizePack
+}
+
 const (
 	statCount        = "count: "
 	statSize         = "size: "
 	statInpack       = "in-pack: "
 	statPacks        = "packs: "
 	statSizePack     = "size-pack: "
-	statPrunePackage = "prune-package: "
+	statPrunePackage = "prune-packable: "
 	statGarbage      = "garbage: "
 	statSizeGarbage  = "size-garbage: "
 )
@@ -295,6 +422,50 @@ func GetRepoSize(repoPath string) (*CountObject, error) {
 	return parseSize(stdout), nil
 }
 
+// CountObjects returns the number and the size of the loose and the packed objects of the
+// repository, as reported by `git count-objects -v`
+func (repo *Repository) CountObjects() (*CountObject, error) {
+	return GetRepoSize(repo.Path)
+}
+
+// SizeWithoutLFS returns the disk space taken by the repository itself: its loose and packed
+// objects, the garbage in its object database, its refs and its config. LFS objects are not
+// counted, neither those Gitea keeps outside of the repository nor those git-lfs fetches into
+// its lfs directory, and neither is the worktree of a non-bare repository.
+func (repo *Repository) SizeWithoutLFS() (int64, error) {
+	count, err := repo.CountObjects()
+	if err != nil {
+		return 0, err
+	}
+	size := count.ObjectsSize() + count.SizeGarbage
+
+	gitDir := repo.gogitStorage.Filesystem().Root()
+	for _, name := range []string{"HEAD", "config", "packed-refs"} {
+		info, err := os.Stat(filepath.Join(gitDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, err
+		}
+		size += info.Size()
+	}
+	err = filepath.Walk(filepath.Join(gitDir, "refs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// refs may be removed or packed concurrently
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 // parseSize parses the output from count-objects and return a CountObject
 func parseSize(objects string) *CountObject {
 	repoSize := new(CountObject)
@@ -354,17 +525,23 @@ func checkDivergence(repoPath string, baseBranch string, targetBranch string) (i
 
 // GetDivergingCommits returns the number of commits a targetBranch is ahead or behind a baseBranch
 func GetDivergingCommits(repoPath string, baseBranch string, targetBranch string) (DivergeObject, error) {
-	// $(git rev-list --count master..feature) commits ahead of master
-	ahead, errorAhead := checkDivergence(repoPath, baseBranch, targetBranch)
-	if errorAhead != nil {
-		return DivergeObject{}, errorAhead
-	}
+	diverge, _, err := queries.Do(queryKey(repoPath, "diverging", baseBranch, targetBranch), func() (interface{}, error) {
+		// $(git rev-list --count master..feature) commits ahead of master
+		ahead, errorAhead := checkDivergence(repoPath, baseBranch, targetBranch)
+		if errorAhead != nil {
+			return nil, errorAhead
+		}
 
-	// $(git rev-list --count feature..master) commits behind master
-	behind, errorBehind := checkDivergence(repoPath, targetBranch, baseBranch)
-	if errorBehind != nil {
-		return DivergeObject{}, errorBehind
-	}
+		// $(git rev-list --count feature..master) commits behind master
+		behind, errorBehind := checkDivergence(repoPath, targetBranch, baseBranch)
+		if errorBehind != nil {
+			return nil, errorBehind
+		}
 
-	return DivergeObject{ahead, behind}, nil
+		return DivergeObject{ahead, behind}, nil
+	})
+	if err != nil {
+		return DivergeObject{}, err
+	}
+	return diverge.(DivergeObject), nil
 }
@@ -0,0 +1,305 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttributeValue is the value of a git attribute of a path as reported by `git check-attr`
+type AttributeValue string
+
+// Special attribute values, any other value is the string assigned to the attribute
+const (
+	AttributeUnspecified AttributeValue = "unspecified"
+	AttributeSet         AttributeValue = "set"
+	AttributeUnset       AttributeValue = "unset"
+)
+
+// IsSpecified returns true if the attribute is set, unset or has a value
+func (v AttributeValue) IsSpecified() bool {
+	return v != AttributeUnspecified && v != ""
+}
+
+// Bool returns the value of a boolean attribute like linguist-vendored, which is true if it
+// is set or "true" and false if it is unset or "false". ok is false for any other value.
+func (v AttributeValue) Bool() (value, ok bool) {
+	switch v {
+	case AttributeSet, "true":
+		return true, true
+	case AttributeUnset, "false":
+		return false, true
+	}
+	return false, false
+}
+
+// AttributeMatrix holds the values of some attributes of some paths
+type AttributeMatrix struct {
+	Paths      []string
+	Attributes []string
+	// Values[i][j] is the value of Attributes[j] for Paths[i]
+	Values [][]AttributeValue
+}
+
+// Get returns the value of the attribute for the path, AttributeUnspecified if it has not been checked
+func (m *AttributeMatrix) Get(path, attribute string) AttributeValue {
+	for i := range m.Paths {
+		if m.Paths[i] != path {
+			continue
+		}
+		for j := range m.Attributes {
+			if m.Attributes[j] == attribute {
+				return m.Values[i][j]
+			}
+		}
+	}
+	return AttributeUnspecified
+}
+
+// CheckAttrIdleTimeout is the time after which the check-attr processes of a repository are stopped
+// if they are not used
+var CheckAttrIdleTimeout = time.Minute
+
+// CheckAttributes returns the given attributes of the paths, as defined by the .gitattributes files in
+// the index and by info/attributes. The queries are answered by a long-lived `git check-attr --stdin`
+// process of the repository for the attributes.
+func (repo *Repository) CheckAttributes(paths []string, attributes []string) (*AttributeMatrix, error) {
+	matrix := &AttributeMatrix{
+		Paths:      paths,
+		Attributes: attributes,
+		Values:     make([][]AttributeValue, len(paths)),
+	}
+	if len(paths) == 0 || len(attributes) == 0 {
+		for i := range matrix.Values {
+			matrix.Values[i] = make([]AttributeValue, len(attributes))
+			for j := range matrix.Values[i] {
+				matrix.Values[i][j] = AttributeUnspecified
+			}
+		}
+		return matrix, nil
+	}
+
+	for _, attribute := range attributes {
+		if attribute == "" || strings.HasPrefix(attribute, "-") || strings.ContainsAny(attribute, "\x00 \t\n") {
+			return nil, fmt.Errorf("invalid attribute name: %q", attribute)
+		}
+	}
+	for _, path := range paths {
+		if path == "" || strings.ContainsRune(path, 0) {
+			return nil, fmt.Errorf("invalid path: %q", path)
+		}
+	}
+
+	proc, err := repo.checkAttr.acquire(repo, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.checkAttr.release()
+
+	for i, path := range paths {
+		values, err := proc.check(path)
+		if err != nil {
+			repo.checkAttr.remove(proc)
+			return nil, err
+		}
+		matrix.Values[i] = values
+	}
+	return matrix, nil
+}
+
+// CheckTreeAttribute returns the attribute of the path as defined by the .gitattributes files of the
+// tree with the given id and by info/attributes, e.g. for a file committed without a worktree
+func (repo *Repository) CheckTreeAttribute(treeID SHA1, attribute, path string) (AttributeValue, error) {
+	if attribute == "" || strings.HasPrefix(attribute, "-") || strings.ContainsAny(attribute, "\x00 \t\n") {
+		return "", fmt.Errorf("invalid attribute name: %q", attribute)
+	}
+
+	cmd := NewCommand("check-attr", "-z")
+	var env []string
+	if GetCapabilities().SupportsCheckAttrSource {
+		cmd.AddArguments("--source=" + treeID.String())
+	} else {
+		// older versions only read the attributes of a tree from an index
+		idx, err := repo.NewTemporaryIndex(treeID)
+		if err != nil {
+			return "", err
+		}
+		defer idx.Close()
+		cmd.AddArguments("--cached")
+		env = idx.env
+	}
+	cmd.AddArguments(attribute, "--", path)
+
+	// the attribute is reported as <path> NUL <attribute> NUL <value> NUL
+	stdout, err := cmd.RunInDirTimeoutEnv(env, -1, repo.Path)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Split(string(stdout), "\x00")
+	if len(fields) < 3 || fields[1] != attribute {
+		return "", fmt.Errorf("unexpected check-attr output for %q: %q", path, stdout)
+	}
+	return AttributeValue(fields[2]), nil
+}
+
+// checkAttrProcess wraps a running `git check-attr --stdin -z` process for a fixed list of attributes.
+// An I/O error leaves its output in an unknown state, so the process is killed then and all later
+// checks fail.
+type checkAttrProcess struct {
+	key        string
+	attributes []string
+
+	lock sync.Mutex
+	proc *pipedCommand
+}
+
+func newCheckAttrProcess(repoPath string, attributes []string) (*checkAttrProcess, error) {
+	// make sure the answer for every path is written at once
+	env := append(os.Environ(), "GIT_FLUSH=1")
+	proc, err := NewCommand("check-attr", "--stdin", "-z", "--cached").AddArguments(attributes...).startPiped(env, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return &checkAttrProcess{
+		key:        checkAttrKey(attributes),
+		attributes: attributes,
+		proc:       proc,
+	}, nil
+}
+
+// check returns the values of the attributes of the path
+func (p *checkAttrProcess) check(path string) ([]AttributeValue, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.proc == nil {
+		return nil, fmt.Errorf("check-attr is closed")
+	}
+	if _, err := io.WriteString(p.proc.stdin, path+"\x00"); err != nil {
+		return nil, p.fail(err)
+	}
+
+	// every attribute is reported as <path> NUL <attribute> NUL <value> NUL
+	values := make([]AttributeValue, len(p.attributes))
+	for j := range p.attributes {
+		var fields [3]string
+		for k := range fields {
+			field, err := p.proc.stdout.ReadString(0)
+			if err != nil {
+				return nil, p.fail(err)
+			}
+			fields[k] = strings.TrimSuffix(field, "\x00")
+		}
+		if fields[1] != p.attributes[j] {
+			return nil, p.fail(fmt.Errorf("unexpected check-attr output for %q: %q", path, fields[1]))
+		}
+		values[j] = AttributeValue(fields[2])
+	}
+	return values, nil
+}
+
+// fail kills the process after err made its output unusable and returns err together with
+// what the process wrote to stderr. The lock must be held.
+func (p *checkAttrProcess) fail(err error) error {
+	proc := p.proc
+	p.proc = nil
+	_ = proc.kill()
+	return concatenateError(err, proc.stderr.String())
+}
+
+// Close terminates the underlying process.
+func (p *checkAttrProcess) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.proc == nil {
+		return nil
+	}
+	proc := p.proc
+	p.proc = nil
+	return proc.close()
+}
+
+func checkAttrKey(attributes []string) string {
+	return strings.Join(attributes, "\x00")
+}
+
+// checkAttrProcesses are the long-lived check-attr processes of a repository, one for every list of
+// attributes. They are started when needed and stopped once they have not been used for CheckAttrIdleTimeout.
+type checkAttrProcesses struct {
+	lock      sync.Mutex
+	processes map[string]*checkAttrProcess
+	users     int
+	lastUsed  time.Time
+	timer     *time.Timer
+}
+
+func (p *checkAttrProcesses) acquire(repo *Repository, attributes []string) (*checkAttrProcess, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := checkAttrKey(attributes)
+	proc, ok := p.processes[key]
+	if !ok {
+		var err error
+		proc, err = newCheckAttrProcess(repo.Path, append([]string(nil), attributes...))
+		if err != nil {
+			return nil, err
+		}
+		if p.processes == nil {
+			p.processes = make(map[string]*checkAttrProcess)
+		}
+		p.processes[key] = proc
+	}
+	p.users++
+	return proc, nil
+}
+
+func (p *checkAttrProcesses) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.users--
+	p.lastUsed = time.Now()
+	if p.timer == nil {
+		p.timer = time.AfterFunc(CheckAttrIdleTimeout, p.stopIdle)
+	}
+}
+
+// remove stops a process which failed, a new one is started when needed
+func (p *checkAttrProcesses) remove(proc *checkAttrProcess) {
+	p.lock.Lock()
+	if p.processes[proc.key] == proc {
+		delete(p.processes, proc.key)
+	}
+	p.lock.Unlock()
+
+	_ = proc.Close()
+}
+
+func (p *checkAttrProcesses) stopIdle() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.timer = nil
+	if idle := time.Since(p.lastUsed); p.users > 0 || idle < CheckAttrIdleTimeout {
+		p.timer = time.AfterFunc(CheckAttrIdleTimeout-idle, p.stopIdle)
+		return
+	}
+	p.stop()
+}
+
+// stop terminates the processes, they are started again when needed
+func (p *checkAttrProcesses) stop() {
+	for key, proc := range p.processes {
+		_ = proc.Close()
+		delete(p.processes, key)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_CheckAttributes(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath := filepath.Join(testReposDir, "repo1_TestRepository_CheckAttributes")
+	assert.NoError(t, Clone(bareRepo1Path, clonedPath, CloneRepoOptions{Bare: true, Quiet: true, Timeout: time.Minute}))
+	defer os.RemoveAll(clonedPath)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(clonedPath, "info"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(clonedPath, "info", "attributes"),
+		[]byte("*.bin filter=lfs -diff\nvendor/** linguist-vendored\n*.txt linguist-vendored=false\n"), 0644))
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	paths := []string{"file.bin", "vendor/lib.go", "file1.txt", "file with spaces"}
+	attributes := []string{"filter", "diff", "linguist-vendored"}
+	for i := 0; i < 2; i++ {
+		matrix, err := repo.CheckAttributes(paths, attributes)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]AttributeValue{
+			{"lfs", AttributeUnset, AttributeUnspecified},
+			{AttributeUnspecified, AttributeUnspecified, AttributeSet},
+			{AttributeUnspecified, AttributeUnspecified, "false"},
+			{AttributeUnspecified, AttributeUnspecified, AttributeUnspecified},
+		}, matrix.Values)
+		assert.EqualValues(t, "lfs", matrix.Get("file.bin", "filter"))
+		assert.Equal(t, AttributeUnspecified, matrix.Get("file.bin", "text"))
+
+		vendored, ok := matrix.Get("vendor/lib.go", "linguist-vendored").Bool()
+		assert.True(t, ok)
+		assert.True(t, vendored)
+		vendored, ok = matrix.Get("file1.txt", "linguist-vendored").Bool()
+		assert.True(t, ok)
+		assert.False(t, vendored)
+		assert.False(t, matrix.Get("file with spaces", "filter").IsSpecified())
+	}
+
+	_, err = repo.CheckAttributes([]string{"file.bin"}, []string{"-diff"})
+	assert.Error(t, err)
+
+	// the process is reused for the same attributes
+	repo.checkAttr.lock.Lock()
+	assert.Len(t, repo.checkAttr.processes, 1)
+	repo.checkAttr.lock.Unlock()
+
+	repo.checkAttr.lock.Lock()
+	repo.checkAttr.stop()
+	repo.checkAttr.lock.Unlock()
+	matrix, err := repo.CheckAttributes([]string{"file.bin"}, []string{"filter"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "lfs", matrix.Get("file.bin", "filter"))
+
+	// a process which died fails the check and is replaced
+	repo.checkAttr.lock.Lock()
+	for _, proc := range repo.checkAttr.processes {
+		assert.NoError(t, proc.proc.cmd.Process.Kill())
+	}
+	repo.checkAttr.lock.Unlock()
+	_, err = repo.CheckAttributes([]string{"file.bin"}, []string{"filter"})
+	assert.Error(t, err)
+	matrix, err = repo.CheckAttributes([]string{"file.bin"}, []string{"filter"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "lfs", matrix.Get("file.bin", "filter"))
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HasBitmapIndex returns true if one of the packfiles of the repository has a reachability bitmap
+func (repo *Repository) HasBitmapIndex() bool {
+	bitmaps, err := filepath.Glob(filepath.Join(repo.Path, "objects", "pack", "*.bitmap"))
+	return err == nil && len(bitmaps) > 0
+}
+
+// WriteBitmapIndex repacks all objects of the repository into a single packfile with a reachability
+// bitmap, which lets object counting and reachability queries skip walking the history.
+// The packfiles are replaced, so open repositories must be invalidated afterwards.
+func (repo *Repository) WriteBitmapIndex(timeout time.Duration) error {
+	defer LockWrites(repo.Path)()
+	return DefaultRetryPolicy.Do(func() error {
+		_, err := NewCommand("repack", "-a", "-d", "-b", "-q").RunInDirTimeout(timeout, repo.Path)
+		return err
+	})
+}
+
+// CountReachableObjects returns the number of objects reachable from the revisions, or from all
+// references if none is given, using the reachability bitmap if there is one
+func (repo *Repository) CountReachableObjects(revisions ...string) (int64, error) {
+	cmd := NewCommand("rev-list", "--count", "--objects", "--use-bitmap-index")
+	if len(revisions) == 0 {
+		cmd.AddArguments("--all")
+	}
+	for _, revision := range revisions {
+		if strings.HasPrefix(revision, "-") {
+			return 0, fmt.Errorf("invalid revision: %s", revision)
+		}
+		cmd.AddArguments(revision)
+	}
+
+	stdout, err := cmd.RunInDir(repo.Path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+}
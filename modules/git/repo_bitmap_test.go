@@ -0,0 +1,46 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_WriteBitmapIndex(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath := filepath.Join(testReposDir, "repo1_TestRepository_WriteBitmapIndex")
+	assert.NoError(t, Clone(bareRepo1Path, clonedPath, CloneRepoOptions{Bare: true, Quiet: true, Timeout: time.Minute}))
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	count, err := repo.CountReachableObjects()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 34, count)
+
+	assert.NoError(t, repo.WriteBitmapIndex(time.Minute))
+	assert.True(t, repo.HasBitmapIndex())
+
+	count, err = repo.CountReachableObjects()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 34, count)
+
+	commitsCount, err := CommitsCountWithOptions(clonedPath, CommitsCountOptions{
+		Revisions: []string{"master"},
+		Not:       []string{"branch1"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, commitsCount)
+
+	_, err = repo.CountReachableObjects("--all")
+	assert.Error(t, err)
+}
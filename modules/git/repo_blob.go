@@ -28,3 +28,28 @@ func (repo *Repository) GetBlob(idStr string) (*Blob, error) {
 	}
 	return repo.getBlob(id)
 }
+
+// GetBlobByRefPath returns the blob at path in the commit the ref (a branch, tag or commit ID)
+// points to. It returns ErrRefNotExist if the ref cannot be resolved, ErrNotExist if there is
+// nothing at path and ErrNotBlob if path is a directory or a submodule.
+func (repo *Repository) GetBlobByRefPath(ref, path string) (*Blob, error) {
+	commit, err := repo.GetCommit(ref)
+	if err != nil {
+		if IsErrNotExist(err) || err == plumbing.ErrObjectNotFound {
+			return nil, ErrRefNotExist{ref}
+		}
+		return nil, err
+	}
+
+	entry, err := commit.GetTreeEntryByPath(path)
+	if err != nil {
+		if IsErrNotExist(err) || err == plumbing.ErrObjectNotFound || err == plumbing.ErrInvalidType {
+			return nil, ErrNotExist{commit.ID.String(), path}
+		}
+		return nil, err
+	}
+	if entry.IsDir() || entry.IsSubModule() {
+		return nil, ErrNotBlob{path, ObjectType(entry.Type())}
+	}
+	return entry.Blob(), nil
+}
@@ -65,3 +65,36 @@ func TestRepository_GetBlob_NoId(t *testing.T) {
 	assert.Nil(t, blob)
 	assert.EqualError(t, err, testError.Error())
 }
+
+func TestRepository_GetBlobByRefPath(t *testing.T) {
+	repoPath := filepath.Join(testReposDir, "repo1_bare")
+	r, err := OpenRepository(repoPath)
+	assert.NoError(t, err)
+
+	for _, ref := range []string{"master", "refs/heads/master", "test", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", "feaf4ba6"} {
+		blob, err := r.GetBlobByRefPath(ref, "file1.txt")
+		if assert.NoError(t, err, ref) {
+			assert.Equal(t, "e2129701f1a4d54dc44f03c93bca0a2aec7c5449", blob.ID.String())
+		}
+	}
+
+	blob, err := r.GetBlobByRefPath("master", "foo/nar/hello")
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 3, blob.Size())
+	}
+
+	_, err = r.GetBlobByRefPath("no-such-branch", "file1.txt")
+	assert.True(t, IsErrRefNotExist(err), "%v", err)
+	_, err = r.GetBlobByRefPath("0000000000000000000000000000000000000000", "file1.txt")
+	assert.True(t, IsErrRefNotExist(err), "%v", err)
+
+	for _, path := range []string{"no-such-file", "foo/no-such-file", "no/such/file", "file1.txt/foo"} {
+		_, err = r.GetBlobByRefPath("master", path)
+		assert.True(t, IsErrNotExist(err), "%s: %v", path, err)
+	}
+
+	_, err = r.GetBlobByRefPath("master", "foo")
+	if assert.True(t, IsErrNotBlob(err), "%v", err) {
+		assert.Equal(t, ObjectTree, err.(ErrNotBlob).Type)
+	}
+}
@@ -6,8 +6,12 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 )
@@ -32,6 +36,30 @@ func (repo *Repository) IsBranchExist(name string) bool {
 	return err == nil
 }
 
+// BranchesExist reports for each of the names whether a branch of that name exists.
+// All branches are looked up by a single `for-each-ref`.
+func (repo *Repository) BranchesExist(names []string) (map[string]bool, error) {
+	exist := make(map[string]bool, len(names))
+	if len(names) == 0 {
+		return exist, nil
+	}
+
+	stdout, err := NewCommand("for-each-ref", "--format=%(refname)", BranchPrefix).RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	branches := make(map[string]bool)
+	for _, ref := range splitLines(stdout) {
+		if bytes.HasPrefix(ref, []byte(BranchPrefix)) {
+			branches[string(ref[len(BranchPrefix):])] = true
+		}
+	}
+	for _, name := range names {
+		exist[name] = branches[name]
+	}
+	return exist, nil
+}
+
 // Branch represents a Git branch.
 type Branch struct {
 	Name string
@@ -42,27 +70,49 @@ type Branch struct {
 
 // GetHEADBranch returns corresponding branch of HEAD.
 func (repo *Repository) GetHEADBranch() (*Branch, error) {
-	stdout, err := NewCommand("symbolic-ref", "HEAD").RunInDir(repo.Path)
+	name, err := repo.GetDefaultBranch()
 	if err != nil {
 		return nil, err
 	}
-	stdout = strings.TrimSpace(stdout)
-
-	if !strings.HasPrefix(stdout, BranchPrefix) {
-		return nil, fmt.Errorf("invalid HEAD branch: %v", stdout)
-	}
 
 	return &Branch{
-		Name:    stdout[len(BranchPrefix):],
-		Path:    stdout,
+		Name:    name,
+		Path:    BranchPrefix + name,
 		gitRepo: repo,
 	}, nil
 }
 
-// SetDefaultBranch sets default branch of repository.
+// GetDefaultBranch returns the name of the branch HEAD points to. In an empty repository
+// HEAD is unborn, the returned branch does not exist until something is pushed to it.
+func (repo *Repository) GetDefaultBranch() (string, error) {
+	head, err := repo.HeadState()
+	if err != nil {
+		return "", err
+	}
+	if head.Detached {
+		return "", fmt.Errorf("HEAD is detached")
+	}
+	return head.Branch, nil
+}
+
+// SetDefaultBranch sets default branch of repository. The branch must exist unless the
+// repository has no branches at all, in which case HEAD is left unborn.
 func (repo *Repository) SetDefaultBranch(name string) error {
-	_, err := NewCommand("symbolic-ref", "HEAD", BranchPrefix+name).RunInDir(repo.Path)
-	return err
+	if _, err := NewCommand("check-ref-format", BranchPrefix+name).RunInDir(repo.Path); err != nil {
+		return fmt.Errorf("invalid branch name: %s", name)
+	}
+	if !repo.IsBranchExist(name) {
+		branches, err := repo.GetBranches()
+		if err != nil {
+			return err
+		}
+		if len(branches) > 0 {
+			return ErrBranchNotExist{name}
+		}
+	}
+
+	defer LockWrites(repo.Path)()
+	return NewCommand("symbolic-ref", "HEAD", BranchPrefix+name).runInDirWithRetry(repo.Path)
 }
 
 // GetBranches returns all branches of the repository.
@@ -84,6 +134,206 @@ func (repo *Repository) GetBranches() ([]string, error) {
 	return branchNames, nil
 }
 
+// BranchSort defines the order of the branches returned by GetBranchesSorted
+type BranchSort int
+
+// BranchSort values
+const (
+	// BranchSortName sorts the branches by name
+	BranchSortName BranchSort = iota
+	// BranchSortCommitterDate puts the most recently updated branches first
+	BranchSortCommitterDate
+	// BranchSortAhead puts the branches with the most commits not in HEAD first
+	BranchSortAhead
+	// BranchSortBehind puts the branches missing the most commits of HEAD first
+	BranchSortBehind
+)
+
+// BranchInfo holds the information about a branch needed to list it
+type BranchInfo struct {
+	Name          string
+	CommitID      SHA1
+	CommitterDate time.Time
+	// Number of commits the branch is ahead of and behind HEAD, or the base branch
+	// passed to GetBranchesMergeStatus
+	Ahead  int
+	Behind int
+	// Merged is only set by GetBranchesMergeStatus, it is true if all commits of the
+	// branch are contained in the base branch
+	Merged bool
+}
+
+// GetBranchesSorted returns at most limit branches after skipping the first skip ones in the
+// given order, together with the total number of branches. A limit <= 0 returns all remaining
+// branches. The ahead/behind counts relative to HEAD are filled in for the returned branches.
+func (repo *Repository) GetBranchesSorted(skip, limit int, order BranchSort) ([]*BranchInfo, int, error) {
+	return repo.GetBranchesSortedAgainst("HEAD", skip, limit, order)
+}
+
+// GetBranchesSortedAgainst is GetBranchesSorted with the ahead/behind counts relative to the
+// base commit-ish instead of HEAD. With git 2.41 or newer everything is computed by a single
+// `for-each-ref`, older versions need one `rev-list` per returned branch, or per branch at all
+// when sorting by the ahead/behind counts.
+func (repo *Repository) GetBranchesSortedAgainst(base string, skip, limit int, order BranchSort) ([]*BranchInfo, int, error) {
+	format := "--format=%(refname:strip=2)%00%(objectname)%00%(committerdate:unix)"
+	// Newer versions of git compute the divergence of all branches in one go
+	withAheadBehind := GetCapabilities().SupportsAheadBehind
+	if withAheadBehind {
+		format += "%00%(ahead-behind:" + base + ")"
+	}
+	cmd := NewCommand("for-each-ref", format, "--sort=refname")
+	if order == BranchSortCommitterDate {
+		cmd.AddArguments("--sort=-committerdate")
+	}
+	cmd.AddArguments(BranchPrefix)
+
+	stdout, err := cmd.RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	branches, err := parseBranchInfos(stdout, withAheadBehind)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(branches)
+
+	if order == BranchSortAhead || order == BranchSortBehind {
+		// The divergence of every branch is needed to sort them
+		if !withAheadBehind {
+			if err := repo.fillAheadBehind(base, branches); err != nil {
+				return nil, 0, err
+			}
+		}
+		sort.SliceStable(branches, func(i, j int) bool {
+			if order == BranchSortAhead {
+				return branches[i].Ahead > branches[j].Ahead
+			}
+			return branches[i].Behind > branches[j].Behind
+		})
+		withAheadBehind = true
+	}
+
+	if skip >= total {
+		return []*BranchInfo{}, total, nil
+	}
+	end := total
+	if limit > 0 && skip+limit < total {
+		end = skip + limit
+	}
+	branches = branches[skip:end]
+
+	if !withAheadBehind {
+		if err := repo.fillAheadBehind(base, branches); err != nil {
+			return nil, 0, err
+		}
+	}
+	return branches, total, nil
+}
+
+func parseBranchInfos(data []byte, withAheadBehind bool) ([]*BranchInfo, error) {
+	numFields := 3
+	if withAheadBehind {
+		numFields = 4
+	}
+
+	branches := make([]*BranchInfo, 0)
+	for _, line := range splitLines(data) {
+		if len(line) == 0 {
+			continue
+		}
+		fields := bytes.Split(line, []byte{0})
+		if len(fields) != numFields {
+			return nil, fmt.Errorf("invalid for-each-ref output: %s", line)
+		}
+
+		id, err := NewIDFromString(string(fields[1]))
+		if err != nil {
+			return nil, err
+		}
+		unix, err := strconv.ParseInt(string(fields[2]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		branch := &BranchInfo{
+			Name:          string(fields[0]),
+			CommitID:      id,
+			CommitterDate: time.Unix(unix, 0),
+		}
+		if withAheadBehind {
+			if _, err := fmt.Sscanf(string(fields[3]), "%d %d", &branch.Ahead, &branch.Behind); err != nil {
+				return nil, fmt.Errorf("invalid ahead-behind value: %s", fields[3])
+			}
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// GetBranchesMergeStatus returns all branches sorted by name, with the number of commits they are ahead
+// of and behind the base branch and whether they have been merged into it, to find stale branches.
+// With git 2.41 or newer all of this is computed by a single `for-each-ref`, older versions need
+// one more `for-each-ref` and a `rev-list` per branch.
+func (repo *Repository) GetBranchesMergeStatus(base string) ([]*BranchInfo, error) {
+	baseID, err := repo.GetBranchCommitID(base)
+	if err != nil {
+		if IsErrNotExist(err) {
+			return nil, ErrBranchNotExist{base}
+		}
+		return nil, err
+	}
+
+	format := "--format=%(refname:strip=2)%00%(objectname)%00%(committerdate:unix)"
+	withAheadBehind := GetCapabilities().SupportsAheadBehind
+	if withAheadBehind {
+		format += "%00%(ahead-behind:" + baseID + ")"
+	}
+	stdout, err := NewCommand("for-each-ref", format, "--sort=refname", BranchPrefix).RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	branches, err := parseBranchInfos(stdout, withAheadBehind)
+	if err != nil {
+		return nil, err
+	}
+
+	if withAheadBehind {
+		for _, branch := range branches {
+			branch.Merged = branch.Ahead == 0
+		}
+		return branches, nil
+	}
+
+	stdout, err = NewCommand("for-each-ref", "--format=%(refname:strip=2)", "--merged", baseID, BranchPrefix).RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]bool)
+	for _, name := range splitLines(stdout) {
+		merged[string(name)] = true
+	}
+	for _, branch := range branches {
+		branch.Merged = merged[branch.Name]
+	}
+	if err := repo.fillAheadBehind(baseID, branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// fillAheadBehind counts the commits the branches are ahead of and behind base
+func (repo *Repository) fillAheadBehind(base string, branches []*BranchInfo) error {
+	for _, branch := range branches {
+		stdout, err := NewCommand("rev-list", "--left-right", "--count", base+"..."+branch.CommitID.String()).RunInDir(repo.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Sscanf(stdout, "%d %d", &branch.Behind, &branch.Ahead); err != nil {
+			return fmt.Errorf("invalid rev-list --count output: %s", stdout)
+		}
+	}
+	return nil
+}
+
 // GetBranch returns a branch by it's name
 func (repo *Repository) GetBranch(branch string) (*Branch, error) {
 	if !repo.IsBranchExist(branch) {
@@ -126,31 +376,110 @@ type DeleteBranchOptions struct {
 }
 
 // DeleteBranch delete a branch by name on repository.
+// Unless opts.Force is set, the branch must be merged into HEAD.
 func (repo *Repository) DeleteBranch(name string, opts DeleteBranchOptions) error {
-	cmd := NewCommand("branch")
-
-	if opts.Force {
-		cmd.AddArguments("-D")
-	} else {
-		cmd.AddArguments("-d")
+	if !repo.IsBranchExist(name) {
+		return ErrBranchNotExist{name}
 	}
 
-	cmd.AddArguments("--", name)
-	_, err := cmd.RunInDir(repo.Path)
+	defer LockWrites(repo.Path)()
+	if !opts.Force {
+		return NewCommand("branch", "-d", "--", name).runInDirWithRetry(repo.Path)
+	}
 
+	if err := NewCommand("update-ref", "-d", "--", BranchPrefix+name).runInDirWithRetry(repo.Path); err != nil {
+		return err
+	}
+	// update-ref leaves the branch.<name>.* configuration behind, which branch -D would remove.
+	_, err := NewCommand("config", "--remove-section", "branch."+name).RunInDir(repo.Path)
+	if cmdErr, ok := err.(CommandError); ok && strings.Contains(cmdErr.Stderr, "no such section") {
+		return nil
+	}
 	return err
 }
 
 // CreateBranch create a new branch
 func (repo *Repository) CreateBranch(branch, oldbranchOrCommit string) error {
-	cmd := NewCommand("branch")
-	cmd.AddArguments("--", branch, oldbranchOrCommit)
+	commitID, err := repo.ConvertToSHA1(oldbranchOrCommit)
+	if err != nil {
+		return err
+	}
 
-	_, err := cmd.RunInDir(repo.Path)
+	// The zero old value makes the update fail if the branch already exists
+	err = repo.UpdateRef(BranchPrefix+branch, commitID.String(), EmptySHA)
+	if IsErrRefChanged(err) {
+		return ErrBranchAlreadyExists{branch}
+	}
+	return err
+}
+
+// CreateOrphanBranch creates a new branch with a root commit of the files of initial, e.g. for
+// gh-pages style branches, and returns the ID of the commit. The branch and the new branch of
+// initial are ignored, the commit is based on an empty tree.
+func (repo *Repository) CreateOrphanBranch(name string, initial CreateCommitOptions) (SHA1, error) {
+	if repo.IsBranchExist(name) {
+		return SHA1{}, ErrBranchAlreadyExists{name}
+	}
+	initial.Branch, initial.NewBranch, initial.LastCommitID = name, "", ""
+
+	// The zero old value makes the update fail if the branch has been created in the meantime
+	commitID, err := repo.createCommit(initial, "")
+	if IsErrRefChanged(err) {
+		return SHA1{}, ErrBranchAlreadyExists{name}
+	}
+	return commitID, err
+}
 
+// RenameBranch renames a branch, HEAD is updated too if it points to the branch.
+func (repo *Repository) RenameBranch(from, to string) error {
+	if !repo.IsBranchExist(from) {
+		return ErrBranchNotExist{from}
+	}
+	if repo.IsBranchExist(to) {
+		return ErrBranchAlreadyExists{to}
+	}
+
+	defer LockWrites(repo.Path)()
+	err := NewCommand("branch", "-m", "--", from, to).runInDirWithRetry(repo.Path)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return ErrBranchAlreadyExists{to}
+	}
 	return err
 }
 
+// UpdateBranch moves an existing branch to the given commit. Unless force is set, the new
+// commit must descend from the current one, otherwise ErrNotFastForward is returned.
+func (repo *Repository) UpdateBranch(name, commitID string, force bool) error {
+	oldCommitID, err := repo.GetBranchCommitID(name)
+	if err != nil {
+		if IsErrNotExist(err) {
+			return ErrBranchNotExist{name}
+		}
+		return err
+	}
+	newID, err := repo.ConvertToSHA1(commitID)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		oldID, err := NewIDFromString(oldCommitID)
+		if err != nil {
+			return err
+		}
+		ok, err := repo.isAncestor(oldID, newID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFastForward{name, oldCommitID, newID.String()}
+		}
+	}
+
+	// Fail if somebody else has moved the branch in the meantime
+	return repo.UpdateRef(BranchPrefix+name, newID.String(), oldCommitID)
+}
+
 // AddRemote adds a new remote to repository.
 func (repo *Repository) AddRemote(name, url string, fetch bool) error {
 	cmd := NewCommand("remote", "add")
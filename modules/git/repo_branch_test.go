@@ -5,10 +5,14 @@
 package git
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRepository_GetBranches(t *testing.T) {
@@ -37,3 +41,217 @@ func BenchmarkRepository_GetBranches(b *testing.B) {
 		}
 	}
 }
+
+func TestRepository_BranchesExist(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	exist, err := bareRepo1.BranchesExist([]string{"master", "branch2", "test", "branch", "refs/heads/master"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"master":            true,
+		"branch2":           true,
+		"test":              false,
+		"branch":            false,
+		"refs/heads/master": false,
+	}, exist)
+
+	exist, err = bareRepo1.BranchesExist(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, exist)
+}
+
+func TestRepository_GetBranchesSorted(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	names := func(branches []*BranchInfo) []string {
+		var names []string
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		return names
+	}
+
+	branches, total, err := bareRepo1.GetBranchesSorted(0, 0, BranchSortName)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"branch1", "branch2", "master"}, names(branches))
+	if assert.Len(t, branches, 3) {
+		assert.Equal(t, "2839944139e0de9737a044f78b0e4b40d989a9e3", branches[0].CommitID.String())
+		assert.EqualValues(t, 1513750592, branches[0].CommitterDate.Unix())
+		assert.Equal(t, 2, branches[0].Ahead)
+		assert.Equal(t, 5, branches[0].Behind)
+		assert.Equal(t, 0, branches[2].Ahead)
+		assert.Equal(t, 0, branches[2].Behind)
+	}
+
+	branches, total, err = bareRepo1.GetBranchesSorted(1, 1, BranchSortCommitterDate)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"branch2"}, names(branches))
+
+	branches, _, err = bareRepo1.GetBranchesSorted(0, 2, BranchSortAhead)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"branch1", "branch2"}, names(branches))
+
+	branches, _, err = bareRepo1.GetBranchesSorted(1, 0, BranchSortBehind)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"branch2", "master"}, names(branches))
+
+	branches, total, err = bareRepo1.GetBranchesSorted(5, 10, BranchSortName)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Empty(t, branches)
+
+	branches, _, err = bareRepo1.GetBranchesSortedAgainst(BranchPrefix+"branch1", 0, 0, BranchSortName)
+	assert.NoError(t, err)
+	if assert.Len(t, branches, 3) {
+		assert.Equal(t, 0, branches[0].Ahead)
+		assert.Equal(t, 0, branches[0].Behind)
+		assert.Equal(t, 5, branches[2].Ahead)
+		assert.Equal(t, 2, branches[2].Behind)
+	}
+}
+
+func TestRepository_GetBranchesMergeStatus(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_GetBranchesMergeStatus")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.CreateBranch("branch1", "2839944139e0de9737a044f78b0e4b40d989a9e3"))
+	assert.NoError(t, repo.CreateBranch("merged", "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"))
+
+	branches, err := repo.GetBranchesMergeStatus("master")
+	assert.NoError(t, err)
+	type status struct {
+		Name          string
+		Ahead, Behind int
+		Merged        bool
+	}
+	var statuses []status
+	for _, branch := range branches {
+		statuses = append(statuses, status{branch.Name, branch.Ahead, branch.Behind, branch.Merged})
+	}
+	assert.Equal(t, []status{
+		{"branch1", 2, 5, false},
+		{"master", 0, 0, true},
+		{"merged", 0, 4, true},
+	}, statuses)
+
+	_, err = repo.GetBranchesMergeStatus("no-such-branch")
+	assert.True(t, IsErrBranchNotExist(err))
+}
+
+func TestRepository_BranchOperations(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_BranchOperations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	// branch1 is not in master
+	assert.NoError(t, repo.CreateBranch("branch1", "origin/branch1"))
+	assert.True(t, IsErrBranchAlreadyExists(repo.CreateBranch("branch1", "master")))
+	assert.Error(t, repo.CreateBranch("new", "no-such-branch"))
+
+	assert.NoError(t, repo.CreateBranch("new", "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"))
+	commitID, err := repo.GetBranchCommitID("new")
+	assert.NoError(t, err)
+	assert.Equal(t, "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", commitID)
+
+	assert.NoError(t, repo.UpdateBranch("new", "master", false))
+	err = repo.UpdateBranch("new", "branch1", false)
+	assert.True(t, IsErrNotFastForward(err), "%v", err)
+	assert.NoError(t, repo.UpdateBranch("new", "branch1", true))
+	assert.True(t, IsErrBranchNotExist(repo.UpdateBranch("no-such-branch", "master", true)))
+
+	assert.True(t, IsErrBranchAlreadyExists(repo.RenameBranch("new", "master")))
+	assert.True(t, IsErrBranchNotExist(repo.RenameBranch("no-such-branch", "renamed")))
+	assert.NoError(t, repo.RenameBranch("new", "renamed"))
+	assert.False(t, repo.IsBranchExist("new"))
+	assert.True(t, repo.IsBranchExist("renamed"))
+
+	// renamed has the same commits as branch1, which is not merged into master
+	assert.Error(t, repo.DeleteBranch("renamed", DeleteBranchOptions{}))
+	_, err = NewCommand("config", "branch.renamed.description", "Renamed").RunInDir(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DeleteBranch("renamed", DeleteBranchOptions{Force: true}))
+	assert.False(t, repo.IsBranchExist("renamed"))
+	_, err = NewCommand("config", "branch.renamed.description").RunInDir(clonedPath)
+	assert.Error(t, err)
+	assert.NoError(t, repo.CreateBranch("no-config", "master"))
+	assert.NoError(t, repo.DeleteBranch("no-config", DeleteBranchOptions{Force: true}))
+	assert.True(t, IsErrBranchNotExist(repo.DeleteBranch("renamed", DeleteBranchOptions{Force: true})))
+}
+
+func TestRepository_DefaultBranch(t *testing.T) {
+	emptyPath, err := ioutil.TempDir("", "repo-default-branch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(emptyPath)
+	assert.NoError(t, InitRepository(emptyPath, true))
+	emptyRepo, err := OpenRepository(emptyPath)
+	assert.NoError(t, err)
+
+	// HEAD of an empty repository is unborn, but can still be changed
+	assert.NoError(t, emptyRepo.SetDefaultBranch("main"))
+	name, err := emptyRepo.GetDefaultBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "main", name)
+
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_DefaultBranch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	name, err = repo.GetDefaultBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "master", name)
+
+	assert.True(t, IsErrBranchNotExist(repo.SetDefaultBranch("no-such-branch")))
+	assert.Error(t, repo.SetDefaultBranch("invalid..name"))
+	assert.NoError(t, repo.CreateBranch("develop", "master"))
+	assert.NoError(t, repo.SetDefaultBranch("develop"))
+	branch, err := repo.GetHEADBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "develop", branch.Name)
+	assert.Equal(t, BranchPrefix+"develop", branch.Path)
+
+	_, err = NewCommand("update-ref", "--no-deref", "HEAD", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2").RunInDir(clonedPath)
+	assert.NoError(t, err)
+	_, err = repo.GetDefaultBranch()
+	assert.Error(t, err)
+}
+
+func TestRepository_CreateOrphanBranch(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	commitID, err := repo.CreateOrphanBranch("gh-pages", CreateCommitOptions{
+		Branch:       "master",
+		LastCommitID: "master",
+		Message:      "Initial pages",
+		Files:        []FileOperation{{Type: FileOperationCreate, Path: "index.html", Content: strings.NewReader("<html>\n")}},
+	})
+	require.NoError(t, err)
+	commit, err := repo.GetBranchCommit("gh-pages")
+	require.NoError(t, err)
+	assert.Equal(t, commitID, commit.ID)
+	assert.EqualValues(t, 0, commit.ParentCount())
+	entries, err := commit.Tree.ListEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "index.html", entries[0].Name())
+
+	_, err = repo.CreateOrphanBranch("master", CreateCommitOptions{Message: "again"})
+	assert.True(t, IsErrBranchAlreadyExists(err))
+}
@@ -9,10 +9,10 @@ import (
 	"bytes"
 	"container/list"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/mcuadros/go-version"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
@@ -21,6 +21,9 @@ import (
 func (repo *Repository) GetRefCommitID(name string) (string, error) {
 	ref, err := repo.gogitRepo.Reference(plumbing.ReferenceName(name), true)
 	if err != nil {
+		if err == plumbing.ErrReferenceNotFound {
+			return "", ErrNotExist{name, ""}
+		}
 		return "", err
 	}
 
@@ -41,14 +44,19 @@ func (repo *Repository) GetBranchCommitID(name string) (string, error) {
 
 // GetTagCommitID returns last commit ID string of given tag.
 func (repo *Repository) GetTagCommitID(name string) (string, error) {
-	stdout, err := NewCommand("rev-list", "-n", "1", name).RunInDir(repo.Path)
+	ref, err := repo.gogitRepo.Reference(plumbing.ReferenceName(TagPrefix+name), true)
 	if err != nil {
-		if strings.Contains(err.Error(), "unknown revision or path") {
+		if err == plumbing.ErrReferenceNotFound {
 			return "", ErrNotExist{name, ""}
 		}
 		return "", err
 	}
-	return strings.TrimSpace(stdout), nil
+
+	id, err := repo.peelToCommit(ref.Hash())
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
 }
 
 func convertPGPSignatureForTag(t *object.Tag) *CommitGPGSignature {
@@ -84,6 +92,10 @@ func convertPGPSignatureForTag(t *object.Tag) *CommitGPGSignature {
 }
 
 func (repo *Repository) getCommit(id SHA1) (*Commit, error) {
+	if commit := globalCommitCache.Get(repo, id); commit != nil {
+		return commit, nil
+	}
+
 	var tagObject *object.Tag
 
 	gogitCommit, err := repo.gogitRepo.CommitObject(id)
@@ -106,14 +118,10 @@ func (repo *Repository) getCommit(id SHA1) (*Commit, error) {
 		commit.Signature = convertPGPSignatureForTag(tagObject)
 	}
 
-	tree, err := gogitCommit.Tree()
-	if err != nil {
-		return nil, err
-	}
-
-	commit.Tree.ID = tree.Hash
-	commit.Tree.gogitTree = tree
+	// the tree object is read when its entries are first needed
+	commit.Tree.ID = gogitCommit.TreeHash
 
+	globalCommitCache.Set(repo, id, commit)
 	return commit, nil
 }
 
@@ -121,10 +129,9 @@ func (repo *Repository) getCommit(id SHA1) (*Commit, error) {
 func (repo *Repository) ConvertToSHA1(commitID string) (SHA1, error) {
 	if len(commitID) != 40 {
 		var err error
-		actualCommitID, err := NewCommand("rev-parse", "--verify", commitID).RunInDir(repo.Path)
+		actualCommitID, err := NewCommand("rev-parse", "--verify").AddDynamicArguments(commitID).RunInDir(repo.Path)
 		if err != nil {
-			if strings.Contains(err.Error(), "unknown revision or path") ||
-				strings.Contains(err.Error(), "fatal: Needed a single revision") {
+			if IsErrCommandKind(err, CommandErrorNotExist) || IsErrUnsafeArgument(err) {
 				return SHA1{}, ErrNotExist{commitID, ""}
 			}
 			return SHA1{}, err
@@ -209,26 +216,14 @@ func (repo *Repository) commitsByRange(id SHA1, page int) (*list.List, error) {
 
 func (repo *Repository) searchCommits(id SHA1, opts SearchCommitsOptions) (*list.List, error) {
 	cmd := NewCommand("log", id.String(), "-100", "-i", prettyLogFormat)
-	if len(opts.Keywords) > 0 {
-		for _, v := range opts.Keywords {
-			cmd.AddArguments("--grep=" + v)
-		}
-	}
-	if len(opts.Authors) > 0 {
-		for _, v := range opts.Authors {
-			cmd.AddArguments("--author=" + v)
-		}
-	}
-	if len(opts.Committers) > 0 {
-		for _, v := range opts.Committers {
-			cmd.AddArguments("--committer=" + v)
-		}
-	}
+	cmd.AddOptionValues("--grep", opts.Keywords...)
+	cmd.AddOptionValues("--author", opts.Authors...)
+	cmd.AddOptionValues("--committer", opts.Committers...)
 	if len(opts.After) > 0 {
-		cmd.AddArguments("--after=" + opts.After)
+		cmd.AddOptionValues("--after", opts.After)
 	}
 	if len(opts.Before) > 0 {
-		cmd.AddArguments("--before=" + opts.Before)
+		cmd.AddOptionValues("--before", opts.Before)
 	}
 	if opts.All {
 		cmd.AddArguments("--all")
@@ -241,7 +236,7 @@ func (repo *Repository) searchCommits(id SHA1, opts SearchCommitsOptions) (*list
 }
 
 func (repo *Repository) getFilesChanged(id1, id2 string) ([]string, error) {
-	stdout, err := NewCommand("diff", "--name-only", id1, id2).RunInDirBytes(repo.Path)
+	stdout, err := NewCommand("diff", "--name-only").AddDynamicArguments(id1, id2).RunInDirBytes(repo.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +246,7 @@ func (repo *Repository) getFilesChanged(id1, id2 string) ([]string, error) {
 // FileChangedBetweenCommits Returns true if the file changed between commit IDs id1 and id2
 // You must ensure that id1 and id2 are valid commit ids.
 func (repo *Repository) FileChangedBetweenCommits(filename, id1, id2 string) (bool, error) {
-	stdout, err := NewCommand("diff", "--name-only", "-z", id1, id2, "--", filename).RunInDirBytes(repo.Path)
+	stdout, err := NewCommand("diff", "--name-only", "-z").AddDynamicArguments(id1, id2).AddDashesAndList(filename).RunInDirBytes(repo.Path)
 	if err != nil {
 		return false, err
 	}
@@ -265,8 +260,8 @@ func (repo *Repository) FileCommitsCount(revision, file string) (int64, error) {
 
 // CommitsByFileAndRange return the commits according revison file and the page
 func (repo *Repository) CommitsByFileAndRange(revision, file string, page int) (*list.List, error) {
-	stdout, err := NewCommand("log", revision, "--follow", "--skip="+strconv.Itoa((page-1)*50),
-		"--max-count="+strconv.Itoa(CommitsRangeSize), prettyLogFormat, "--", file).RunInDirBytes(repo.Path)
+	stdout, err := NewCommand("log").AddDynamicArguments(revision).AddArguments("--follow", "--skip="+strconv.Itoa((page-1)*50),
+		"--max-count="+strconv.Itoa(CommitsRangeSize), prettyLogFormat).AddDashesAndList(file).RunInDirBytes(repo.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -275,8 +270,8 @@ func (repo *Repository) CommitsByFileAndRange(revision, file string, page int) (
 
 // CommitsByFileAndRangeNoFollow return the commits according revison file and the page
 func (repo *Repository) CommitsByFileAndRangeNoFollow(revision, file string, page int) (*list.List, error) {
-	stdout, err := NewCommand("log", revision, "--skip="+strconv.Itoa((page-1)*50),
-		"--max-count="+strconv.Itoa(CommitsRangeSize), prettyLogFormat, "--", file).RunInDirBytes(repo.Path)
+	stdout, err := NewCommand("log").AddDynamicArguments(revision).AddArguments("--skip="+strconv.Itoa((page-1)*50),
+		"--max-count="+strconv.Itoa(CommitsRangeSize), prettyLogFormat).AddDashesAndList(file).RunInDirBytes(repo.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -285,7 +280,7 @@ func (repo *Repository) CommitsByFileAndRangeNoFollow(revision, file string, pag
 
 // FilesCountBetween return the number of files changed between two commits
 func (repo *Repository) FilesCountBetween(startCommitID, endCommitID string) (int, error) {
-	stdout, err := NewCommand("diff", "--name-only", startCommitID+"..."+endCommitID).RunInDir(repo.Path)
+	stdout, err := NewCommand("diff", "--name-only").AddDynamicArguments(startCommitID + "..." + endCommitID).RunInDir(repo.Path)
 	if err != nil {
 		return 0, err
 	}
@@ -338,15 +333,19 @@ func (repo *Repository) commitsBefore(id SHA1, limit int) (*list.List, error) {
 		return nil, err
 	}
 
+	ids := make([]SHA1, 0, formattedLog.Len())
+	for logEntry := formattedLog.Front(); logEntry != nil; logEntry = logEntry.Next() {
+		ids = append(ids, logEntry.Value.(*Commit).ID)
+	}
+	branchCounts, err := repo.CountBranchesContaining(ids, 2)
+	if err != nil {
+		return nil, err
+	}
+
 	commits := list.New()
 	for logEntry := formattedLog.Front(); logEntry != nil; logEntry = logEntry.Next() {
 		commit := logEntry.Value.(*Commit)
-		branches, err := repo.getBranches(commit, 2)
-		if err != nil {
-			return nil, err
-		}
-
-		if len(branches) > 1 {
+		if branchCounts[commit.ID] > 1 {
 			break
 		}
 
@@ -365,35 +364,167 @@ func (repo *Repository) getCommitsBeforeLimit(id SHA1, num int) (*list.List, err
 }
 
 func (repo *Repository) getBranches(commit *Commit, limit int) ([]string, error) {
-	if version.Compare(gitVersion, "2.7.0", ">=") {
-		stdout, err := NewCommand("for-each-ref", "--count="+strconv.Itoa(limit), "--format=%(refname:strip=2)", "--contains", commit.ID.String(), BranchPrefix).RunInDir(repo.Path)
+	branches, _, err := repo.GetBranchesContaining(commit.ID.String(), 0, limit)
+	return branches, err
+}
+
+// CountBranchesContaining returns the number of branches which contain each of the commits, counting
+// at most max branches per commit. Instead of looking up the branches of every commit on its own, the
+// history between the branches and the commits is walked once.
+func (repo *Repository) CountBranchesContaining(commitIDs []SHA1, max int) (map[SHA1]int, error) {
+	counts := make(map[SHA1]int, len(commitIDs))
+	if len(commitIDs) == 0 || max <= 0 {
+		return counts, nil
+	}
+
+	stdout, err := NewCommand("for-each-ref", "--format=%(objectname)", BranchPrefix).RunInDir(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	// several branches may point to the same commit
+	tips := make(map[SHA1]int)
+	for _, tip := range strings.Fields(stdout) {
+		id, err := NewIDFromString(tip)
 		if err != nil {
 			return nil, err
 		}
+		tips[id]++
+	}
+	if len(tips) == 0 {
+		return counts, nil
+	}
 
-		branches := strings.Fields(stdout)
-		return branches, nil
+	// the walk stops at the parents of the commits, unless they are in the list themselves
+	wanted := make(map[SHA1]bool, len(commitIDs))
+	for _, id := range commitIDs {
+		wanted[id] = true
+	}
+	stdin := new(bytes.Buffer)
+	for tip := range tips {
+		stdin.WriteString(tip.String() + "\n")
+	}
+	for _, id := range commitIDs {
+		commit, err := repo.getCommit(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range commit.parents {
+			if !wanted[parent] {
+				stdin.WriteString("^" + parent.String() + "\n")
+			}
+		}
 	}
 
-	stdout, err := NewCommand("branch", "--contains", commit.ID.String()).RunInDir(repo.Path)
+	// children are listed before their parents, so the branches reaching a commit are known once it is listed
+	stdout, err = NewCommand("rev-list", "--topo-order", "--parents", "--stdin").RunInDirWithStdin(repo.Path, stdin)
 	if err != nil {
 		return nil, err
 	}
+	reachedBy := make(map[SHA1][]SHA1)
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := NewIDFromString(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		reached := reachedBy[id]
+		delete(reachedBy, id)
+		if tips[id] > 0 {
+			reached = addBranchTip(reached, id, tips, max)
+		}
+		if wanted[id] {
+			counts[id] = countBranchTips(reached, tips, max)
+		}
+		for _, parent := range fields[1:] {
+			parentID, err := NewIDFromString(parent)
+			if err != nil {
+				return nil, err
+			}
+			for _, tip := range reached {
+				reachedBy[parentID] = addBranchTip(reachedBy[parentID], tip, tips, max)
+			}
+		}
+	}
 
-	refs := strings.Split(stdout, "\n")
+	// commits which are ancestors of the parents the walk stops at, e.g. because of skewed
+	// commit dates, are looked up one by one
+	for _, id := range commitIDs {
+		if _, ok := counts[id]; ok {
+			continue
+		}
+		_, total, err := repo.GetBranchesContaining(id.String(), 0, max)
+		if err != nil {
+			return nil, err
+		}
+		if total > max {
+			total = max
+		}
+		counts[id] = total
+	}
+	return counts, nil
+}
 
-	var max int
-	if len(refs) > limit {
-		max = limit
-	} else {
-		max = len(refs) - 1
+// addBranchTip adds the tip to the set of tips, unless they already hold max branches
+func addBranchTip(reached []SHA1, tip SHA1, tips map[SHA1]int, max int) []SHA1 {
+	if countBranchTips(reached, tips, max) >= max {
+		return reached
 	}
+	for _, other := range reached {
+		if other == tip {
+			return reached
+		}
+	}
+	return append(reached, tip)
+}
 
-	branches := make([]string, max)
-	for i, ref := range refs[:max] {
-		parts := strings.Fields(ref)
+func countBranchTips(reached []SHA1, tips map[SHA1]int, max int) int {
+	count := 0
+	for _, tip := range reached {
+		count += tips[tip]
+	}
+	if count > max {
+		return max
+	}
+	return count
+}
 
-		branches[i] = parts[len(parts)-1]
+// GetBranchesContaining returns the names of the branches which contain the commit, sorted by name.
+// At most limit branches are returned after skipping the first skip ones, a limit <= 0 returns all
+// remaining branches. The total number of branches containing the commit is returned as well.
+func (repo *Repository) GetBranchesContaining(commitID string, skip, limit int) ([]string, int, error) {
+	var branches []string
+	if GetCapabilities().SupportsForEachRefContains {
+		stdout, err := NewCommand("for-each-ref", "--format=%(refname)", "--contains").AddDynamicArguments(commitID).AddArguments(BranchPrefix).RunInDir(repo.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, ref := range strings.Fields(stdout) {
+			branches = append(branches, strings.TrimPrefix(ref, BranchPrefix))
+		}
+	} else {
+		stdout, err := NewCommand("branch", "--contains").AddDynamicArguments(commitID).RunInDir(repo.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, line := range strings.Split(stdout, "\n") {
+			// the current branch is marked by a leading `*`
+			if fields := strings.Fields(line); len(fields) > 0 {
+				branches = append(branches, fields[len(fields)-1])
+			}
+		}
+		sort.Strings(branches)
+	}
+
+	total := len(branches)
+	if skip >= total {
+		return []string{}, total, nil
+	}
+	branches = branches[skip:]
+	if limit > 0 && limit < len(branches) {
+		branches = branches[:limit]
 	}
-	return branches, nil
+	return branches, total, nil
 }
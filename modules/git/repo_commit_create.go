@@ -0,0 +1,358 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// FileOperationType is the kind of change a FileOperation makes
+type FileOperationType int
+
+// The changes CreateCommit can make to files
+const (
+	// FileOperationCreate adds a new file, ErrFileAlreadyExists is returned if the path is taken
+	FileOperationCreate FileOperationType = iota
+	// FileOperationUpdate replaces the content of an existing file
+	FileOperationUpdate
+	// FileOperationDelete removes a file or a whole directory
+	FileOperationDelete
+	// FileOperationRename moves a file or a whole directory from FromPath to Path, replacing
+	// the content of a file if Content is set
+	FileOperationRename
+	// FileOperationChmod changes the mode of an existing file to Mode
+	FileOperationChmod
+)
+
+// FileOperation is a change of a file made by CreateCommit
+type FileOperation struct {
+	Type FileOperationType
+	Path string
+	// FromPath is the path moved to Path by FileOperationRename
+	FromPath string
+	// Content is the new content of the file
+	Content io.Reader
+	// Mode is the mode of the file, the current mode is kept or EntryModeBlob used for new
+	// files if it is zero
+	Mode EntryMode
+}
+
+// CreateCommitOptions are the options of CreateCommit
+type CreateCommitOptions struct {
+	// Branch is the branch the changes are made on, it is created with a root commit if it
+	// does not exist yet
+	Branch string
+	// NewBranch is created with the commit instead of moving Branch if set, it must not exist
+	NewBranch string
+	// LastCommitID is the commit the changes are based on, the current head of Branch if empty.
+	// ErrRefChanged is returned if Branch has moved from it.
+	LastCommitID string
+	Files        []FileOperation
+	Message      string
+	// Author and Committer of the commit, the identity configured for git is used if nil
+	Author    *Signature
+	Committer *Signature
+	// KeyID is the key the commit is signed with, NoGPGSign disables signing. SigningKey
+	// decides if neither is set.
+	KeyID     string
+	NoGPGSign bool
+	// PushEnv is the environment of a push of the commit from the repository to itself, which moves
+	// the branch through receive-pack so the hooks of the repository run, e.g. to check protected
+	// branches. ErrPushRejected is returned if a hook declines the push. The branch is moved with
+	// update-ref without running any hooks if PushEnv is nil.
+	PushEnv []string
+}
+
+// CreateCommit applies the file operations to the tree of the branch without a worktree or an
+// index, commits the result and moves the branch to the commit, whose ID is returned. The branch
+// is only moved if it still points to the parent of the commit, so concurrent changes are never
+// overwritten.
+func (repo *Repository) CreateCommit(opts CreateCommitOptions) (SHA1, error) {
+	return repo.createCommit(opts, repo.parentRevision(opts))
+}
+
+// createCommit is CreateCommit with the given parent, none if parentRev is empty
+func (repo *Repository) createCommit(opts CreateCommitOptions, parentRev string) (SHA1, error) {
+	return repo.commitOnBranch(opts, parentRev, func(treeID SHA1) (SHA1, error) {
+		return repo.buildTree(treeID, opts.Files)
+	})
+}
+
+// AmendCommit replaces the last commit of the branch by a commit with the same parents and
+// author, the changes applied to its tree and the new message, the message is kept if newMessage
+// is empty. The committer is the identity configured for git, the commit is signed as set by
+// SigningKey. The branch is only moved if it
+// still points to the replaced commit, ErrRefChanged is returned otherwise.
+func (repo *Repository) AmendCommit(branch, newMessage string, changes ...FileOperation) (SHA1, error) {
+	commit, err := repo.GetBranchCommit(branch)
+	if err != nil {
+		return SHA1{}, err
+	}
+	treeID, err := repo.buildTree(commit.Tree.ID, changes)
+	if err != nil {
+		return SHA1{}, err
+	}
+	parents := make([]SHA1, 0, commit.ParentCount())
+	for i := 0; i < commit.ParentCount(); i++ {
+		parentID, err := commit.ParentID(i)
+		if err != nil {
+			return SHA1{}, err
+		}
+		parents = append(parents, parentID)
+	}
+	if newMessage == "" {
+		newMessage = commit.CommitMessage
+	}
+
+	commitID, err := repo.writeCommit(treeID, parents, newMessage, commit.Author, nil, repo.SigningArguments())
+	if err != nil {
+		return SHA1{}, err
+	}
+	if err := repo.UpdateRef(BranchPrefix+branch, commitID.String(), commit.ID.String()); err != nil {
+		return SHA1{}, err
+	}
+	return commitID, nil
+}
+
+// buildTree applies the file operations to the tree with the given id and writes the result
+func (repo *Repository) buildTree(treeID SHA1, files []FileOperation) (SHA1, error) {
+	builder := repo.NewTreeBuilder(treeID)
+	for _, file := range files {
+		if err := repo.applyFileOperation(builder, file); err != nil {
+			return SHA1{}, err
+		}
+	}
+	return builder.Write()
+}
+
+// EditFile is CreateCommit for a single file operation, which is applied with
+// `update-index --index-info` to a temporary index instead of building the trees in memory.
+// The files of opts are ignored.
+func (repo *Repository) EditFile(file FileOperation, opts CreateCommitOptions) (SHA1, error) {
+	return repo.commitOnBranch(opts, repo.parentRevision(opts), func(treeID SHA1) (SHA1, error) {
+		idx, err := repo.NewTemporaryIndex(treeID)
+		if err != nil {
+			return SHA1{}, err
+		}
+		defer idx.Close()
+		if err := repo.applyFileOperationToIndex(idx, NewTree(repo, treeID), file); err != nil {
+			return SHA1{}, err
+		}
+		return idx.WriteTree()
+	})
+}
+
+// parentRevision returns the revision the commit of the options is based on, none if the branch
+// does not exist yet
+func (repo *Repository) parentRevision(opts CreateCommitOptions) string {
+	if opts.LastCommitID == "" && repo.IsBranchExist(opts.Branch) {
+		return BranchPrefix + opts.Branch
+	}
+	return opts.LastCommitID
+}
+
+// commitOnBranch commits the tree built from the tree of the parent commit, a zero id if there
+// is no parent, and moves the branch to the commit if it still points to the parent
+func (repo *Repository) commitOnBranch(opts CreateCommitOptions, parentRev string, buildTree func(treeID SHA1) (SHA1, error)) (SHA1, error) {
+	if opts.Branch == "" {
+		return SHA1{}, fmt.Errorf("no branch to commit to")
+	}
+
+	var parents []SHA1
+	var treeID SHA1
+	oldValue := EmptySHA
+	if parentRev != "" {
+		parent, err := repo.GetCommit(parentRev)
+		if err != nil {
+			return SHA1{}, err
+		}
+		parents = append(parents, parent.ID)
+		treeID = parent.Tree.ID
+		oldValue = parent.ID.String()
+	}
+
+	newTreeID, err := buildTree(treeID)
+	if err != nil {
+		return SHA1{}, err
+	}
+	commitID, err := repo.writeCommit(newTreeID, parents, opts.Message, opts.Author, opts.Committer, repo.signingArguments(opts.KeyID, opts.NoGPGSign))
+	if err != nil {
+		return SHA1{}, err
+	}
+
+	ref := BranchPrefix + opts.Branch
+	if opts.NewBranch != "" {
+		ref, oldValue = BranchPrefix+opts.NewBranch, EmptySHA
+	}
+	if opts.PushEnv != nil {
+		return commitID, repo.pushToSelf(commitID, ref, oldValue, opts.PushEnv)
+	}
+	if err := repo.UpdateRef(ref, commitID.String(), oldValue); err != nil {
+		return SHA1{}, err
+	}
+	return commitID, nil
+}
+
+// pushToSelf moves the ref from oldValue to the commit with a push to the repository itself. The
+// objects are in the repository already, so only the hooks run.
+func (repo *Repository) pushToSelf(commitID SHA1, ref, oldValue string, env []string) error {
+	lease := oldValue
+	if lease == EmptySHA {
+		// the ref must not exist
+		lease = ""
+	}
+	_, err := repo.Push(".", []string{commitID.String() + ":" + ref}, PushOptions{
+		Env:            env,
+		ForceWithLease: map[string]string{ref: lease},
+	})
+	if IsErrPushLeaseFailed(err) {
+		return ErrRefChanged{Ref: ref, Reason: "stale info"}
+	}
+	return err
+}
+
+// checkFileOperation returns an error if the operation lacks the content or mode it needs
+func checkFileOperation(file FileOperation) error {
+	if file.Content == nil && (file.Type == FileOperationCreate || file.Type == FileOperationUpdate) {
+		return fmt.Errorf("no content for %s", file.Path)
+	}
+	if file.Mode == 0 && file.Type == FileOperationChmod {
+		return fmt.Errorf("no mode for %s", file.Path)
+	}
+	return nil
+}
+
+func (repo *Repository) applyFileOperation(builder *TreeBuilder, file FileOperation) error {
+	if err := checkFileOperation(file); err != nil {
+		return err
+	}
+
+	var id SHA1
+	if file.Content != nil {
+		var err error
+		if id, err = repo.HashObject(file.Content); err != nil {
+			return err
+		}
+	}
+
+	switch file.Type {
+	case FileOperationCreate:
+		if _, err := builder.get(file.Path); err == nil {
+			return ErrFileAlreadyExists{file.Path}
+		}
+		if file.Mode == 0 {
+			file.Mode = EntryModeBlob
+		}
+		return builder.Add(file.Path, file.Mode, id)
+	case FileOperationUpdate:
+		if err := builder.Update(file.Path, id); err != nil {
+			return err
+		}
+	case FileOperationDelete:
+		return builder.Delete(file.Path)
+	case FileOperationRename:
+		if err := builder.Rename(file.FromPath, file.Path); err != nil {
+			return err
+		}
+		if file.Content != nil {
+			if err := builder.Update(file.Path, id); err != nil {
+				return err
+			}
+		}
+	case FileOperationChmod:
+	default:
+		return fmt.Errorf("unknown file operation: %d", file.Type)
+	}
+
+	if file.Mode != 0 {
+		return builder.Chmod(file.Path, file.Mode)
+	}
+	return nil
+}
+
+// applyFileOperationToIndex applies the operation to the index holding the tree
+func (repo *Repository) applyFileOperationToIndex(idx *TemporaryIndex, tree *Tree, file FileOperation) error {
+	if err := checkFileOperation(file); err != nil {
+		return err
+	}
+	// update-index replaces files which are in the way of new directories
+	if file.Type == FileOperationCreate || file.Type == FileOperationRename {
+		for dir := path.Dir(file.Path); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if !tree.ID.IsZero() {
+				if entry, err := tree.GetTreeEntryByPath(dir); err == nil && !entry.IsDir() {
+					return fmt.Errorf("%s is not a directory", dir)
+				}
+			}
+		}
+	}
+	fromPath := file.Path
+	if file.Type == FileOperationRename {
+		fromPath = file.FromPath
+	}
+	entries, err := idx.Entries(fromPath)
+	if err != nil {
+		return err
+	}
+	if file.Type == FileOperationCreate {
+		if len(entries) > 0 {
+			return ErrFileAlreadyExists{file.Path}
+		}
+		if file.Mode == 0 {
+			file.Mode = EntryModeBlob
+		}
+		entries = []IndexEntry{{Mode: file.Mode, Path: file.Path}}
+	} else if len(entries) == 0 {
+		return ErrNotExist{"", fromPath}
+	}
+	// only whole directories may be deleted or renamed
+	isFile := len(entries) == 1 && entries[0].Path == fromPath
+	if !isFile && file.Type != FileOperationDelete && file.Type != FileOperationRename {
+		return ErrNotExist{"", fromPath}
+	}
+
+	if file.Content != nil {
+		if !isFile {
+			return fmt.Errorf("%s is a directory", fromPath)
+		}
+		if entries[0].ID, err = repo.HashObject(file.Content); err != nil {
+			return err
+		}
+	}
+	if file.Mode != 0 {
+		if !isFile {
+			return fmt.Errorf("%s is a directory", fromPath)
+		}
+		entries[0].Mode = file.Mode
+	}
+
+	var updates []IndexEntry
+	switch file.Type {
+	case FileOperationCreate, FileOperationUpdate, FileOperationChmod:
+		updates = entries
+	case FileOperationDelete:
+		for _, entry := range entries {
+			updates = append(updates, IndexEntry{Path: entry.Path})
+		}
+	case FileOperationRename:
+		// an existing file or directory at the new path is replaced
+		replaced, err := idx.Entries(file.Path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range append(replaced, entries...) {
+			updates = append(updates, IndexEntry{Path: entry.Path})
+		}
+		for _, entry := range entries {
+			entry.Path = file.Path + strings.TrimPrefix(entry.Path, file.FromPath)
+			updates = append(updates, entry)
+		}
+	default:
+		return fmt.Errorf("unknown file operation: %d", file.Type)
+	}
+	return idx.Update(updates...)
+}
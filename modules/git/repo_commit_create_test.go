@@ -0,0 +1,236 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_CreateCommit(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n", "dir/c.txt": "c\n", "d.sh": "d\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+	parentID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+
+	sig := &Signature{Name: "Editor", Email: "editor@example.com", When: time.Now()}
+	commitID, err := repo.CreateCommit(CreateCommitOptions{
+		Branch: "master",
+		Files: []FileOperation{
+			{Type: FileOperationCreate, Path: "new/e.txt", Content: strings.NewReader("e\n")},
+			{Type: FileOperationUpdate, Path: "a.txt", Content: strings.NewReader("aa\n")},
+			{Type: FileOperationDelete, Path: "b.txt"},
+			{Type: FileOperationRename, FromPath: "dir", Path: "moved"},
+			{Type: FileOperationChmod, Path: "d.sh", Mode: EntryModeExec},
+		},
+		Message:   "batch edit",
+		Author:    sig,
+		Committer: sig,
+	})
+	require.NoError(t, err)
+
+	branchID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	assert.Equal(t, commitID.String(), branchID)
+	commit, err := repo.GetCommit(branchID)
+	require.NoError(t, err)
+	assert.Equal(t, "batch edit\n", commit.CommitMessage)
+	assert.Equal(t, "Editor", commit.Author.Name)
+	commitParentID, err := commit.ParentID(0)
+	require.NoError(t, err)
+	assert.Equal(t, parentID, commitParentID.String())
+
+	assert.Equal(t, "e\n", blobContent(t, repo, commitID, "new/e.txt"))
+	assert.Equal(t, "aa\n", blobContent(t, repo, commitID, "a.txt"))
+	assert.Equal(t, "c\n", blobContent(t, repo, commitID, "moved/c.txt"))
+	_, err = commit.Tree.GetTreeEntryByPath("b.txt")
+	assert.True(t, IsErrNotExist(err))
+	_, err = commit.Tree.GetTreeEntryByPath("dir")
+	assert.True(t, IsErrNotExist(err))
+	entry, err := commit.Tree.GetTreeEntryByPath("d.sh")
+	require.NoError(t, err)
+	assert.Equal(t, EntryModeExec, entry.Mode())
+}
+
+func TestRepository_CreateCommitErrors(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+	parentID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+
+	create := func(opts CreateCommitOptions) error {
+		_, err := repo.CreateCommit(opts)
+		return err
+	}
+	err = create(CreateCommitOptions{Branch: "master", Files: []FileOperation{{Type: FileOperationCreate, Path: "a.txt", Content: strings.NewReader("")}}})
+	assert.True(t, IsErrFileAlreadyExists(err), "%v", err)
+	err = create(CreateCommitOptions{Branch: "master", Files: []FileOperation{{Type: FileOperationUpdate, Path: "b.txt", Content: strings.NewReader("")}}})
+	assert.True(t, IsErrNotExist(err), "%v", err)
+	err = create(CreateCommitOptions{Branch: "master", Files: []FileOperation{{Type: FileOperationUpdate, Path: "a.txt"}}})
+	assert.Error(t, err)
+
+	// the branch has moved since the changes were made
+	_, err = repo.CreateCommit(CreateCommitOptions{Branch: "master", Message: "first", Files: []FileOperation{{Type: FileOperationDelete, Path: "a.txt"}}})
+	require.NoError(t, err)
+	err = create(CreateCommitOptions{Branch: "master", LastCommitID: parentID, Message: "second", Files: []FileOperation{{Type: FileOperationUpdate, Path: "a.txt", Content: strings.NewReader("b\n")}}})
+	assert.True(t, IsErrRefChanged(err), "%v", err)
+
+	// but it can be committed to a new branch
+	commitID, err := repo.CreateCommit(CreateCommitOptions{Branch: "master", NewBranch: "feature", LastCommitID: parentID, Message: "second",
+		Files: []FileOperation{{Type: FileOperationUpdate, Path: "a.txt", Content: strings.NewReader("b\n")}}})
+	require.NoError(t, err)
+	branchID, err := repo.GetBranchCommitID("feature")
+	require.NoError(t, err)
+	assert.Equal(t, commitID.String(), branchID)
+	err = create(CreateCommitOptions{Branch: "master", NewBranch: "feature", Message: "third"})
+	assert.True(t, IsErrRefChanged(err), "%v", err)
+}
+
+func TestRepository_CreateCommitOrphan(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	commitID, err := repo.CreateCommit(CreateCommitOptions{
+		Branch:  "gh-pages",
+		Message: "root",
+		Files:   []FileOperation{{Type: FileOperationCreate, Path: "index.html", Content: strings.NewReader("<html>\n")}},
+	})
+	require.NoError(t, err)
+	commit, err := repo.GetCommit(commitID.String())
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, commit.ParentCount())
+	assert.Equal(t, "<html>\n", blobContent(t, repo, commitID, "index.html"))
+	_, err = commit.Tree.GetTreeEntryByPath("a.txt")
+	assert.True(t, IsErrNotExist(err))
+}
+
+func TestRepository_EditFile(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n", "dir/c.txt": "c\n", "dir/sub/d.txt": "d\n", "e.sh": "e\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	indexBefore, err := NewCommand("ls-files", "--stage").RunInDir(tmpDir)
+	require.NoError(t, err)
+
+	sig := &Signature{Name: "Editor", Email: "editor@example.com", When: time.Now()}
+	opts := CreateCommitOptions{Branch: "master", Message: "edit", Author: sig, Committer: sig}
+	for _, file := range []FileOperation{
+		{Type: FileOperationCreate, Path: "new/f.txt", Content: strings.NewReader("f\n")},
+		{Type: FileOperationUpdate, Path: "a.txt", Content: strings.NewReader("aa\n")},
+		{Type: FileOperationDelete, Path: "b.txt"},
+		{Type: FileOperationRename, FromPath: "dir", Path: "moved"},
+		{Type: FileOperationRename, FromPath: "moved/c.txt", Path: "c.txt", Content: strings.NewReader("cc\n")},
+		{Type: FileOperationChmod, Path: "e.sh", Mode: EntryModeExec},
+	} {
+		parentID, err := repo.GetBranchCommitID("master")
+		require.NoError(t, err)
+		commitID, err := repo.EditFile(file, opts)
+		require.NoError(t, err, "%v", file)
+		commit, err := repo.GetCommit(commitID.String())
+		require.NoError(t, err)
+		commitParentID, err := commit.ParentID(0)
+		require.NoError(t, err)
+		assert.Equal(t, parentID, commitParentID.String())
+	}
+
+	commitID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	commit, err := repo.GetCommit(commitID)
+	require.NoError(t, err)
+	assert.Equal(t, "f\n", blobContent(t, repo, commit.ID, "new/f.txt"))
+	assert.Equal(t, "aa\n", blobContent(t, repo, commit.ID, "a.txt"))
+	assert.Equal(t, "cc\n", blobContent(t, repo, commit.ID, "c.txt"))
+	assert.Equal(t, "d\n", blobContent(t, repo, commit.ID, "moved/sub/d.txt"))
+	for _, name := range []string{"b.txt", "dir", "moved/c.txt"} {
+		_, err = commit.Tree.GetTreeEntryByPath(name)
+		assert.True(t, IsErrNotExist(err), name)
+	}
+	entry, err := commit.Tree.GetTreeEntryByPath("e.sh")
+	require.NoError(t, err)
+	assert.Equal(t, EntryModeExec, entry.Mode())
+
+	// the index of the repository is untouched
+	index, err := NewCommand("ls-files", "--stage").RunInDir(tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, indexBefore, index)
+}
+
+func TestRepository_EditFileErrors(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	opts := CreateCommitOptions{Branch: "master", Message: "edit"}
+	for _, file := range []FileOperation{
+		{Type: FileOperationCreate, Path: "a.txt", Content: strings.NewReader("")},
+		{Type: FileOperationCreate, Path: "dir", Content: strings.NewReader("")},
+		{Type: FileOperationCreate, Path: "a.txt/b.txt", Content: strings.NewReader("")},
+		{Type: FileOperationUpdate, Path: "missing.txt", Content: strings.NewReader("")},
+		{Type: FileOperationUpdate, Path: "dir", Content: strings.NewReader("")},
+		{Type: FileOperationUpdate, Path: "a.txt"},
+		{Type: FileOperationDelete, Path: "missing.txt"},
+		{Type: FileOperationRename, FromPath: "missing.txt", Path: "b.txt"},
+		{Type: FileOperationChmod, Path: "a.txt"},
+		{Type: FileOperationChmod, Path: "dir", Mode: EntryModeExec},
+	} {
+		_, err := repo.EditFile(file, opts)
+		assert.Error(t, err, "%v", file)
+	}
+
+	// paths are never globs
+	_, err := repo.EditFile(FileOperation{Type: FileOperationCreate, Path: "*.txt", Content: strings.NewReader("")}, opts)
+	assert.NoError(t, err)
+}
+
+func TestRepository_AmendCommit(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	author := &Signature{Name: "Author", Email: "author@example.com", When: time.Unix(1500000000, 0)}
+	parentID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	commitID, err := repo.CreateCommit(CreateCommitOptions{
+		Branch:  "master",
+		Message: "Add b.txt\n\nwith a typo",
+		Author:  author,
+		Files:   []FileOperation{{Type: FileOperationCreate, Path: "b.txt", Content: strings.NewReader("b\n")}},
+	})
+	require.NoError(t, err)
+
+	// only the message
+	amendedID, err := repo.AmendCommit("master", "Add b.txt\n")
+	require.NoError(t, err)
+	amended, err := repo.GetBranchCommit("master")
+	require.NoError(t, err)
+	assert.Equal(t, amendedID, amended.ID)
+	assert.Equal(t, "Add b.txt\n", amended.CommitMessage)
+	assert.Equal(t, "Author", amended.Author.Name)
+	assert.Equal(t, int64(1500000000), amended.Author.When.Unix())
+	amendedParentID, err := amended.ParentID(0)
+	require.NoError(t, err)
+	assert.Equal(t, parentID, amendedParentID.String())
+	assert.Equal(t, "b\n", blobContent(t, repo, amendedID, "b.txt"))
+
+	// only the files
+	amendedID, err = repo.AmendCommit("master", "", FileOperation{Type: FileOperationUpdate, Path: "b.txt", Content: strings.NewReader("bb\n")})
+	require.NoError(t, err)
+	amended, err = repo.GetCommit(amendedID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Add b.txt\n", amended.CommitMessage)
+	assert.Equal(t, "bb\n", blobContent(t, repo, amendedID, "b.txt"))
+	assert.EqualValues(t, 1, amended.ParentCount())
+
+	// the replaced commit is gone from the branch
+	commits, err := CommitsCount(repo.Path, "master")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, commits)
+	assert.NotEqual(t, commitID, amendedID)
+
+	_, err = repo.AmendCommit("missing", "message")
+	assert.Error(t, err)
+}
@@ -5,7 +5,9 @@
 package git
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -37,6 +39,29 @@ func TestRepository_GetCommitBranches(t *testing.T) {
 	}
 }
 
+func TestRepository_GetBranchesContaining(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	// the initial commit is contained in all branches
+	commitID := "95bb4d39648ee7e325106df01a621c530863a653"
+	branches, total, err := bareRepo1.GetBranchesContaining(commitID, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"branch1", "branch2", "master"}, branches)
+
+	branches, total, err = bareRepo1.GetBranchesContaining(commitID, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"branch2"}, branches)
+
+	branches, total, err = bareRepo1.GetBranchesContaining(commitID, 3, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Empty(t, branches)
+}
+
 func TestGetTagCommitWithSignature(t *testing.T) {
 	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
 	bareRepo1, err := OpenRepository(bareRepo1Path)
@@ -60,3 +85,144 @@ func TestGetCommitWithBadCommitID(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, IsErrNotExist(err))
 }
+
+func TestRepository_GetTagCommitID(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_GetTagCommitID")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.CreateTag("lightweight", "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"))
+	// a tag of the annotated tag "test"
+	_, err = NewCommand("tag", "-a", "-m", "nested", "nested", "test").RunInDir(clonedPath)
+	assert.NoError(t, err)
+
+	testCases := map[string]string{
+		"test":        "37991dec2c8e592043f47155ce4808d4580f9123",
+		"lightweight": "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2",
+		"nested":      "37991dec2c8e592043f47155ce4808d4580f9123",
+	}
+	for name, expected := range testCases {
+		commitID, err := repo.GetTagCommitID(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, commitID, name)
+	}
+
+	_, err = repo.GetTagCommitID("master")
+	assert.True(t, IsErrNotExist(err))
+}
+
+func TestRepository_CountBranchesContaining(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	stdout, err := NewCommand("rev-list", "--branches").RunInDir(bareRepo1Path)
+	assert.NoError(t, err)
+	var ids []SHA1
+	for _, line := range strings.Fields(stdout) {
+		id, err := NewIDFromString(line)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	for _, max := range []int{1, 2, 3} {
+		counts, err := bareRepo1.CountBranchesContaining(ids, max)
+		assert.NoError(t, err)
+		assert.Len(t, counts, len(ids))
+		for _, id := range ids {
+			_, total, err := bareRepo1.GetBranchesContaining(id.String(), 0, 0)
+			assert.NoError(t, err)
+			if total > max {
+				total = max
+			}
+			assert.Equal(t, total, counts[id], "%s", id)
+		}
+	}
+
+	// only some commits of the history
+	master, _ := NewIDFromString("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	shared, _ := NewIDFromString("95bb4d39648ee7e325106df01a621c530863a653")
+	counts, err := bareRepo1.CountBranchesContaining([]SHA1{master, shared}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[SHA1]int{master: 1, shared: 2}, counts)
+}
+
+func TestRepository_GetCommitCache(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	cached := globalCommitCache.Get(bareRepo1, commit.ID)
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, commit.CommitMessage, cached.CommitMessage)
+		assert.Equal(t, commit.Tree.ID, cached.Tree.ID)
+		// the tree of a cached commit is loaded again
+		entries, err := cached.ListEntries()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, entries)
+	}
+
+	// copies are returned, so modifying them does not change the cache
+	commit.Branch = "master"
+	again, err := bareRepo1.GetCommit(commit.ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, again.Branch)
+
+	cache := newCommitCache()
+	oldMaxItems := CommitCacheMaxItems
+	defer func() { CommitCacheMaxItems = oldMaxItems }()
+	CommitCacheMaxItems = 2
+	ids := []string{
+		"feaf4ba6bc635fec442f46ddd4512416ec43c2c2",
+		"37991dec2c8e592043f47155ce4808d4580f9123",
+		"8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2",
+	}
+	for _, id := range ids {
+		commit, err := bareRepo1.GetCommit(id)
+		assert.NoError(t, err)
+		cache.Set(bareRepo1, commit.ID, commit)
+		// the first commit is the most recently used one
+		first, _ := NewIDFromString(ids[0])
+		assert.NotNil(t, cache.Get(bareRepo1, first))
+	}
+	assert.Equal(t, 2, cache.Len())
+	second, _ := NewIDFromString(ids[1])
+	assert.Nil(t, cache.Get(bareRepo1, second))
+
+	oldMaxMemory := CommitCacheMaxMemory
+	defer func() { CommitCacheMaxMemory = oldMaxMemory }()
+	CommitCacheMaxMemory = commitSize(commit)
+	cache.Set(bareRepo1, commit.ID, commit)
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestRepository_GetCommitLazyTree(t *testing.T) {
+	oldMaxItems := CommitCacheMaxItems
+	CommitCacheMaxItems = 0
+	defer func() { CommitCacheMaxItems = oldMaxItems }()
+
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	assert.Nil(t, commit.Tree.gogitTree)
+	assert.NotEqual(t, SHA1{}, commit.Tree.ID)
+
+	assert.NoError(t, commit.LoadTree())
+	assert.NotNil(t, commit.Tree.gogitTree)
+
+	commit, err = bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	entry, err := commit.GetTreeEntryByPath("file1.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "file1.txt", entry.Name())
+}
@@ -6,26 +6,29 @@
 package git
 
 import (
+	"io"
 	"os"
-	"path"
 
 	gitealog "code.gitea.io/gitea/modules/log"
 
-	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	cgobject "gopkg.in/src-d/go-git.v4/plumbing/object/commitgraph"
 )
 
-// CommitNodeIndex returns the index for walking commit graph
-func (r *Repository) CommitNodeIndex() (cgobject.CommitNodeIndex, *os.File) {
-	indexPath := path.Join(r.Path, "objects", "info", "commit-graph")
+// CommitNodeIndex returns the index for walking commit graph. The returned closer is not nil
+// if the commit-graph of the repository is used and must be closed after the walk.
+func (r *Repository) CommitNodeIndex() (cgobject.CommitNodeIndex, io.Closer) {
+	index, graph := r.commitNodeIndex()
+	if graph == nil {
+		return index, nil
+	}
+	return index, graph
+}
 
-	file, err := os.Open(indexPath)
+// commitNodeIndex returns the index for walking commit graph and the commit-graph it reads, if any
+func (r *Repository) commitNodeIndex() (cgobject.CommitNodeIndex, *commitGraph) {
+	graph, err := openCommitGraph(r.Path)
 	if err == nil {
-		var index commitgraph.Index
-		index, err = commitgraph.OpenFileIndex(file)
-		if err == nil {
-			return cgobject.NewGraphCommitNodeIndex(index, r.gogitRepo.Storer), file
-		}
+		return cgobject.NewGraphCommitNodeIndex(graph, r.gogitRepo.Storer), graph
 	}
 
 	if !os.IsNotExist(err) {
@@ -34,3 +37,23 @@ func (r *Repository) CommitNodeIndex() (cgobject.CommitNodeIndex, *os.File) {
 
 	return cgobject.NewObjectCommitNodeIndex(r.gogitRepo.Storer), nil
 }
+
+// WriteCommitGraph writes the commit-graph of all reachable commits, including the changed-path
+// Bloom filters if git supports them. If git supports incremental commit-graph chains only the
+// commits missing from the chain are written, and git merges the small layers as the chain grows.
+// Older git versions without commit-graph support are ignored.
+func (r *Repository) WriteCommitGraph() error {
+	caps := GetCapabilities()
+	if !caps.SupportsCommitGraph {
+		return nil
+	}
+
+	cmd := NewCommand("commit-graph", "write", "--reachable")
+	if caps.SupportsCommitGraphSplit {
+		cmd.AddArguments("--split")
+	}
+	if caps.SupportsCommitGraphChangedPaths {
+		cmd.AddArguments("--changed-paths")
+	}
+	return cmd.runInDirWithRetry(r.Path)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_WriteCommitGraph(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	// commit-graph files are only read from bare repositories
+	clonedPath := filepath.Join(testReposDir, "repo1_TestRepository_WriteCommitGraph")
+	assert.NoError(t, Clone(bareRepo1Path, clonedPath, CloneRepoOptions{Bare: true, Quiet: true, Timeout: time.Minute}))
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.WriteCommitGraph())
+
+	if !GetCapabilities().SupportsCommitGraph {
+		t.Skip("git does not support commit-graph files")
+	}
+
+	index, closer := repo.CommitNodeIndex()
+	if assert.NotNil(t, closer) {
+		defer closer.Close()
+	}
+	commit, err := repo.GetBranchCommit("master")
+	assert.NoError(t, err)
+	node, err := index.Get(commit.ID)
+	assert.NoError(t, err)
+	assert.EqualValues(t, commit.ParentCount(), node.NumParents())
+}
+
+func TestRepository_WriteCommitGraphSplit(t *testing.T) {
+	if !GetCapabilities().SupportsCommitGraphSplit {
+		t.Skip("git does not support incremental commit-graph files")
+	}
+
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath := filepath.Join(testReposDir, "repo1_TestRepository_WriteCommitGraphSplit")
+	assert.NoError(t, Clone(bareRepo1Path, clonedPath, CloneRepoOptions{Bare: true, Quiet: true, Timeout: time.Minute}))
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.WriteCommitGraph())
+
+	// a new commit is written to a new layer on top of the existing one
+	commitID, err := NewCommand("commit-tree", "master^{tree}", "-p", "master", "-m", "split").RunInDir(clonedPath)
+	assert.NoError(t, err)
+	commitID = strings.TrimSpace(commitID)
+	_, err = NewCommand("update-ref", BranchPrefix+"master", commitID).RunInDir(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.WriteCommitGraph())
+
+	graph, err := openCommitGraph(clonedPath)
+	assert.NoError(t, err)
+	defer graph.Close()
+	assert.Len(t, graph.layers, 2)
+	assert.Equal(t, 1, graph.layers[1].numCommits)
+
+	// the parents of all commits are read across the layers
+	stdout, err := NewCommand("rev-list", "--parents", "--all").RunInDir(clonedPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Len(t, graph.Hashes(), len(lines))
+
+	index, closer := repo.CommitNodeIndex()
+	defer closer.Close()
+	for _, line := range lines {
+		ids := strings.Fields(line)
+		node, err := index.Get(MustIDFromString(ids[0]))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, ids[0], node.ID().String())
+		assert.Len(t, node.ParentHashes(), len(ids)-1)
+		for i, parent := range node.ParentHashes() {
+			assert.Equal(t, ids[i+1], parent.String())
+		}
+	}
+}
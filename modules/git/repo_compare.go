@@ -6,7 +6,6 @@
 package git
 
 import (
-	"bytes"
 	"container/list"
 	"fmt"
 	"io"
@@ -68,7 +67,7 @@ func (repo *Repository) GetCompareInfo(basePath, baseBranch, headBranch string)
 	compareInfo.MergeBase, remoteBranch, err = repo.GetMergeBase(tmpRemote, baseBranch, headBranch)
 	if err == nil {
 		// We have a common base
-		logs, err := NewCommand("log", compareInfo.MergeBase+"..."+headBranch, prettyLogFormat).RunInDirBytes(repo.Path)
+		logs, err := NewCommand("log").AddDynamicArguments(compareInfo.MergeBase + "..." + headBranch).AddArguments(prettyLogFormat).RunInDirBytes(repo.Path)
 		if err != nil {
 			return nil, err
 		}
@@ -85,7 +84,7 @@ func (repo *Repository) GetCompareInfo(basePath, baseBranch, headBranch string)
 	}
 
 	// Count number of changed files.
-	stdout, err := NewCommand("diff", "--name-only", remoteBranch+"..."+headBranch).RunInDir(repo.Path)
+	stdout, err := NewCommand("diff", "--name-only").AddDynamicArguments(remoteBranch + "..." + headBranch).RunInDir(repo.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -96,17 +95,11 @@ func (repo *Repository) GetCompareInfo(basePath, baseBranch, headBranch string)
 
 // GetPatch generates and returns patch data between given revisions.
 func (repo *Repository) GetPatch(base, head string) ([]byte, error) {
-	return NewCommand("diff", "-p", "--binary", base, head).RunInDirBytes(repo.Path)
+	return NewCommand("diff", "-p", "--binary").AddDynamicArguments(base, head).RunInDirBytes(repo.Path)
 }
 
 // GetFormatPatch generates and returns format-patch data between given revisions.
-func (repo *Repository) GetFormatPatch(base, head string) (io.Reader, error) {
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-
-	if err := NewCommand("format-patch", "--binary", "--stdout", base+"..."+head).
-		RunInDirPipeline(repo.Path, stdout, stderr); err != nil {
-		return nil, concatenateError(err, stderr.String())
-	}
-	return stdout, nil
+// The data is streamed from git, the returned reader must be closed.
+func (repo *Repository) GetFormatPatch(base, head string) (io.ReadCloser, error) {
+	return NewCommand("format-patch", "--binary", "--stdout").AddDynamicArguments(base+"..."+head).RunInDirReader(repo.Path, 0)
 }
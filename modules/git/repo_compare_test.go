@@ -22,6 +22,7 @@ func TestGetFormatPatch(t *testing.T) {
 	assert.NoError(t, err)
 	rd, err := repo.GetFormatPatch("8d92fc95^", "8d92fc95")
 	assert.NoError(t, err)
+	defer rd.Close()
 	patchb, err := ioutil.ReadAll(rd)
 	assert.NoError(t, err)
 	patch := string(patchb)
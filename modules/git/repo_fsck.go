@@ -0,0 +1,195 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FsckProblem is the kind of problem fsck finds with an object
+type FsckProblem string
+
+// The problems reported by fsck
+const (
+	// FsckDangling is an object which is not referenced by any other object
+	FsckDangling FsckProblem = "dangling"
+	// FsckUnreachable is an object which is not reachable from any ref, reported with
+	// FsckOptions.Unreachable instead of FsckDangling
+	FsckUnreachable FsckProblem = "unreachable"
+	// FsckMissing is an object which is referenced but does not exist
+	FsckMissing FsckProblem = "missing"
+	// FsckCorrupt is an object which cannot be read or is malformed
+	FsckCorrupt FsckProblem = "corrupt"
+	// FsckWarning is a minor flaw of an object, like a zero-padded file mode in a tree
+	FsckWarning FsckProblem = "warning"
+)
+
+// FsckFinding is a problem fsck found with an object
+type FsckFinding struct {
+	Problem FsckProblem
+	// Type is the type of the object, empty if it is unknown
+	Type ObjectType
+	ID   SHA1
+	// Name is the name of the object like "master~2:dir/file" if FsckOptions.NameObjects is
+	// set and Path the file the object is stored in, if they are known
+	Name string
+	Path string
+	// Message is the line reported by git
+	Message string
+}
+
+// FsckReport is the outcome of Fsck
+type FsckReport struct {
+	Findings []*FsckFinding
+	// Errors are the errors reported by git which do not concern a single object
+	Errors []string
+}
+
+// Healthy returns true if no object is missing or corrupt, dangling objects and warnings are fine
+func (report *FsckReport) Healthy() bool {
+	if len(report.Errors) > 0 {
+		return false
+	}
+	for _, finding := range report.Findings {
+		if finding.Problem == FsckMissing || finding.Problem == FsckCorrupt {
+			return false
+		}
+	}
+	return true
+}
+
+// FsckOptions are the options of Fsck
+type FsckOptions struct {
+	// Unreachable reports the objects not reachable from any ref, not just the dangling ones
+	Unreachable bool
+	// NoDangling does not report dangling objects
+	NoDangling bool
+	// Strict reports more flaws of objects, like group writable file modes
+	Strict bool
+	// ConnectivityOnly only checks that the reachable objects exist, not that they are valid
+	ConnectivityOnly bool
+	// NameObjects names the objects by how they are reachable, which takes more time
+	NameObjects bool
+	// Args are further arguments of git fsck, e.g. ARGS of the repository health check
+	Args    []string
+	Timeout time.Duration
+}
+
+var (
+	// "dangling blob <id>" and "missing tree <id> (master:dir)"
+	fsckObjectRegexp = regexp.MustCompile(`^(dangling|unreachable|missing) (blob|tree|commit|tag) ([0-9a-f]{40})(?: \((.*)\))?$`)
+	// "error in tree <id>: zeroPaddedFilemode: ..." and the warnings alike
+	fsckMalformedRegexp = regexp.MustCompile(`^(error|warning) in (blob|tree|commit|tag) ([0-9a-f]{40}): (.*)$`)
+	// the object files which cannot be read or do not hold the object their name claims
+	fsckCorruptRegexps = []struct {
+		regexp   *regexp.Regexp
+		id, path int
+	}{
+		{regexp.MustCompile(`^error: ([0-9a-f]{40}): object corrupt or missing: (.+)$`), 1, 2},
+		{regexp.MustCompile(`^fatal: (?:loose|packed) object ([0-9a-f]{40}) \(stored in (.+)\) is corrupt$`), 1, 2},
+		{regexp.MustCompile(`^error: (?:sha1|hash) mismatch for (.+) \(expected ([0-9a-f]{40})\)$`), 2, 1},
+		{regexp.MustCompile(`^error: ([0-9a-f]{40}): hash-path mismatch, found at: (.+)$`), 1, 2},
+	}
+)
+
+// Fsck verifies the connectivity and the validity of the objects of the repository and returns
+// the problems found. Problems make git fail, they are only returned as error if git did not
+// report what they are.
+func (repo *Repository) Fsck(opts FsckOptions) (*FsckReport, error) {
+	cmd := NewCommand("fsck", "--no-progress")
+	if opts.Unreachable {
+		cmd.AddArguments("--unreachable")
+	}
+	if opts.NoDangling {
+		cmd.AddArguments("--no-dangling")
+	}
+	if opts.Strict {
+		cmd.AddArguments("--strict")
+	}
+	if opts.ConnectivityOnly {
+		cmd.AddArguments("--connectivity-only")
+	}
+	if opts.NameObjects {
+		cmd.AddArguments("--name-objects")
+	}
+	cmd.AddArguments(opts.Args...)
+	if opts.Timeout <= 0 {
+		opts.Timeout = -1
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	err := cmd.RunInDirTimeoutPipeline(opts.Timeout, repo.Path, stdout, stderr)
+	report := parseFsckOutput(stdout.String() + stderr.String())
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok || report.Healthy() {
+			return nil, newCommandError(err, stderr.String())
+		}
+	}
+	return report, nil
+}
+
+// parseFsckOutput parses the lines fsck prints to stdout and stderr, every object is reported
+// once per problem
+func parseFsckOutput(output string) *FsckReport {
+	report := new(FsckReport)
+	seen := make(map[string]bool)
+	add := func(finding *FsckFinding) {
+		key := string(finding.Problem) + " " + finding.ID.String()
+		if !seen[key] {
+			seen[key] = true
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if matches := fsckObjectRegexp.FindStringSubmatch(line); matches != nil {
+			add(&FsckFinding{
+				Problem: FsckProblem(matches[1]),
+				Type:    ObjectType(matches[2]),
+				ID:      MustIDFromString(matches[3]),
+				Name:    matches[4],
+				Message: line,
+			})
+			continue
+		}
+		if matches := fsckMalformedRegexp.FindStringSubmatch(line); matches != nil {
+			problem := FsckCorrupt
+			if matches[1] == "warning" {
+				problem = FsckWarning
+			}
+			add(&FsckFinding{
+				Problem: problem,
+				Type:    ObjectType(matches[2]),
+				ID:      MustIDFromString(matches[3]),
+				Message: matches[4],
+			})
+			continue
+		}
+
+		corrupt := false
+		for _, pattern := range fsckCorruptRegexps {
+			if matches := pattern.regexp.FindStringSubmatch(line); matches != nil {
+				add(&FsckFinding{
+					Problem: FsckCorrupt,
+					ID:      MustIDFromString(matches[pattern.id]),
+					Path:    matches[pattern.path],
+					Message: line,
+				})
+				corrupt = true
+				break
+			}
+		}
+		// the errors leading up to a corrupt object, like those of zlib, do not matter by themselves
+		if !corrupt && strings.HasPrefix(line, "fatal: ") {
+			report.Errors = append(report.Errors, strings.TrimPrefix(line, "fatal: "))
+		}
+	}
+	return report
+}
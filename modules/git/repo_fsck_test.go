@@ -0,0 +1,99 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func looseObjectPath(repoPath, id string) string {
+	return filepath.Join(repoPath, ".git", "objects", id[:2], id[2:])
+}
+
+func TestRepository_Fsck(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	report, err := repo.Fsck(FsckOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+	assert.Empty(t, report.Findings)
+
+	danglingID, err := repo.HashObject(strings.NewReader("dangling\n"))
+	require.NoError(t, err)
+	report, err = repo.Fsck(FsckOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, FsckDangling, report.Findings[0].Problem)
+	assert.Equal(t, ObjectBlob, report.Findings[0].Type)
+	assert.Equal(t, danglingID, report.Findings[0].ID)
+
+	report, err = repo.Fsck(FsckOptions{NoDangling: true})
+	require.NoError(t, err)
+	assert.Empty(t, report.Findings)
+
+	commit, err := repo.GetBranchCommit("master")
+	require.NoError(t, err)
+	blob, err := commit.Tree.GetBlobByPath("dir/b.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(looseObjectPath(tmpDir, blob.ID.String())))
+	report, err = repo.Fsck(FsckOptions{NameObjects: true, Unreachable: true})
+	require.NoError(t, err)
+	assert.False(t, report.Healthy())
+	findings := make(map[FsckProblem]*FsckFinding)
+	for _, finding := range report.Findings {
+		findings[finding.Problem] = finding
+	}
+	require.NotNil(t, findings[FsckMissing])
+	assert.Equal(t, blob.ID, findings[FsckMissing].ID)
+	assert.True(t, strings.HasSuffix(findings[FsckMissing].Name, ":dir/b.txt"), findings[FsckMissing].Name)
+	require.NotNil(t, findings[FsckUnreachable])
+	assert.Equal(t, danglingID, findings[FsckUnreachable].ID)
+
+	// the object file does not hold a valid object
+	treeID := commit.Tree.ID.String()
+	path := looseObjectPath(tmpDir, treeID)
+	require.NoError(t, os.Chmod(path, 0644))
+	require.NoError(t, ioutil.WriteFile(path, []byte("corrupt"), 0644))
+	report, err = repo.Fsck(FsckOptions{})
+	require.NoError(t, err)
+	assert.False(t, report.Healthy())
+	var corrupt []*FsckFinding
+	for _, finding := range report.Findings {
+		if finding.Problem == FsckCorrupt {
+			corrupt = append(corrupt, finding)
+		}
+	}
+	require.Len(t, corrupt, 1)
+	assert.Equal(t, treeID, corrupt[0].ID.String())
+	assert.NotEmpty(t, corrupt[0].Path)
+}
+
+func TestParseFsckOutput(t *testing.T) {
+	report := parseFsckOutput(`error in tree 1111111111111111111111111111111111111111: duplicateEntries: contains duplicate file entries
+warning in tree 2222222222222222222222222222222222222222: zeroPaddedFilemode: contains zero-padded file modes
+error: hash mismatch for .git/objects/33/33333333333333333333333333333333333333 (expected 3333333333333333333333333333333333333333)
+error: inflate: data stream error (incorrect header check)
+fatal: unable to read the index
+`)
+	require.Len(t, report.Findings, 3)
+	assert.Equal(t, FsckCorrupt, report.Findings[0].Problem)
+	assert.Equal(t, ObjectTree, report.Findings[0].Type)
+	assert.Equal(t, "duplicateEntries: contains duplicate file entries", report.Findings[0].Message)
+	assert.Equal(t, FsckWarning, report.Findings[1].Problem)
+	assert.Equal(t, FsckCorrupt, report.Findings[2].Problem)
+	assert.Equal(t, "3333333333333333333333333333333333333333", report.Findings[2].ID.String())
+	assert.Equal(t, ".git/objects/33/33333333333333333333333333333333333333", report.Findings[2].Path)
+	assert.Equal(t, []string{"unable to read the index"}, report.Errors)
+	assert.False(t, report.Healthy())
+}
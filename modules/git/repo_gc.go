@@ -0,0 +1,118 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GCOptions are the options of GC
+type GCOptions struct {
+	// Aggressive optimizes the repository more thoroughly, which takes much longer
+	Aggressive bool
+	// Auto only collects garbage if there are too many loose objects or packfiles, see gc.auto
+	Auto bool
+	// Prune is the expiry of unreachable loose objects, e.g. "2.weeks.ago" or "now".
+	// gc.pruneExpire of the repository decides if it is empty.
+	Prune string
+	// Args are further arguments of git gc, e.g. GC_ARGS of the settings
+	Args    []string
+	Timeout time.Duration
+}
+
+// GCResult is the outcome of GC
+type GCResult struct {
+	// SizeBefore and SizeAfter are the disk space taken by the objects of the repository in bytes
+	SizeBefore int64
+	SizeAfter  int64
+	// Freed is the disk space reclaimed in bytes, negative if the objects take more space now
+	Freed    int64
+	Duration time.Duration
+}
+
+// GC runs `git gc` on the repository, waiting for the other operations changing it, and returns
+// how much space it freed. ErrGCRunning is returned if a gc started by another process still
+// runs. The packfiles are replaced, so open repositories must be invalidated afterwards.
+func (repo *Repository) GC(opts GCOptions) (*GCResult, error) {
+	// gc --auto detaches into the background by default
+	cmd := NewCommand("-c", "gc.autoDetach=false", "gc", "--quiet")
+	if opts.Aggressive {
+		cmd.AddArguments("--aggressive")
+	}
+	if opts.Auto {
+		cmd.AddArguments("--auto")
+	}
+	if opts.Prune != "" {
+		if strings.HasPrefix(opts.Prune, "-") {
+			return nil, fmt.Errorf("invalid prune expiry: %s", opts.Prune)
+		}
+		cmd.AddArguments("--prune=" + opts.Prune)
+	}
+	cmd.AddArguments(opts.Args...)
+	if opts.Timeout <= 0 {
+		opts.Timeout = -1
+	}
+
+	defer LockWrites(repo.Path)()
+	before, err := repo.CountObjects()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = DefaultRetryPolicy.Do(func() error {
+		_, err := cmd.RunInDirTimeout(opts.Timeout, repo.Path)
+		return err
+	})
+	if err != nil {
+		if cmdErr, ok := err.(CommandError); ok && strings.Contains(cmdErr.Stderr, "gc is already running") {
+			return nil, ErrGCRunning{Path: repo.Path}
+		}
+		return nil, err
+	}
+	duration := time.Since(start)
+	after, err := repo.CountObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{
+		SizeBefore: before.Size + before.SizePack + before.SizeGarbage,
+		SizeAfter:  after.Size + after.SizePack + after.SizeGarbage,
+		Duration:   duration,
+	}
+	result.Freed = result.SizeBefore - result.SizeAfter
+	return result, nil
+}
+
+// Repack packs the loose objects of the repository, waiting for the other operations changing it.
+// A full repack consolidates all reachable objects into a single packfile, optionally with a
+// reachability bitmap. Otherwise the loose objects get a packfile of their own
+// and, if git supports it, packfiles are combined so their number stays logarithmic in the number
+// of objects. threads limits the threads delta compression uses, 0 uses one per CPU.
+// The packfiles are replaced, so open repositories must be invalidated afterwards.
+func (repo *Repository) Repack(full bool, writeBitmaps bool, threads int) error {
+	cmd := NewCommand("repack", "-d", "-q")
+	if full {
+		cmd.AddArguments("-a")
+	} else if writeBitmaps {
+		return fmt.Errorf("bitmaps can only be written by full repacks")
+	} else if GetCapabilities().SupportsGeometricRepack {
+		cmd.AddArguments("--geometric=2")
+	}
+	if writeBitmaps {
+		cmd.AddArguments("-b")
+	}
+	if threads > 0 {
+		cmd.AddArguments(fmt.Sprintf("--threads=%d", threads))
+	}
+
+	defer LockWrites(repo.Path)()
+	return DefaultRetryPolicy.Do(func() error {
+		_, err := cmd.RunInDirTimeout(-1, repo.Path)
+		return err
+	})
+}
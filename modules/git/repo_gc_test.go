@@ -0,0 +1,93 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GC(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	before, err := GetRepoSize(tmpDir)
+	require.NoError(t, err)
+	assert.NotZero(t, before.Count)
+
+	result, err := repo.GC(GCOptions{Prune: "now", Timeout: time.Minute})
+	require.NoError(t, err)
+	after, err := GetRepoSize(tmpDir)
+	require.NoError(t, err)
+	assert.Zero(t, after.Count)
+	assert.EqualValues(t, 1, after.Packs)
+	assert.Equal(t, after.Size+after.SizePack+after.SizeGarbage, result.SizeAfter)
+	assert.Equal(t, result.SizeBefore-result.SizeAfter, result.Freed)
+	assert.True(t, result.Duration > 0)
+
+	// there is nothing to collect
+	result, err = repo.GC(GCOptions{Auto: true})
+	require.NoError(t, err)
+	assert.Equal(t, result.SizeBefore, result.SizeAfter)
+
+	_, err = repo.GC(GCOptions{Prune: "--all"})
+	assert.Error(t, err)
+}
+
+func TestRepository_GCRunning(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	// a gc of this process holds the lock
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, ".git", "gc.pid"), []byte(fmt.Sprintf("%d %s", os.Getpid(), hostname)), 0644))
+
+	_, err = repo.GC(GCOptions{Timeout: time.Minute})
+	assert.True(t, IsErrGCRunning(err), "%v", err)
+}
+
+func TestRepository_Repack(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	require.NoError(t, repo.Repack(false, false, 1))
+	size, err := GetRepoSize(tmpDir)
+	require.NoError(t, err)
+	assert.Zero(t, size.Count)
+	assert.EqualValues(t, 1, size.Packs)
+	bitmaps, err := filepath.Glob(filepath.Join(tmpDir, ".git", "objects", "pack", "*.bitmap"))
+	require.NoError(t, err)
+	assert.Empty(t, bitmaps)
+
+	// the packfiles have been replaced
+	repo, err = OpenRepository(tmpDir)
+	require.NoError(t, err)
+	commitFiles(t, repo, "other", "other", map[string]string{"d.txt": "d\n"})
+	require.NoError(t, repo.Repack(false, false, 0))
+	size, err = GetRepoSize(tmpDir)
+	require.NoError(t, err)
+	assert.Zero(t, size.Count)
+	assert.Error(t, repo.Repack(false, true, 0))
+
+	repo, err = OpenRepository(tmpDir)
+	require.NoError(t, err)
+	commitFiles(t, repo, "other", "other", map[string]string{"e.txt": "e\n"})
+	require.NoError(t, repo.Repack(true, true, 0))
+	size, err = GetRepoSize(tmpDir)
+	require.NoError(t, err)
+	assert.Zero(t, size.Count)
+	assert.EqualValues(t, 1, size.Packs)
+	bitmaps, err = filepath.Glob(filepath.Join(tmpDir, ".git", "objects", "pack", "*.bitmap"))
+	require.NoError(t, err)
+	assert.Len(t, bitmaps, 1)
+}
@@ -6,21 +6,18 @@ package git
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // ReadTreeToIndex reads a treeish to the index
 func (repo *Repository) ReadTreeToIndex(treeish string) error {
-	if len(treeish) != 40 {
-		res, err := NewCommand("rev-parse", "--verify", treeish).RunInDir(repo.Path)
-		if err != nil {
-			return err
-		}
-		if len(res) > 0 {
-			treeish = res[:len(res)-1]
-		}
-	}
-	id, err := NewIDFromString(treeish)
+	id, err := repo.resolveRevision(treeish)
 	if err != nil {
 		return err
 	}
@@ -63,9 +60,6 @@ func (repo *Repository) LsFiles(filenames ...string) ([]string, error) {
 
 // RemoveFilesFromIndex removes given filenames from the index - it does not check whether they are present.
 func (repo *Repository) RemoveFilesFromIndex(filenames ...string) error {
-	cmd := NewCommand("update-index", "--remove", "-z", "--index-info")
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
 	buffer := new(bytes.Buffer)
 	for _, file := range filenames {
 		if file != "" {
@@ -74,7 +68,8 @@ func (repo *Repository) RemoveFilesFromIndex(filenames ...string) error {
 			buffer.WriteByte('\000')
 		}
 	}
-	return cmd.RunInDirFullPipeline(repo.Path, stdout, stderr, bytes.NewReader(buffer.Bytes()))
+	_, err := NewCommand("update-index", "--remove", "-z", "--index-info").RunInDirWithStdin(repo.Path, buffer)
+	return err
 }
 
 // AddObjectToIndex adds the provided object hash to the index at the provided filename
@@ -96,3 +91,102 @@ func (repo *Repository) WriteTree() (*Tree, error) {
 	}
 	return NewTree(repo, id), nil
 }
+
+// IndexEntry is a file in an index
+type IndexEntry struct {
+	Mode EntryMode
+	ID   SHA1
+	Path string
+}
+
+// TemporaryIndex is an index in a temporary file, which builds trees with git itself without a
+// worktree and without touching the index of the repository
+type TemporaryIndex struct {
+	repo *Repository
+	dir  string
+	env  []string
+}
+
+// NewTemporaryIndex creates a temporary index holding the tree with the given id, an empty index
+// for a zero id. Close must be called to remove the index.
+func (repo *Repository) NewTemporaryIndex(treeID SHA1) (*TemporaryIndex, error) {
+	dir, err := ioutil.TempDir("", "gitea-index")
+	if err != nil {
+		return nil, err
+	}
+	idx := &TemporaryIndex{
+		repo: repo,
+		dir:  dir,
+		// paths are never globs
+		env: append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(dir, "index"), "GIT_LITERAL_PATHSPECS=1"),
+	}
+	if !treeID.IsZero() {
+		if _, err := idx.run(NewCommand("read-tree", treeID.String()), nil); err != nil {
+			idx.Close()
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+func (idx *TemporaryIndex) run(cmd *Command, stdin io.Reader) ([]byte, error) {
+	return cmd.RunInDirTimeoutEnvWithStdin(idx.env, -1, idx.repo.Path, stdin)
+}
+
+// Entries returns the entries of the file at treePath or of all files in the directory at treePath
+func (idx *TemporaryIndex) Entries(treePath string) ([]IndexEntry, error) {
+	stdout, err := idx.run(NewCommand("ls-files", "--stage", "-z", "--", treePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []IndexEntry
+	for _, field := range strings.Split(string(stdout), "\x00") {
+		if field == "" {
+			continue
+		}
+		// every entry is "<mode> <object> <stage>\t<file>"
+		tab := strings.IndexByte(field, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("invalid index entry: %q", field)
+		}
+		info := strings.Fields(field[:tab])
+		if len(info) != 3 {
+			return nil, fmt.Errorf("invalid index entry: %q", field)
+		}
+		mode, err := strconv.ParseInt(info[0], 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		id, err := NewIDFromString(info[1])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, IndexEntry{Mode: EntryMode(mode), ID: id, Path: field[tab+1:]})
+	}
+	return entries, nil
+}
+
+// Update adds the entries to the index with `update-index --index-info`, replacing the entries at
+// the same paths. Entries with a zero mode are removed from the index.
+func (idx *TemporaryIndex) Update(entries ...IndexEntry) error {
+	stdin := new(bytes.Buffer)
+	for _, entry := range entries {
+		fmt.Fprintf(stdin, "%o %s\t%s\x00", entry.Mode, entry.ID, entry.Path)
+	}
+	_, err := idx.run(NewCommand("update-index", "-z", "--index-info"), stdin)
+	return err
+}
+
+// WriteTree writes the trees of the index to the object database and returns the id of the root tree
+func (idx *TemporaryIndex) WriteTree() (SHA1, error) {
+	stdout, err := idx.run(NewCommand("write-tree"), nil)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return NewIDFromString(strings.TrimSpace(string(stdout)))
+}
+
+// Close removes the index
+func (idx *TemporaryIndex) Close() {
+	removeTemporaryPath(idx.dir)
+}
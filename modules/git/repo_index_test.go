@@ -0,0 +1,53 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemporaryIndex(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n", "dir/c.txt": "c\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+	commit, err := repo.GetBranchCommit("master")
+	require.NoError(t, err)
+
+	idx, err := repo.NewTemporaryIndex(commit.Tree.ID)
+	require.NoError(t, err)
+	defer idx.Close()
+
+	entries, err := idx.Entries("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "dir/b.txt", entries[0].Path)
+	assert.Equal(t, EntryModeBlob, entries[0].Mode)
+	entries, err = idx.Entries("missing")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// writing the unchanged index gives the same tree
+	treeID, err := idx.WriteTree()
+	require.NoError(t, err)
+	assert.Equal(t, commit.Tree.ID, treeID)
+
+	blobID, err := repo.HashObject(strings.NewReader("d\n"))
+	require.NoError(t, err)
+	require.NoError(t, idx.Update(IndexEntry{Path: "a.txt"}, IndexEntry{Mode: EntryModeExec, ID: blobID, Path: "dir/d.sh"}))
+	treeID, err = idx.WriteTree()
+	require.NoError(t, err)
+
+	tree := NewTree(repo, treeID)
+	_, err = tree.GetTreeEntryByPath("a.txt")
+	assert.True(t, IsErrNotExist(err))
+	entry, err := tree.GetTreeEntryByPath("dir/d.sh")
+	require.NoError(t, err)
+	assert.Equal(t, EntryModeExec, entry.Mode())
+	assert.Equal(t, blobID, entry.ID)
+}
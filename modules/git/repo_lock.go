@@ -0,0 +1,35 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"path/filepath"
+
+	"code.gitea.io/gitea/modules/sync"
+)
+
+// SerializeWrites makes the operations changing a repository, like ref updates, tag creation
+// and repacks, wait for each other if they run on the same repository, so concurrent automation
+// does not fail with "cannot lock ref". Reading the repository never waits.
+var SerializeWrites = false
+
+var writeLocks = sync.NewExclusivePool()
+
+// LockWrites waits until no other operation changes the repository if SerializeWrites is set and
+// returns the function releasing the lock, which must be called once the change is done.
+// Operations of this package lock the repository themselves, LockWrites is meant for changes
+// made by other means, e.g. pushing a merge or running gc.
+func LockWrites(repoPath string) func() {
+	if !SerializeWrites {
+		return func() {}
+	}
+	if absPath, err := filepath.Abs(repoPath); err == nil {
+		repoPath = absPath
+	}
+	writeLocks.CheckIn(repoPath)
+	return func() {
+		writeLocks.CheckOut(repoPath)
+	}
+}
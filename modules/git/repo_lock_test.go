@@ -0,0 +1,51 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockWrites(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestLockWrites")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	SerializeWrites = true
+	defer func() { SerializeWrites = false }()
+
+	unlock := LockWrites(clonedPath)
+	updated := make(chan error)
+	go func() {
+		updated <- repo.UpdateRef("refs/pull/1/head", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", EmptySHA)
+	}()
+
+	// reads are not blocked by the lock
+	_, err = repo.GetBranchCommitID("master")
+	assert.NoError(t, err)
+
+	select {
+	case err := <-updated:
+		t.Fatalf("ref updated while the repository is locked: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	assert.NoError(t, <-updated)
+
+	// the lock is not taken at all if writes are not serialized
+	SerializeWrites = false
+	unlock = LockWrites(clonedPath)
+	assert.NoError(t, repo.UpdateRef("refs/pull/2/head", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", EmptySHA))
+	unlock()
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Repositories is the RepositoryManager shared by the whole process
+var Repositories = NewRepositoryManager(100, 5*time.Minute)
+
+// RepositoryManager pools open repositories, so opening a repository again reuses its go-git storage
+// and caches instead of reading the packfile indexes anew. Every handle is lent to one user at a time,
+// who returns it with Release, so settings like ProtectedTags do not leak to other users.
+type RepositoryManager struct {
+	// MaxIdle is the maximum number of unused repositories kept open
+	MaxIdle int
+	// IdleTimeout is the time after which an unused repository is closed
+	IdleTimeout time.Duration
+
+	lock sync.Mutex
+	// idle holds the unused repositories by path, the most recently used last
+	idle      map[string][]*pooledRepository
+	idleCount int
+	borrowed  map[*Repository]*pooledRepository
+	// refs counts the borrowed repositories of every path
+	refs map[string]int
+	// generations are increased by Invalidate, handles of older generations are not reused
+	generations map[string]uint64
+	timer       *time.Timer
+}
+
+type pooledRepository struct {
+	repo       *Repository
+	generation uint64
+	lastUsed   time.Time
+}
+
+// NewRepositoryManager creates a RepositoryManager keeping at most maxIdle unused repositories open
+// for idleTimeout
+func NewRepositoryManager(maxIdle int, idleTimeout time.Duration) *RepositoryManager {
+	return &RepositoryManager{
+		MaxIdle:     maxIdle,
+		IdleTimeout: idleTimeout,
+		idle:        make(map[string][]*pooledRepository),
+		borrowed:    make(map[*Repository]*pooledRepository),
+		refs:        make(map[string]int),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Open borrows an unused handle of the repository, or opens a new one if there is none.
+// The handle must be returned with Release.
+func (m *RepositoryManager) Open(repoPath string) (*Repository, error) {
+	repoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	generation := m.generations[repoPath]
+	if idle := m.idle[repoPath]; len(idle) > 0 {
+		pooled := idle[len(idle)-1]
+		m.removeIdle(repoPath, len(idle)-1)
+		m.borrowed[pooled.repo] = pooled
+		m.refs[repoPath]++
+		m.lock.Unlock()
+		return pooled.repo, nil
+	}
+	m.lock.Unlock()
+
+	repo, err := OpenRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.borrowed[repo] = &pooledRepository{repo: repo, generation: generation}
+	m.refs[repoPath]++
+	return repo, nil
+}
+
+// Release returns a handle borrowed with Open. Handles of repositories which have been invalidated
+// meanwhile, and handles not opened by the manager, are closed.
+func (m *RepositoryManager) Release(repo *Repository) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	pooled, ok := m.borrowed[repo]
+	if !ok {
+		repo.Close()
+		return
+	}
+	delete(m.borrowed, repo)
+	if m.refs[repo.Path]--; m.refs[repo.Path] == 0 {
+		delete(m.refs, repo.Path)
+	}
+
+	if m.MaxIdle <= 0 || pooled.generation != m.generations[repo.Path] {
+		repo.Close()
+		return
+	}
+	// settings of the previous borrower do not apply to the next one
+	repo.ProtectedTags = nil
+	pooled.lastUsed = time.Now()
+	m.idle[repo.Path] = append(m.idle[repo.Path], pooled)
+	m.idleCount++
+	for m.idleCount > m.MaxIdle {
+		m.closeLeastRecentlyUsed()
+	}
+	m.scheduleEviction()
+}
+
+// Invalidate closes the unused handles of the repository and makes sure the borrowed ones are not
+// reused. It must be called after the repository has been changed behind the back of go-git in a way
+// it does not notice, e.g. packfiles being added or removed, or the repository being moved or deleted.
+func (m *RepositoryManager) Invalidate(repoPath string) {
+	repoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.generations[repoPath]++
+	for len(m.idle[repoPath]) > 0 {
+		m.idle[repoPath][0].repo.Close()
+		m.removeIdle(repoPath, 0)
+	}
+}
+
+// InUse returns the number of borrowed handles of the repository
+func (m *RepositoryManager) InUse(repoPath string) int {
+	repoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return 0
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.refs[repoPath]
+}
+
+// EvictIdle closes the repositories which have not been used for IdleTimeout
+func (m *RepositoryManager) EvictIdle() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.timer = nil
+	deadline := time.Now().Add(-m.IdleTimeout)
+	for repoPath, idle := range m.idle {
+		for len(idle) > 0 && !idle[0].lastUsed.After(deadline) {
+			idle[0].repo.Close()
+			m.removeIdle(repoPath, 0)
+			idle = m.idle[repoPath]
+		}
+	}
+	m.scheduleEviction()
+}
+
+// scheduleEviction makes sure EvictIdle runs while there are unused repositories
+func (m *RepositoryManager) scheduleEviction() {
+	if m.timer != nil || m.idleCount == 0 || m.IdleTimeout <= 0 {
+		return
+	}
+	m.timer = time.AfterFunc(m.IdleTimeout, m.EvictIdle)
+}
+
+func (m *RepositoryManager) closeLeastRecentlyUsed() {
+	var oldestPath string
+	var oldest *pooledRepository
+	for repoPath, idle := range m.idle {
+		if oldest == nil || idle[0].lastUsed.Before(oldest.lastUsed) {
+			oldestPath, oldest = repoPath, idle[0]
+		}
+	}
+	oldest.repo.Close()
+	m.removeIdle(oldestPath, 0)
+}
+
+func (m *RepositoryManager) removeIdle(repoPath string, i int) {
+	idle := m.idle[repoPath]
+	idle = append(idle[:i], idle[i+1:]...)
+	if len(idle) == 0 {
+		delete(m.idle, repoPath)
+	} else {
+		m.idle[repoPath] = idle
+	}
+	m.idleCount--
+}
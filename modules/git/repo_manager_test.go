@@ -0,0 +1,62 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryManager(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	emptyRepoPath := filepath.Join(testReposDir, "repo2_empty")
+	m := NewRepositoryManager(1, time.Hour)
+
+	repo, err := m.Open(bareRepo1Path)
+	assert.NoError(t, err)
+	// a borrowed repository is not lent twice
+	other, err := m.Open(bareRepo1Path)
+	assert.NoError(t, err)
+	assert.True(t, repo != other)
+	assert.Equal(t, 2, m.InUse(bareRepo1Path))
+
+	m.Release(other)
+	assert.Equal(t, 1, m.InUse(bareRepo1Path))
+	reused, err := m.Open(bareRepo1Path)
+	assert.NoError(t, err)
+	assert.True(t, reused == other)
+	m.Release(reused)
+
+	// handles borrowed before Invalidate are not reused
+	m.Invalidate(bareRepo1Path)
+	m.Release(repo)
+	assert.Equal(t, 0, m.InUse(bareRepo1Path))
+	fresh, err := m.Open(bareRepo1Path)
+	assert.NoError(t, err)
+	assert.True(t, fresh != repo && fresh != other)
+	commit, err := fresh.GetBranchCommit("master")
+	assert.NoError(t, err)
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", commit.ID.String())
+	m.Release(fresh)
+
+	// only MaxIdle unused repositories are kept open
+	empty, err := m.Open(emptyRepoPath)
+	assert.NoError(t, err)
+	m.Release(empty)
+	assert.Equal(t, 1, m.idleCount)
+	again, err := m.Open(bareRepo1Path)
+	assert.NoError(t, err)
+	assert.True(t, again != fresh)
+	m.Release(again)
+
+	// unused repositories are closed after IdleTimeout
+	m.IdleTimeout = 0
+	m.EvictIdle()
+	assert.Equal(t, 0, m.idleCount)
+	assert.Empty(t, m.idle)
+}
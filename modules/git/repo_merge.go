@@ -0,0 +1,543 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// MergeStrategy is the strategy git merges the trees of two commits with
+type MergeStrategy string
+
+// The merge strategies supported by Merge
+const (
+	// MergeStrategyDefault is ort if git supports it and recursive otherwise
+	MergeStrategyDefault MergeStrategy = ""
+	// MergeStrategyOrt merges in memory without a worktree, it needs git 2.38
+	MergeStrategyOrt MergeStrategy = "ort"
+	// MergeStrategyRecursive is the default strategy of git before 2.34
+	MergeStrategyRecursive MergeStrategy = "recursive"
+	// MergeStrategyResolve only resolves trivial conflicts, it does not support MergeFavor
+	MergeStrategyResolve MergeStrategy = "resolve"
+)
+
+// MergeFavor decides how conflicting hunks are resolved
+type MergeFavor string
+
+// The ways conflicts are resolved
+const (
+	// MergeFavorNone fails the merge with ErrMergeConflict on conflicts
+	MergeFavorNone MergeFavor = ""
+	// MergeFavorOurs takes the conflicting hunks of the base
+	MergeFavorOurs MergeFavor = "ours"
+	// MergeFavorTheirs takes the conflicting hunks of the head
+	MergeFavorTheirs MergeFavor = "theirs"
+)
+
+// DefaultMergeMessageTemplate is the message of merge commits if MergeOptions.Message is empty
+const DefaultMergeMessageTemplate = "Merge branch '{{.Head}}' into {{.Base}}"
+
+// MergeOptions are the options of Merge
+type MergeOptions struct {
+	Strategy MergeStrategy
+	Favor    MergeFavor
+	// Author and Committer of the merge commit, the identity configured for git is used if nil
+	Author    *Signature
+	Committer *Signature
+	// Message is a text/template of the commit message executed with MergeMessageData
+	Message string
+	// KeyID is the key the commit is signed with, NoGPGSign disables signing. SigningKey
+	// decides if neither is set.
+	KeyID     string
+	NoGPGSign bool
+}
+
+// MergeMessageData is the data the template of the message of a merge commit is executed with
+type MergeMessageData struct {
+	// Base and Head are the revisions as passed to Merge
+	Base       string
+	Head       string
+	BaseCommit SHA1
+	HeadCommit SHA1
+}
+
+// Merge creates a merge commit of the head revision into the base revision and returns its ID.
+// No worktree of the repository is needed and no ref is changed, the caller updates the branch.
+// ErrMergeConflict is returned if the revisions conflict, ErrMergeUnrelatedHistories if they do
+// not have a common ancestor.
+func (repo *Repository) Merge(base, head string, opts MergeOptions) (SHA1, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return SHA1{}, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return SHA1{}, err
+	}
+
+	message, err := mergeMessage(opts.Message, MergeMessageData{Base: base, Head: head, BaseCommit: baseID, HeadCommit: headID})
+	if err != nil {
+		return SHA1{}, err
+	}
+	treeID, err := repo.mergeTree(baseID, headID, opts.Strategy, opts.Favor)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return repo.writeCommit(treeID, []SHA1{baseID, headID}, message, opts.Author, opts.Committer, repo.signingArguments(opts.KeyID, opts.NoGPGSign))
+}
+
+func mergeMessage(text string, data MergeMessageData) (string, error) {
+	if text == "" {
+		text = DefaultMergeMessageTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid merge message template: %v", err)
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", fmt.Errorf("invalid merge message template: %v", err)
+	}
+	return message.String(), nil
+}
+
+// mergeBases returns the best common ancestors of the commits
+func (repo *Repository) mergeBases(baseID, headID SHA1) ([]SHA1, error) {
+	stdout, err := NewCommand("merge-base", "--all", baseID.String(), headID.String()).RunInDir(repo.Path)
+	if err != nil {
+		// merge-base exits with 1 without output if there is no common ancestor
+		if cmdErr, ok := err.(CommandError); ok && cmdErr.ExitCode == 1 && cmdErr.Stderr == "" {
+			return nil, ErrMergeUnrelatedHistories{Base: baseID.String(), Head: headID.String()}
+		}
+		return nil, err
+	}
+	var bases []SHA1
+	for _, field := range strings.Fields(stdout) {
+		id, err := NewIDFromString(field)
+		if err != nil {
+			return nil, err
+		}
+		bases = append(bases, id)
+	}
+	return bases, nil
+}
+
+// mergeTree merges the trees of the commits and returns the ID of the merged tree
+func (repo *Repository) mergeTree(baseID, headID SHA1, strategy MergeStrategy, favor MergeFavor) (SHA1, error) {
+	switch strategy {
+	case MergeStrategyDefault, MergeStrategyOrt:
+		// merge-tree does not take strategy options, so favoring a side needs the recursive strategy
+		if GetCapabilities().SupportsMergeTreeWriteTree && favor == MergeFavorNone {
+			return repo.mergeTreeInMemory(nil, baseID, headID, false)
+		}
+		strategy = MergeStrategyRecursive
+	case MergeStrategyRecursive:
+	case MergeStrategyResolve:
+		if favor != MergeFavorNone {
+			return SHA1{}, fmt.Errorf("merge strategy %s cannot favor %s", strategy, favor)
+		}
+	default:
+		return SHA1{}, fmt.Errorf("unknown merge strategy: %s", strategy)
+	}
+
+	bases, err := repo.mergeBases(baseID, headID)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return repo.mergeTreeInWorktree(bases, baseID, headID, strategy, favor, false)
+}
+
+// cherryPickTree applies the changes of the commit relative to its parent onto the commit onto
+// and returns the ID of the resulting tree
+func (repo *Repository) cherryPickTree(parentID, ontoID, commitID SHA1) (SHA1, error) {
+	if GetCapabilities().SupportsMergeTreeMergeBase {
+		return repo.mergeTreeInMemory(&parentID, ontoID, commitID, false)
+	}
+	return repo.mergeTreeInWorktree([]SHA1{parentID}, ontoID, commitID, MergeStrategyRecursive, MergeFavorNone, false)
+}
+
+// mergeTreeInMemory merges the commits with `merge-tree --write-tree`, which needs neither an
+// index nor a worktree and computes the merge bases itself unless mergeBase is given. If details
+// is set, ErrMergeConflict describes the conflicting files with their contents.
+func (repo *Repository) mergeTreeInMemory(mergeBase *SHA1, baseID, headID SHA1, details bool) (SHA1, error) {
+	cmd := NewCommand("merge-tree", "--write-tree", "-z", "--no-messages")
+	if !details {
+		cmd.AddArguments("--name-only")
+	}
+	if mergeBase != nil {
+		cmd.AddArguments("--merge-base=" + mergeBase.String())
+	}
+	cmd.AddArguments(baseID.String(), headID.String())
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err := cmd.RunInDirPipeline(repo.Path, stdout, stderr)
+	// the output is the merged tree followed by the conflicting files, or their index entries
+	fields := strings.Split(stdout.String(), "\x00")
+	if err != nil {
+		if strings.Contains(stderr.String(), "refusing to merge unrelated histories") {
+			return SHA1{}, ErrMergeUnrelatedHistories{Base: baseID.String(), Head: headID.String()}
+		}
+		if exitCode(err) != 1 {
+			return SHA1{}, newCommandError(err, stderr.String())
+		}
+		if !details {
+			var files []string
+			for _, name := range fields[1:] {
+				if name == "" {
+					break
+				}
+				if len(files) == 0 || files[len(files)-1] != name {
+					files = append(files, name)
+				}
+			}
+			return SHA1{}, ErrMergeConflict{Files: files}
+		}
+
+		conflicts, err := parseUnmergedEntries(fields[1:])
+		if err != nil {
+			return SHA1{}, err
+		}
+		// the conflicting files are written to the tree with conflict markers
+		treeID, err := NewIDFromString(fields[0])
+		if err != nil {
+			return SHA1{}, err
+		}
+		tree := NewTree(repo, treeID)
+		for _, conflict := range conflicts {
+			blob, err := tree.GetBlobByPath(conflict.Path)
+			if err != nil {
+				if IsErrNotExist(err) {
+					continue
+				}
+				return SHA1{}, err
+			}
+			if conflict.Content, err = readBlob(blob); err != nil {
+				return SHA1{}, err
+			}
+		}
+		return SHA1{}, newMergeConflict(conflicts)
+	}
+	return NewIDFromString(strings.TrimSpace(fields[0]))
+}
+
+// mergeTreeInWorktree merges the commits with the merge backend of the strategy in a temporary
+// index and worktree, the index and the refs of the repository are left untouched. If details
+// is set, ErrMergeConflict describes the conflicting files with their contents.
+func (repo *Repository) mergeTreeInWorktree(bases []SHA1, baseID, headID SHA1, strategy MergeStrategy, favor MergeFavor, details bool) (SHA1, error) {
+	tmpDir, err := ioutil.TempDir("", "gitea-merge")
+	if err != nil {
+		return SHA1{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	worktree := filepath.Join(tmpDir, "worktree")
+	if err := os.Mkdir(worktree, os.ModePerm); err != nil {
+		return SHA1{}, err
+	}
+
+	gitDir, err := filepath.Abs(repo.gogitStorage.Filesystem().Root())
+	if err != nil {
+		return SHA1{}, err
+	}
+	env := append(os.Environ(),
+		"GIT_DIR="+gitDir,
+		"GIT_INDEX_FILE="+filepath.Join(tmpDir, "index"),
+		"GIT_WORK_TREE="+worktree,
+	)
+	run := func(cmd *Command) (string, error) {
+		stdout, err := cmd.RunInDirTimeoutEnv(env, -1, worktree)
+		return string(stdout), err
+	}
+
+	if _, err := run(NewCommand("read-tree", "--reset", "-u", baseID.String())); err != nil {
+		return SHA1{}, err
+	}
+
+	var mergeErr error
+	if strategy == MergeStrategyResolve {
+		// merge-resolve insists on the index matching HEAD, so run its steps directly
+		cmd := NewCommand("read-tree", "-u", "-m", "--aggressive")
+		for _, id := range bases {
+			cmd.AddArguments(id.String())
+		}
+		cmd.AddArguments(baseID.String(), headID.String())
+		if _, err := run(cmd); err != nil {
+			return SHA1{}, err
+		}
+		_, mergeErr = run(NewCommand("merge-index", "-o", "git-merge-one-file", "-a"))
+	} else {
+		cmd := NewCommand("merge-" + string(strategy))
+		if favor != MergeFavorNone {
+			cmd.AddArguments("--" + string(favor))
+		}
+		for _, id := range bases {
+			cmd.AddArguments(id.String())
+		}
+		cmd.AddArguments("--", baseID.String(), headID.String())
+		_, mergeErr = run(cmd)
+	}
+	if mergeErr != nil {
+		unmerged, err := run(NewCommand("ls-files", "-u", "-z"))
+		if err != nil {
+			return SHA1{}, err
+		}
+		conflicts, err := parseUnmergedEntries(strings.Split(unmerged, "\x00"))
+		if err != nil {
+			return SHA1{}, err
+		}
+		if len(conflicts) == 0 {
+			return SHA1{}, mergeErr
+		}
+		if details {
+			// the merge leaves the conflicting files with conflict markers in the worktree
+			for _, conflict := range conflicts {
+				content, err := ioutil.ReadFile(filepath.Join(worktree, filepath.FromSlash(conflict.Path)))
+				if err != nil && !os.IsNotExist(err) {
+					return SHA1{}, err
+				}
+				conflict.Content = content
+			}
+		}
+		return SHA1{}, newMergeConflict(conflicts)
+	}
+
+	stdout, err := run(NewCommand("write-tree"))
+	if err != nil {
+		return SHA1{}, err
+	}
+	return NewIDFromString(strings.TrimSpace(stdout))
+}
+
+// writeCommit writes a commit of the tree with the given parents, signed as set by the signing
+// arguments, and returns its ID
+func (repo *Repository) writeCommit(treeID SHA1, parents []SHA1, message string, author, committer *Signature, signing []string) (SHA1, error) {
+	cmd := NewCommand("commit-tree", treeID.String()).SetEnv(CommandEnvOptions{Author: author, Committer: committer})
+	for _, parent := range parents {
+		cmd.AddArguments("-p", parent.String())
+	}
+	cmd.AddArguments("-m", message)
+	cmd.AddArguments(signing...)
+
+	stdout, err := cmd.RunInDir(repo.Path)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return NewIDFromString(strings.TrimSpace(stdout))
+}
+
+// SquashMerge creates a single commit on top of the base revision with the changes of the head
+// revision merged in and returns its ID, no ref is changed. The authors of the squashed commits
+// other than author are credited with Co-authored-by trailers appended to the message. The commit
+// is signed as set by SigningKey.
+func (repo *Repository) SquashMerge(base, head, message string, author *Signature) (SHA1, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return SHA1{}, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return SHA1{}, err
+	}
+
+	treeID, err := repo.mergeTree(baseID, headID, MergeStrategyDefault, MergeFavorNone)
+	if err != nil {
+		return SHA1{}, err
+	}
+	coAuthors, err := repo.squashedAuthors(baseID, headID)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return repo.writeCommit(treeID, []SHA1{baseID}, addCoAuthors(message, author, coAuthors), author, nil, repo.SigningArguments())
+}
+
+// squashedAuthors returns the distinct authors of the commits reachable from head but not from
+// base as "Name <email>", oldest first
+func (repo *Repository) squashedAuthors(baseID, headID SHA1) ([]string, error) {
+	stdout, err := NewCommand("log", "--reverse", "--format=%aN <%aE>", baseID.String()+".."+headID.String()).RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	var authors []string
+	seen := make(map[string]bool)
+	for _, line := range splitLines(stdout) {
+		author := string(line)
+		if !seen[author] {
+			seen[author] = true
+			authors = append(authors, author)
+		}
+	}
+	return authors, nil
+}
+
+// addCoAuthors appends a Co-authored-by trailer for every co-author which is neither the author
+// nor already credited in the message
+func addCoAuthors(message string, author *Signature, coAuthors []string) string {
+	var trailers strings.Builder
+	for _, coAuthor := range coAuthors {
+		trailer := "Co-authored-by: " + coAuthor
+		if author != nil && coAuthor == author.Name+" <"+author.Email+">" || strings.Contains(message, trailer) {
+			continue
+		}
+		trailers.WriteString(trailer)
+		trailers.WriteByte('\n')
+	}
+	if trailers.Len() == 0 {
+		return message
+	}
+	message = strings.TrimRight(message, "\n")
+	if message == "" {
+		return trailers.String()
+	}
+	// trailers form the last paragraph, which may already hold trailers
+	lastParagraph := message
+	if i := strings.LastIndex(message, "\n\n"); i >= 0 {
+		lastParagraph = message[i+2:]
+	}
+	if !strings.HasPrefix(lastParagraph, "Co-authored-by: ") {
+		message += "\n"
+	}
+	return message + "\n" + trailers.String()
+}
+
+// RebaseMerge replays the commits of the head revision which are not on the base branch onto it,
+// like `git rebase`, and fast-forwards the base branch to the last of them, whose ID is returned.
+// The authors of the commits are kept and the committer is replaced, the new commits are signed as
+// set by SigningKey. Merge commits and commits which become empty are dropped. Commits already on
+// top of the branch are not rewritten. RebasePlan previews the commits replayed.
+// ErrRebaseConflict names the first commit which does not apply, the branch is left untouched
+// then. ErrRefChanged is returned if the branch moved during the rebase.
+func (repo *Repository) RebaseMerge(base, head string, opts RebaseOptions) (SHA1, error) {
+	ref := branchRef(base)
+	baseID, err := repo.resolveRevision(ref)
+	if err != nil {
+		return SHA1{}, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return SHA1{}, err
+	}
+	if _, err := repo.mergeBases(baseID, headID); err != nil {
+		return SHA1{}, err
+	}
+
+	steps, err := repo.rebasePlan(baseID, headID, opts.Autosquash)
+	if err != nil {
+		return SHA1{}, err
+	}
+	baseCommit, err := repo.GetCommit(baseID.String())
+	if err != nil {
+		return SHA1{}, err
+	}
+	signing := repo.SigningArguments()
+	tipID, tipTreeID := baseID, baseCommit.Tree.ID
+	for len(steps) > 0 {
+		// a picked commit is replayed together with the commits folded into it
+		n := 1
+		for n < len(steps) && steps[n].Action != RebaseActionPick {
+			n++
+		}
+		tipID, tipTreeID, err = repo.rebaseCommits(steps[:n], tipID, tipTreeID, opts.Committer, signing)
+		if err != nil {
+			return SHA1{}, err
+		}
+		steps = steps[n:]
+	}
+
+	if tipID != baseID {
+		if err := repo.UpdateRef(ref, tipID.String(), baseID.String()); err != nil {
+			return SHA1{}, err
+		}
+	}
+	return tipID, nil
+}
+
+// branchRef returns the full name of the ref of a branch, full names are returned unchanged
+func branchRef(branch string) string {
+	if strings.HasPrefix(branch, "refs/") {
+		return branch
+	}
+	return BranchPrefix + branch
+}
+
+// isAncestor returns whether ancestorID is reachable from id, a commit is its own ancestor
+func (repo *Repository) isAncestor(ancestorID, id SHA1) (bool, error) {
+	_, err := NewCommand("merge-base", "--is-ancestor", ancestorID.String(), id.String()).RunInDir(repo.Path)
+	if err != nil {
+		if cmdErr, ok := err.(CommandError); ok && cmdErr.ExitCode == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CanFastForward returns whether the base revision is an ancestor of the head revision, so
+// merging the head only needs to move the base branch without creating a commit
+func (repo *Repository) CanFastForward(base, head string) (bool, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return false, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return false, err
+	}
+	return repo.isAncestor(baseID, headID)
+}
+
+// FastForward moves the base branch to the head revision, whose ID is returned, if the branch is
+// an ancestor of it and ErrNotFastForward otherwise. The branch is only moved if it did not change
+// since it was checked, ErrRefChanged is returned if it did.
+func (repo *Repository) FastForward(base, head string) (SHA1, error) {
+	ref := branchRef(base)
+	baseID, err := repo.resolveRevision(ref)
+	if err != nil {
+		return SHA1{}, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return SHA1{}, err
+	}
+	ok, err := repo.isAncestor(baseID, headID)
+	if err != nil {
+		return SHA1{}, err
+	}
+	if !ok {
+		return SHA1{}, ErrNotFastForward{Name: base, OldCommitID: baseID.String(), NewCommitID: headID.String()}
+	}
+	if headID != baseID {
+		if err := repo.UpdateRef(ref, headID.String(), baseID.String()); err != nil {
+			return SHA1{}, err
+		}
+	}
+	return headID, nil
+}
+
+// CheckConflicts returns the files which conflict if the head revision is merged into the base
+// revision, none if they merge cleanly. The merge happens in memory, or with git before 2.38 in
+// a temporary index, so neither a worktree nor a change of the repository is needed.
+func (repo *Repository) CheckConflicts(base, head string) ([]string, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return nil, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.mergeTree(baseID, headID, MergeStrategyDefault, MergeFavorNone); err != nil {
+		if conflict, ok := err.(ErrMergeConflict); ok {
+			return conflict.Files, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ConflictFile is a file which conflicts in a merge
+type ConflictFile struct {
+	Path string
+	// BaseID, OursID and TheirsID are the blobs of the file in the merge base, the base revision
+	// and the head revision, zero if the file does not exist in it
+	BaseID   SHA1
+	OursID   SHA1
+	TheirsID SHA1
+	// Content is the file merged as far as possible with conflict markers around the conflicting
+	// hunks, nil if the file is not left in the merge, e.g. if both sides renamed it
+	Content []byte
+}
+
+// GetConflictFiles returns the files which conflict if the head revision is merged into the base
+// revision with their blobs on both sides and the merged content, none if they merge cleanly.
+// Like CheckConflicts no worktree of the repository is needed.
+func (repo *Repository) GetConflictFiles(base, head string) ([]*ConflictFile, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return nil, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return nil, err
+	}
+
+	if GetCapabilities().SupportsMergeTreeWriteTree {
+		_, err = repo.mergeTreeInMemory(nil, baseID, headID, true)
+	} else {
+		var bases []SHA1
+		if bases, err = repo.mergeBases(baseID, headID); err == nil {
+			_, err = repo.mergeTreeInWorktree(bases, baseID, headID, MergeStrategyRecursive, MergeFavorNone, true)
+		}
+	}
+	if err != nil {
+		if conflict, ok := err.(ErrMergeConflict); ok {
+			return conflict.conflicts, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// parseUnmergedEntries parses the NUL separated "<mode> <object> <stage>\t<file>" index entries
+// of conflicting files up to the first empty one, the entries of a file must be adjacent
+func parseUnmergedEntries(fields []string) ([]*ConflictFile, error) {
+	var conflicts []*ConflictFile
+	for _, field := range fields {
+		if field == "" {
+			break
+		}
+		tab := strings.IndexByte(field, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("invalid unmerged entry: %q", field)
+		}
+		info := strings.Fields(field[:tab])
+		if len(info) != 3 {
+			return nil, fmt.Errorf("invalid unmerged entry: %q", field)
+		}
+		id, err := NewIDFromString(info[1])
+		if err != nil {
+			return nil, err
+		}
+
+		path := field[tab+1:]
+		if len(conflicts) == 0 || conflicts[len(conflicts)-1].Path != path {
+			conflicts = append(conflicts, &ConflictFile{Path: path})
+		}
+		conflict := conflicts[len(conflicts)-1]
+		switch info[2] {
+		case "1":
+			conflict.BaseID = id
+		case "2":
+			conflict.OursID = id
+		case "3":
+			conflict.TheirsID = id
+		default:
+			return nil, fmt.Errorf("invalid unmerged entry: %q", field)
+		}
+	}
+	return conflicts, nil
+}
+
+func readBlob(blob *Blob) ([]byte, error) {
+	rc, err := blob.DataAsync()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GetConflictFiles(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	caps := GetCapabilities()
+	defer capabilities.Store(caps)
+	// without merge-tree --write-tree the merge happens in a temporary index
+	for _, gitVersion := range []string{caps.Version, "2.37.0"} {
+		capabilities.Store(CapabilitiesForVersion(gitVersion))
+
+		conflicts, err := repo.GetConflictFiles("ours", "other")
+		require.NoError(t, err)
+		assert.Empty(t, conflicts)
+
+		conflicts, err = repo.GetConflictFiles("ours", "theirs")
+		require.NoError(t, err)
+		require.Len(t, conflicts, 1, "git %s", gitVersion)
+		conflict := conflicts[0]
+		assert.Equal(t, "a.txt", conflict.Path)
+
+		for id, content := range map[SHA1]string{conflict.BaseID: "a\n", conflict.OursID: "ours\n", conflict.TheirsID: "theirs\n"} {
+			blob, err := repo.GetBlob(id.String())
+			require.NoError(t, err)
+			data, err := readBlob(blob)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+		}
+
+		lines := strings.Split(string(conflict.Content), "\n")
+		require.Len(t, lines, 6, "git %s: %s", gitVersion, conflict.Content)
+		assert.True(t, strings.HasPrefix(lines[0], "<<<<<<< "))
+		assert.Equal(t, []string{"ours", "=======", "theirs"}, lines[1:4])
+		assert.True(t, strings.HasPrefix(lines[4], ">>>>>>> "))
+	}
+}
+
+func TestParseUnmergedEntries(t *testing.T) {
+	base := "1111111111111111111111111111111111111111"
+	ours := "2222222222222222222222222222222222222222"
+	theirs := "3333333333333333333333333333333333333333"
+	conflicts, err := parseUnmergedEntries([]string{
+		"100644 " + base + " 1\ta.txt",
+		"100644 " + ours + " 2\ta.txt",
+		"100644 " + theirs + " 3\ta.txt",
+		"100644 " + ours + " 2\tdir/deleted by them.txt",
+		"",
+		"ignored",
+	})
+	require.NoError(t, err)
+	require.Len(t, conflicts, 2)
+	assert.Equal(t, &ConflictFile{Path: "a.txt", BaseID: MustIDFromString(base), OursID: MustIDFromString(ours), TheirsID: MustIDFromString(theirs)}, conflicts[0])
+	assert.Equal(t, &ConflictFile{Path: "dir/deleted by them.txt", OursID: MustIDFromString(ours)}, conflicts[1])
+
+	_, err = parseUnmergedEntries([]string{"100644 " + base + " 4\ta.txt"})
+	assert.Error(t, err)
+	_, err = parseUnmergedEntries([]string{"a.txt"})
+	assert.Error(t, err)
+}
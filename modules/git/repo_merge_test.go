@@ -0,0 +1,344 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitFiles commits the files on top of the parent revision and points the branch at the commit
+func commitFiles(t *testing.T, repo *Repository, branch, parent string, files map[string]string) SHA1 {
+	return commitFilesAs(t, repo, &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}, branch, parent, files)
+}
+
+// commitFilesAs is commitFiles with the given author and committer
+func commitFilesAs(t *testing.T, repo *Repository, sig *Signature, branch, parent string, files map[string]string) SHA1 {
+	parentCommit, err := repo.GetCommit(parent)
+	require.NoError(t, err)
+	builder := repo.NewTreeBuilder(parentCommit.Tree.ID)
+	for name, content := range files {
+		id, err := repo.HashObject(strings.NewReader(content))
+		require.NoError(t, err)
+		if err := builder.Update(name, id); err != nil {
+			require.NoError(t, builder.Add(name, EntryModeBlob, id))
+		}
+	}
+	treeID, err := builder.Write()
+	require.NoError(t, err)
+
+	commitID, err := repo.CommitTree(sig, NewTree(repo, treeID), CommitTreeOpts{Parents: []string{parentCommit.ID.String()}, Message: "change " + branch})
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRef(BranchPrefix+branch, commitID.String(), ""))
+	return commitID
+}
+
+func initMergeTestRepo(t *testing.T) (string, *Repository) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"}, nil)
+	commitFiles(t, repo, "ours", "master", map[string]string{"a.txt": "ours\n"})
+	commitFiles(t, repo, "theirs", "master", map[string]string{"a.txt": "theirs\n", "c.txt": "c\n"})
+	commitFiles(t, repo, "other", "master", map[string]string{"b.txt": "other\n"})
+	return tmpDir, repo
+}
+
+func blobContent(t *testing.T, repo *Repository, commitID SHA1, name string) string {
+	commit, err := repo.GetCommit(commitID.String())
+	require.NoError(t, err)
+	blob, err := commit.Tree.GetBlobByPath(name)
+	require.NoError(t, err)
+	content, err := blob.GetBlobContent()
+	require.NoError(t, err)
+	return content
+}
+
+func TestRepository_Merge(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	strategies := []MergeStrategy{MergeStrategyDefault, MergeStrategyRecursive, MergeStrategyResolve}
+	if GetCapabilities().SupportsMergeTreeWriteTree {
+		strategies = append(strategies, MergeStrategyOrt)
+	}
+	for _, strategy := range strategies {
+		sig := &Signature{Name: "Merger", Email: "merger@example.com", When: time.Unix(1500000000, 0)}
+		mergeID, err := repo.Merge("ours", "other", MergeOptions{
+			Strategy:  strategy,
+			Author:    sig,
+			Committer: sig,
+			Message:   "Merge {{.Head}} ({{.HeadCommit}}) into {{.Base}}",
+		})
+		require.NoError(t, err, "strategy %q", strategy)
+
+		merge, err := repo.GetCommit(mergeID.String())
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, merge.ParentCount())
+		headID, err := repo.GetBranchCommitID("other")
+		require.NoError(t, err)
+		assert.Equal(t, "Merge other ("+headID+") into ours\n", merge.CommitMessage)
+		assert.Equal(t, "Merger", merge.Author.Name)
+		assert.Equal(t, "merger@example.com", merge.Committer.Email)
+		assert.Equal(t, "ours\n", blobContent(t, repo, mergeID, "a.txt"))
+		assert.Equal(t, "other\n", blobContent(t, repo, mergeID, "b.txt"))
+
+		// no ref is changed
+		oursID, err := repo.GetBranchCommitID("ours")
+		require.NoError(t, err)
+		parentID, err := merge.ParentID(0)
+		require.NoError(t, err)
+		assert.Equal(t, oursID, parentID.String())
+	}
+}
+
+func TestRepository_MergeDefaultMessage(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	mergeID, err := repo.Merge("ours", "other", MergeOptions{})
+	require.NoError(t, err)
+	merge, err := repo.GetCommit(mergeID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Merge branch 'other' into ours\n", merge.CommitMessage)
+
+	_, err = repo.Merge("ours", "other", MergeOptions{Message: "{{.Unknown}}"})
+	assert.Error(t, err)
+}
+
+func TestRepository_MergeConflict(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	strategies := []MergeStrategy{MergeStrategyDefault, MergeStrategyRecursive, MergeStrategyResolve}
+	for _, strategy := range strategies {
+		_, err := repo.Merge("ours", "theirs", MergeOptions{Strategy: strategy})
+		assert.True(t, IsErrMergeConflict(err), "strategy %q: %v", strategy, err)
+		assert.Equal(t, []string{"a.txt"}, err.(ErrMergeConflict).Files)
+	}
+
+	_, err := repo.Merge("ours", "theirs", MergeOptions{Strategy: MergeStrategyResolve, Favor: MergeFavorOurs})
+	assert.Error(t, err)
+	assert.False(t, IsErrMergeConflict(err))
+}
+
+func TestRepository_MergeFavor(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	mergeID, err := repo.Merge("ours", "theirs", MergeOptions{Favor: MergeFavorOurs})
+	require.NoError(t, err)
+	assert.Equal(t, "ours\n", blobContent(t, repo, mergeID, "a.txt"))
+	assert.Equal(t, "c\n", blobContent(t, repo, mergeID, "c.txt"))
+
+	mergeID, err = repo.Merge("ours", "theirs", MergeOptions{Strategy: MergeStrategyRecursive, Favor: MergeFavorTheirs})
+	require.NoError(t, err)
+	assert.Equal(t, "theirs\n", blobContent(t, repo, mergeID, "a.txt"))
+}
+
+func TestRepository_MergeUnrelatedHistories(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	treeID, err := repo.NewTreeBuilder(SHA1{}).Write()
+	require.NoError(t, err)
+	sig := &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	orphanID, err := repo.CommitTree(sig, NewTree(repo, treeID), CommitTreeOpts{Message: "orphan"})
+	require.NoError(t, err)
+
+	for _, strategy := range []MergeStrategy{MergeStrategyDefault, MergeStrategyRecursive} {
+		_, err = repo.Merge("ours", orphanID.String(), MergeOptions{Strategy: strategy})
+		assert.True(t, IsErrMergeUnrelatedHistories(err), "strategy %q: %v", strategy, err)
+	}
+
+	_, err = repo.Merge("ours", "other", MergeOptions{Strategy: "octopus"})
+	assert.Error(t, err)
+}
+
+func TestRepository_SquashMerge(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	alice := &Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	bob := &Signature{Name: "Bob", Email: "bob@example.com", When: time.Now()}
+	commitFilesAs(t, repo, alice, "other", "other", map[string]string{"d.txt": "d\n"})
+	commitFilesAs(t, repo, bob, "other", "other", map[string]string{"e.txt": "e\n"})
+	commitFilesAs(t, repo, alice, "other", "other", map[string]string{"d.txt": "dd\n"})
+
+	squashID, err := repo.SquashMerge("ours", "other", "Squashed (#1)", alice)
+	require.NoError(t, err)
+	squash, err := repo.GetCommit(squashID.String())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, squash.ParentCount())
+	parentID, err := squash.ParentID(0)
+	require.NoError(t, err)
+	oursID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, oursID, parentID.String())
+	assert.Equal(t, "Alice", squash.Author.Name)
+	assert.Equal(t, "Squashed (#1)\n\nCo-authored-by: Test <test@example.com>\nCo-authored-by: Bob <bob@example.com>\n", squash.CommitMessage)
+	assert.Equal(t, "ours\n", blobContent(t, repo, squashID, "a.txt"))
+	assert.Equal(t, "other\n", blobContent(t, repo, squashID, "b.txt"))
+	assert.Equal(t, "dd\n", blobContent(t, repo, squashID, "d.txt"))
+	assert.Equal(t, "e\n", blobContent(t, repo, squashID, "e.txt"))
+
+	_, err = repo.SquashMerge("ours", "theirs", "conflict", alice)
+	assert.True(t, IsErrMergeConflict(err))
+}
+
+func TestAddCoAuthors(t *testing.T) {
+	author := &Signature{Name: "Alice", Email: "alice@example.com"}
+	coAuthors := []string{"Alice <alice@example.com>", "Bob <bob@example.com>"}
+
+	assert.Equal(t, "Subject\n", addCoAuthors("Subject\n", author, coAuthors[:1]))
+	assert.Equal(t, "Subject\n\nBody\n\nCo-authored-by: Bob <bob@example.com>\n", addCoAuthors("Subject\n\nBody", author, coAuthors))
+	assert.Equal(t, "Subject\n\nCo-authored-by: Carol <carol@example.com>\nCo-authored-by: Bob <bob@example.com>\n",
+		addCoAuthors("Subject\n\nCo-authored-by: Carol <carol@example.com>\n", author, coAuthors))
+	assert.Equal(t, "Subject\n\nCo-authored-by: Bob <bob@example.com>\n", addCoAuthors("Subject\n\nCo-authored-by: Bob <bob@example.com>\n", author, coAuthors))
+	assert.Equal(t, "Co-authored-by: Bob <bob@example.com>\n", addCoAuthors("", author, coAuthors))
+}
+
+func TestRepository_RebaseMerge(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	alice := &Signature{Name: "Alice", Email: "alice@example.com", When: time.Now()}
+	committer := &Signature{Name: "Merger", Email: "merger@example.com", When: time.Now().Add(time.Hour)}
+	commitFilesAs(t, repo, alice, "other", "other", map[string]string{"d.txt": "d\n"})
+	headID, err := repo.GetBranchCommitID("other")
+	require.NoError(t, err)
+	oursID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+
+	tipID, err := repo.RebaseMerge("ours", "other", RebaseOptions{Committer: committer})
+	require.NoError(t, err)
+	branchID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, tipID.String(), branchID)
+	assert.NotEqual(t, headID, tipID.String())
+	assert.Equal(t, "ours\n", blobContent(t, repo, tipID, "a.txt"))
+	assert.Equal(t, "other\n", blobContent(t, repo, tipID, "b.txt"))
+	assert.Equal(t, "d\n", blobContent(t, repo, tipID, "d.txt"))
+
+	tip, err := repo.GetCommit(tipID.String())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, tip.ParentCount())
+	assert.Equal(t, "Alice", tip.Author.Name)
+	head, err := repo.GetCommit(headID)
+	require.NoError(t, err)
+	assert.Equal(t, head.Author.When.Unix(), tip.Author.When.Unix())
+	assert.Equal(t, "Merger", tip.Committer.Name)
+	assert.Equal(t, "change other\n", tip.CommitMessage)
+	parentID, err := tip.ParentID(0)
+	require.NoError(t, err)
+	parent, err := repo.GetCommit(parentID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "Test", parent.Author.Name)
+	grandparentID, err := parent.ParentID(0)
+	require.NoError(t, err)
+	assert.Equal(t, oursID, grandparentID.String())
+
+	// nothing to replay
+	sameID, err := repo.RebaseMerge("ours", "other", RebaseOptions{Committer: committer})
+	require.NoError(t, err)
+	assert.Equal(t, tipID, sameID)
+}
+
+func TestRepository_RebaseMergeFastForward(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	headID, err := repo.GetBranchCommitID("other")
+	require.NoError(t, err)
+	tipID, err := repo.RebaseMerge("master", "other", RebaseOptions{Committer: &Signature{Name: "Merger", Email: "merger@example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, headID, tipID.String())
+	branchID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	assert.Equal(t, headID, branchID)
+}
+
+func TestRepository_RebaseMergeConflict(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	oursID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	theirsID, err := repo.GetBranchCommitID("theirs")
+	require.NoError(t, err)
+
+	_, err = repo.RebaseMerge("ours", "theirs", RebaseOptions{Committer: &Signature{Name: "Merger", Email: "merger@example.com"}})
+	require.True(t, IsErrRebaseConflict(err), "%v", err)
+	assert.Equal(t, theirsID, err.(ErrRebaseConflict).Commit)
+	assert.Equal(t, []string{"a.txt"}, err.(ErrRebaseConflict).Files)
+
+	branchID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, oursID, branchID)
+}
+
+func TestRepository_FastForward(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	ok, err := repo.CanFastForward("master", "other")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.CanFastForward("ours", "other")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = repo.CanFastForward("other", "master")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = repo.FastForward("ours", "other")
+	assert.True(t, IsErrNotFastForward(err), "%v", err)
+
+	headID, err := repo.GetBranchCommitID("other")
+	require.NoError(t, err)
+	tipID, err := repo.FastForward("master", "other")
+	require.NoError(t, err)
+	assert.Equal(t, headID, tipID.String())
+	branchID, err := repo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	assert.Equal(t, headID, branchID)
+
+	// already up to date
+	tipID, err = repo.FastForward(BranchPrefix+"master", "other")
+	require.NoError(t, err)
+	assert.Equal(t, headID, tipID.String())
+}
+
+func TestRepository_CheckConflicts(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+	commitFiles(t, repo, "theirs", "theirs", map[string]string{"b.txt": "theirs\n"})
+
+	caps := GetCapabilities()
+	defer capabilities.Store(caps)
+	// without merge-tree --write-tree the merge happens in a temporary index
+	for _, gitVersion := range []string{caps.Version, "2.37.0"} {
+		capabilities.Store(CapabilitiesForVersion(gitVersion))
+
+		conflicts, err := repo.CheckConflicts("ours", "other")
+		require.NoError(t, err)
+		assert.Empty(t, conflicts)
+
+		conflicts, err = repo.CheckConflicts("other", "theirs")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b.txt"}, conflicts, "git %s", gitVersion)
+
+		conflicts, err = repo.CheckConflicts("ours", "theirs")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a.txt"}, conflicts, "git %s", gitVersion)
+	}
+
+	// the index and the branches are untouched
+	status, err := NewCommand("status", "--porcelain").RunInDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, status)
+}
@@ -6,7 +6,6 @@
 package git
 
 import (
-	"bytes"
 	"io"
 	"strings"
 )
@@ -37,15 +36,11 @@ func (repo *Repository) HashObject(reader io.Reader) (SHA1, error) {
 }
 
 func (repo *Repository) hashObject(reader io.Reader) (string, error) {
-	cmd := NewCommand("hash-object", "-w", "--stdin")
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-	err := cmd.RunInDirFullPipeline(repo.Path, stdout, stderr, reader)
-
+	stdout, err := NewCommand("hash-object", "-w", "--stdin").RunInDirWithStdin(repo.Path, reader)
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(stdout.String()), nil
+	return strings.TrimSpace(stdout), nil
 }
 
 // GetRefType gets the type of the ref based on the string
@@ -0,0 +1,366 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ObjectInfo represents the type and size of a git object
+type ObjectInfo struct {
+	ID   SHA1
+	Type ObjectType
+	Size int64
+}
+
+// catFileProcess is a running `git cat-file` process reading object names from its stdin.
+// An I/O error leaves its output in an unknown state, so the process is stopped then and
+// all later requests fail.
+type catFileProcess struct {
+	lock   sync.Mutex
+	name   string
+	proc   *pipedCommand
+	failed int32
+}
+
+// start starts `git cat-file` with the given mode, e.g. --batch, for the repository
+func (p *catFileProcess) start(repo *Repository, mode string) error {
+	proc, err := NewCommand("cat-file", mode).startPiped(nil, repo.Path)
+	if err != nil {
+		return err
+	}
+	p.name = "cat-file " + mode
+	p.proc = proc
+	return nil
+}
+
+// request writes the object name to the process and reads the info line about the object.
+// The lock must be held.
+func (p *catFileProcess) request(rev string) (*ObjectInfo, error) {
+	if strings.Contains(rev, "\n") {
+		return nil, fmt.Errorf("invalid object name: %q", rev)
+	}
+	if p.proc == nil {
+		return nil, fmt.Errorf("%s is closed", p.name)
+	}
+	if _, err := io.WriteString(p.proc.stdin, rev+"\n"); err != nil {
+		return nil, p.fail(err)
+	}
+	line, err := p.proc.stdout.ReadString('\n')
+	if err != nil {
+		return nil, p.fail(err)
+	}
+	info, err := parseCatFileBatchCheckLine(rev, strings.TrimRight(line, "\n"))
+	if err != nil && !IsErrNotExist(err) {
+		return nil, p.fail(err)
+	}
+	return info, err
+}
+
+// fail kills the process after err made its output unusable and returns err together with
+// what the process wrote to stderr. The lock must be held.
+func (p *catFileProcess) fail(err error) error {
+	atomic.StoreInt32(&p.failed, 1)
+	if p.proc == nil {
+		return err
+	}
+	proc := p.proc
+	p.proc = nil
+	_ = proc.kill()
+	return concatenateError(err, proc.stderr.String())
+}
+
+// hasFailed returns true if the process has been stopped after an error
+func (p *catFileProcess) hasFailed() bool {
+	return atomic.LoadInt32(&p.failed) != 0
+}
+
+// Close terminates the underlying process.
+func (p *catFileProcess) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.proc == nil {
+		return nil
+	}
+	proc := p.proc
+	p.proc = nil
+	return proc.close()
+}
+
+// CatFileBatchCheck wraps a running `git cat-file --batch-check` process which
+// can answer any number of object type and size queries.
+type CatFileBatchCheck struct {
+	catFileProcess
+}
+
+// NewCatFileBatchCheck starts a `git cat-file --batch-check` process for the repository.
+// The caller must call Close once it is done with it.
+func (repo *Repository) NewCatFileBatchCheck() (*CatFileBatchCheck, error) {
+	b := &CatFileBatchCheck{}
+	if err := b.start(repo, "--batch-check"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Info returns the type and size of the object named by rev.
+func (b *CatFileBatchCheck) Info(rev string) (*ObjectInfo, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.request(rev)
+}
+
+// parseCatFileBatchCheckLine parses a "<sha> <type> <size>" line as written by `cat-file --batch-check`
+func parseCatFileBatchCheckLine(rev, line string) (*ObjectInfo, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 2 && fields[1] == "missing" {
+		return nil, ErrNotExist{rev, ""}
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid cat-file --batch-check output: %s", line)
+	}
+
+	id, err := NewIDFromString(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		ID:   id,
+		Type: ObjectType(fields[1]),
+		Size: size,
+	}, nil
+}
+
+// CatFileBatch wraps a running `git cat-file --batch` process which can read
+// any number of objects.
+type CatFileBatch struct {
+	catFileProcess
+}
+
+// NewCatFileBatch starts a `git cat-file --batch` process for the repository.
+// The caller must call Close once it is done with it.
+func (repo *Repository) NewCatFileBatch() (*CatFileBatch, error) {
+	b := &CatFileBatch{}
+	if err := b.start(repo, "--batch"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Object returns the type and size of the object named by rev together with a reader of its
+// content. No other object can be read until the reader is closed.
+func (b *CatFileBatch) Object(rev string) (*ObjectInfo, io.ReadCloser, error) {
+	b.lock.Lock()
+	info, err := b.request(rev)
+	if err != nil {
+		b.lock.Unlock()
+		return nil, nil, err
+	}
+	return info, &catFileObjectReader{batch: b, remaining: info.Size}, nil
+}
+
+// catFileObjectReader reads the content of an object from `cat-file --batch` and
+// holds the lock of the process until it is closed
+type catFileObjectReader struct {
+	batch     *CatFileBatch
+	remaining int64
+	closed    bool
+}
+
+func (r *catFileObjectReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, fmt.Errorf("read from closed object reader")
+	}
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if r.batch.proc == nil {
+		return 0, fmt.Errorf("%s is closed", r.batch.name)
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.batch.proc.stdout.Read(p)
+	r.remaining -= int64(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, r.batch.fail(err)
+	}
+	return n, nil
+}
+
+// Close skips the rest of the content, so the next object can be read
+func (r *catFileObjectReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	defer r.batch.lock.Unlock()
+
+	if r.batch.proc == nil {
+		return nil
+	}
+	// the content is followed by a newline
+	if _, err := r.batch.proc.stdout.Discard(int(r.remaining) + 1); err != nil {
+		return r.batch.fail(err)
+	}
+	return nil
+}
+
+// CatFileIdleTimeout is the time after which the cat-file processes of a repository are stopped
+// if they are not used
+var CatFileIdleTimeout = time.Minute
+
+// catFileProcesses are the long-lived cat-file processes of a repository. They are started when needed
+// and stopped once they have not been used for CatFileIdleTimeout.
+type catFileProcesses struct {
+	lock     sync.Mutex
+	batch    *CatFileBatch
+	check    *CatFileBatchCheck
+	users    int
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+func (p *catFileProcesses) acquireBatch(repo *Repository) (*CatFileBatch, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// a process which has failed is stopped already and replaced by a new one
+	if p.batch == nil || p.batch.hasFailed() {
+		batch, err := repo.NewCatFileBatch()
+		if err != nil {
+			return nil, err
+		}
+		p.batch = batch
+	}
+	p.users++
+	return p.batch, nil
+}
+
+func (p *catFileProcesses) acquireCheck(repo *Repository) (*CatFileBatchCheck, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// a process which has failed is stopped already and replaced by a new one
+	if p.check == nil || p.check.hasFailed() {
+		check, err := repo.NewCatFileBatchCheck()
+		if err != nil {
+			return nil, err
+		}
+		p.check = check
+	}
+	p.users++
+	return p.check, nil
+}
+
+func (p *catFileProcesses) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.users--
+	p.lastUsed = time.Now()
+	if p.timer == nil {
+		p.timer = time.AfterFunc(CatFileIdleTimeout, p.stopIdle)
+	}
+}
+
+func (p *catFileProcesses) stopIdle() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.timer = nil
+	if idle := time.Since(p.lastUsed); p.users > 0 || idle < CatFileIdleTimeout {
+		p.timer = time.AfterFunc(CatFileIdleTimeout-idle, p.stopIdle)
+		return
+	}
+	p.stop()
+}
+
+// stop terminates the processes, they are started again when needed
+func (p *catFileProcesses) stop() {
+	if p.batch != nil {
+		_ = p.batch.Close()
+		p.batch = nil
+	}
+	if p.check != nil {
+		_ = p.check.Close()
+		p.check = nil
+	}
+}
+
+// GetObjectInfo returns the type and size of the object named by rev, using the long-lived
+// `cat-file --batch-check` process of the repository
+func (repo *Repository) GetObjectInfo(rev string) (*ObjectInfo, error) {
+	check, err := repo.catFile.acquireCheck(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.catFile.release()
+	return check.Info(rev)
+}
+
+// ReadObject returns the type, size and content of the object named by rev, using the long-lived
+// `cat-file --batch` process of the repository
+func (repo *Repository) ReadObject(rev string) (*ObjectInfo, []byte, error) {
+	batch, err := repo.catFile.acquireBatch(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer repo.catFile.release()
+
+	info, rd, err := batch.Object(rev)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rd.Close()
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, data, nil
+}
+
+// GetObjectInfos returns the type and size of all the given objects using the long-lived
+// `cat-file --batch-check` process of the repository. Objects which do not exist are left out of the result.
+func (repo *Repository) GetObjectInfos(revs []string) (map[string]*ObjectInfo, error) {
+	infos := make(map[string]*ObjectInfo, len(revs))
+	if len(revs) == 0 {
+		return infos, nil
+	}
+
+	batch, err := repo.catFile.acquireCheck(repo)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.catFile.release()
+
+	for _, rev := range revs {
+		info, err := batch.Info(rev)
+		if err != nil {
+			if IsErrNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		infos[rev] = info
+	}
+	return infos, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_GetObjectInfos(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	infos, err := bareRepo1.GetObjectInfos([]string{
+		"e2129701f1a4d54dc44f03c93bca0a2aec7c5449",
+		"feaf4ba6bc635fec442f46ddd4512416ec43c2c2",
+		"0000000000000000000000000000000000000001",
+		"master:foo",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 3)
+
+	if info := infos["e2129701f1a4d54dc44f03c93bca0a2aec7c5449"]; assert.NotNil(t, info) {
+		assert.Equal(t, ObjectBlob, info.Type)
+		assert.EqualValues(t, 6, info.Size)
+	}
+	if info := infos["feaf4ba6bc635fec442f46ddd4512416ec43c2c2"]; assert.NotNil(t, info) {
+		assert.Equal(t, ObjectCommit, info.Type)
+	}
+	if info := infos["master:foo"]; assert.NotNil(t, info) {
+		assert.Equal(t, ObjectTree, info.Type)
+	}
+}
+
+func TestCatFileBatchCheck_Info(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	batch, err := bareRepo1.NewCatFileBatchCheck()
+	assert.NoError(t, err)
+	defer batch.Close()
+
+	_, err = batch.Info("does-not-exist")
+	assert.True(t, IsErrNotExist(err))
+
+	info, err := batch.Info("master")
+	assert.NoError(t, err)
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", info.ID.String())
+	assert.Equal(t, ObjectCommit, info.Type)
+}
+
+func TestCatFileBatch_Object(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	batch, err := bareRepo1.NewCatFileBatch()
+	assert.NoError(t, err)
+	defer batch.Close()
+
+	_, _, err = batch.Object("does-not-exist")
+	assert.True(t, IsErrNotExist(err))
+
+	// the reader is closed without reading the content
+	info, rd, err := batch.Object("master")
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectCommit, info.Type)
+	assert.NoError(t, rd.Close())
+
+	info, rd, err = batch.Object("master:file1.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectBlob, info.Type)
+	data, err := ioutil.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.NoError(t, rd.Close())
+	assert.Equal(t, "file1\n", string(data))
+}
+
+func TestRepository_ReadObject(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+	defer bareRepo1.Close()
+
+	info, data, err := bareRepo1.ReadObject("master:file1.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, info.Size)
+	assert.Equal(t, "file1\n", string(data))
+
+	info, err = bareRepo1.GetObjectInfo("master")
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectCommit, info.Type)
+
+	// the processes are stopped when idle and started again when needed
+	oldTimeout := CatFileIdleTimeout
+	CatFileIdleTimeout = 10 * time.Millisecond
+	defer func() { CatFileIdleTimeout = oldTimeout }()
+	bareRepo1.catFile.lock.Lock()
+	bareRepo1.catFile.lastUsed = time.Time{}
+	bareRepo1.catFile.lock.Unlock()
+	bareRepo1.catFile.stopIdle()
+
+	bareRepo1.catFile.lock.Lock()
+	assert.Nil(t, bareRepo1.catFile.batch)
+	assert.Nil(t, bareRepo1.catFile.check)
+	bareRepo1.catFile.lock.Unlock()
+
+	_, data, err = bareRepo1.ReadObject("master:file1.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "file1\n", string(data))
+}
+
+func TestRepository_ReadObjectRestartsFailedProcess(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+	defer bareRepo1.Close()
+
+	_, _, err = bareRepo1.ReadObject("master:file1.txt")
+	assert.NoError(t, err)
+
+	bareRepo1.catFile.lock.Lock()
+	batch := bareRepo1.catFile.batch
+	bareRepo1.catFile.lock.Unlock()
+	assert.NoError(t, batch.proc.cmd.Process.Kill())
+
+	// the request to the killed process fails and stops it, the next one starts a new process
+	_, _, err = bareRepo1.ReadObject("master:file1.txt")
+	assert.Error(t, err)
+	assert.True(t, batch.hasFailed())
+
+	_, data, err := bareRepo1.ReadObject("master:file1.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "file1\n", string(data))
+	bareRepo1.catFile.lock.Lock()
+	assert.True(t, bareRepo1.catFile.batch != batch)
+	bareRepo1.catFile.lock.Unlock()
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// PullPrefix is the prefix of the refs kept for pull requests
+const PullPrefix = "refs/pull/"
+
+// PullRequestHeadRef returns the name of the ref pointing to the head commit of a pull request
+func PullRequestHeadRef(index int64) string {
+	return fmt.Sprintf("%s%d/head", PullPrefix, index)
+}
+
+// PullRequestMergeRef returns the name of the ref pointing to the test merge of a pull request
+func PullRequestMergeRef(index int64) string {
+	return fmt.Sprintf("%s%d/merge", PullPrefix, index)
+}
+
+// GetPullRequestHead returns the head commit of the pull request.
+// ErrRefNotExist is returned if the head ref does not exist.
+func (repo *Repository) GetPullRequestHead(index int64) (string, error) {
+	return repo.getPullRequestRef(PullRequestHeadRef(index))
+}
+
+// GetPullRequestMerge returns the test merge commit of the pull request.
+// ErrRefNotExist is returned if the merge ref does not exist.
+func (repo *Repository) GetPullRequestMerge(index int64) (string, error) {
+	return repo.getPullRequestRef(PullRequestMergeRef(index))
+}
+
+func (repo *Repository) getPullRequestRef(name string) (string, error) {
+	ref, err := repo.gogitRepo.Reference(plumbing.ReferenceName(name), true)
+	if err != nil {
+		if err == plumbing.ErrReferenceNotFound {
+			return "", ErrRefNotExist{name}
+		}
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+// UpdatePullRequestHead points the head ref of the pull request to commitID, if it currently
+// points to oldCommitID. See RefUpdate for the special old values.
+func (repo *Repository) UpdatePullRequestHead(index int64, commitID, oldCommitID string) error {
+	return repo.UpdateRef(PullRequestHeadRef(index), commitID, oldCommitID)
+}
+
+// UpdatePullRequestMerge points the merge ref of the pull request to commitID, if it currently
+// points to oldCommitID. See RefUpdate for the special old values.
+func (repo *Repository) UpdatePullRequestMerge(index int64, commitID, oldCommitID string) error {
+	return repo.UpdateRef(PullRequestMergeRef(index), commitID, oldCommitID)
+}
+
+// DeletePullRequestRefs deletes the head and merge refs of the pull request in one transaction.
+// Refs which do not exist are ignored.
+func (repo *Repository) DeletePullRequestRefs(index int64) error {
+	return repo.UpdateRefs([]RefUpdate{
+		{Ref: PullRequestHeadRef(index)},
+		{Ref: PullRequestMergeRef(index)},
+	})
+}
+
+// PullRequestMergeStatus is the outcome of the test merge of a pull request
+type PullRequestMergeStatus struct {
+	BaseCommit SHA1
+	HeadCommit SHA1
+	// MergeCommit is the commit the merge ref points at, zero if the pull request conflicts
+	MergeCommit SHA1
+	// ConflictedFiles are the files which conflict, none if the pull request merges cleanly
+	ConflictedFiles []string
+}
+
+// RefreshPullRequestMerge merges the head ref of the pull request into the base branch and points
+// the merge ref of the pull request at the merge commit, which is kept if it already merges the
+// current commits. The merge ref is deleted if they conflict and the conflicting files are
+// returned. committer authors and commits the merge, the identity configured for git is used if
+// nil. ErrRefChanged is returned if the merge ref was updated concurrently.
+func (repo *Repository) RefreshPullRequestMerge(index int64, baseBranch string, committer *Signature) (*PullRequestMergeStatus, error) {
+	baseID, err := repo.resolveRevision(branchRef(baseBranch))
+	if err != nil {
+		return nil, err
+	}
+	headID, err := repo.resolveRevision(PullRequestHeadRef(index))
+	if err != nil {
+		return nil, err
+	}
+	status := &PullRequestMergeStatus{BaseCommit: baseID, HeadCommit: headID}
+
+	oldMergeID, err := repo.GetPullRequestMerge(index)
+	if err != nil && !IsErrRefNotExist(err) {
+		return nil, err
+	}
+	if oldMergeID != "" {
+		merge, err := repo.GetCommit(oldMergeID)
+		if err != nil {
+			return nil, err
+		}
+		if merge.ParentCount() == 2 {
+			firstID, _ := merge.ParentID(0)
+			secondID, _ := merge.ParentID(1)
+			if firstID == baseID && secondID == headID {
+				status.MergeCommit = merge.ID
+				return status, nil
+			}
+		}
+	}
+
+	mergeID, err := repo.Merge(baseID.String(), headID.String(), MergeOptions{
+		Author:    committer,
+		Committer: committer,
+		Message:   "Merge {{.HeadCommit}} into {{.BaseCommit}}",
+		NoGPGSign: true,
+	})
+	if err != nil {
+		conflict, ok := err.(ErrMergeConflict)
+		if !ok {
+			return nil, err
+		}
+		status.ConflictedFiles = conflict.Files
+		if oldMergeID != "" {
+			if err := repo.UpdatePullRequestMerge(index, "", oldMergeID); err != nil {
+				return nil, err
+			}
+		}
+		return status, nil
+	}
+
+	oldValue := oldMergeID
+	if oldValue == "" {
+		oldValue = EmptySHA
+	}
+	if err := repo.UpdatePullRequestMerge(index, mergeID.String(), oldValue); err != nil {
+		return nil, err
+	}
+	status.MergeCommit = mergeID
+	return status, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_PullRequestRefs(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_PullRequestRefs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "refs/pull/3/head", PullRequestHeadRef(3))
+	assert.Equal(t, "refs/pull/3/merge", PullRequestMergeRef(3))
+
+	_, err = repo.GetPullRequestHead(3)
+	assert.True(t, IsErrRefNotExist(err))
+
+	oldHead := "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"
+	newHead := "feaf4ba6bc635fec442f46ddd4512416ec43c2c2"
+	assert.NoError(t, repo.UpdatePullRequestHead(3, oldHead, EmptySHA))
+	assert.True(t, IsErrRefChanged(repo.UpdatePullRequestHead(3, newHead, EmptySHA)))
+	assert.NoError(t, repo.UpdatePullRequestHead(3, newHead, oldHead))
+	assert.True(t, IsErrRefChanged(repo.UpdatePullRequestHead(3, oldHead, oldHead)))
+	head, err := repo.GetPullRequestHead(3)
+	assert.NoError(t, err)
+	assert.Equal(t, newHead, head)
+
+	assert.NoError(t, repo.UpdatePullRequestMerge(3, newHead, ""))
+	assert.NoError(t, repo.DeletePullRequestRefs(3))
+	_, err = repo.GetPullRequestHead(3)
+	assert.True(t, IsErrRefNotExist(err))
+	assert.False(t, IsReferenceExist(clonedPath, PullRequestMergeRef(3)))
+
+	// deleting refs which do not exist succeeds
+	assert.NoError(t, repo.DeletePullRequestRefs(4))
+}
+
+func TestRepository_RefreshPullRequestMerge(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+	committer := &Signature{Name: "Gitea", Email: "gitea@example.com"}
+
+	theirsID, err := repo.GetBranchCommitID("theirs")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdatePullRequestHead(1, theirsID, EmptySHA))
+
+	status, err := repo.RefreshPullRequestMerge(1, "other", committer)
+	require.NoError(t, err)
+	assert.Empty(t, status.ConflictedFiles)
+	assert.Equal(t, theirsID, status.HeadCommit.String())
+	mergeID, err := repo.GetPullRequestMerge(1)
+	require.NoError(t, err)
+	assert.Equal(t, status.MergeCommit.String(), mergeID)
+	assert.Equal(t, "theirs\n", blobContent(t, repo, status.MergeCommit, "a.txt"))
+	assert.Equal(t, "other\n", blobContent(t, repo, status.MergeCommit, "b.txt"))
+	merge, err := repo.GetCommit(mergeID)
+	require.NoError(t, err)
+	assert.Equal(t, "Gitea", merge.Committer.Name)
+
+	// the merge ref is kept while the base branch and the head do not change
+	again, err := repo.RefreshPullRequestMerge(1, "other", committer)
+	require.NoError(t, err)
+	assert.Equal(t, status.MergeCommit, again.MergeCommit)
+
+	commitFiles(t, repo, "other", "other", map[string]string{"d.txt": "d\n"})
+	updated, err := repo.RefreshPullRequestMerge(1, "other", committer)
+	require.NoError(t, err)
+	assert.NotEqual(t, status.MergeCommit, updated.MergeCommit)
+	assert.Equal(t, "d\n", blobContent(t, repo, updated.MergeCommit, "d.txt"))
+
+	// the merge ref is deleted once the pull request conflicts
+	conflicted, err := repo.RefreshPullRequestMerge(1, "ours", committer)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt"}, conflicted.ConflictedFiles)
+	assert.True(t, conflicted.MergeCommit.IsZero())
+	_, err = repo.GetPullRequestMerge(1)
+	assert.True(t, IsErrRefNotExist(err))
+
+	_, err = repo.RefreshPullRequestMerge(2, "ours", committer)
+	assert.Error(t, err)
+}
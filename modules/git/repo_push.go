@@ -0,0 +1,167 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// PushStatus is what a push did with a ref of the remote
+type PushStatus string
+
+// The statuses of the refs of a push, as flagged by `git push --porcelain`
+const (
+	PushStatusFastForward PushStatus = "fast-forward"
+	PushStatusForced      PushStatus = "forced"
+	PushStatusDeleted     PushStatus = "deleted"
+	PushStatusNew         PushStatus = "new"
+	PushStatusRejected    PushStatus = "rejected"
+	PushStatusUpToDate    PushStatus = "up-to-date"
+)
+
+var pushStatusFlags = map[byte]PushStatus{
+	' ': PushStatusFastForward,
+	'+': PushStatusForced,
+	'-': PushStatusDeleted,
+	'*': PushStatusNew,
+	'!': PushStatusRejected,
+	'=': PushStatusUpToDate,
+}
+
+// PushResult is the outcome of the push of a ref
+type PushResult struct {
+	Status PushStatus
+	// Source is the local ref pushed, empty for a deletion, and Destination the ref of the remote
+	Source      string
+	Destination string
+	// Summary is e.g. the range of commits of an update or "[rejected]", Reason tells why the ref
+	// was not updated, e.g. "fetch first"
+	Summary string
+	Reason  string
+}
+
+// Push pushes the refspecs to the remote, a name or a URL, and returns the status of every ref.
+// Force is the same as prefixing every refspec with "+". If any ref is rejected, ErrPushRejected
+// is returned together with the statuses, ErrPushLeaseFailed if a ref of ForceWithLease moved.
+func (repo *Repository) Push(remote string, refspecs []string, opts PushOptions) ([]*PushResult, error) {
+	cmd, env := newPushCommand(true, remote, refspecs, opts)
+	if opts.Timeout <= 0 {
+		opts.Timeout = -1
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	err := cmd.RunInDirTimeoutEnvPipeline(env, opts.Timeout, repo.Path, stdout, stderr)
+	results, parseErr := parsePushResults(stdout.String())
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	var rejected, staleLeases []string
+	for _, result := range results {
+		if result.Status != PushStatusRejected {
+			continue
+		}
+		if result.Reason == "stale info" {
+			staleLeases = append(staleLeases, result.Destination)
+		} else {
+			rejected = append(rejected, result.Destination)
+		}
+	}
+	if len(staleLeases) > 0 {
+		return results, ErrPushLeaseFailed{Remote: remote, Refs: staleLeases}
+	}
+	if len(rejected) > 0 {
+		return results, ErrPushRejected{Remote: remote, Refs: rejected}
+	}
+	if err != nil {
+		return nil, newCommandError(err, stderr.String())
+	}
+	return results, nil
+}
+
+// newPushCommand returns the command pushing the refspecs to the remote with the options, and
+// the environment to run it in, which holds the credentials of opts
+func newPushCommand(porcelain bool, remote string, refspecs []string, opts PushOptions) (*Command, []string) {
+	cmd := NewCommand("push")
+	if porcelain {
+		cmd.AddArguments("--porcelain")
+	}
+	if opts.Force {
+		cmd.AddArguments("--force")
+	}
+	leased := make([]string, 0, len(opts.ForceWithLease))
+	for ref := range opts.ForceWithLease {
+		leased = append(leased, ref)
+	}
+	sort.Strings(leased)
+	for _, ref := range leased {
+		cmd.AddArguments("--force-with-lease=" + ref + ":" + opts.ForceWithLease[ref])
+	}
+	cmd.AddArguments("--", remote)
+	cmd.AddArguments(refspecs...)
+
+	env := opts.Env
+	if opts.Token != "" {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(append(make([]string, 0, len(env)+4), env...), credentialEnv(opts.Username, opts.Token)...)
+	}
+	return cmd, env
+}
+
+// parsePushResults parses the "<flag>\t<from>:<to>\t<summary> (<reason>)" lines of the refs
+// printed by `git push --porcelain`
+func parsePushResults(stdout string) ([]*PushResult, error) {
+	var results []*PushResult
+	for _, line := range strings.Split(stdout, "\n") {
+		// the remote is introduced by "To <url>" and the output ends with "Done"
+		if len(line) < 2 || line[1] != '\t' {
+			continue
+		}
+		status, ok := pushStatusFlags[line[0]]
+		if !ok {
+			return nil, fmt.Errorf("invalid push status: %q", line)
+		}
+		fields := strings.SplitN(line[2:], "\t", 2)
+		refs := strings.SplitN(fields[0], ":", 2)
+		if len(fields) != 2 || len(refs) != 2 {
+			return nil, fmt.Errorf("invalid push status: %q", line)
+		}
+		result := &PushResult{
+			Status:      status,
+			Source:      refs[0],
+			Destination: refs[1],
+			Summary:     fields[1],
+		}
+		if i := strings.Index(result.Summary, " ("); i >= 0 && strings.HasSuffix(result.Summary, ")") {
+			result.Reason = result.Summary[i+2 : len(result.Summary)-1]
+			result.Summary = result.Summary[:i]
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// credentialEnv returns the environment which makes git authenticate to remotes over HTTP with
+// the username and the token, with basic authentication like a password. The username defaults
+// to "oauth2". Prompting for other credentials is disabled.
+func credentialEnv(username, token string) []string {
+	if username == "" {
+		username = "oauth2"
+	}
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+token))
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if GetCapabilities().SupportsConfigEnv {
+		return append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0="+header)
+	}
+	// the header never contains quotes, which would have to be escaped
+	return append(env, "GIT_CONFIG_PARAMETERS='http.extraHeader="+header+"'")
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Push(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+	remoteDir, err := ioutil.TempDir("", "gitea-push")
+	require.NoError(t, err)
+	defer os.RemoveAll(remoteDir)
+	remotePath := filepath.Join(remoteDir, "remote.git")
+	require.NoError(t, InitRepository(remotePath, true))
+	remote, err := OpenRepository(remotePath)
+	require.NoError(t, err)
+
+	results, err := repo.Push(remotePath, []string{"refs/heads/master", "refs/heads/ours"}, PushOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, PushStatusNew, results[0].Status)
+	assert.Equal(t, "refs/heads/master", results[0].Source)
+	assert.Equal(t, "refs/heads/master", results[0].Destination)
+	assert.Equal(t, "[new branch]", results[0].Summary)
+
+	results, err = repo.Push(remotePath, []string{"refs/heads/master"}, PushOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, PushStatusUpToDate, results[0].Status)
+
+	// ours does not descend from theirs
+	results, err = repo.Push(remotePath, []string{"refs/heads/theirs:refs/heads/ours"}, PushOptions{})
+	require.True(t, IsErrPushRejected(err), "%v", err)
+	assert.Equal(t, []string{"refs/heads/ours"}, err.(ErrPushRejected).Refs)
+	require.Len(t, results, 1)
+	assert.Equal(t, PushStatusRejected, results[0].Status)
+	assert.Equal(t, "[rejected]", results[0].Summary)
+	assert.Equal(t, "non-fast-forward", results[0].Reason)
+
+	results, err = repo.Push(remotePath, []string{"refs/heads/theirs:refs/heads/ours"}, PushOptions{Force: true})
+	require.NoError(t, err)
+	assert.Equal(t, PushStatusForced, results[0].Status)
+	theirsID, err := repo.GetBranchCommitID("theirs")
+	require.NoError(t, err)
+	oursID, err := remote.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, theirsID, oursID)
+
+	results, err = repo.Push(remotePath, []string{":refs/heads/ours"}, PushOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, PushStatusDeleted, results[0].Status)
+	assert.Equal(t, "", results[0].Source)
+	assert.False(t, remote.IsBranchExist("ours"))
+
+	_, err = repo.Push(filepath.Join(remoteDir, "missing.git"), []string{"refs/heads/master"}, PushOptions{})
+	assert.Error(t, err)
+}
+
+func TestCredentialEnv(t *testing.T) {
+	// the credentials are passed to git as configuration in the environment
+	stdout, err := NewCommand("config", "--get", "http.extraHeader").RunInDirWithEnv("", append(os.Environ(), credentialEnv("", "token")...))
+	require.NoError(t, err)
+	assert.Equal(t, "Authorization: Basic b2F1dGgyOnRva2Vu", strings.TrimSpace(stdout))
+
+	defer capabilities.Store(GetCapabilities())
+	capabilities.Store(CapabilitiesForVersion("2.30.0"))
+	stdout, err = NewCommand("config", "--get", "http.extraHeader").RunInDirWithEnv("", append(os.Environ(), credentialEnv("user", "token")...))
+	require.NoError(t, err)
+	assert.Equal(t, "Authorization: Basic dXNlcjp0b2tlbg==", strings.TrimSpace(stdout))
+}
+
+func TestRepository_PushForceWithLease(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+	remoteDir, err := ioutil.TempDir("", "gitea-push")
+	require.NoError(t, err)
+	defer os.RemoveAll(remoteDir)
+	remotePath := filepath.Join(remoteDir, "remote.git")
+	require.NoError(t, InitRepository(remotePath, true))
+	remote, err := OpenRepository(remotePath)
+	require.NoError(t, err)
+
+	oursID, err := repo.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	theirsID, err := repo.GetBranchCommitID("theirs")
+	require.NoError(t, err)
+	otherID, err := repo.GetBranchCommitID("other")
+	require.NoError(t, err)
+	_, err = repo.Push(remotePath, []string{"refs/heads/ours"}, PushOptions{})
+	require.NoError(t, err)
+
+	// the branch was updated by someone else since
+	_, err = repo.Push(remotePath, []string{"refs/heads/theirs:refs/heads/ours"}, PushOptions{ForceWithLease: map[string]string{"refs/heads/ours": otherID}})
+	require.True(t, IsErrPushLeaseFailed(err), "%v", err)
+	assert.Equal(t, []string{"refs/heads/ours"}, err.(ErrPushLeaseFailed).Refs)
+	branchID, err := remote.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, oursID, branchID)
+
+	results, err := repo.Push(remotePath, []string{"refs/heads/theirs:refs/heads/ours"}, PushOptions{ForceWithLease: map[string]string{"refs/heads/ours": oursID}})
+	require.NoError(t, err)
+	assert.Equal(t, PushStatusForced, results[0].Status)
+	branchID, err = remote.GetBranchCommitID("ours")
+	require.NoError(t, err)
+	assert.Equal(t, theirsID, branchID)
+
+	// the branch must not exist yet
+	_, err = repo.Push(remotePath, []string{"refs/heads/other:refs/heads/ours"}, PushOptions{ForceWithLease: map[string]string{"refs/heads/ours": ""}})
+	assert.True(t, IsErrPushLeaseFailed(err), "%v", err)
+	results, err = repo.Push(remotePath, []string{"refs/heads/other"}, PushOptions{ForceWithLease: map[string]string{"refs/heads/other": ""}})
+	require.NoError(t, err)
+	assert.Equal(t, PushStatusNew, results[0].Status)
+
+	// the package-level Push honors the lease too
+	assert.Error(t, Push(tmpDir, PushOptions{Remote: remotePath, Branch: "ours:refs/heads/other", ForceWithLease: map[string]string{"refs/heads/other": oursID}}))
+	assert.NoError(t, Push(tmpDir, PushOptions{Remote: remotePath, Branch: "ours:refs/heads/other", ForceWithLease: map[string]string{"refs/heads/other": otherID}}))
+	branchID, err = remote.GetBranchCommitID("other")
+	require.NoError(t, err)
+	assert.Equal(t, oursID, branchID)
+}
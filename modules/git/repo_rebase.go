@@ -0,0 +1,214 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+)
+
+// RebaseAction is what a rebase does with a commit
+type RebaseAction string
+
+// The actions of a rebase
+const (
+	// RebaseActionPick replays the commit
+	RebaseActionPick RebaseAction = "pick"
+	// RebaseActionFixup folds the commit into the commit picked before it and drops its message
+	RebaseActionFixup RebaseAction = "fixup"
+	// RebaseActionSquash folds the commit into the commit picked before it and appends its message
+	RebaseActionSquash RebaseAction = "squash"
+)
+
+// RebaseStep is a commit in the plan of a rebase, like a line of the todo list of `git rebase -i`
+type RebaseStep struct {
+	Action  RebaseAction
+	Commit  SHA1
+	Subject string
+}
+
+// RebaseOptions are the options of RebaseMerge
+type RebaseOptions struct {
+	// Committer of the new commits, the identity configured for git is used if nil
+	Committer *Signature
+	// Autosquash folds the commits whose subjects start with "fixup! " or "squash! " into the
+	// commit they name, like `git rebase --autosquash`
+	Autosquash bool
+}
+
+// RebasePlan returns the steps RebaseMerge takes to replay the head revision onto the base
+// revision, oldest first, so the rebase can be previewed
+func (repo *Repository) RebasePlan(base, head string, autosquash bool) ([]*RebaseStep, error) {
+	baseID, err := repo.resolveRevision(base)
+	if err != nil {
+		return nil, err
+	}
+	headID, err := repo.resolveRevision(head)
+	if err != nil {
+		return nil, err
+	}
+	return repo.rebasePlan(baseID, headID, autosquash)
+}
+
+func (repo *Repository) rebasePlan(baseID, headID SHA1, autosquash bool) ([]*RebaseStep, error) {
+	stdout, err := NewCommand("log", "--reverse", "--topo-order", "--no-merges", "--format=%H %s", baseID.String()+".."+headID.String()).RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	var steps []*RebaseStep
+	for _, line := range splitLines(stdout) {
+		fields := strings.SplitN(string(line), " ", 2)
+		id, err := NewIDFromString(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		step := &RebaseStep{Action: RebaseActionPick, Commit: id}
+		if len(fields) == 2 {
+			step.Subject = fields[1]
+		}
+		steps = append(steps, step)
+	}
+	if autosquash {
+		steps = autosquashSteps(steps)
+	}
+	return steps, nil
+}
+
+// autosquashSteps moves every commit whose subject starts with "fixup! " or "squash! " right
+// behind the earlier commit it names by subject or by ID, after the commits already folded into
+// it. Like git the first prefix decides the action and "fixup! fixup! subject" names the commit
+// with the subject. Commits naming no earlier commit are picked.
+func autosquashSteps(steps []*RebaseStep) []*RebaseStep {
+	var groups [][]*RebaseStep
+	groupOf := make([]int, len(steps))
+	bySubject := make(map[string]int)
+	for i, step := range steps {
+		subject := step.Subject
+		action := RebaseActionPick
+		for {
+			var prefixed RebaseAction
+			if strings.HasPrefix(subject, "fixup! ") {
+				prefixed = RebaseActionFixup
+			} else if strings.HasPrefix(subject, "squash! ") {
+				prefixed = RebaseActionSquash
+			} else {
+				break
+			}
+			if action == RebaseActionPick {
+				action = prefixed
+			}
+			subject = strings.TrimLeft(subject[len(prefixed)+2:], " ")
+		}
+
+		group := -1
+		if action != RebaseActionPick {
+			if g, ok := bySubject[subject]; ok {
+				group = g
+			} else if len(subject) >= 4 {
+				for j := 0; j < i; j++ {
+					if strings.HasPrefix(steps[j].Commit.String(), subject) {
+						group = groupOf[j]
+						break
+					}
+				}
+			}
+		}
+		if group < 0 {
+			group = len(groups)
+			groups = append(groups, nil)
+		} else {
+			step.Action = action
+		}
+		groups[group] = append(groups[group], step)
+		groupOf[i] = group
+		if _, ok := bySubject[step.Subject]; !ok {
+			bySubject[step.Subject] = group
+		}
+	}
+
+	sorted := make([]*RebaseStep, 0, len(steps))
+	for _, group := range groups {
+		sorted = append(sorted, group...)
+	}
+	return sorted
+}
+
+// rebaseCommits replays a picked commit and the commits folded into it onto the commit onto with
+// the tree ontoTreeID, and returns the ID and the tree of the new tip. A commit whose parent is
+// onto is kept unless others are folded into it, the tip stays onto if the commits change nothing.
+func (repo *Repository) rebaseCommits(steps []*RebaseStep, ontoID, ontoTreeID SHA1, committer *Signature, signing []string) (SHA1, SHA1, error) {
+	var author *Signature
+	var messages []string
+	// the commit the next commit is replayed onto, which is only written for that
+	tipID, treeID := ontoID, ontoTreeID
+	kept := false
+	for i, step := range steps {
+		commit, err := repo.GetCommit(step.Commit.String())
+		if err != nil {
+			return SHA1{}, SHA1{}, err
+		}
+		parentID, err := commit.ParentID(0)
+		if err != nil {
+			return SHA1{}, SHA1{}, err
+		}
+
+		if parentID == tipID {
+			tipID, treeID = commit.ID, commit.Tree.ID
+			kept = i == 0
+		} else {
+			treeID, err = repo.cherryPickTree(parentID, tipID, commit.ID)
+			if err != nil {
+				if conflict, ok := err.(ErrMergeConflict); ok {
+					return SHA1{}, SHA1{}, ErrRebaseConflict{Commit: commit.ID.String(), Files: conflict.Files}
+				}
+				return SHA1{}, SHA1{}, err
+			}
+			if i < len(steps)-1 {
+				tipID, err = repo.writeCommit(treeID, []SHA1{ontoID}, commit.CommitMessage, commit.Author, committer, []string{"--no-gpg-sign"})
+				if err != nil {
+					return SHA1{}, SHA1{}, err
+				}
+			}
+		}
+
+		switch step.Action {
+		case RebaseActionPick:
+			author = commit.Author
+			messages = append(messages, commit.CommitMessage)
+		case RebaseActionSquash:
+			if message := squashMessage(commit.CommitMessage); message != "" {
+				messages = append(messages, message)
+			}
+		}
+	}
+
+	if kept && len(steps) == 1 {
+		return tipID, treeID, nil
+	}
+	if treeID == ontoTreeID {
+		return ontoID, ontoTreeID, nil
+	}
+	tipID, err := repo.writeCommit(treeID, []SHA1{ontoID}, strings.Join(messages, "\n"), author, committer, signing)
+	if err != nil {
+		return SHA1{}, SHA1{}, err
+	}
+	return tipID, treeID, nil
+}
+
+// squashMessage returns the message of a squash commit without its "squash! " subject, which
+// only names the commit it is folded into
+func squashMessage(message string) string {
+	if strings.HasPrefix(message, "squash! ") {
+		if i := strings.Index(message, "\n"); i >= 0 {
+			message = message[i+1:]
+		} else {
+			message = ""
+		}
+	}
+	message = strings.TrimLeft(message, "\n")
+	if strings.TrimSpace(message) == "" {
+		return ""
+	}
+	return message
+}
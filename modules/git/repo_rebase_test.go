@@ -0,0 +1,133 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitWithMessage commits the files with the message on top of the branch
+func commitWithMessage(t *testing.T, repo *Repository, branch, message string, files map[string]string) SHA1 {
+	parent, err := repo.GetBranchCommit(branch)
+	require.NoError(t, err)
+	builder := repo.NewTreeBuilder(parent.Tree.ID)
+	for name, content := range files {
+		id, err := repo.HashObject(strings.NewReader(content))
+		require.NoError(t, err)
+		if err := builder.Update(name, id); err != nil {
+			require.NoError(t, builder.Add(name, EntryModeBlob, id))
+		}
+	}
+	treeID, err := builder.Write()
+	require.NoError(t, err)
+
+	sig := &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	commitID, err := repo.CommitTree(sig, NewTree(repo, treeID), CommitTreeOpts{Parents: []string{parent.ID.String()}, Message: message})
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRef(BranchPrefix+branch, commitID.String(), parent.ID.String()))
+	return commitID
+}
+
+func TestAutosquashSteps(t *testing.T) {
+	ids := []string{
+		"1111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222",
+		"3333333333333333333333333333333333333333",
+		"4444444444444444444444444444444444444444",
+		"5555555555555555555555555555555555555555",
+		"6666666666666666666666666666666666666666",
+		"7777777777777777777777777777777777777777",
+	}
+	subjects := []string{
+		"add a",
+		"add b",
+		"squash! add a",
+		"fixup! 2222222",
+		"fixup! squash! add a",
+		"fixup! unknown",
+		"fixup! fixup! add a",
+	}
+	var steps []*RebaseStep
+	for i, id := range ids {
+		steps = append(steps, &RebaseStep{Action: RebaseActionPick, Commit: MustIDFromString(id), Subject: subjects[i]})
+	}
+
+	var plan []string
+	for _, step := range autosquashSteps(steps) {
+		plan = append(plan, string(step.Action)+" "+step.Subject)
+	}
+	assert.Equal(t, []string{
+		"pick add a",
+		"squash squash! add a",
+		"fixup fixup! squash! add a",
+		"fixup fixup! fixup! add a",
+		"pick add b",
+		"fixup fixup! 2222222",
+		"pick fixup! unknown",
+	}, plan)
+}
+
+func TestSquashMessage(t *testing.T) {
+	assert.Equal(t, "", squashMessage("squash! add a\n"))
+	assert.Equal(t, "", squashMessage("squash! add a"))
+	assert.Equal(t, "more of a\n", squashMessage("squash! add a\n\nmore of a\n"))
+	assert.Equal(t, "add more a\n", squashMessage("add more a\n"))
+}
+
+func TestRepository_RebaseMergeAutosquash(t *testing.T) {
+	tmpDir, repo := initMergeTestRepo(t)
+	defer os.RemoveAll(tmpDir)
+
+	commitWithMessage(t, repo, "other", "add d\n", map[string]string{"d.txt": "d\n"})
+	commitWithMessage(t, repo, "other", "add e\n", map[string]string{"e.txt": "e\n"})
+	commitWithMessage(t, repo, "other", "fixup! add d\n", map[string]string{"d.txt": "fixed d\n"})
+	commitWithMessage(t, repo, "other", "squash! add d\n\nand f\n", map[string]string{"f.txt": "f\n"})
+
+	plan, err := repo.RebasePlan("ours", "other", true)
+	require.NoError(t, err)
+	var actions []string
+	for _, step := range plan {
+		actions = append(actions, string(step.Action)+" "+step.Subject)
+	}
+	assert.Equal(t, []string{"pick change other", "pick add d", "fixup fixup! add d", "squash squash! add d", "pick add e"}, actions)
+
+	plan, err = repo.RebasePlan("ours", "other", false)
+	require.NoError(t, err)
+	assert.Len(t, plan, 5)
+	assert.Equal(t, RebaseActionPick, plan[3].Action)
+
+	tipID, err := repo.RebaseMerge("ours", "other", RebaseOptions{Committer: &Signature{Name: "Merger", Email: "merger@example.com"}, Autosquash: true})
+	require.NoError(t, err)
+	assert.Equal(t, "ours\n", blobContent(t, repo, tipID, "a.txt"))
+	assert.Equal(t, "other\n", blobContent(t, repo, tipID, "b.txt"))
+	assert.Equal(t, "fixed d\n", blobContent(t, repo, tipID, "d.txt"))
+	assert.Equal(t, "e\n", blobContent(t, repo, tipID, "e.txt"))
+	assert.Equal(t, "f\n", blobContent(t, repo, tipID, "f.txt"))
+
+	tip, err := repo.GetCommit(tipID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "add e\n", tip.CommitMessage)
+	squashedID, err := tip.ParentID(0)
+	require.NoError(t, err)
+	squashed, err := repo.GetCommit(squashedID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "add d\n\nand f\n", squashed.CommitMessage)
+	assert.Equal(t, "Merger", squashed.Committer.Name)
+	assert.Equal(t, "fixed d\n", blobContent(t, repo, squashedID, "d.txt"))
+	_, err = squashed.Tree.GetBlobByPath("e.txt")
+	assert.True(t, IsErrNotExist(err))
+	count, err := CommitsCount(repo.Path, "ours..master")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, count)
+	count, err = CommitsCount(repo.Path, "master..ours")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+}
@@ -5,10 +5,14 @@
 package git
 
 import (
+	"bytes"
+	"fmt"
+	"regexp"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 // GetRefs returns all references of the repository.
@@ -53,3 +57,193 @@ func (repo *Repository) GetRefsFiltered(pattern string) ([]*Reference, error) {
 
 	return refs, nil
 }
+
+// ListRefs returns the references of the repository matching any of the patterns, all of them
+// if no pattern is given. A pattern matches a ref if it is the full name of the ref, one of
+// the parent namespaces (e.g. "refs/pull") or a glob like "refs/tags/v1.*".
+// Unlike GetRefs the result includes remote-tracking and any other refs, in refname order.
+func (repo *Repository) ListRefs(patterns ...string) ([]*Reference, error) {
+	cmd := NewCommand("for-each-ref", "--format=%(objectname)%00%(objecttype)%00%(*objectname)%00%(refname)")
+	if len(patterns) > 0 {
+		cmd.AddArguments("--")
+		cmd.AddArguments(patterns...)
+	}
+	stdout, err := cmd.RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRefList(repo, stdout)
+}
+
+// parseRefList parses the output of ListRefs' `for-each-ref` invocation
+func parseRefList(repo *Repository, data []byte) ([]*Reference, error) {
+	refs := make([]*Reference, 0)
+	for _, line := range splitLines(data) {
+		if len(line) == 0 {
+			continue
+		}
+		fields := bytes.Split(line, []byte{0})
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid for-each-ref output: %s", line)
+		}
+
+		id, err := NewIDFromString(string(fields[0]))
+		if err != nil {
+			return nil, err
+		}
+		peeled := id
+		if len(fields[2]) > 0 {
+			if peeled, err = NewIDFromString(string(fields[2])); err != nil {
+				return nil, err
+			}
+		}
+		refs = append(refs, &Reference{
+			Name:   string(fields[3]),
+			Object: id,
+			Type:   string(fields[1]),
+			Peeled: peeled,
+			repo:   repo,
+		})
+	}
+	return refs, nil
+}
+
+// RefUpdate describes the change of a single ref by UpdateRefs
+type RefUpdate struct {
+	Ref string
+	// NewValue is the object the ref is set to, the ref is deleted if it is empty or EmptySHA
+	NewValue string
+	// OldValue is the object the ref must currently point to. An empty value skips the check,
+	// while EmptySHA requires that the ref does not exist yet.
+	OldValue string
+}
+
+// refLockErrorRegexp matches the errors reported by update-ref if a ref does not have the expected value
+var refLockErrorRegexp = regexp.MustCompile(`cannot lock ref '([^']+)': (is at [0-9a-f]+ but expected [0-9a-f]+|reference already exists|unable to resolve reference.*|reference is missing but expected [0-9a-f]+)`)
+
+// UpdateRef sets ref to newValue if it currently points to oldValue, see RefUpdate for the
+// special values. ErrRefChanged is returned if the ref does not have the expected old value.
+func (repo *Repository) UpdateRef(ref, newValue, oldValue string) error {
+	var cmd *Command
+	if newValue == "" || newValue == EmptySHA {
+		cmd = NewCommand("update-ref", "-d", "--", ref)
+	} else {
+		cmd = NewCommand("update-ref", "--", ref, newValue)
+	}
+	if oldValue != "" {
+		cmd.AddArguments(oldValue)
+	}
+	defer LockWrites(repo.Path)()
+	return convertUpdateRefError(cmd.runInDirWithRetry(repo.Path))
+}
+
+// UpdateRefs applies all updates in a single transaction, either all of them succeed or none.
+func (repo *Repository) UpdateRefs(updates []RefUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	stdin := new(bytes.Buffer)
+	for _, update := range updates {
+		if strings.ContainsAny(update.Ref+update.NewValue+update.OldValue, "\x00\n ") {
+			return fmt.Errorf("invalid ref update: %v", update)
+		}
+		if update.NewValue == "" || update.NewValue == EmptySHA {
+			fmt.Fprintf(stdin, "delete %s", update.Ref)
+		} else {
+			fmt.Fprintf(stdin, "update %s %s", update.Ref, update.NewValue)
+		}
+		// An empty old value would be read as the zero ID
+		if update.OldValue != "" {
+			fmt.Fprintf(stdin, " %s", update.OldValue)
+		}
+		stdin.WriteByte('\n')
+	}
+
+	defer LockWrites(repo.Path)()
+	err := DefaultRetryPolicy.Do(func() error {
+		_, err := NewCommand("update-ref", "--stdin").RunInDirWithStdin(repo.Path, bytes.NewReader(stdin.Bytes()))
+		return err
+	})
+	return convertUpdateRefError(err)
+}
+
+func convertUpdateRefError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if m := refLockErrorRegexp.FindStringSubmatch(err.Error()); m != nil {
+		return ErrRefChanged{Ref: m[1], Reason: strings.TrimSpace(m[2])}
+	}
+	return err
+}
+
+// refResolveRules are the places a ref name is looked up at, in the order used by git
+var refResolveRules = []string{"%s", "refs/%s", TagPrefix + "%s", BranchPrefix + "%s", RemotePrefix + "%s", RemotePrefix + "%s/HEAD"}
+
+// PeelRef resolves the ref, which may be abbreviated like `master` or `v1.0`, and follows any
+// annotated tags until it reaches a commit, tree or blob. It returns the ID and type of that object.
+// ErrRefNotExist is returned if there is no such ref and ErrNotExist if an object is missing.
+func (repo *Repository) PeelRef(name string) (SHA1, ObjectType, error) {
+	for _, rule := range refResolveRules {
+		ref, err := repo.gogitRepo.Reference(plumbing.ReferenceName(fmt.Sprintf(rule, name)), true)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		} else if err != nil {
+			return SHA1{}, "", err
+		}
+		return repo.peelObject(ref.Hash())
+	}
+	return SHA1{}, "", ErrRefNotExist{name}
+}
+
+// resolveRevision returns the ID of the object rev refers to. Refs are peeled by PeelRef,
+// `rev-parse` is only run for abbreviated IDs and revision expressions like `master~1`.
+func (repo *Repository) resolveRevision(rev string) (SHA1, error) {
+	if len(rev) == 40 {
+		return NewIDFromString(rev)
+	}
+	id, _, err := repo.PeelRef(rev)
+	if !IsErrRefNotExist(err) {
+		return id, err
+	}
+	stdout, err := NewCommand("rev-parse", "--verify", rev).RunInDir(repo.Path)
+	if err != nil {
+		return SHA1{}, err
+	}
+	return NewIDFromString(strings.TrimSpace(stdout))
+}
+
+// peelObject follows annotated tags, which may point to other tags, until it reaches another kind of object
+func (repo *Repository) peelObject(id SHA1) (SHA1, ObjectType, error) {
+	for {
+		obj, err := repo.gogitRepo.Storer.EncodedObject(plumbing.AnyObject, id)
+		if err != nil {
+			if err == plumbing.ErrObjectNotFound {
+				return SHA1{}, "", ErrNotExist{id.String(), ""}
+			}
+			return SHA1{}, "", err
+		}
+
+		if obj.Type() != plumbing.TagObject {
+			return id, ObjectType(obj.Type().String()), nil
+		}
+		tag, err := object.DecodeTag(repo.gogitRepo.Storer, obj)
+		if err != nil {
+			return SHA1{}, "", err
+		}
+		id = tag.Target
+	}
+}
+
+// peelToCommit is peelObject for objects which must end up at a commit
+func (repo *Repository) peelToCommit(id SHA1) (SHA1, error) {
+	id, typ, err := repo.peelObject(id)
+	if err != nil {
+		return SHA1{}, err
+	}
+	if typ != ObjectCommit {
+		return SHA1{}, fmt.Errorf("%s is a %s, not a commit", id, typ)
+	}
+	return id, nil
+}
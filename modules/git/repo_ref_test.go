@@ -5,6 +5,7 @@
 package git
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -48,3 +49,117 @@ func TestRepository_GetRefsFiltered(t *testing.T) {
 		assert.Equal(t, "3ad28a9149a2864384548f3d17ed7f38014c9e8a", refs[0].Object.String())
 	}
 }
+
+func TestRepository_ListRefs(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	refs, err := bareRepo1.ListRefs()
+	assert.NoError(t, err)
+	var names []string
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	assert.Equal(t, []string{
+		BranchPrefix + "branch1",
+		BranchPrefix + "branch2",
+		BranchPrefix + "master",
+		NotesRef,
+		TagPrefix + "test",
+	}, names)
+
+	refs, err = bareRepo1.ListRefs("refs/tags", "refs/heads/branch*")
+	assert.NoError(t, err)
+	if assert.Len(t, refs, 3) {
+		assert.Equal(t, BranchPrefix+"branch1", refs[0].Name)
+		assert.Equal(t, "commit", refs[0].Type)
+		assert.Equal(t, refs[0].Object, refs[0].Peeled)
+
+		assert.Equal(t, TagPrefix+"test", refs[2].Name)
+		assert.Equal(t, "tag", refs[2].Type)
+		assert.Equal(t, "3ad28a9149a2864384548f3d17ed7f38014c9e8a", refs[2].Object.String())
+		assert.Equal(t, "37991dec2c8e592043f47155ce4808d4580f9123", refs[2].Peeled.String())
+	}
+
+	refs, err = bareRepo1.ListRefs("refs/pull")
+	assert.NoError(t, err)
+	assert.Empty(t, refs)
+}
+
+func TestRepository_UpdateRef(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_UpdateRef")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	const (
+		master   = "feaf4ba6bc635fec442f46ddd4512416ec43c2c2"
+		file1    = "95bb4d39648ee7e325106df01a621c530863a653"
+		file2    = "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"
+		testRef  = "refs/pull/1/head"
+		otherRef = "refs/pull/2/head"
+	)
+
+	assert.NoError(t, repo.UpdateRef(testRef, file1, EmptySHA))
+	err = repo.UpdateRef(testRef, file2, EmptySHA)
+	if assert.True(t, IsErrRefChanged(err), "%v", err) {
+		assert.Equal(t, testRef, err.(ErrRefChanged).Ref)
+	}
+	err = repo.UpdateRef(testRef, file2, master)
+	assert.True(t, IsErrRefChanged(err), "%v", err)
+	assert.NoError(t, repo.UpdateRef(testRef, file2, file1))
+	assert.NoError(t, repo.UpdateRef(testRef, master, ""))
+
+	// The transaction fails as a whole
+	err = repo.UpdateRefs([]RefUpdate{
+		{Ref: otherRef, NewValue: file1, OldValue: EmptySHA},
+		{Ref: testRef, NewValue: file1, OldValue: file2},
+	})
+	assert.True(t, IsErrRefChanged(err), "%v", err)
+	refs, err := repo.ListRefs(otherRef)
+	assert.NoError(t, err)
+	assert.Empty(t, refs)
+
+	assert.NoError(t, repo.UpdateRefs([]RefUpdate{
+		{Ref: otherRef, NewValue: file1, OldValue: EmptySHA},
+		{Ref: testRef, OldValue: master},
+	}))
+	refs, err = repo.ListRefs("refs/pull")
+	assert.NoError(t, err)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, otherRef, refs[0].Name)
+	}
+
+	err = repo.UpdateRef(testRef, "", file1)
+	assert.True(t, IsErrRefChanged(err), "%v", err)
+}
+
+func TestRepository_PeelRef(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		Name         string
+		ExpectedID   string
+		ExpectedType ObjectType
+	}{
+		{"master", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", ObjectCommit},
+		{"refs/heads/branch1", "2839944139e0de9737a044f78b0e4b40d989a9e3", ObjectCommit},
+		{"HEAD", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", ObjectCommit},
+		{"test", "37991dec2c8e592043f47155ce4808d4580f9123", ObjectCommit},
+		{"tags/test", "37991dec2c8e592043f47155ce4808d4580f9123", ObjectCommit},
+	}
+	for _, testCase := range testCases {
+		id, typ, err := bareRepo1.PeelRef(testCase.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.ExpectedID, id.String(), testCase.Name)
+		assert.Equal(t, testCase.ExpectedType, typ, testCase.Name)
+	}
+
+	_, _, err = bareRepo1.PeelRef("no-such-ref")
+	assert.True(t, IsErrRefNotExist(err))
+}
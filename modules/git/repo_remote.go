@@ -0,0 +1,133 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RemotePrefix is the prefix of the remote-tracking refs
+const RemotePrefix = "refs/remotes/"
+
+// RemoteTrackingRef represents a ref below refs/remotes/ which mirrors a ref of a remote
+type RemoteTrackingRef struct {
+	// Name is the full name of the remote-tracking ref, e.g. refs/remotes/origin/master
+	Name     string
+	CommitID SHA1
+	Remote   string
+	// Upstream is the full name of the ref of the remote the ref is fetched from, e.g.
+	// refs/heads/master. It is empty if none of the fetch refspecs of the remote maps to it.
+	Upstream string
+}
+
+// GetRemoteTrackingRefs returns the remote-tracking refs of the remote, sorted by name,
+// together with the refs of the remote they are fetched from.
+func (repo *Repository) GetRemoteTrackingRefs(remote string) ([]*RemoteTrackingRef, error) {
+	if remote == "" || strings.ContainsAny(remote, "/*") {
+		return nil, fmt.Errorf("invalid remote name: %s", remote)
+	}
+
+	refspecs, err := repo.getFetchRefspecs(remote)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := repo.ListRefs(RemotePrefix + remote + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	trackingRefs := make([]*RemoteTrackingRef, 0, len(refs))
+	for _, ref := range refs {
+		trackingRef := &RemoteTrackingRef{
+			Name:     ref.Name,
+			CommitID: ref.Object,
+			Remote:   remote,
+		}
+		// refs/remotes/<remote>/HEAD is a symbolic ref to the default branch of the remote
+		if ref.Name == RemotePrefix+remote+"/HEAD" {
+			trackingRef.Upstream = "HEAD"
+			trackingRefs = append(trackingRefs, trackingRef)
+			continue
+		}
+		for _, refspec := range refspecs {
+			if upstream, ok := refspec.mapToSource(ref.Name); ok {
+				trackingRef.Upstream = upstream
+				break
+			}
+		}
+		trackingRefs = append(trackingRefs, trackingRef)
+	}
+	return trackingRefs, nil
+}
+
+// PruneRemote deletes the remote-tracking refs of the remote whose upstream refs no longer exist,
+// e.g. because the branches have been deleted in the remote. It returns the names of the deleted refs.
+func (repo *Repository) PruneRemote(remote string) ([]string, error) {
+	before, err := repo.GetRemoteTrackingRefs(remote)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := NewCommand("remote", "prune", remote).RunInDir(repo.Path); err != nil {
+		return nil, err
+	}
+	after, err := repo.GetRemoteTrackingRefs(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make(map[string]bool, len(after))
+	for _, ref := range after {
+		remaining[ref.Name] = true
+	}
+	pruned := make([]string, 0)
+	for _, ref := range before {
+		if !remaining[ref.Name] {
+			pruned = append(pruned, ref.Name)
+		}
+	}
+	return pruned, nil
+}
+
+// fetchRefspec is a parsed `remote.<name>.fetch` entry like `+refs/heads/*:refs/remotes/origin/*`
+type fetchRefspec struct {
+	src, dst string
+}
+
+func (repo *Repository) getFetchRefspecs(remote string) ([]fetchRefspec, error) {
+	stdout, err := NewCommand("config", "--get-all", "remote."+remote+".fetch").RunInDir(repo.Path)
+	if err != nil {
+		// config exits with 1 if the key is not set
+		if cmdErr, ok := err.(CommandError); ok && cmdErr.ExitCode == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var refspecs []fetchRefspec
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "+")
+		i := strings.IndexByte(line, ':')
+		// negative refspecs and refspecs without destination do not create remote-tracking refs
+		if i <= 0 || i == len(line)-1 || strings.HasPrefix(line, "^") {
+			continue
+		}
+		refspecs = append(refspecs, fetchRefspec{src: line[:i], dst: line[i+1:]})
+	}
+	return refspecs, nil
+}
+
+// mapToSource returns the ref of the remote which is fetched into the local ref
+func (r fetchRefspec) mapToSource(ref string) (string, bool) {
+	i := strings.IndexByte(r.dst, '*')
+	if i < 0 {
+		return r.src, ref == r.dst
+	}
+	prefix, suffix := r.dst[:i], r.dst[i+1:]
+	if len(ref) < len(prefix)+len(suffix) || !strings.HasPrefix(ref, prefix) || !strings.HasSuffix(ref, suffix) {
+		return "", false
+	}
+	return strings.Replace(r.src, "*", ref[len(prefix):len(ref)-len(suffix)], 1), true
+}
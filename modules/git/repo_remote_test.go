@@ -0,0 +1,55 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_RemoteTrackingRefs(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	upstreamPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_RemoteTrackingRefs_upstream")
+	assert.NoError(t, err)
+	defer os.RemoveAll(upstreamPath)
+	upstream, err := OpenRepository(upstreamPath)
+	assert.NoError(t, err)
+	assert.NoError(t, upstream.CreateBranch("feature", "master"))
+
+	clonedPath, err := cloneRepo(upstreamPath, testReposDir, "repo1_TestRepository_RemoteTrackingRefs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	refs, err := repo.GetRemoteTrackingRefs("origin")
+	assert.NoError(t, err)
+	upstreams := make(map[string]string)
+	for _, ref := range refs {
+		assert.Equal(t, "origin", ref.Remote)
+		upstreams[ref.Name] = ref.Upstream
+	}
+	assert.Equal(t, map[string]string{
+		"refs/remotes/origin/HEAD":    "HEAD",
+		"refs/remotes/origin/feature": "refs/heads/feature",
+		"refs/remotes/origin/master":  "refs/heads/master",
+	}, upstreams)
+
+	pruned, err := repo.PruneRemote("origin")
+	assert.NoError(t, err)
+	assert.Empty(t, pruned)
+
+	assert.NoError(t, upstream.DeleteBranch("feature", DeleteBranchOptions{Force: true}))
+	pruned, err = repo.PruneRemote("origin")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"refs/remotes/origin/feature"}, pruned)
+
+	_, err = repo.GetRemoteTrackingRefs("origin/master")
+	assert.Error(t, err)
+}
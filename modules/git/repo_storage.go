@@ -0,0 +1,83 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"sync"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+var (
+	// ObjectCacheMaxMemory is the maximum number of bytes of the go-git object cache of a repository,
+	// which also holds the delta bases of the objects read from packs. The cache is disabled if it is 0.
+	ObjectCacheMaxMemory = int64(cache.DefaultMaxSize)
+	// WalkObjectCacheMaxMemory is the maximum number of bytes of the object cache of every worker
+	// walking the history to find the last commits of tree entries. The cache is disabled if it is 0.
+	WalkObjectCacheMaxMemory = int64(cache.DefaultMaxSize)
+)
+
+// newStorage returns a go-git storage of the repository files whose object cache is limited to maxMemory bytes
+func newStorage(fs billy.Filesystem, maxMemory int64) *filesystem.Storage {
+	return filesystem.NewStorageWithOptions(fs, cache.NewObjectLRU(cache.FileSize(maxMemory)), filesystem.Options{KeepDescriptors: true})
+}
+
+// lockedStorage serializes the object reads and writes of a go-git storage, which keeps
+// unsynchronized caches of the packfile indexes and of the open packfiles. Objects read
+// through it load their content from their own file descriptors, so they may be read concurrently.
+type lockedStorage struct {
+	*filesystem.Storage
+	lock sync.Mutex
+}
+
+func newLockedStorage(storage *filesystem.Storage) *lockedStorage {
+	return &lockedStorage{Storage: storage}
+}
+
+func (s *lockedStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.EncodedObject(t, h)
+}
+
+func (s *lockedStorage) HasEncodedObject(h plumbing.Hash) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.HasEncodedObject(h)
+}
+
+func (s *lockedStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.EncodedObjectSize(h)
+}
+
+func (s *lockedStorage) SetEncodedObject(o plumbing.EncodedObject) (plumbing.Hash, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.SetEncodedObject(o)
+}
+
+func (s *lockedStorage) DeltaObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.DeltaObject(t, h)
+}
+
+func (s *lockedStorage) Reindex() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Storage.Reindex()
+}
+
+// Close closes the open packfiles, they are opened again when needed
+func (s *lockedStorage) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Storage.Close()
+}
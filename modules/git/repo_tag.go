@@ -6,6 +6,7 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 
@@ -27,16 +28,71 @@ func (repo *Repository) IsTagExist(name string) bool {
 	return err == nil
 }
 
-// CreateTag create one tag in the repository
+// CreateTag create one lightweight tag pointing to revision in the repository
 func (repo *Repository) CreateTag(name, revision string) error {
-	_, err := NewCommand("tag", "--", name, revision).RunInDir(repo.Path)
-	return err
+	id, err := repo.checkNewTag(name, revision)
+	if err != nil {
+		return err
+	}
+	defer LockWrites(repo.Path)()
+	return NewCommand("tag", "--", name, id.String()).runInDirWithRetry(repo.Path)
 }
 
-// CreateAnnotatedTag create one annotated tag in the repository
-func (repo *Repository) CreateAnnotatedTag(name, message, revision string) error {
-	_, err := NewCommand("tag", "-a", "-m", message, "--", name, revision).RunInDir(repo.Path)
-	return err
+// CreateAnnotatedTag create one annotated tag pointing to revision in the repository.
+// If tagger is nil, the identity configured for git is used.
+func (repo *Repository) CreateAnnotatedTag(name, message, revision string, tagger *Signature) error {
+	if strings.TrimSpace(message) == "" {
+		return fmt.Errorf("annotated tag %s needs a message", name)
+	}
+	id, err := repo.checkNewTag(name, revision)
+	if err != nil {
+		return err
+	}
+
+	defer LockWrites(repo.Path)()
+	return NewCommand("tag", "-a", "-m", message, "--", name, id.String()).
+		SetEnv(CommandEnvOptions{Committer: tagger}).
+		runInDirWithRetry(repo.Path)
+}
+
+// DeleteTag deletes the tag and returns the ID of the commit it pointed to.
+// It returns ErrTagNotExist if there is no such tag and ErrTagProtected if the
+// tag matches one of the ProtectedTags patterns.
+func (repo *Repository) DeleteTag(name string) (SHA1, error) {
+	for _, pattern := range repo.ProtectedTags {
+		if ok, _ := MatchPathGlob(pattern, name); ok {
+			return SHA1{}, ErrTagProtected{name}
+		}
+	}
+
+	ref, err := repo.gogitRepo.Reference(plumbing.ReferenceName(TagPrefix+name), true)
+	if err != nil {
+		if err == plumbing.ErrReferenceNotFound {
+			return SHA1{}, ErrTagNotExist{name}
+		}
+		return SHA1{}, err
+	}
+	commitID, err := repo.GetTagCommitID(name)
+	if err != nil {
+		return SHA1{}, err
+	}
+
+	// Only delete the tag if it has not been changed in the meantime
+	if err := repo.UpdateRef(TagPrefix+name, EmptySHA, ref.Hash().String()); err != nil {
+		return SHA1{}, err
+	}
+	return NewIDFromString(commitID)
+}
+
+// checkNewTag validates the name of a new tag and resolves the revision it is created for
+func (repo *Repository) checkNewTag(name, revision string) (SHA1, error) {
+	if _, err := NewCommand("check-ref-format", TagPrefix+name).RunInDir(repo.Path); err != nil {
+		return SHA1{}, fmt.Errorf("invalid tag name: %s", name)
+	}
+	if repo.IsTagExist(name) {
+		return SHA1{}, ErrTagAlreadyExists{name}
+	}
+	return repo.ConvertToSHA1(revision)
 }
 
 func (repo *Repository) getTag(id SHA1) (*Tag, error) {
@@ -105,7 +161,7 @@ func (repo *Repository) getTag(id SHA1) (*Tag, error) {
 	}
 
 	// The tag is an annotated tag with a message.
-	data, err := NewCommand("cat-file", "-p", id.String()).RunInDirBytes(repo.Path)
+	_, data, err := repo.ReadObject(id.String())
 	if err != nil {
 		return nil, err
 	}
@@ -183,31 +239,80 @@ func (repo *Repository) GetTag(name string) (*Tag, error) {
 	return tag, nil
 }
 
-// GetTagInfos returns all tag infos of the repository.
-func (repo *Repository) GetTagInfos() ([]*Tag, error) {
-	// TODO this a slow implementation, makes one git command per tag
-	stdout, err := NewCommand("tag").RunInDir(repo.Path)
+// GetTagInfos returns at most limit tags of the repository, newest first, after skipping the
+// first skip ones, together with the total number of tags. A limit <= 0 returns all remaining
+// tags. All tags are read by a single `for-each-ref`, the PGP signatures of signed tags are not
+// loaded though, use GetTag for these.
+func (repo *Repository) GetTagInfos(skip, limit int) ([]*Tag, int, error) {
+	stdout, err := NewCommand("for-each-ref", "--sort=-creatordate",
+		"--format=%(objectname)%00%(objecttype)%00%(*objectname)%00%(refname)%00%(creator)%00%(contents)%1e",
+		TagPrefix).RunInDirBytes(repo.Path)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	tagNames := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
-	var tags = make([]*Tag, 0, len(tagNames))
-	for _, tagName := range tagNames {
-		tagName = strings.TrimSpace(tagName)
-		if len(tagName) == 0 {
-			continue
-		}
+	records := bytes.Split(stdout, []byte{'\x1e'})
+	// The output ends with the separator and a newline
+	records = records[:len(records)-1]
+	total := len(records)
+	if skip >= total {
+		return []*Tag{}, total, nil
+	}
+	records = records[skip:]
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
 
-		tag, err := repo.GetTag(tagName)
+	tags := make([]*Tag, 0, len(records))
+	for _, record := range records {
+		tag, err := parseTagInfo(bytes.TrimPrefix(record, []byte{'\n'}))
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		tag.Name = tagName
+		tag.repo = repo
 		tags = append(tags, tag)
 	}
-	sortTagsByTime(tags)
-	return tags, nil
+	return tags, total, nil
+}
+
+// parseTagInfo parses a record of the `for-each-ref` output of GetTagInfos
+func parseTagInfo(record []byte) (*Tag, error) {
+	fields := bytes.SplitN(record, []byte{0}, 6)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid for-each-ref output: %s", record)
+	}
+
+	id, err := NewIDFromString(string(fields[0]))
+	if err != nil {
+		return nil, err
+	}
+	tag := &Tag{
+		Name:   strings.TrimPrefix(string(fields[3]), TagPrefix),
+		ID:     id,
+		Object: id,
+		Type:   string(fields[1]),
+	}
+	if len(fields[2]) > 0 {
+		if tag.Object, err = NewIDFromString(string(fields[2])); err != nil {
+			return nil, err
+		}
+	}
+	if len(fields[4]) > 0 {
+		if tag.Tagger, err = newSignatureFromCommitline(fields[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	message := fields[5]
+	if tag.IsAnnotated() {
+		if idx := bytes.Index(message, []byte(pgpSignatureBegin)); idx >= 0 {
+			message = message[:idx]
+		}
+		tag.Message = strings.TrimRight(string(message), "\n")
+	} else {
+		tag.Message = string(message)
+	}
+	return tag, nil
 }
 
 // GetTags returns all tags of the repository.
@@ -235,17 +340,34 @@ func (repo *Repository) GetTags() ([]string, error) {
 	return tagNames, nil
 }
 
+// GetTagsContaining returns the names of the tags which contain the commit, newest first.
+func (repo *Repository) GetTagsContaining(commitID string) ([]string, error) {
+	var cmd *Command
+	if GetCapabilities().SupportsForEachRefContains {
+		cmd = NewCommand("for-each-ref", "--sort=-creatordate", "--format=%(refname)", "--contains", commitID, TagPrefix)
+	} else {
+		cmd = NewCommand("tag", "--contains", commitID)
+	}
+	stdout, err := cmd.RunInDir(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0)
+	for _, ref := range strings.Fields(stdout) {
+		tags = append(tags, strings.TrimPrefix(ref, TagPrefix))
+	}
+	return tags, nil
+}
+
 // GetTagType gets the type of the tag, either commit (simple) or tag (annotated)
 func (repo *Repository) GetTagType(id SHA1) (string, error) {
 	// Get tag type
-	stdout, err := NewCommand("cat-file", "-t", id.String()).RunInDir(repo.Path)
+	info, err := repo.GetObjectInfo(id.String())
 	if err != nil {
 		return "", err
 	}
-	if len(stdout) == 0 {
-		return "", ErrNotExist{ID: id.String()}
-	}
-	return strings.TrimSpace(stdout), nil
+	return string(info.Type), nil
 }
 
 // GetAnnotatedTag returns a Git tag by its SHA, must be an annotated tag
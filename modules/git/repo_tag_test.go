@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,14 +18,59 @@ func TestRepository_GetTags(t *testing.T) {
 	bareRepo1, err := OpenRepository(bareRepo1Path)
 	assert.NoError(t, err)
 
-	tags, err := bareRepo1.GetTagInfos()
+	tags, total, err := bareRepo1.GetTagInfos(0, 0)
 	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
 	assert.Len(t, tags, 1)
 	assert.EqualValues(t, "test", tags[0].Name)
 	assert.EqualValues(t, "3ad28a9149a2864384548f3d17ed7f38014c9e8a", tags[0].ID.String())
 	assert.EqualValues(t, "tag", tags[0].Type)
 }
 
+func TestRepository_GetTagInfosPaged(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_GetTagInfosPaged")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	// lightweight tags are dated by their commit
+	assert.NoError(t, repo.CreateTag("lightweight", "8006ff9adbf0cb94da7dad9e537e53817f9fa5c0"))
+	tagger := &Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
+	assert.NoError(t, repo.CreateAnnotatedTag("annotated", "my annotated message\n\nwith a body", "master", tagger))
+
+	tags, total, err := repo.GetTagInfos(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, tags, 3) {
+		assert.Equal(t, "annotated", tags[0].Name)
+		for _, tag := range tags {
+			expected, err := repo.GetTag(tag.Name)
+			assert.NoError(t, err)
+			assert.Equal(t, expected.ID, tag.ID)
+			assert.Equal(t, expected.Object, tag.Object)
+			assert.Equal(t, expected.Type, tag.Type)
+			assert.Equal(t, expected.Message, tag.Message)
+			assert.Equal(t, expected.Tagger.Name, tag.Tagger.Name)
+			assert.Equal(t, expected.Tagger.Email, tag.Tagger.Email)
+			assert.Equal(t, expected.Tagger.When.Unix(), tag.Tagger.When.Unix())
+		}
+	}
+
+	tags, total, err = repo.GetTagInfos(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, tags, 1)
+
+	tags, total, err = repo.GetTagInfos(3, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, tags, 0)
+}
+
 func TestRepository_GetTag(t *testing.T) {
 	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
 
@@ -42,7 +88,7 @@ func TestRepository_GetTag(t *testing.T) {
 	aTagCommitID := "8006ff9adbf0cb94da7dad9e537e53817f9fa5c0"
 	aTagName := "annotatedTag"
 	aTagMessage := "my annotated message"
-	bareRepo1.CreateAnnotatedTag(aTagName, aTagMessage, aTagCommitID)
+	bareRepo1.CreateAnnotatedTag(aTagName, aTagMessage, aTagCommitID, nil)
 	aTagID, _ := bareRepo1.GetTagID(aTagName)
 
 	lTag, err := bareRepo1.GetTag(lTagName)
@@ -53,6 +99,7 @@ func TestRepository_GetTag(t *testing.T) {
 	assert.EqualValues(t, lTagCommitID, lTag.ID.String())
 	assert.EqualValues(t, lTagCommitID, lTag.Object.String())
 	assert.EqualValues(t, "commit", lTag.Type)
+	assert.False(t, lTag.IsAnnotated())
 
 	aTag, err := bareRepo1.GetTag(aTagName)
 	assert.NoError(t, err)
@@ -62,6 +109,10 @@ func TestRepository_GetTag(t *testing.T) {
 	assert.NotEqual(t, aTagID, aTag.Object.String())
 	assert.EqualValues(t, aTagCommitID, aTag.Object.String())
 	assert.EqualValues(t, "tag", aTag.Type)
+	assert.True(t, aTag.IsAnnotated())
+	assert.False(t, aTag.IsSigned())
+	assert.Equal(t, aTagMessage, aTag.Message)
+	assert.NotNil(t, aTag.Tagger)
 }
 
 func TestRepository_GetAnnotatedTag(t *testing.T) {
@@ -81,7 +132,7 @@ func TestRepository_GetAnnotatedTag(t *testing.T) {
 	aTagCommitID := "8006ff9adbf0cb94da7dad9e537e53817f9fa5c0"
 	aTagName := "annotatedTag"
 	aTagMessage := "my annotated message"
-	bareRepo1.CreateAnnotatedTag(aTagName, aTagMessage, aTagCommitID)
+	bareRepo1.CreateAnnotatedTag(aTagName, aTagMessage, aTagCommitID, nil)
 	aTagID, _ := bareRepo1.GetTagID(aTagName)
 
 	// Try an annotated tag
@@ -110,3 +161,89 @@ func TestRepository_GetAnnotatedTag(t *testing.T) {
 	assert.True(t, IsErrNotExist(err))
 	assert.Nil(t, tag4)
 }
+
+func TestRepository_CreateTag(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_CreateTag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.CreateTag("v1.0", "master"))
+	assert.True(t, IsErrTagAlreadyExists(repo.CreateTag("v1.0", "master")))
+	assert.True(t, IsErrTagAlreadyExists(repo.CreateAnnotatedTag("v1.0", "message", "master", nil)))
+	assert.Error(t, repo.CreateTag("invalid..name", "master"))
+	assert.True(t, IsErrNotExist(repo.CreateTag("v1.1", "no-such-branch")))
+	assert.Error(t, repo.CreateAnnotatedTag("v1.1", " ", "master", nil))
+
+	tagger := &Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Unix(1565116800, 0),
+	}
+	assert.NoError(t, repo.CreateAnnotatedTag("v1.1", "Release 1.1", "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", tagger))
+	tag, err := repo.GetTag("v1.1")
+	assert.NoError(t, err)
+	assert.True(t, tag.IsAnnotated())
+	assert.Equal(t, "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", tag.Object.String())
+	assert.Equal(t, "Release 1.1", tag.Message)
+	if assert.NotNil(t, tag.Tagger) {
+		assert.Equal(t, tagger.Name, tag.Tagger.Name)
+		assert.Equal(t, tagger.Email, tag.Tagger.Email)
+		assert.EqualValues(t, 1565116800, tag.Tagger.When.Unix())
+	}
+}
+
+func TestRepository_DeleteTag(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_DeleteTag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	repo.ProtectedTags = []string{"v1.*"}
+
+	assert.NoError(t, repo.CreateTag("v1.0", "master"))
+	assert.NoError(t, repo.CreateAnnotatedTag("v2.0", "Release 2.0", "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", nil))
+
+	_, err = repo.DeleteTag("v1.0")
+	assert.True(t, IsErrTagProtected(err))
+	assert.True(t, repo.IsTagExist("v1.0"))
+
+	_, err = repo.DeleteTag("v3.0")
+	assert.True(t, IsErrTagNotExist(err))
+
+	commitID, err := repo.DeleteTag("v2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2", commitID.String())
+	assert.False(t, repo.IsTagExist("v2.0"))
+}
+
+func TestRepository_GetTagsContaining(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_GetTagsContaining")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.CreateTag("branch1-tag", "2839944139e0de9737a044f78b0e4b40d989a9e3"))
+
+	tags, err := repo.GetTagsContaining("95bb4d39648ee7e325106df01a621c530863a653")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"test", "branch1-tag"}, tags)
+
+	tags, err = repo.GetTagsContaining("2839944139e0de9737a044f78b0e4b40d989a9e3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"branch1-tag"}, tags)
+
+	tags, err = repo.GetTagsContaining("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	gitealog "code.gitea.io/gitea/modules/log"
+)
+
+// TemporaryRepository is a repository in a temporary directory which borrows the objects of
+// another repository, so it is created in no time whatever the size of the other repository.
+// Merges, patches and conflict checks can work on it without changing the other repository.
+type TemporaryRepository struct {
+	*Repository
+	closeOnce sync.Once
+}
+
+// NewTemporaryRepository clones the repository at sourcePath with `clone --shared` into a new
+// directory in parentDir, the temporary directory of the system if it is empty. The clone has
+// the branches and tags of the source and uses its objects through alternates, new objects are
+// written to the clone only. Close must be called to remove the directory.
+func NewTemporaryRepository(parentDir, sourcePath string) (*TemporaryRepository, error) {
+	return newTemporaryRepository(parentDir, sourcePath, "--bare")
+}
+
+// NewTemporaryWorkingRepository is like NewTemporaryRepository, but the clone has a work tree for
+// the commands which need one like merge and rebase. The branch is checked out without any file
+// being written to the work tree, and the branches of the source are origin/<branch>.
+func NewTemporaryWorkingRepository(parentDir, sourcePath, branch string) (*TemporaryRepository, error) {
+	return newTemporaryRepository(parentDir, sourcePath, "--no-checkout", "--branch", branch)
+}
+
+func newTemporaryRepository(parentDir, sourcePath string, cloneArgs ...string) (*TemporaryRepository, error) {
+	sourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if parentDir != "" {
+		if err := os.MkdirAll(parentDir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	tmpPath, err := ioutil.TempDir(parentDir, "temporary-repo")
+	if err != nil {
+		return nil, err
+	}
+	// the clone runs in the new directory, so its path must not be relative
+	if tmpPath, err = filepath.Abs(tmpPath); err != nil {
+		removeTemporaryPath(tmpPath)
+		return nil, err
+	}
+
+	cmd := NewCommand("clone", "--shared", "--quiet").AddArguments(cloneArgs...).AddArguments("--", sourcePath, tmpPath)
+	if _, err := cmd.RunInDir(tmpPath); err != nil {
+		removeTemporaryPath(tmpPath)
+		return nil, err
+	}
+	repo, err := OpenRepository(tmpPath)
+	if err != nil {
+		removeTemporaryPath(tmpPath)
+		return nil, err
+	}
+
+	return &TemporaryRepository{Repository: repo}, nil
+}
+
+// Close closes the repository and removes its directory, it must not be used afterwards
+func (t *TemporaryRepository) Close() {
+	t.closeOnce.Do(func() {
+		t.Repository.Close()
+		removeTemporaryPath(t.Path)
+	})
+}
+
+func removeTemporaryPath(path string) {
+	if err := os.RemoveAll(path); err != nil {
+		gitealog.Error("Unable to remove temporary path %s: %v", path, err)
+	}
+}
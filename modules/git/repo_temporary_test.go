@@ -0,0 +1,110 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemporaryRepository(t *testing.T) {
+	parentDir, err := ioutil.TempDir("", "temporary-repos")
+	require.NoError(t, err)
+	defer os.RemoveAll(parentDir)
+
+	tmpRepo, err := NewTemporaryRepository(parentDir, filepath.Join(testReposDir, "repo1_bare"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tmpRepo.Path, parentDir))
+
+	// the objects of the source are borrowed, not copied
+	alternates, err := ioutil.ReadFile(filepath.Join(tmpRepo.Path, "objects", "info", "alternates"))
+	require.NoError(t, err)
+	assert.Contains(t, string(alternates), "repo1_bare")
+	commitID, err := tmpRepo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", commitID)
+	_, err = tmpRepo.GetCommit(commitID)
+	require.NoError(t, err)
+
+	// changes stay in the temporary repository
+	require.NoError(t, tmpRepo.UpdateRef(BranchPrefix+"master", "37991dec2c8e592043f47155ce4808d4580f9123", commitID))
+	sourceRepo, err := OpenRepository(filepath.Join(testReposDir, "repo1_bare"))
+	require.NoError(t, err)
+	defer sourceRepo.Close()
+	sourceID, err := sourceRepo.GetBranchCommitID("master")
+	require.NoError(t, err)
+	assert.Equal(t, commitID, sourceID)
+
+	tmpRepo.Close()
+	tmpRepo.Close()
+	_, err = os.Stat(tmpRepo.Path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTemporaryRepositoryWithoutClose(t *testing.T) {
+	parentDir, err := ioutil.TempDir("", "temporary-repos")
+	require.NoError(t, err)
+	defer os.RemoveAll(parentDir)
+
+	// the directory is only removed by Close, however long the embedded repository is used
+	repo := func() *Repository {
+		tmpRepo, err := NewTemporaryRepository(parentDir, filepath.Join(testReposDir, "repo1_bare"))
+		require.NoError(t, err)
+		return tmpRepo.Repository
+	}()
+	runtime.GC()
+	runtime.GC()
+	_, err = repo.GetBranchCommitID("master")
+	assert.NoError(t, err)
+	_, err = os.Stat(repo.Path)
+	assert.NoError(t, err)
+	repo.Close()
+}
+
+func TestNewTemporaryRepositoryNotExist(t *testing.T) {
+	parentDir, err := ioutil.TempDir("", "temporary-repos")
+	require.NoError(t, err)
+	defer os.RemoveAll(parentDir)
+
+	_, err = NewTemporaryRepository(parentDir, filepath.Join(parentDir, "missing"))
+	assert.Error(t, err)
+	entries, err := ioutil.ReadDir(parentDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestNewTemporaryWorkingRepository(t *testing.T) {
+	parentDir, err := ioutil.TempDir("", "temporary-repos")
+	require.NoError(t, err)
+	defer os.RemoveAll(parentDir)
+
+	tmpRepo, err := NewTemporaryWorkingRepository(parentDir, filepath.Join(testReposDir, "repo1_bare"), "master")
+	require.NoError(t, err)
+	defer tmpRepo.Close()
+
+	// the branch is checked out without writing the files of its tree
+	alternates, err := ioutil.ReadFile(filepath.Join(tmpRepo.Path, ".git", "objects", "info", "alternates"))
+	require.NoError(t, err)
+	assert.Contains(t, string(alternates), "repo1_bare")
+	head, err := NewCommand("symbolic-ref", "HEAD").RunInDir(tmpRepo.Path)
+	require.NoError(t, err)
+	assert.Equal(t, BranchPrefix+"master", strings.TrimSpace(head))
+	entries, err := ioutil.ReadDir(tmpRepo.Path)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, ".git", entries[0].Name())
+	}
+
+	commitID, err := GetFullCommitID(tmpRepo.Path, "origin/master")
+	require.NoError(t, err)
+	assert.Equal(t, "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", commitID)
+}
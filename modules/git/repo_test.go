@@ -5,13 +5,53 @@
 package git
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// initTestRepo creates a non-bare repository in a temporary directory and commits the
+// given files and symlinks (path -> content / link target) to it. The caller must
+// remove the returned directory.
+func initTestRepo(t *testing.T, files, links map[string]string) (string, *Repository) {
+	// Commits created by the tests without a signature must not depend on the identity configured on the host
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		require.NoError(t, os.Setenv(name, "Test"))
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		require.NoError(t, os.Setenv(name, "test@example.com"))
+	}
+
+	tmpDir, err := ioutil.TempDir("", "git-test-repo")
+	require.NoError(t, err)
+	require.NoError(t, InitRepository(tmpDir, false))
+
+	for name, content := range files {
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+	for name, target := range links {
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.NoError(t, os.Symlink(target, p))
+	}
+	require.NoError(t, AddChanges(tmpDir, true))
+	sig := &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	require.NoError(t, CommitChanges(tmpDir, CommitChangesOptions{Committer: sig, Author: sig, Message: "initial commit"}))
+
+	repo, err := OpenRepository(tmpDir)
+	require.NoError(t, err)
+	return tmpDir, repo
+}
+
 func TestGetLatestCommitTime(t *testing.T) {
 	lct, err := GetLatestCommitTime(".")
 	assert.NoError(t, err)
@@ -34,3 +74,189 @@ func TestRepoIsEmpty(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, isEmpty)
 }
+
+func TestRepository_HeadState(t *testing.T) {
+	emptyRepo2Path := filepath.Join(testReposDir, "repo2_empty")
+	emptyRepo, err := OpenRepository(emptyRepo2Path)
+	assert.NoError(t, err)
+	head, err := emptyRepo.HeadState()
+	assert.NoError(t, err)
+	assert.Equal(t, &HeadState{Branch: "master", Unborn: true}, head)
+
+	tmpDir, repo := initTestRepo(t, map[string]string{"README.md": "readme"}, nil)
+	defer os.RemoveAll(tmpDir)
+	commitID, err := repo.GetBranchCommitID("master")
+	assert.NoError(t, err)
+
+	head, err = repo.HeadState()
+	assert.NoError(t, err)
+	assert.Equal(t, &HeadState{Branch: "master", CommitID: MustIDFromString(commitID)}, head)
+
+	_, err = NewCommand("checkout", "--detach").RunInDir(tmpDir)
+	assert.NoError(t, err)
+	head, err = repo.HeadState()
+	assert.NoError(t, err)
+	assert.Equal(t, &HeadState{Detached: true, CommitID: MustIDFromString(commitID)}, head)
+	_, err = repo.GetDefaultBranch()
+	assert.Error(t, err)
+
+	_, err = NewCommand("checkout", "--orphan", "new").RunInDir(tmpDir)
+	assert.NoError(t, err)
+	head, err = repo.HeadState()
+	assert.NoError(t, err)
+	assert.Equal(t, &HeadState{Branch: "new", Unborn: true}, head)
+	isEmpty, err := repo.IsEmpty()
+	assert.NoError(t, err)
+	assert.True(t, isEmpty)
+}
+
+func TestRepository_ConcurrentUse(t *testing.T) {
+	oldMaxItems := CommitCacheMaxItems
+	CommitCacheMaxItems = 0
+	defer func() { CommitCacheMaxItems = oldMaxItems }()
+
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	repo, err := OpenRepository(bareRepo1Path)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				commit, err := repo.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+				if !assert.NoError(t, err) {
+					return
+				}
+				entry, err := commit.GetTreeEntryByPath("file1.txt")
+				if !assert.NoError(t, err) {
+					return
+				}
+				data, err := entry.Blob().GetBlobContent()
+				assert.NoError(t, err)
+				assert.Equal(t, "file1\n", data)
+				if i == 0 {
+					repo.Close()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRepository_ObjectCacheDisabled(t *testing.T) {
+	oldMaxMemory, oldWalkMaxMemory := ObjectCacheMaxMemory, WalkObjectCacheMaxMemory
+	defer func() { ObjectCacheMaxMemory, WalkObjectCacheMaxMemory = oldMaxMemory, oldWalkMaxMemory }()
+	ObjectCacheMaxMemory, WalkObjectCacheMaxMemory = 0, 0
+
+	bareRepo1, err := OpenRepository(filepath.Join(testReposDir, "repo1_bare"))
+	require.NoError(t, err)
+	defer bareRepo1.Close()
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	require.NoError(t, err)
+	entries, err := commit.Tree.ListEntries()
+	require.NoError(t, err)
+	commitsInfo, _, err := entries.GetCommitsInfo(commit, "", nil)
+	assert.NoError(t, err)
+	for _, commitInfo := range commitsInfo {
+		assert.NotNil(t, commitInfo.Commit)
+	}
+}
+
+func TestInitRepositoryWithOptions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "git-init")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// only the default branch
+	emptyPath := filepath.Join(tmpDir, "empty.git")
+	require.NoError(t, InitRepositoryWithOptions(emptyPath, InitRepositoryOptions{Bare: true, DefaultBranch: "main"}))
+	repo, err := OpenRepository(emptyPath)
+	require.NoError(t, err)
+	defaultBranch, err := repo.GetDefaultBranch()
+	require.NoError(t, err)
+	assert.Equal(t, "main", defaultBranch)
+	assert.False(t, repo.IsBranchExist("main"))
+	repo.Close()
+
+	sig := &Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	repoPath := filepath.Join(tmpDir, "seeded.git")
+	require.NoError(t, InitRepositoryWithOptions(repoPath, InitRepositoryOptions{
+		Bare:          true,
+		DefaultBranch: "trunk",
+		InitialCommit: &CreateCommitOptions{
+			Message:   "Initial commit",
+			Author:    sig,
+			Committer: sig,
+			Files: []FileOperation{
+				{Type: FileOperationCreate, Path: "README.md", Content: strings.NewReader("# seeded\n")},
+				{Type: FileOperationCreate, Path: ".gitignore", Content: strings.NewReader("*.o\n")},
+			},
+		},
+	}))
+	repo, err = OpenRepository(repoPath)
+	require.NoError(t, err)
+	defer repo.Close()
+	commit, err := repo.GetBranchCommit("trunk")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, commit.ParentCount())
+	assert.Equal(t, "Initial commit\n", commit.CommitMessage)
+	entries, err := commit.Tree.ListEntries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Error(t, InitRepositoryWithOptions(filepath.Join(tmpDir, "invalid.git"), InitRepositoryOptions{Bare: true, DefaultBranch: "in..valid"}))
+}
+
+func TestRepository_CountObjects(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	// a commit with a tree, a subtree and two blobs
+	count, err := repo.CountObjects()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, count.Count)
+	assert.True(t, count.Size > 0)
+	assert.Zero(t, count.Packs)
+	assert.Equal(t, count.Size, count.ObjectsSize())
+
+	// the loose objects are kept next to the packfile
+	_, err = NewCommand("repack", "-q").RunInDir(tmpDir)
+	assert.NoError(t, err)
+	count, err = repo.CountObjects()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, count.InPack)
+	assert.EqualValues(t, 1, count.Packs)
+	assert.EqualValues(t, 5, count.PrunePack)
+	assert.Equal(t, count.Size+count.SizePack, count.ObjectsSize())
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, ".git", "objects", "pack", "garbage"), make([]byte, 4096), 0644))
+	count, err = repo.CountObjects()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count.Garbage)
+	assert.True(t, count.SizeGarbage > 0)
+}
+
+func TestRepository_SizeWithoutLFS(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	size, err := repo.SizeWithoutLFS()
+	assert.NoError(t, err)
+	count, err := repo.CountObjects()
+	assert.NoError(t, err)
+	// the refs and the config are counted besides the objects
+	assert.True(t, size > count.ObjectsSize()+count.SizeGarbage)
+
+	lfsDir := filepath.Join(tmpDir, ".git", "lfs", "objects", "ab", "cd")
+	assert.NoError(t, os.MkdirAll(lfsDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(lfsDir, "abcdef"), make([]byte, 1<<20), 0644))
+	// neither the large files nor the worktree are counted
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "large.bin"), make([]byte, 1<<20), 0644))
+	sizeAfter, err := repo.SizeWithoutLFS()
+	assert.NoError(t, err)
+	assert.Equal(t, size, sizeAfter)
+}
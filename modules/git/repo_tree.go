@@ -7,9 +7,12 @@ package git
 
 import (
 	"fmt"
-	"os"
+	"path"
 	"strings"
 	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 )
 
 func (repo *Repository) getTree(id SHA1) (*Tree, error) {
@@ -25,16 +28,7 @@ func (repo *Repository) getTree(id SHA1) (*Tree, error) {
 
 // GetTree find the tree object in the repository.
 func (repo *Repository) GetTree(idStr string) (*Tree, error) {
-	if len(idStr) != 40 {
-		res, err := NewCommand("rev-parse", "--verify", idStr).RunInDir(repo.Path)
-		if err != nil {
-			return nil, err
-		}
-		if len(res) > 0 {
-			idStr = res[:len(res)-1]
-		}
-	}
-	id, err := NewIDFromString(idStr)
+	id, err := repo.resolveRevision(idStr)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +45,147 @@ func (repo *Repository) GetTree(idStr string) (*Tree, error) {
 	return treeObject, nil
 }
 
+// LsTreeRecursive lists all blobs and submodules below treePath in the tree of the given ref.
+// The names of the returned entries are full paths relative to the repository root. The first skip
+// entries are omitted and at most limit entries are returned, a limit <= 0 returns all of them.
+func (repo *Repository) LsTreeRecursive(ref, treePath string, skip, limit int) (Entries, error) {
+	tree, err := repo.GetTree(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := NewCommand("ls-tree", "-r", "--full-tree", tree.ID.String())
+	if len(treePath) > 0 {
+		cmd.AddArguments("--", treePath)
+	}
+	stdout, err := cmd.RunInDirBytes(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseTreeEntries(stdout, tree)
+	if err != nil {
+		return nil, err
+	}
+	return paginateEntries(entries, skip, limit), nil
+}
+
+// SearchFiles returns the paths of all files in the tree of the given ref matching the glob
+// pattern (see MatchPathGlob). A pattern without a `/` is matched against the file name only.
+// At most limit paths are returned, a limit <= 0 returns all matches.
+func (repo *Repository) SearchFiles(ref, pattern string, limit int) ([]string, error) {
+	if _, err := MatchPathGlob(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	entries, err := repo.LsTreeRecursive(ref, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	matchName := !strings.Contains(pattern, "/")
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if matchName {
+			name = path.Base(name)
+		}
+		if matched, _ := MatchPathGlob(pattern, name); !matched {
+			continue
+		}
+		paths = append(paths, entry.Name())
+		if limit > 0 && len(paths) >= limit {
+			break
+		}
+	}
+	return paths, nil
+}
+
+func paginateEntries(entries Entries, skip, limit int) Entries {
+	if skip >= len(entries) {
+		return Entries{}
+	}
+	if skip > 0 {
+		entries = entries[skip:]
+	}
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// ChangedEntry describes a path whose tree entry differs between two trees.
+// The old fields are empty for added paths and the new fields for removed ones.
+type ChangedEntry struct {
+	Path    string
+	OldID   SHA1
+	OldMode EntryMode
+	NewID   SHA1
+	NewMode EntryMode
+}
+
+// IsModeChange reports whether only the mode of the entry changed but not its content
+func (e *ChangedEntry) IsModeChange() bool {
+	return e.OldID == e.NewID && e.OldMode != e.NewMode
+}
+
+// TreeEntryChanges represents the entries changed between two trees
+type TreeEntryChanges struct {
+	Added    []*ChangedEntry
+	Removed  []*ChangedEntry
+	Modified []*ChangedEntry
+}
+
+// DiffTreeEntries compares two trees and returns the added, removed and modified entries
+// (blobs, symlinks and submodules) by their full paths without generating a textual diff.
+// An empty oldTreeID or newTreeID is treated as the empty tree.
+func (repo *Repository) DiffTreeEntries(oldTreeID, newTreeID SHA1) (*TreeEntryChanges, error) {
+	var oldTree, newTree *object.Tree
+	var err error
+	if !oldTreeID.IsZero() {
+		if oldTree, err = repo.gogitRepo.TreeObject(oldTreeID); err != nil {
+			return nil, err
+		}
+	}
+	if !newTreeID.IsZero() {
+		if newTree, err = repo.gogitRepo.TreeObject(newTreeID); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TreeEntryChanges{}
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &ChangedEntry{
+			OldID:   change.From.TreeEntry.Hash,
+			OldMode: EntryMode(change.From.TreeEntry.Mode),
+			NewID:   change.To.TreeEntry.Hash,
+			NewMode: EntryMode(change.To.TreeEntry.Mode),
+		}
+		switch action {
+		case merkletrie.Insert:
+			entry.Path = change.To.Name
+			result.Added = append(result.Added, entry)
+		case merkletrie.Delete:
+			entry.Path = change.From.Name
+			result.Removed = append(result.Removed, entry)
+		default:
+			entry.Path = change.To.Name
+			result.Modified = append(result.Modified, entry)
+		}
+	}
+	return result, nil
+}
+
 // CommitTreeOpts represents the possible options to CommitTree
 type CommitTreeOpts struct {
 	Parents   []string
@@ -61,18 +196,9 @@ type CommitTreeOpts struct {
 
 // CommitTree creates a commit from a given tree id for the user with provided message
 func (repo *Repository) CommitTree(sig *Signature, tree *Tree, opts CommitTreeOpts) (SHA1, error) {
-	commitTimeStr := time.Now().Format(time.RFC3339)
-
-	// Because this may call hooks we should pass in the environment
-	env := append(os.Environ(),
-		"GIT_AUTHOR_NAME="+sig.Name,
-		"GIT_AUTHOR_EMAIL="+sig.Email,
-		"GIT_AUTHOR_DATE="+commitTimeStr,
-		"GIT_COMMITTER_NAME="+sig.Name,
-		"GIT_COMMITTER_EMAIL="+sig.Email,
-		"GIT_COMMITTER_DATE="+commitTimeStr,
-	)
-	cmd := NewCommand("commit-tree", tree.ID.String())
+	now := &Signature{Name: sig.Name, Email: sig.Email, When: time.Now()}
+	// Because this may call hooks the environment of Gitea is passed in as well
+	cmd := NewCommand("commit-tree", tree.ID.String()).SetEnv(CommandEnvOptions{Author: now, Committer: now})
 
 	for _, parent := range opts.Parents {
 		cmd.AddArguments("-p", parent)
@@ -88,7 +214,7 @@ func (repo *Repository) CommitTree(sig *Signature, tree *Tree, opts CommitTreeOp
 		cmd.AddArguments("--no-gpg-sign")
 	}
 
-	res, err := cmd.RunInDirWithEnv(repo.Path, env)
+	res, err := cmd.RunInDir(repo.Path)
 
 	if err != nil {
 		return SHA1{}, err
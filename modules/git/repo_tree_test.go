@@ -0,0 +1,105 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_LsTreeRecursive(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	entries, err := bareRepo1.LsTreeRecursive("master", "", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 7) {
+		assert.Equal(t, "file1.txt", entries[0].Name())
+		assert.Equal(t, "foo/bar/link_to_hello", entries[2].Name())
+		assert.Equal(t, EntryModeSymlink, entries[2].Mode())
+		assert.Equal(t, "foo/nar/hello", entries[5].Name())
+		assert.Equal(t, EntryModeBlob, entries[5].Mode())
+		assert.EqualValues(t, 3, entries[5].Size())
+	}
+
+	entries, err = bareRepo1.LsTreeRecursive("master", "foo", 1, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "foo/broken_link", entries[0].Name())
+		assert.Equal(t, "foo/link_short", entries[1].Name())
+	}
+
+	entries, err = bareRepo1.LsTreeRecursive("master", "foo", 10, 2)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestRepository_DiffTreeEntries(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	getTreeID := func(commitID string) SHA1 {
+		commit, err := bareRepo1.GetCommit(commitID)
+		assert.NoError(t, err)
+		return commit.Tree.ID
+	}
+
+	// "Add file2.txt"
+	changes, err := bareRepo1.DiffTreeEntries(getTreeID("95bb4d39648ee7e325106df01a621c530863a653"), getTreeID("8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"))
+	assert.NoError(t, err)
+	assert.Len(t, changes.Removed, 0)
+	assert.Len(t, changes.Modified, 0)
+	if assert.Len(t, changes.Added, 1) {
+		assert.Equal(t, "file2.txt", changes.Added[0].Path)
+		assert.Equal(t, EntryModeBlob, changes.Added[0].NewMode)
+		assert.True(t, changes.Added[0].OldID.IsZero())
+	}
+
+	// "Edit file1.txt" compared backwards to "Add file2.txt"
+	changes, err = bareRepo1.DiffTreeEntries(getTreeID("8d92fc957a4d7cfd98bc375f0b7bb189a0d6c9f2"), getTreeID("2839944139e0de9737a044f78b0e4b40d989a9e3"))
+	assert.NoError(t, err)
+	if assert.Len(t, changes.Added, 1) {
+		assert.Equal(t, "branch1.txt", changes.Added[0].Path)
+	}
+	if assert.Len(t, changes.Removed, 1) {
+		assert.Equal(t, "file2.txt", changes.Removed[0].Path)
+		assert.True(t, changes.Removed[0].NewID.IsZero())
+	}
+	if assert.Len(t, changes.Modified, 1) {
+		assert.Equal(t, "file1.txt", changes.Modified[0].Path)
+		assert.NotEqual(t, changes.Modified[0].OldID, changes.Modified[0].NewID)
+	}
+
+	// Everything is added when compared to the empty tree
+	changes, err = bareRepo1.DiffTreeEntries(SHA1{}, getTreeID("feaf4ba6bc635fec442f46ddd4512416ec43c2c2"))
+	assert.NoError(t, err)
+	assert.Len(t, changes.Added, 7)
+}
+
+func TestRepository_SearchFiles(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	paths, err := bareRepo1.SearchFiles("master", "*.txt", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file1.txt", "file2.txt"}, paths)
+
+	paths, err = bareRepo1.SearchFiles("master", "foo/**/*link*", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo/bar/link_to_hello", "foo/broken_link", "foo/link_short"}, paths)
+
+	paths, err = bareRepo1.SearchFiles("master", "hello", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo/nar/hello"}, paths)
+
+	paths, err = bareRepo1.SearchFiles("master", "*", 2)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 2)
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how often an operation failing transiently is retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the operation is run, values below 2 disable retries
+	MaxAttempts int
+	// Backoff is the time waited before the first retry, it doubles for every further retry
+	Backoff time.Duration
+}
+
+// DefaultRetryPolicy is the policy of the ref updates and maintenance operations of this package
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     100 * time.Millisecond,
+}
+
+// Do runs fn until it succeeds, fails with an error which is not transient or MaxAttempts is reached.
+// The last error is returned as ErrRetriesExhausted if all attempts have failed transiently.
+func (p RetryPolicy) Do(fn func() error) error {
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsErrTransient(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			if attempt == 1 {
+				return err
+			}
+			return ErrRetriesExhausted{Attempts: attempt, Err: err}
+		}
+		log("retrying after transient failure: %v", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// IsErrTransient returns true if err is the failure of a git command which is likely to succeed when
+// run again, like a lock file held by a concurrent process
+func IsErrTransient(err error) bool {
+	cmdErr, ok := err.(CommandError)
+	return ok && cmdErr.Kind == CommandErrorLockFailed && strings.Contains(cmdErr.Stderr, ".lock': File exists")
+}
+
+// runInDirWithRetry runs the command in dir, retrying transient failures with DefaultRetryPolicy
+func (c *Command) runInDirWithRetry(dir string) error {
+	return DefaultRetryPolicy.Do(func() error {
+		_, err := c.RunInDir(dir)
+		return err
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	transient := CommandError{Err: errors.New("exit status 128"), ExitCode: 128, Kind: CommandErrorLockFailed,
+		Stderr: "fatal: Unable to create '/tmp/repo.git/refs/heads/master.lock': File exists."}
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	attempts := 0
+	assert.NoError(t, policy.Do(func() error {
+		if attempts++; attempts < 3 {
+			return transient
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err := policy.Do(func() error {
+		attempts++
+		return transient
+	})
+	assert.Equal(t, ErrRetriesExhausted{Attempts: 3, Err: transient}, err)
+	assert.Equal(t, 3, attempts)
+
+	// other failures are not retried
+	attempts = 0
+	other := CommandError{Err: errors.New("exit status 1"), ExitCode: 1, Kind: CommandErrorLockFailed,
+		Stderr: "fatal: cannot lock ref 'refs/heads/master': is at 1 but expected 2"}
+	err = policy.Do(func() error {
+		attempts++
+		return other
+	})
+	assert.Equal(t, other, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRepository_UpdateRefRetry(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	clonedPath, err := cloneRepo(bareRepo1Path, testReposDir, "repo1_TestRepository_UpdateRefRetry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(clonedPath)
+	repo, err := OpenRepository(clonedPath)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	oldPolicy := DefaultRetryPolicy
+	defer func() { DefaultRetryPolicy = oldPolicy }()
+	DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, Backoff: 50 * time.Millisecond}
+
+	// the update succeeds once the lock of a concurrent process is gone
+	lockPath := filepath.Join(clonedPath, ".git", "refs", "heads", "master.lock")
+	assert.NoError(t, ioutil.WriteFile(lockPath, nil, 0644))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.Remove(lockPath)
+	}()
+	assert.NoError(t, repo.UpdateRef(BranchPrefix+"master", "37991dec2c8e592043f47155ce4808d4580f9123", ""))
+
+	// a lock which is never released fails once all attempts are used up
+	DefaultRetryPolicy = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	assert.NoError(t, ioutil.WriteFile(lockPath, nil, 0644))
+	err = repo.UpdateRef(BranchPrefix+"master", "feaf4ba6bc635fec442f46ddd4512416ec43c2c2", "")
+	if assert.True(t, IsErrRetriesExhausted(err), "%v", err) {
+		assert.Equal(t, 2, err.(ErrRetriesExhausted).Attempts)
+		assert.True(t, IsErrTransient(err.(ErrRetriesExhausted).Err))
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+)
+
+// The special values of SigningKey
+const (
+	// SigningKeyDefault follows the configuration of git
+	SigningKeyDefault = "default"
+	// SigningKeyNone never signs
+	SigningKeyNone = "none"
+)
+
+// SigningKey decides how the commits created by the server, e.g. merges, squashes and rebases,
+// are signed. SigningKeyDefault follows commit.gpgsign and user.signingkey configured for the
+// repository, or for git globally, SigningKeyNone never signs and any other value is the ID of
+// the key all commits are signed with.
+var SigningKey = SigningKeyDefault
+
+// SigningArguments returns the arguments making commit, commit-tree or rebase sign the commits
+// created for the repository as configured by SigningKey. They are also meant for temporary
+// clones of the repository, which do not have its configuration.
+func (repo *Repository) SigningArguments() []string {
+	switch SigningKey {
+	case SigningKeyNone:
+		return []string{"--no-gpg-sign"}
+	case SigningKeyDefault, "":
+	default:
+		return []string{"-S" + SigningKey}
+	}
+
+	sign, err := NewCommand("config", "--bool", "--get", "commit.gpgsign").RunInDir(repo.Path)
+	if err != nil || strings.TrimSpace(sign) != "true" {
+		return []string{"--no-gpg-sign"}
+	}
+	// without a key git picks one matching the committer
+	keyID, _ := NewCommand("config", "--get", "user.signingkey").RunInDir(repo.Path)
+	return []string{"-S" + strings.TrimSpace(keyID)}
+}
+
+// signingArguments returns the arguments signing a commit with the given key, or not at all if
+// noGPGSign is set, and as configured by SigningKey if neither is set
+func (repo *Repository) signingArguments(keyID string, noGPGSign bool) []string {
+	if noGPGSign {
+		return []string{"--no-gpg-sign"}
+	}
+	if keyID != "" {
+		return []string{"-S" + keyID}
+	}
+	return repo.SigningArguments()
+}
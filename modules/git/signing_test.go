@@ -0,0 +1,37 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_SigningArguments(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"a.txt": "a\n"}, nil)
+	defer os.RemoveAll(tmpDir)
+	defer func(key string) { SigningKey = key }(SigningKey)
+
+	SigningKey = SigningKeyNone
+	assert.Equal(t, []string{"--no-gpg-sign"}, repo.SigningArguments())
+	SigningKey = "ABCDEF01"
+	assert.Equal(t, []string{"-SABCDEF01"}, repo.SigningArguments())
+	assert.Equal(t, []string{"-S0123"}, repo.signingArguments("0123", false))
+	assert.Equal(t, []string{"--no-gpg-sign"}, repo.signingArguments("0123", true))
+
+	// the configuration of the repository
+	SigningKey = SigningKeyDefault
+	assert.Equal(t, []string{"--no-gpg-sign"}, repo.SigningArguments())
+	_, err := NewCommand("config", "commit.gpgsign", "true").RunInDir(tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-S"}, repo.SigningArguments())
+	_, err = NewCommand("config", "user.signingkey", "FEDCBA98").RunInDir(tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-SFEDCBA98"}, repo.SigningArguments())
+	assert.Equal(t, []string{"--no-gpg-sign"}, repo.signingArguments("", true))
+}
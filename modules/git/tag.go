@@ -6,19 +6,19 @@ package git
 
 import (
 	"bytes"
-	"sort"
 	"strings"
 )
 
 // Tag represents a Git tag.
 type Tag struct {
-	Name    string
-	ID      SHA1
-	repo    *Repository
-	Object  SHA1 // The id of this commit object
-	Type    string
-	Tagger  *Signature
-	Message string
+	Name      string
+	ID        SHA1
+	repo      *Repository
+	Object    SHA1 // The id of this commit object
+	Type      string
+	Tagger    *Signature
+	Message   string
+	Signature *CommitGPGSignature
 }
 
 // Commit return the commit of the tag reference
@@ -26,6 +26,19 @@ func (tag *Tag) Commit() (*Commit, error) {
 	return tag.repo.getCommit(tag.Object)
 }
 
+// IsAnnotated returns true if the tag has a tag object of its own. The tagger and message of
+// lightweight tags are taken from the commit they point to.
+func (tag *Tag) IsAnnotated() bool {
+	return tag.Type == string(ObjectTag)
+}
+
+// IsSigned returns true if the tag object carries a PGP signature
+func (tag *Tag) IsSigned() bool {
+	return tag.Signature != nil
+}
+
+const pgpSignatureBegin = "-----BEGIN PGP SIGNATURE-----"
+
 // Parse commit information from the (uncompressed) raw
 // data from the commit object.
 // \n\n separate headers from message
@@ -60,7 +73,16 @@ l:
 			}
 			nextline += eol + 1
 		case eol == 0:
-			tag.Message = strings.TrimRight(string(data[nextline+1:]), "\n")
+			message := data[nextline+1:]
+			// A signature is appended to the message of signed tags
+			if idx := bytes.Index(message, []byte(pgpSignatureBegin)); idx >= 0 {
+				tag.Signature = &CommitGPGSignature{
+					Signature: string(message[idx:]),
+					Payload:   string(data[:nextline+1+idx]),
+				}
+				message = message[:idx]
+			}
+			tag.Message = strings.TrimRight(string(message), "\n")
 			break l
 		default:
 			break l
@@ -68,23 +90,3 @@ l:
 	}
 	return tag, nil
 }
-
-type tagSorter []*Tag
-
-func (ts tagSorter) Len() int {
-	return len([]*Tag(ts))
-}
-
-func (ts tagSorter) Less(i, j int) bool {
-	return []*Tag(ts)[i].Tagger.When.After([]*Tag(ts)[j].Tagger.When)
-}
-
-func (ts tagSorter) Swap(i, j int) {
-	[]*Tag(ts)[i], []*Tag(ts)[j] = []*Tag(ts)[j], []*Tag(ts)[i]
-}
-
-// sortTagsByTime
-func sortTagsByTime(tags []*Tag) {
-	sorter := tagSorter(tags)
-	sort.Sort(sorter)
-}
@@ -0,0 +1,46 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseTagData(t *testing.T) {
+	payload := "object 3b114ab800c6432ad42387ccf6bc8d4388a2885a\n" +
+		"type commit\n" +
+		"tag v1.0\n" +
+		"tagger Jane Doe <jane@example.com> 1565116800 +0200\n" +
+		"\n" +
+		"Release 1.0\n" +
+		"\n" +
+		"With notes\n"
+	signature := "-----BEGIN PGP SIGNATURE-----\n" +
+		"\n" +
+		"iQEzBAABCAAdFiEE\n" +
+		"-----END PGP SIGNATURE-----\n"
+
+	tag, err := parseTagData([]byte(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, "3b114ab800c6432ad42387ccf6bc8d4388a2885a", tag.Object.String())
+	assert.Equal(t, "commit", tag.Type)
+	if assert.NotNil(t, tag.Tagger) {
+		assert.Equal(t, "Jane Doe", tag.Tagger.Name)
+		assert.Equal(t, "jane@example.com", tag.Tagger.Email)
+		assert.EqualValues(t, 1565116800, tag.Tagger.When.Unix())
+	}
+	assert.Equal(t, "Release 1.0\n\nWith notes", tag.Message)
+	assert.False(t, tag.IsSigned())
+
+	tag, err = parseTagData([]byte(payload + signature))
+	assert.NoError(t, err)
+	assert.Equal(t, "Release 1.0\n\nWith notes", tag.Message)
+	if assert.True(t, tag.IsSigned()) {
+		assert.Equal(t, signature, tag.Signature.Signature)
+		assert.Equal(t, payload, tag.Signature.Payload)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+)
+
+// Span is a traced git operation
+type Span interface {
+	// End finishes the span, err is the error of the operation if it failed
+	End(err error)
+}
+
+// Tracer creates the spans of git operations, e.g. by passing them to OpenTelemetry
+type Tracer interface {
+	// StartSpan starts a span as a child of the span in ctx, if any, and returns a context
+	// holding the new span. The attributes describe the operation, e.g. the repository path.
+	StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+}
+
+// tracer holds the Tracer set by SetTracer
+var tracer atomic.Value
+
+type tracerHolder struct {
+	Tracer
+}
+
+// SetTracer sets the tracer of the git commands and of high-level operations like GetCommitsInfo,
+// e.g. the OpenTelemetry exporter of modules/tracing. nil stops tracing.
+func SetTracer(t Tracer) {
+	tracer.Store(tracerHolder{t})
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// startSpan starts a span with the attributes given as key and value pairs if there is a tracer
+func startSpan(ctx context.Context, name string, attributes ...string) (context.Context, Span) {
+	holder, _ := tracer.Load().(tracerHolder)
+	if holder.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	attrs := make(map[string]string, len(attributes)/2)
+	for i := 0; i+1 < len(attributes); i += 2 {
+		attrs[attributes[i]] = attributes[i+1]
+	}
+	return holder.StartSpan(ctx, name, attrs)
+}
+
+func isTraced() bool {
+	holder, _ := tracer.Load().(tracerHolder)
+	return holder.Tracer != nil
+}
+
+// startSpan starts the span of the command run in dir, credentials in the arguments are redacted
+func (c *Command) startSpan(ctx context.Context, dir string) (context.Context, Span) {
+	if !isTraced() {
+		return ctx, noopSpan{}
+	}
+	return startSpan(ctx, "git "+commandVerb(c.args), "git.repo_path", dir, "git.args", strings.Join(redactArguments(c.args), " "))
+}
@@ -6,7 +6,9 @@
 package git
 
 import (
+	"errors"
 	"io"
+	"path"
 	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -93,6 +95,37 @@ func (t *Tree) ListEntries() (Entries, error) {
 	return entries, nil
 }
 
+// ListEntriesPaged returns at most limit entries of current tree after skipping the first skip
+// ones, together with the total number of entries in the tree. A limit <= 0 returns all remaining
+// entries.
+func (t *Tree) ListEntriesPaged(skip, limit int) (Entries, int, error) {
+	if t.gogitTree == nil {
+		err := t.loadTreeObject()
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	total := len(t.gogitTree.Entries)
+	if skip >= total {
+		return Entries{}, total, nil
+	}
+	end := total
+	if limit > 0 && skip+limit < total {
+		end = skip + limit
+	}
+
+	entries := make([]*TreeEntry, 0, end-skip)
+	for i := skip; i < end; i++ {
+		entries = append(entries, &TreeEntry{
+			ID:             t.gogitTree.Entries[i].Hash,
+			gogitTreeEntry: &t.gogitTree.Entries[i],
+			ptree:          t,
+		})
+	}
+	return entries, total, nil
+}
+
 // ListEntriesRecursive returns all entries of current tree recursively including all subtrees
 func (t *Tree) ListEntriesRecursive() (Entries, error) {
 	if t.gogitTree == nil {
@@ -128,3 +161,100 @@ func (t *Tree) ListEntriesRecursive() (Entries, error) {
 
 	return entries, nil
 }
+
+// ErrSkipDir can be returned by a WalkFunc to skip the remaining entries of the
+// directory the callback was invoked for.
+var ErrSkipDir = errors.New("skip this directory")
+
+// WalkFunc is called for every entry visited by Tree.Walk with the path of the
+// entry relative to the walked tree. If it returns ErrSkipDir for a directory
+// entry the directory is not descended into.
+type WalkFunc func(path string, entry *TreeEntry) error
+
+// Walk traverses the tree in depth-first order calling fn for every entry.
+// maxDepth limits how many levels are visited, 1 means only the direct entries
+// of the tree; values <= 0 walk the whole tree.
+func (t *Tree) Walk(fn WalkFunc, maxDepth int) error {
+	err := t.walk("", fn, 1, maxDepth)
+	if err == ErrSkipDir {
+		return nil
+	}
+	return err
+}
+
+func (t *Tree) walk(prefix string, fn WalkFunc, depth, maxDepth int) error {
+	entries, err := t.ListEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := path.Join(prefix, entry.Name())
+		err := fn(entryPath, entry)
+		if err == ErrSkipDir {
+			if entry.IsDir() {
+				continue
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || (maxDepth > 0 && depth >= maxDepth) {
+			continue
+		}
+
+		subTree, err := t.repo.getTree(entry.ID)
+		if err != nil {
+			return err
+		}
+		subTree.ptree = t
+		if err := subTree.walk(entryPath, fn, depth+1, maxDepth); err != nil && err != ErrSkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the sum of the sizes of the files in the tree, including those in
+// subdirectories if recursive is set. Submodules are not counted. The sizes are
+// looked up in a single `cat-file --batch-check` process.
+func (t *Tree) Size(recursive bool) (int64, error) {
+	maxDepth := 1
+	if recursive {
+		maxDepth = 0
+	}
+
+	// the same blob can occur several times
+	counts := make(map[string]int64)
+	var ids []string
+	err := t.Walk(func(path string, entry *TreeEntry) error {
+		if entry.IsDir() || entry.IsSubModule() {
+			return nil
+		}
+		id := entry.ID.String()
+		if counts[id] == 0 {
+			ids = append(ids, id)
+		}
+		counts[id]++
+		return nil
+	}, maxDepth)
+	if err != nil {
+		return 0, err
+	}
+
+	infos, err := t.repo.GetObjectInfos(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, id := range ids {
+		info, ok := infos[id]
+		if !ok {
+			return 0, ErrNotExist{id, ""}
+		}
+		size += info.Size * counts[id]
+	}
+	return size, nil
+}
@@ -52,6 +52,55 @@ func (t *Tree) GetTreeEntryByPath(relpath string) (*TreeEntry, error) {
 	return nil, ErrNotExist{"", relpath}
 }
 
+// FindEntryFold gets the tree entry for relpath like GetTreeEntryByPath, but matches the
+// path components case-insensitively if there is no exact match. It also returns the
+// canonical path of the entry with the casing actually used in the tree.
+func (t *Tree) FindEntryFold(relpath string) (*TreeEntry, string, error) {
+	if len(relpath) == 0 {
+		entry, err := t.GetTreeEntryByPath("")
+		return entry, "", err
+	}
+
+	relpath = path.Clean(relpath)
+	parts := strings.Split(relpath, "/")
+	canonical := make([]string, 0, len(parts))
+	tree := t
+	for i, name := range parts {
+		entries, err := tree.ListEntries()
+		if err != nil {
+			return nil, "", err
+		}
+
+		var found *TreeEntry
+		for _, v := range entries {
+			if v.Name() == name {
+				found = v
+				break
+			}
+			if found == nil && strings.EqualFold(v.Name(), name) {
+				found = v
+			}
+		}
+		if found == nil {
+			return nil, "", ErrNotExist{"", relpath}
+		}
+		canonical = append(canonical, found.Name())
+
+		if i == len(parts)-1 {
+			return found, strings.Join(canonical, "/"), nil
+		}
+		if !found.IsDir() {
+			return nil, "", ErrNotExist{"", relpath}
+		}
+		sub, err := tree.SubTree(found.Name())
+		if err != nil {
+			return nil, "", err
+		}
+		tree = sub
+	}
+	return nil, "", ErrNotExist{"", relpath}
+}
+
 // GetBlobByPath get the blob object according the path
 func (t *Tree) GetBlobByPath(relpath string) (*Blob, error) {
 	entry, err := t.GetTreeEntryByPath(relpath)
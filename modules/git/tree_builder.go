@@ -0,0 +1,291 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// TreeBuilder applies changes to a tree in memory and writes the resulting trees
+// to the object database, without the need for an index or a working copy.
+type TreeBuilder struct {
+	repo *Repository
+	root *treeBuilderNode
+}
+
+// treeBuilderNode is a directory of the tree being built. Its entries are only
+// loaded once the directory is modified.
+type treeBuilderNode struct {
+	id      SHA1
+	entries map[string]*treeBuilderEntry
+	dirty   bool
+}
+
+type treeBuilderEntry struct {
+	mode EntryMode
+	id   SHA1
+	// tree is set for directories which have been loaded
+	tree *treeBuilderNode
+}
+
+// NewTreeBuilder creates a TreeBuilder starting from the tree with the given id.
+// A zero id starts from an empty tree.
+func (repo *Repository) NewTreeBuilder(id SHA1) *TreeBuilder {
+	root := &treeBuilderNode{id: id}
+	if id.IsZero() {
+		root.entries = make(map[string]*treeBuilderEntry)
+		root.dirty = true
+	}
+	return &TreeBuilder{
+		repo: repo,
+		root: root,
+	}
+}
+
+func (b *TreeBuilder) load(node *treeBuilderNode) error {
+	if node.entries != nil {
+		return nil
+	}
+	tree, err := b.repo.gogitRepo.TreeObject(node.id)
+	if err != nil {
+		return err
+	}
+	node.entries = make(map[string]*treeBuilderEntry, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		node.entries[entry.Name] = &treeBuilderEntry{
+			mode: EntryMode(entry.Mode),
+			id:   entry.Hash,
+		}
+	}
+	return nil
+}
+
+// splitTreeBuilderPath cleans and splits treePath into the directory components and the name.
+func splitTreeBuilderPath(treePath string) ([]string, string, error) {
+	cleaned := path.Clean(strings.Trim(treePath, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return nil, "", fmt.Errorf("invalid tree path: %s", treePath)
+	}
+	parts := strings.Split(cleaned, "/")
+	for _, part := range parts {
+		if strings.EqualFold(part, ".git") {
+			return nil, "", fmt.Errorf("invalid tree path: %s", treePath)
+		}
+	}
+	return parts[:len(parts)-1], parts[len(parts)-1], nil
+}
+
+// dir returns the loaded node of the directory at dirs, marking every directory on
+// the way as modified. Missing directories are created if create is set.
+func (b *TreeBuilder) dir(dirs []string, create bool) (*treeBuilderNode, error) {
+	node := b.root
+	if err := b.load(node); err != nil {
+		return nil, err
+	}
+	var visited []*treeBuilderNode
+	for i, name := range dirs {
+		visited = append(visited, node)
+		entry, ok := node.entries[name]
+		if !ok {
+			if !create {
+				return nil, ErrNotExist{"", strings.Join(dirs[:i+1], "/")}
+			}
+			entry = &treeBuilderEntry{
+				mode: EntryModeTree,
+				tree: &treeBuilderNode{entries: make(map[string]*treeBuilderEntry)},
+			}
+			node.entries[name] = entry
+		} else if entry.mode != EntryModeTree {
+			return nil, fmt.Errorf("%s is not a directory", strings.Join(dirs[:i+1], "/"))
+		}
+		if entry.tree == nil {
+			entry.tree = &treeBuilderNode{id: entry.id}
+			if err := b.load(entry.tree); err != nil {
+				return nil, err
+			}
+		}
+		node = entry.tree
+	}
+	for _, n := range visited {
+		n.dirty = true
+	}
+	node.dirty = true
+	return node, nil
+}
+
+// Add sets the entry at treePath to the object id with the given mode, replacing the
+// entry if there already is one. Missing parent directories are created.
+func (b *TreeBuilder) Add(treePath string, mode EntryMode, id SHA1) error {
+	switch mode {
+	case EntryModeBlob, EntryModeExec, EntryModeSymlink, EntryModeCommit, EntryModeTree:
+	default:
+		return fmt.Errorf("unknown entry mode: %s", mode)
+	}
+	dirs, name, err := splitTreeBuilderPath(treePath)
+	if err != nil {
+		return err
+	}
+	node, err := b.dir(dirs, true)
+	if err != nil {
+		return err
+	}
+	node.entries[name] = &treeBuilderEntry{mode: mode, id: id}
+	return nil
+}
+
+// Update changes the object id of the existing entry at treePath, keeping its mode.
+func (b *TreeBuilder) Update(treePath string, id SHA1) error {
+	entry, err := b.get(treePath)
+	if err != nil {
+		return err
+	}
+	return b.Add(treePath, entry.mode, id)
+}
+
+// Chmod changes the mode of the existing entry at treePath.
+func (b *TreeBuilder) Chmod(treePath string, mode EntryMode) error {
+	entry, err := b.get(treePath)
+	if err != nil {
+		return err
+	}
+	if entry.mode == EntryModeTree || mode == EntryModeTree {
+		return fmt.Errorf("cannot change mode of %s to %s", treePath, mode)
+	}
+	return b.Add(treePath, mode, entry.id)
+}
+
+func (b *TreeBuilder) get(treePath string) (*treeBuilderEntry, error) {
+	dirs, name, err := splitTreeBuilderPath(treePath)
+	if err != nil {
+		return nil, err
+	}
+	node, err := b.dir(dirs, false)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := node.entries[name]
+	if !ok {
+		return nil, ErrNotExist{"", treePath}
+	}
+	return entry, nil
+}
+
+// Delete removes the entry at treePath, which may be a whole directory.
+func (b *TreeBuilder) Delete(treePath string) error {
+	dirs, name, err := splitTreeBuilderPath(treePath)
+	if err != nil {
+		return err
+	}
+	node, err := b.dir(dirs, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := node.entries[name]; !ok {
+		return ErrNotExist{"", treePath}
+	}
+	delete(node.entries, name)
+	return nil
+}
+
+// Rename moves the entry at from, which may be a whole directory, to to.
+// An existing entry at to is replaced. Nothing is changed if the move fails.
+func (b *TreeBuilder) Rename(from, to string) error {
+	fromDirs, fromName, err := splitTreeBuilderPath(from)
+	if err != nil {
+		return err
+	}
+	toDirs, toName, err := splitTreeBuilderPath(to)
+	if err != nil {
+		return err
+	}
+	fromPath := path.Join(path.Join(fromDirs...), fromName)
+	if strings.HasPrefix(path.Join(path.Join(toDirs...), toName), fromPath+"/") {
+		return fmt.Errorf("cannot move %s into itself", from)
+	}
+
+	entry, err := b.get(from)
+	if err != nil {
+		return err
+	}
+	// the destination is resolved first, so from is kept if it is invalid
+	node, err := b.dir(toDirs, true)
+	if err != nil {
+		return err
+	}
+	if err := b.Delete(from); err != nil {
+		return err
+	}
+	node.entries[toName] = entry
+	return nil
+}
+
+// Write writes all modified trees to the object database and returns the id of the root tree.
+func (b *TreeBuilder) Write() (SHA1, error) {
+	id, _, err := b.write(b.root)
+	return id, err
+}
+
+// write writes node if it has been modified and returns its id, and whether it is empty.
+func (b *TreeBuilder) write(node *treeBuilderNode) (SHA1, bool, error) {
+	if !node.dirty {
+		return node.id, node.entries != nil && len(node.entries) == 0, nil
+	}
+
+	tree := &object.Tree{}
+	for name, entry := range node.entries {
+		if entry.tree != nil {
+			id, empty, err := b.write(entry.tree)
+			if err != nil {
+				return SHA1{}, false, err
+			}
+			entry.id = id
+			// Git does not store empty directories
+			if empty {
+				continue
+			}
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{
+			Name: name,
+			Mode: filemode.FileMode(entry.mode),
+			Hash: entry.id,
+		})
+	}
+	sort.Sort(gitTreeOrder(tree.Entries))
+
+	obj := b.repo.gogitRepo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return SHA1{}, false, err
+	}
+	id, err := b.repo.gogitRepo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return SHA1{}, false, err
+	}
+	node.id = id
+	node.dirty = false
+	return id, len(tree.Entries) == 0, nil
+}
+
+// gitTreeOrder sorts tree entries the way git expects them in tree objects,
+// which compares directory names as if they had a trailing slash.
+type gitTreeOrder []object.TreeEntry
+
+func (s gitTreeOrder) Len() int      { return len(s) }
+func (s gitTreeOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s gitTreeOrder) Less(i, j int) bool {
+	return gitTreeSortName(s[i]) < gitTreeSortName(s[j])
+}
+
+func gitTreeSortName(entry object.TreeEntry) string {
+	if entry.Mode == filemode.Dir {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{
+		"a.txt":         "a",
+		"a-b":           "a-b",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+		"z.txt":         "z",
+	}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	commit, err := repo.GetBranchCommit("master")
+	require.NoError(t, err)
+	blobID, err := repo.HashObject(strings.NewReader("new content"))
+	require.NoError(t, err)
+
+	builder := repo.NewTreeBuilder(commit.Tree.ID)
+	assert.NoError(t, builder.Add("a/new.txt", EntryModeBlob, blobID))
+	assert.NoError(t, builder.Update("a.txt", blobID))
+	assert.NoError(t, builder.Chmod("z.txt", EntryModeExec))
+	assert.NoError(t, builder.Delete("dir/sub/c.txt"))
+	assert.NoError(t, builder.Rename("dir", "moved"))
+
+	assert.True(t, IsErrNotExist(builder.Delete("no-such-file")))
+	assert.True(t, IsErrNotExist(builder.Update("dir/b.txt", blobID)))
+	assert.Error(t, builder.Add("a.txt/foo", EntryModeBlob, blobID))
+	assert.Error(t, builder.Add(".git/config", EntryModeBlob, blobID))
+	assert.Error(t, builder.Add("../outside", EntryModeBlob, blobID))
+
+	// failed renames keep the source
+	assert.Error(t, builder.Rename("a-b", "a.txt/a-b"))
+	assert.Error(t, builder.Rename("a-b", "../a-b"))
+	assert.Error(t, builder.Rename("moved", "moved/inner"))
+	assert.True(t, IsErrNotExist(builder.Rename("no-such-file", "a-c")))
+
+	treeID, err := builder.Write()
+	require.NoError(t, err)
+
+	stdout, err := NewCommand("ls-tree", "-r", treeID.String()).RunInDir(tmpDir)
+	require.NoError(t, err)
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		fields := strings.Fields(line)
+		names = append(names, fields[0]+" "+fields[3])
+		if fields[3] == "a.txt" || fields[3] == "a/new.txt" {
+			assert.Equal(t, blobID.String(), fields[2])
+		}
+	}
+	assert.Equal(t, []string{
+		"100644 a-b",
+		"100644 a.txt",
+		"100644 a/new.txt",
+		"100644 moved/b.txt",
+		"100755 z.txt",
+	}, names)
+
+	// git must accept the written trees, including their order
+	_, err = NewCommand("fsck", "--strict").RunInDir(tmpDir)
+	assert.NoError(t, err)
+
+	// Writing again without changes gives the same tree
+	sameID, err := builder.Write()
+	assert.NoError(t, err)
+	assert.Equal(t, treeID, sameID)
+}
+
+func TestTreeBuilder_Empty(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{"file": "content"}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	blobID, err := repo.HashObject(strings.NewReader("content"))
+	require.NoError(t, err)
+
+	builder := repo.NewTreeBuilder(SHA1{})
+	assert.NoError(t, builder.Add("file", EntryModeBlob, blobID))
+	treeID, err := builder.Write()
+	assert.NoError(t, err)
+
+	commit, err := repo.GetBranchCommit("master")
+	require.NoError(t, err)
+	assert.Equal(t, commit.Tree.ID, treeID)
+}
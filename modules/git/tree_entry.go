@@ -6,10 +6,14 @@
 package git
 
 import (
+	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
+	"golang.org/x/text/unicode/norm"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
@@ -33,6 +37,24 @@ const (
 	EntryModeTree EntryMode = 0040000
 )
 
+// String converts an EntryMode to the octal string representation used by git
+func (e EntryMode) String() string {
+	return fmt.Sprintf("%06o", int(e))
+}
+
+// ToEntryMode converts a git octal mode string (as used in tree objects and the index) to an EntryMode
+func ToEntryMode(value string) (EntryMode, error) {
+	v, err := strconv.ParseInt(value, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	switch mode := EntryMode(v); mode {
+	case EntryModeBlob, EntryModeExec, EntryModeSymlink, EntryModeCommit, EntryModeTree:
+		return mode, nil
+	}
+	return 0, fmt.Errorf("unknown entry mode: %s", value)
+}
+
 // TreeEntry the leaf in the git tree
 type TreeEntry struct {
 	ID SHA1
@@ -127,44 +149,121 @@ func (te *TreeEntry) Blob() *Blob {
 	}
 }
 
-// FollowLink returns the entry pointed to by a symlink
-func (te *TreeEntry) FollowLink() (*TreeEntry, error) {
+// MaxSymlinkDepth is the maximum number of symlinks followed when resolving a path
+var MaxSymlinkDepth = 40
+
+// LinkTarget returns the raw target path stored in a symlink
+func (te *TreeEntry) LinkTarget() (string, error) {
 	if !te.IsLink() {
-		return nil, ErrBadLink{te.Name(), "not a symlink"}
+		return "", ErrBadLink{te.Name(), "not a symlink"}
 	}
 
-	// read the link
 	r, err := te.Blob().DataAsync()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer r.Close()
 	buf := make([]byte, te.Size())
 	_, err = io.ReadFull(r, buf)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return string(buf), nil
+}
 
-	lnk := string(buf)
-	t := te.ptree
+// FollowLink returns the entry pointed to by a symlink. The target may itself be a symlink.
+func (te *TreeEntry) FollowLink() (*TreeEntry, error) {
+	return te.followLink(0)
+}
+
+// FollowLinks returns the entry pointed to by a symlink, following chains of symlinks
+// until a non-symlink entry is reached. It returns an ErrBadLink if the target does not
+// exist, points outside of the repository or too many symlinks have to be followed.
+func (te *TreeEntry) FollowLinks() (*TreeEntry, error) {
+	entry := te
+	for depth := 0; entry.IsLink(); depth++ {
+		if depth >= MaxSymlinkDepth {
+			return nil, ErrBadLink{te.Name(), "too many levels of symbolic links"}
+		}
+		var err error
+		entry, err = entry.followLink(depth)
+		if err != nil {
+			if badLink, ok := err.(ErrBadLink); ok {
+				badLink.Name = te.Name()
+				return nil, badLink
+			}
+			return nil, err
+		}
+	}
+	return entry, nil
+}
 
-	// traverse up directories
-	for ; t != nil && strings.HasPrefix(lnk, "../"); lnk = lnk[3:] {
-		t = t.ptree
+func (te *TreeEntry) followLink(depth int) (*TreeEntry, error) {
+	lnk, err := te.LinkTarget()
+	if err != nil {
+		return nil, err
 	}
 
-	if t == nil {
+	if strings.HasPrefix(lnk, "/") {
 		return nil, ErrBadLink{te.Name(), "points outside of repo"}
 	}
 
-	target, err := t.GetTreeEntryByPath(lnk)
-	if err != nil {
-		if IsErrNotExist(err) {
+	// resolve the target relative to the directory containing the link
+	t := te.ptree
+	parts := strings.Split(lnk, "/")
+	for i, name := range parts {
+		switch name {
+		case "", ".":
+			if i == len(parts)-1 {
+				return t.GetTreeEntryByPath("")
+			}
+			continue
+		case "..":
+			t = t.ptree
+			if t == nil {
+				return nil, ErrBadLink{te.Name(), "points outside of repo"}
+			}
+			if i == len(parts)-1 {
+				return t.GetTreeEntryByPath("")
+			}
+			continue
+		}
+
+		entry, err := t.GetTreeEntryByPath(name)
+		if err != nil {
+			if IsErrNotExist(err) {
+				return nil, ErrBadLink{te.Name(), "broken link"}
+			}
+			return nil, err
+		}
+		if i == len(parts)-1 {
+			return entry, nil
+		}
+
+		// intermediate path components may be symlinks to directories themselves
+		if entry.IsLink() {
+			if depth+1 >= MaxSymlinkDepth {
+				return nil, ErrBadLink{te.Name(), "too many levels of symbolic links"}
+			}
+			entry, err = entry.followLink(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !entry.IsDir() {
 			return nil, ErrBadLink{te.Name(), "broken link"}
 		}
-		return nil, err
+		if entry.ptree == nil {
+			// the link resolved to the root of a tree we already have
+			t, err = t.repo.getTree(entry.ID)
+		} else {
+			t, err = entry.ptree.SubTree(entry.Name())
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	return target, nil
+	return nil, ErrBadLink{te.Name(), "broken link"}
 }
 
 // GetSubJumpablePathName return the full path of subdirectory jumpable ( contains only one directory )
@@ -190,7 +289,8 @@ func (te *TreeEntry) GetSubJumpablePathName() string {
 type Entries []*TreeEntry
 
 type customSortableEntries struct {
-	Comparer func(s1, s2 string) bool
+	Comparer         func(s1, s2 string) bool
+	DirectoriesFirst bool
 	Entries
 }
 
@@ -212,7 +312,10 @@ func (ctes customSortableEntries) Swap(i, j int) {
 func (ctes customSortableEntries) Less(i, j int) bool {
 	t1, t2 := ctes.Entries[i], ctes.Entries[j]
 	var k int
-	for k = 0; k < len(sorter)-1; k++ {
+	if !ctes.DirectoriesFirst {
+		k = 1
+	}
+	for ; k < len(sorter)-1; k++ {
 		s := sorter[k]
 		switch {
 		case s(t1, t2, ctes.Comparer):
@@ -224,14 +327,63 @@ func (ctes customSortableEntries) Less(i, j int) bool {
 	return sorter[k](t1, t2, ctes.Comparer)
 }
 
+func bytewiseLess(s1, s2 string) bool {
+	return s1 < s2
+}
+
 // Sort sort the list of entry
 func (tes Entries) Sort() {
-	sort.Sort(customSortableEntries{func(s1, s2 string) bool {
-		return s1 < s2
-	}, tes})
+	sort.Sort(customSortableEntries{bytewiseLess, true, tes})
 }
 
 // CustomSort customizable string comparing sort entry list
 func (tes Entries) CustomSort(cmp func(s1, s2 string) bool) {
-	sort.Sort(customSortableEntries{cmp, tes})
+	sort.Sort(customSortableEntries{cmp, true, tes})
+}
+
+// EntriesSortOptions configures the order produced by Entries.SortWithOptions
+type EntriesSortOptions struct {
+	// DirectoriesFirst lists directories and submodules before files
+	DirectoriesFirst bool
+	// Comparer compares entry names, it defaults to a byte-wise comparison
+	Comparer func(s1, s2 string) bool
+	// IgnoreCaseAndAccents compares names by their FoldedName before using the comparer
+	// to break ties, so case and accents only matter for otherwise equal names. This is
+	// not the collation of any particular language, accented letters sort like the plain
+	// ones, e.g. the Swedish "ä" next to "a" instead of after "z".
+	IgnoreCaseAndAccents bool
+}
+
+// SortWithOptions sorts the list of entries according to the given options
+func (tes Entries) SortWithOptions(opts EntriesSortOptions) {
+	cmp := opts.Comparer
+	if cmp == nil {
+		cmp = bytewiseLess
+	}
+	if opts.IgnoreCaseAndAccents {
+		less := cmp
+		cmp = func(s1, s2 string) bool {
+			k1, k2 := FoldedName(s1), FoldedName(s2)
+			if k1 != k2 {
+				return less(k1, k2)
+			}
+			return less(s1, s2)
+		}
+	}
+	sort.Stable(customSortableEntries{cmp, opts.DirectoriesFirst, tes})
+}
+
+// FoldedName returns name with its letters lower-cased and accents removed, so that
+// names sorted by it ignore case and accents: "Äpfel" sorts next to "apfel" instead
+// of after "zebra".
+func FoldedName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+	for _, r := range norm.NFD.String(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
 }
@@ -5,6 +5,7 @@
 package git
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,110 @@ func TestFollowLink(t *testing.T) {
 	_, err = target.FollowLink()
 	assert.Equal(t, err.Error(), "link_short: broken link")
 }
+
+func TestEntriesSortWithOptions(t *testing.T) {
+	entries := getTestEntries()
+	entries.SortWithOptions(EntriesSortOptions{})
+	assert.Equal(t, "abc", entries[0].Name())
+	assert.Equal(t, "bcd", entries[1].Name())
+	assert.Equal(t, "v1.0", entries[2].Name())
+	assert.Equal(t, "v12.0", entries[3].Name())
+
+	entries = getTestEntries()
+	entries.SortWithOptions(EntriesSortOptions{
+		DirectoriesFirst: true,
+		Comparer: func(s1, s2 string) bool {
+			return s1 > s2
+		},
+	})
+	assert.Equal(t, "v2.2", entries[0].Name())
+	assert.Equal(t, "abc", entries[7].Name())
+
+	entries = Entries{
+		&TreeEntry{gogitTreeEntry: &object.TreeEntry{Name: "zebra", Mode: filemode.Regular}},
+		&TreeEntry{gogitTreeEntry: &object.TreeEntry{Name: "Äpfel", Mode: filemode.Regular}},
+		&TreeEntry{gogitTreeEntry: &object.TreeEntry{Name: "Birne", Mode: filemode.Regular}},
+		&TreeEntry{gogitTreeEntry: &object.TreeEntry{Name: "apfel", Mode: filemode.Regular}},
+	}
+	entries.SortWithOptions(EntriesSortOptions{IgnoreCaseAndAccents: true})
+	assert.Equal(t, "apfel", entries[0].Name())
+	assert.Equal(t, "Äpfel", entries[1].Name())
+	assert.Equal(t, "Birne", entries[2].Name())
+	assert.Equal(t, "zebra", entries[3].Name())
+}
+
+func TestFoldedName(t *testing.T) {
+	assert.Equal(t, "apfel", FoldedName("Äpfel"))
+	assert.Equal(t, "creme brulee", FoldedName("Crème Brûlée"))
+	assert.Equal(t, "v1.0", FoldedName("v1.0"))
+}
+
+func TestFollowLinks(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{
+		"docs/guide.md": "guide",
+	}, map[string]string{
+		"current":           "docs",
+		"guide":             "current/guide.md",
+		"chain/link":        "../guide",
+		"chain/dot":         "./link",
+		"loop/a":            "b",
+		"loop/b":            "a",
+		"missing/in_target": "../current/missing.md",
+	})
+	defer os.RemoveAll(tmpDir)
+
+	commit, err := repo.GetBranchCommit("master")
+	assert.NoError(t, err)
+
+	lnk, err := commit.Tree.GetTreeEntryByPath("chain/dot")
+	assert.NoError(t, err)
+
+	// FollowLink only resolves a single level
+	target, err := lnk.FollowLink()
+	assert.NoError(t, err)
+	assert.Equal(t, "link", target.Name())
+	assert.True(t, target.IsLink())
+
+	// FollowLinks resolves the whole chain, including symlinked directories
+	target, err = lnk.FollowLinks()
+	assert.NoError(t, err)
+	assert.Equal(t, "guide.md", target.Name())
+	assert.False(t, target.IsLink())
+
+	lnk, err = commit.Tree.GetTreeEntryByPath("current")
+	assert.NoError(t, err)
+	target, err = lnk.FollowLinks()
+	assert.NoError(t, err)
+	assert.True(t, target.IsDir())
+
+	lnk, err = commit.Tree.GetTreeEntryByPath("loop/a")
+	assert.NoError(t, err)
+	_, err = lnk.FollowLinks()
+	assert.True(t, IsErrBadLink(err))
+	assert.Equal(t, "a: too many levels of symbolic links", err.Error())
+
+	lnk, err = commit.Tree.GetTreeEntryByPath("missing/in_target")
+	assert.NoError(t, err)
+	_, err = lnk.FollowLinks()
+	assert.True(t, IsErrBadLink(err))
+	assert.Equal(t, "in_target: broken link", err.Error())
+}
+
+func TestEntryMode(t *testing.T) {
+	assert.Equal(t, "100644", EntryModeBlob.String())
+	assert.Equal(t, "100755", EntryModeExec.String())
+	assert.Equal(t, "040000", EntryModeTree.String())
+
+	mode, err := ToEntryMode("100755")
+	assert.NoError(t, err)
+	assert.Equal(t, EntryModeExec, mode)
+
+	mode, err = ToEntryMode("040000")
+	assert.NoError(t, err)
+	assert.Equal(t, EntryModeTree, mode)
+
+	_, err = ToEntryMode("100600")
+	assert.Error(t, err)
+	_, err = ToEntryMode("abc")
+	assert.Error(t, err)
+}
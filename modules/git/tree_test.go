@@ -0,0 +1,207 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTree_ListEntriesPaged(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+
+	entries, total, err := commit.Tree.ListEntriesPaged(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "file2.txt", entries[0].Name())
+	}
+
+	entries, total, err = commit.Tree.ListEntriesPaged(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, entries, 2)
+
+	entries, total, err = commit.Tree.ListEntriesPaged(5, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, entries, 0)
+}
+
+func TestTree_FindEntryFold(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+
+	entry, canonical, err := commit.Tree.FindEntryFold("FOO/Nar/HELLO")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/nar/hello", canonical)
+	assert.Equal(t, "b14df6442ea5a1b382985a6549b85d435376c351", entry.ID.String())
+
+	_, canonical, err = commit.Tree.FindEntryFold("file1.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "file1.txt", canonical)
+
+	_, _, err = commit.Tree.FindEntryFold("File1.txt/hello")
+	assert.True(t, IsErrNotExist(err))
+
+	_, _, err = commit.Tree.FindEntryFold("foo/missing")
+	assert.True(t, IsErrNotExist(err))
+}
+
+func TestTree_Walk(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+
+	var paths []string
+	err = commit.Tree.Walk(func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"file1.txt",
+		"file2.txt",
+		"foo",
+		"foo/bar",
+		"foo/bar/link_to_hello",
+		"foo/broken_link",
+		"foo/link_short",
+		"foo/nar",
+		"foo/nar/hello",
+		"foo/outside_repo",
+	}, paths)
+
+	paths = paths[:0]
+	err = commit.Tree.Walk(func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"file1.txt",
+		"file2.txt",
+		"foo",
+		"foo/bar",
+		"foo/broken_link",
+		"foo/link_short",
+		"foo/nar",
+		"foo/outside_repo",
+	}, paths)
+
+	paths = paths[:0]
+	err = commit.Tree.Walk(func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "foo/bar" {
+			return ErrSkipDir
+		}
+		return nil
+	}, 0)
+	assert.NoError(t, err)
+	assert.NotContains(t, paths, "foo/bar/link_to_hello")
+	assert.Contains(t, paths, "foo/nar/hello")
+}
+
+func TestTree_FindWellKnownFiles(t *testing.T) {
+	tmpDir, repo := initTestRepo(t, map[string]string{
+		"README":                  "readme",
+		"readme.md":               "# readme",
+		"README.de.md":            "# liesmich",
+		"LICENCE.txt":             "license",
+		"Contributing.rst":        "contributing",
+		"CODE_OF_CONDUCT.md":      "be nice",
+		"docs/SECURITY.md":        "in a subdirectory",
+		"CHANGELOG/1.0.md":        "a directory",
+		"not-a-readme.md":         "other",
+		"changelog.md.orig":       "leftover",
+		"security_advisories.txt": "other",
+	}, nil)
+	defer os.RemoveAll(tmpDir)
+
+	commit, err := repo.GetBranchCommit("master")
+	assert.NoError(t, err)
+
+	files, err := commit.Tree.FindWellKnownFiles()
+	assert.NoError(t, err)
+	if assert.NotNil(t, files.Readme) {
+		assert.Equal(t, "readme.md", files.Readme.Name())
+	}
+	if assert.NotNil(t, files.License) {
+		assert.Equal(t, "LICENCE.txt", files.License.Name())
+	}
+	if assert.NotNil(t, files.Contributing) {
+		assert.Equal(t, "Contributing.rst", files.Contributing.Name())
+	}
+	if assert.NotNil(t, files.CodeOfConduct) {
+		assert.Equal(t, files.CodeOfConduct, files.Get(WellKnownCodeOfConduct))
+	}
+	if assert.NotNil(t, files.Changelog) {
+		assert.Equal(t, "changelog.md.orig", files.Changelog.Name())
+	}
+	assert.Nil(t, files.Security)
+}
+
+func TestParseWellKnownFileName(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		typ      WellKnownFileType
+		priority int
+		ok       bool
+	}{
+		{"README.md", WellKnownReadme, 0, true},
+		{"readme.TXT", WellKnownReadme, 1, true},
+		{"Readme", WellKnownReadme, 2, true},
+		{"README.zh-CN.md", WellKnownReadme, 3, true},
+		{"COPYING", WellKnownLicense, 2, true},
+		{"code-of-conduct.md", WellKnownCodeOfConduct, 0, true},
+		{"readme-first.md", 0, 0, false},
+		{"main.go", 0, 0, false},
+	} {
+		typ, priority, ok := ParseWellKnownFileName(testCase.name)
+		assert.Equal(t, testCase.ok, ok, testCase.name)
+		if testCase.ok {
+			assert.Equal(t, testCase.typ, typ, testCase.name)
+			assert.Equal(t, testCase.priority, priority, testCase.name)
+		}
+	}
+}
+
+func TestTree_Size(t *testing.T) {
+	bareRepo1Path := filepath.Join(testReposDir, "repo1_bare")
+	bareRepo1, err := OpenRepository(bareRepo1Path)
+	assert.NoError(t, err)
+
+	commit, err := bareRepo1.GetCommit("feaf4ba6bc635fec442f46ddd4512416ec43c2c2")
+	assert.NoError(t, err)
+
+	size, err := commit.Tree.Size(false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, size)
+
+	size, err = commit.Tree.Size(true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 61, size)
+
+	foo, err := commit.Tree.SubTree("foo")
+	assert.NoError(t, err)
+	size, err = foo.Size(true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 49, size)
+}
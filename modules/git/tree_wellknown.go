@@ -0,0 +1,123 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+)
+
+// WellKnownFileExtensions lists the extensions of well-known files by priority.
+// Files with any other extension are only used if none of these exist.
+var WellKnownFileExtensions = []string{".md", ".txt", ""}
+
+// wellKnownFileNames maps the lowercase base names to the kind of file they are
+var wellKnownFileNames = map[string]WellKnownFileType{
+	"readme":          WellKnownReadme,
+	"license":         WellKnownLicense,
+	"licence":         WellKnownLicense,
+	"copying":         WellKnownLicense,
+	"contributing":    WellKnownContributing,
+	"security":        WellKnownSecurity,
+	"code_of_conduct": WellKnownCodeOfConduct,
+	"code-of-conduct": WellKnownCodeOfConduct,
+	"changelog":       WellKnownChangelog,
+	"changes":         WellKnownChangelog,
+	"history":         WellKnownChangelog,
+	"news":            WellKnownChangelog,
+}
+
+// WellKnownFileType is the kind of a well-known file in a repository
+type WellKnownFileType int
+
+// WellKnownFileType values
+const (
+	WellKnownReadme WellKnownFileType = iota
+	WellKnownLicense
+	WellKnownContributing
+	WellKnownSecurity
+	WellKnownCodeOfConduct
+	WellKnownChangelog
+)
+
+// WellKnownFiles holds the well-known files found in a tree, nil if the tree has none of a kind
+type WellKnownFiles struct {
+	Readme        *TreeEntry
+	License       *TreeEntry
+	Contributing  *TreeEntry
+	Security      *TreeEntry
+	CodeOfConduct *TreeEntry
+	Changelog     *TreeEntry
+}
+
+// Get returns the entry found for the given kind of file
+func (f *WellKnownFiles) Get(typ WellKnownFileType) *TreeEntry {
+	return *f.field(typ)
+}
+
+func (f *WellKnownFiles) field(typ WellKnownFileType) **TreeEntry {
+	switch typ {
+	case WellKnownReadme:
+		return &f.Readme
+	case WellKnownLicense:
+		return &f.License
+	case WellKnownContributing:
+		return &f.Contributing
+	case WellKnownSecurity:
+		return &f.Security
+	case WellKnownCodeOfConduct:
+		return &f.CodeOfConduct
+	default:
+		return &f.Changelog
+	}
+}
+
+// ParseWellKnownFileName returns the kind of well-known file name is and the priority
+// of its extension, lower is better. ok is false if name is not a well-known file.
+func ParseWellKnownFileName(name string) (typ WellKnownFileType, priority int, ok bool) {
+	name = strings.ToLower(name)
+	base, ext := name, ""
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		base, ext = name[:i], name[i:]
+	}
+
+	typ, ok = wellKnownFileNames[base]
+	if !ok {
+		return typ, 0, false
+	}
+	for i, e := range WellKnownFileExtensions {
+		if ext == e {
+			return typ, i, true
+		}
+	}
+	return typ, len(WellKnownFileExtensions), true
+}
+
+// FindWellKnownFiles looks up README, LICENSE, CONTRIBUTING, SECURITY, CODE_OF_CONDUCT
+// and CHANGELOG files in any case in a single pass over the entries of the tree.
+// If several files of the same kind exist, the one with the preferred extension is returned.
+func (t *Tree) FindWellKnownFiles() (*WellKnownFiles, error) {
+	entries, err := t.ListEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	files := &WellKnownFiles{}
+	priorities := make(map[WellKnownFileType]int)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.IsSubModule() {
+			continue
+		}
+		typ, priority, ok := ParseWellKnownFileName(entry.Name())
+		if !ok {
+			continue
+		}
+		field := files.field(typ)
+		if *field == nil || priority < priorities[typ] {
+			*field = entry
+			priorities[typ] = priority
+		}
+	}
+	return files, nil
+}
@@ -5,6 +5,7 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
@@ -86,3 +87,45 @@ func RefEndName(refStr string) string {
 
 	return refStr
 }
+
+// splitLines splits the output of git into lines without their line endings, "\r\n" is accepted as
+// well as "\n". A trailing line ending does not result in an empty last line.
+func splitLines(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'})
+	for i, line := range lines {
+		lines[i] = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+	return lines
+}
+
+// quoteArgument quotes an argument containing whitespace or quotes, so commands shown in logs
+// can be run again on Windows as well as by POSIX shells
+func quoteArgument(arg string) string {
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\r\n\"'") {
+		return arg
+	}
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// backslashes in front of a quote are escaped as well
+			quoted.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			quoted.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		quoted.WriteByte(arg[i])
+	}
+	// the closing quote must not be escaped by trailing backslashes
+	quoted.WriteString(strings.Repeat(`\`, 2*backslashes))
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,29 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLines(t *testing.T) {
+	assert.Nil(t, splitLines(nil))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, splitLines([]byte("a\nb\n")))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, splitLines([]byte("a\r\nb\r\n")))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte(""), []byte("b")}, splitLines([]byte("a\n\nb")))
+}
+
+func TestQuoteArgument(t *testing.T) {
+	assert.Equal(t, "log", quoteArgument("log"))
+	assert.Equal(t, `""`, quoteArgument(""))
+	assert.Equal(t, `"Initial commit"`, quoteArgument("Initial commit"))
+	assert.Equal(t, `"say \"hi\""`, quoteArgument(`say "hi"`))
+	assert.Equal(t, `"C:\Program Files\\"`, quoteArgument(`C:\Program Files\`))
+	assert.Equal(t, `"a\\\"b"`, quoteArgument(`a\"b`))
+	assert.True(t, strings.HasSuffix(NewCommand("log", "--grep=fix bug").String(), ` log "--grep=fix bug"`))
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"strconv"
+
+	"code.gitea.io/gitea/modules/git"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GitCommandCollector implements the prometheus.Collector interface and exposes
+// the git commands run by gitea, by subcommand
+type GitCommandCollector struct {
+	Commands   *prometheus.CounterVec
+	Durations  *prometheus.HistogramVec
+	OutputSize *prometheus.HistogramVec
+}
+
+// NewGitCommandCollector returns a new GitCommandCollector, which is to be set as the
+// observer of the git commands with git.SetCommandObserver(c.Observe)
+func NewGitCommandCollector() *GitCommandCollector {
+	return &GitCommandCollector{
+		Commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: namespace + "git_commands_total",
+			Help: "Number of git commands run",
+		}, []string{"verb", "exit_code"}),
+		Durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    namespace + "git_command_duration_seconds",
+			Help:    "Duration of git commands",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 9),
+		}, []string{"verb"}),
+		OutputSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    namespace + "git_command_output_bytes",
+			Help:    "Size of the output of git commands",
+			Buckets: prometheus.ExponentialBuckets(256, 8, 8),
+		}, []string{"verb"}),
+	}
+}
+
+// Observe records a finished git command
+func (c *GitCommandCollector) Observe(stats git.CommandStats) {
+	c.Commands.WithLabelValues(stats.Verb, strconv.Itoa(stats.ExitCode)).Inc()
+	c.Durations.WithLabelValues(stats.Verb).Observe(stats.Duration.Seconds())
+	c.OutputSize.WithLabelValues(stats.Verb).Observe(float64(stats.OutputSize))
+}
+
+// Describe returns all possible prometheus.Desc
+func (c *GitCommandCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.Commands.Describe(ch)
+	c.Durations.Describe(ch)
+	c.OutputSize.Describe(ch)
+}
+
+// Collect returns the metrics with values
+func (c *GitCommandCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Commands.Collect(ch)
+	c.Durations.Collect(ch)
+	c.OutputSize.Collect(ch)
+}
@@ -86,3 +86,21 @@ func HookPostReceive(ownerName, repoName string, opts HookOptions) (map[string]i
 
 	return res, ""
 }
+
+// HookPushDone notifies that all refs of a push have been updated
+func HookPushDone(ownerName, repoName string) error {
+	reqURL := setting.LocalURL + fmt.Sprintf("api/internal/hook/push-done/%s/%s",
+		url.PathEscape(ownerName),
+		url.PathEscape(repoName))
+
+	resp, err := newInternalRequest(reqURL, "POST").Response()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to finish push: %s", decodeJSONError(resp).Err)
+	}
+	return nil
+}
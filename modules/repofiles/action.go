@@ -51,6 +51,7 @@ func CommitRepoAction(opts CommitRepoActionOptions) error {
 			if err != nil {
 				return err
 			}
+			defer gitRepo.Close()
 			if err := gitRepo.SetDefaultBranch(repo.DefaultBranch); err != nil {
 				if !git.IsErrUnsupportedVersion(err) {
 					return err
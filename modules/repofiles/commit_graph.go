@@ -0,0 +1,49 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repofiles
+
+import (
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/sync"
+)
+
+// commitGraphQueue holds the paths of the repositories whose commit-graph is to be written
+var commitGraphQueue = sync.NewUniqueQueue(setting.Repository.CommitGraphQueueLength)
+
+// AddWriteCommitGraphTask queues writing the commit-graph of the repository. A repository is queued
+// only once until its commit-graph is being written, so pushes arriving meanwhile share one write.
+func AddWriteCommitGraphTask(repoPath string) {
+	if !git.GetCapabilities().SupportsCommitGraph {
+		return
+	}
+	commitGraphQueue.Add(repoPath)
+}
+
+// WriteCommitGraphs writes the commit-graph of the queued repositories
+func WriteCommitGraphs() {
+	for repoPath := range commitGraphQueue.Queue() {
+		commitGraphQueue.Remove(repoPath)
+
+		if err := writeCommitGraph(repoPath); err != nil {
+			log.Error("WriteCommitGraph [%s]: %v", repoPath, err)
+		}
+	}
+}
+
+// InitWriteCommitGraphs runs the task writing the commit-graph of pushed repositories
+func InitWriteCommitGraphs() {
+	go WriteCommitGraphs()
+}
+
+func writeCommitGraph(repoPath string) error {
+	gitRepo, err := git.OpenRepository(repoPath)
+	if err != nil {
+		return err
+	}
+	defer gitRepo.Close()
+	return gitRepo.WriteCommitGraph()
+}
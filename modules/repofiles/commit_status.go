@@ -22,6 +22,7 @@ func CreateCommitStatus(repo *models.Repository, creator *models.User, sha strin
 	if err != nil {
 		return fmt.Errorf("OpenRepository[%s]: %v", repoPath, err)
 	}
+	defer gitRepo.Close()
 	if _, err := gitRepo.GetCommit(sha); err != nil {
 		return fmt.Errorf("GetCommit[%s]: %v", sha, err)
 	}
@@ -0,0 +1,155 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repofiles
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/sync"
+)
+
+// lastCommitCacheQueue holds the pushed branches, as repository path and branch name separated
+// by a NUL byte, whose last commit cache is to be warmed, and the trees, as repository path, commit ID
+// and tree path separated by NUL bytes, whose last commits are to be completed
+var lastCommitCacheQueue = sync.NewUniqueQueue(0)
+
+// AddWarmLastCommitCacheTask queues warming the last commit cache of the branch
+func AddWarmLastCommitCacheTask(repoPath, branch string) {
+	if setting.CacheService == nil || !setting.CacheService.LastCommit.Enabled {
+		return
+	}
+	lastCommitCacheQueue.Add(repoPath + "\x00" + branch)
+}
+
+// AddCompleteLastCommitsTask queues looking up the last commits of the entries of a tree which
+// have not been found within the limits of the history walk, so they are in the last commit cache
+// when the tree is viewed again
+func AddCompleteLastCommitsTask(repoPath, commitID, treePath string) {
+	if setting.CacheService == nil || !setting.CacheService.LastCommit.Enabled {
+		return
+	}
+	lastCommitCacheQueue.Add(repoPath + "\x00" + commitID + "\x00" + treePath)
+}
+
+// WarmLastCommitCaches warms the last commit cache of the queued branches and trees
+func WarmLastCommitCaches() {
+	for task := range lastCommitCacheQueue.Queue() {
+		lastCommitCacheQueue.Remove(task)
+
+		fields := strings.SplitN(task, "\x00", 3)
+		switch len(fields) {
+		case 2:
+			if err := WarmLastCommitCache(fields[0], fields[1]); err != nil {
+				log.Error("WarmLastCommitCache [%s, branch: %s]: %v", fields[0], fields[1], err)
+			}
+		case 3:
+			if err := CompleteLastCommits(fields[0], fields[1], fields[2]); err != nil {
+				log.Error("CompleteLastCommits [%s, commit: %s, tree: %s]: %v", fields[0], fields[1], fields[2], err)
+			}
+		}
+	}
+}
+
+// InitWarmLastCommitCaches runs the task warming the last commit cache of pushed branches
+func InitWarmLastCommitCaches() {
+	go WarmLastCommitCaches()
+}
+
+// WarmLastCommitCache looks up the last commits of the entries of the root tree and the directories
+// of the branch down to setting.CacheService.LastCommit.WarmDepth, so they are in the last commit cache
+// when the branch is viewed.
+func WarmLastCommitCache(repoPath, branch string) error {
+	gitRepo, err := git.Repositories.Open(repoPath)
+	if err != nil {
+		return err
+	}
+	defer git.Repositories.Release(gitRepo)
+
+	lastCommitCache := cache.NewLastCommitCache(gitRepo)
+	if lastCommitCache == nil {
+		return nil
+	}
+	// the branch may have been deleted meanwhile
+	if !gitRepo.IsBranchExist(branch) {
+		return nil
+	}
+	commit, err := gitRepo.GetBranchCommit(branch)
+	if err != nil {
+		return err
+	}
+
+	return warmLastCommitCache(lastCommitCache, commit, "", setting.CacheService.LastCommit.WarmDepth)
+}
+
+// warmLastCommitCache looks up the last commits of the entries of the tree, and recurses into its
+// directories while depth is not 0
+func warmLastCommitCache(lastCommitCache git.LastCommitCache, commit *git.Commit, treePath string, depth int) error {
+	tree, err := commit.SubTree(treePath)
+	if err != nil {
+		return err
+	}
+	entries, err := tree.ListEntries()
+	if err != nil {
+		return err
+	}
+
+	// every tree gets its own timeout, as a deep walk of a big repository takes long in total
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(setting.Git.Timeout.Default)*time.Second)
+	_, _, err = entries.GetCommitsInfoLimits(ctx, commit, treePath, nil, lastCommitCache, git.LastCommitsLimits{})
+	cancel()
+	if err != nil || depth == 0 {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err := warmLastCommitCache(lastCommitCache, commit, path.Join(treePath, entry.Name()), depth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteLastCommits looks up the last commits of all entries of the tree without limiting the
+// history walk, and stores them in the last commit cache.
+func CompleteLastCommits(repoPath, commitID, treePath string) error {
+	gitRepo, err := git.Repositories.Open(repoPath)
+	if err != nil {
+		return err
+	}
+	defer git.Repositories.Release(gitRepo)
+
+	lastCommitCache := cache.NewLastCommitCache(gitRepo)
+	if lastCommitCache == nil {
+		return nil
+	}
+	commit, err := gitRepo.GetCommit(commitID)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.SubTree(treePath)
+	if err != nil {
+		return err
+	}
+	entries, err := tree.ListEntries()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(setting.Git.Timeout.Default)*time.Second)
+	defer cancel()
+
+	_, _, err = entries.GetCommitsInfoLimits(ctx, commit, treePath, nil, lastCommitCache, git.LastCommitsLimits{})
+	return err
+}
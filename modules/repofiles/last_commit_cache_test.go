@@ -0,0 +1,103 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package repofiles
+
+import (
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models"
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmLastCommitCache(t *testing.T) {
+	models.PrepareTestEnv(t)
+
+	// only the last commit cache is used, nothing else is cached
+	setting.CacheService = &setting.Cache{Adapter: "memory", Interval: 60}
+	setting.CacheService.LastCommit.Enabled = true
+	setting.CacheService.LastCommit.TTL = time.Hour
+	setting.CacheService.LastCommit.Adapter = "cache"
+	assert.NoError(t, cache.NewContext())
+
+	repoPath := models.RepoPath("user2", "repo1")
+	assert.NoError(t, WarmLastCommitCache(repoPath, "master"))
+	// branches which do not exist are ignored
+	assert.NoError(t, WarmLastCommitCache(repoPath, "does-not-exist"))
+
+	gitRepo, err := git.OpenRepository(repoPath)
+	assert.NoError(t, err)
+	commit, err := gitRepo.GetBranchCommit("master")
+	assert.NoError(t, err)
+	cached, err := cache.NewLastCommitCache(gitRepo).Get(gitRepo.Path, commit.ID.String(), "README.md")
+	assert.NoError(t, err)
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, "65f1bf27bc3bf70f64657658635e66094edbcb4d", cached.ID.String())
+	}
+}
+
+func TestWarmLastCommitCacheDepth(t *testing.T) {
+	models.PrepareTestEnv(t)
+
+	setting.CacheService = &setting.Cache{Adapter: "memory", Interval: 60}
+	setting.CacheService.LastCommit.Enabled = true
+	setting.CacheService.LastCommit.TTL = time.Hour
+	setting.CacheService.LastCommit.Adapter = "cache"
+	assert.NoError(t, cache.NewContext())
+
+	repoPath := models.RepoPath("user2", "repo20")
+	gitRepo, err := git.OpenRepository(repoPath)
+	assert.NoError(t, err)
+	defer gitRepo.Close()
+	commit, err := gitRepo.GetBranchCommit("master")
+	assert.NoError(t, err)
+	lastCommitCache := cache.NewLastCommitCache(gitRepo)
+
+	// a/b is below the depth of the walk
+	setting.CacheService.LastCommit.WarmDepth = 1
+	assert.NoError(t, WarmLastCommitCache(repoPath, "master"))
+	cached, err := lastCommitCache.Get(gitRepo.Path, commit.ID.String(), "a/link_annex")
+	assert.NoError(t, err)
+	assert.NotNil(t, cached)
+	cached, err = lastCommitCache.Get(gitRepo.Path, commit.ID.String(), "a/b/link_c")
+	assert.NoError(t, err)
+	assert.Nil(t, cached)
+
+	// all directories
+	setting.CacheService.LastCommit.WarmDepth = -1
+	assert.NoError(t, WarmLastCommitCache(repoPath, "master"))
+	cached, err = lastCommitCache.Get(gitRepo.Path, commit.ID.String(), "a/b/link_c")
+	assert.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+func TestCompleteLastCommits(t *testing.T) {
+	models.PrepareTestEnv(t)
+
+	setting.CacheService = &setting.Cache{Adapter: "memory", Interval: 60}
+	setting.CacheService.LastCommit.Enabled = true
+	setting.CacheService.LastCommit.TTL = time.Hour
+	setting.CacheService.LastCommit.Adapter = "cache"
+	assert.NoError(t, cache.NewContext())
+
+	repoPath := models.RepoPath("user2", "repo1")
+	gitRepo, err := git.OpenRepository(repoPath)
+	assert.NoError(t, err)
+	defer gitRepo.Close()
+	commit, err := gitRepo.GetBranchCommit("master")
+	assert.NoError(t, err)
+
+	assert.NoError(t, CompleteLastCommits(repoPath, commit.ID.String(), ""))
+
+	cached, err := cache.NewLastCommitCache(gitRepo).Get(gitRepo.Path, commit.ID.String(), "README.md")
+	assert.NoError(t, err)
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, "65f1bf27bc3bf70f64657658635e66094edbcb4d", cached.ID.String())
+	}
+}
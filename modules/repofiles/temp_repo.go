@@ -9,7 +9,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"regexp"
 	"strings"
@@ -41,6 +40,9 @@ func NewTemporaryUploadRepository(repo *models.Repository) (*TemporaryUploadRepo
 
 // Close the repository cleaning up all files
 func (t *TemporaryUploadRepository) Close() {
+	if t.gitRepo != nil {
+		t.gitRepo.Close()
+	}
 	if err := models.RemoveTemporaryPath(t.basePath); err != nil {
 		log.Error("Failed to remove temporary path %s: %v", t.basePath, err)
 	}
@@ -130,8 +132,6 @@ func (t *TemporaryUploadRepository) LsFiles(filenames ...string) ([]string, erro
 
 // RemoveFilesFromIndex removes the given files from the index
 func (t *TemporaryUploadRepository) RemoveFilesFromIndex(filenames ...string) error {
-	stdOut := new(bytes.Buffer)
-	stdErr := new(bytes.Buffer)
 	stdIn := new(bytes.Buffer)
 	for _, file := range filenames {
 		if file != "" {
@@ -141,61 +141,19 @@ func (t *TemporaryUploadRepository) RemoveFilesFromIndex(filenames ...string) er
 		}
 	}
 
-	timeout := 5 * time.Minute
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	cmdArgs := []string{"update-index", "--remove", "-z", "--index-info"}
-	cmd := exec.CommandContext(ctx, git.GitExecutable, cmdArgs...)
-	desc := fmt.Sprintf("removeFilesFromIndex: (git update-index) %v", filenames)
-	cmd.Dir = t.basePath
-	cmd.Stdout = stdOut
-	cmd.Stderr = stdErr
-	cmd.Stdin = bytes.NewReader(stdIn.Bytes())
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("exec(%s) failed: %v(%v)", desc, err, ctx.Err())
-	}
-
-	pid := process.GetManager().Add(desc, cmd)
-	err := cmd.Wait()
-	process.GetManager().Remove(pid)
-
-	if err != nil {
-		err = fmt.Errorf("exec(%d:%s) failed: %v(%v) stdout: %v stderr: %v", pid, desc, err, ctx.Err(), stdOut, stdErr)
+	if _, err := git.NewCommand("update-index", "--remove", "-z", "--index-info").RunInDirTimeoutEnvWithStdin(nil, 5*time.Minute, t.basePath, stdIn); err != nil {
+		return fmt.Errorf("removeFilesFromIndex: (git update-index) %v: %v", filenames, err)
 	}
-
-	return err
+	return nil
 }
 
 // HashObject writes the provided content to the object db and returns its hash
 func (t *TemporaryUploadRepository) HashObject(content io.Reader) (string, error) {
-	timeout := 5 * time.Minute
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	hashCmd := exec.CommandContext(ctx, git.GitExecutable, "hash-object", "-w", "--stdin")
-	hashCmd.Dir = t.basePath
-	hashCmd.Stdin = content
-	stdOutBuffer := new(bytes.Buffer)
-	stdErrBuffer := new(bytes.Buffer)
-	hashCmd.Stdout = stdOutBuffer
-	hashCmd.Stderr = stdErrBuffer
-	desc := fmt.Sprintf("hashObject: (git hash-object)")
-	if err := hashCmd.Start(); err != nil {
-		return "", fmt.Errorf("git hash-object: %s", err)
-	}
-
-	pid := process.GetManager().Add(desc, hashCmd)
-	err := hashCmd.Wait()
-	process.GetManager().Remove(pid)
-
+	stdout, err := git.NewCommand("hash-object", "-w", "--stdin").RunInDirTimeoutEnvWithStdin(nil, 5*time.Minute, t.basePath, content)
 	if err != nil {
-		err = fmt.Errorf("exec(%d:%s) failed: %v(%v) stdout: %v stderr: %v", pid, desc, err, ctx.Err(), stdOutBuffer, stdErrBuffer)
-		return "", err
+		return "", fmt.Errorf("git hash-object: %v", err)
 	}
-
-	return strings.TrimSpace(stdOutBuffer.String()), nil
+	return strings.TrimSpace(string(stdout)), nil
 }
 
 // AddObjectToIndex adds the provided object hash to the index with the provided mode and path
@@ -249,29 +207,21 @@ func (t *TemporaryUploadRepository) GetLastCommitByRef(ref string) (string, erro
 
 // CommitTree creates a commit from a given tree for the user with provided message
 func (t *TemporaryUploadRepository) CommitTree(author, committer *models.User, treeHash string, message string) (string, error) {
-	commitTimeStr := time.Now().Format(time.RFC3339)
+	now := time.Now()
 	authorSig := author.NewGitSig()
+	authorSig.When = now
 	committerSig := committer.NewGitSig()
+	committerSig.When = now
 
 	// FIXME: Should we add SSH_ORIGINAL_COMMAND to this
-	// Because this may call hooks we should pass in the environment
-	env := append(os.Environ(),
-		"GIT_AUTHOR_NAME="+authorSig.Name,
-		"GIT_AUTHOR_EMAIL="+authorSig.Email,
-		"GIT_AUTHOR_DATE="+commitTimeStr,
-		"GIT_COMMITTER_NAME="+committerSig.Name,
-		"GIT_COMMITTER_EMAIL="+committerSig.Email,
-		"GIT_COMMITTER_DATE="+commitTimeStr,
-	)
-	commitHash, stderr, err := process.GetManager().ExecDirEnv(5*time.Minute,
-		t.basePath,
-		fmt.Sprintf("commitTree (git commit-tree): %s", t.basePath),
-		env,
-		git.GitExecutable, "commit-tree", treeHash, "-p", "HEAD", "-m", message)
+	// Because this may call hooks the environment of Gitea is passed in as well
+	commitHash, err := git.NewCommand("commit-tree", treeHash, "-p", "HEAD", "-m", message).
+		SetEnv(git.CommandEnvOptions{Author: authorSig, Committer: committerSig}).
+		RunInDirTimeout(5*time.Minute, t.basePath)
 	if err != nil {
-		return "", fmt.Errorf("git commit-tree: %s", stderr)
+		return "", fmt.Errorf("git commit-tree: %v", err)
 	}
-	return strings.TrimSpace(commitHash), nil
+	return strings.TrimSpace(string(commitHash)), nil
 }
 
 // Push the provided commitHash to the repository branch by the provided user
@@ -327,60 +277,29 @@ func (t *TemporaryUploadRepository) DiffIndex() (diff *models.Diff, err error) {
 
 // CheckAttribute checks the given attribute of the provided files
 func (t *TemporaryUploadRepository) CheckAttribute(attribute string, args ...string) (map[string]map[string]string, error) {
-	stdOut := new(bytes.Buffer)
-	stdErr := new(bytes.Buffer)
-
-	timeout := 5 * time.Minute
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	if t.gitRepo == nil {
+		return nil, fmt.Errorf("repository has not been cloned")
+	}
 
-	cmdArgs := []string{"check-attr", "-z", attribute, "--cached", "--"}
+	paths := make([]string, 0, len(args))
 	for _, arg := range args {
 		if arg != "" {
-			cmdArgs = append(cmdArgs, arg)
+			paths = append(paths, arg)
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, git.GitExecutable, cmdArgs...)
-	desc := fmt.Sprintf("checkAttr: (git check-attr) %s %v", attribute, cmdArgs)
-	cmd.Dir = t.basePath
-	cmd.Stdout = stdOut
-	cmd.Stderr = stdErr
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("exec(%s) failed: %v(%v)", desc, err, ctx.Err())
-	}
-
-	pid := process.GetManager().Add(desc, cmd)
-	err := cmd.Wait()
-	process.GetManager().Remove(pid)
-
+	matrix, err := t.gitRepo.CheckAttributes(paths, []string{attribute})
 	if err != nil {
-		err = fmt.Errorf("exec(%d:%s) failed: %v(%v) stdout: %v stderr: %v", pid, desc, err, ctx.Err(), stdOut, stdErr)
-		return nil, err
-	}
-
-	fields := bytes.Split(stdOut.Bytes(), []byte{'\000'})
-
-	if len(fields)%3 != 1 {
-		return nil, fmt.Errorf("Wrong number of fields in return from check-attr")
+		return nil, fmt.Errorf("CheckAttributes: %v", err)
 	}
 
-	var name2attribute2info = make(map[string]map[string]string)
-
-	for i := 0; i < (len(fields) / 3); i++ {
-		filename := string(fields[3*i])
-		attribute := string(fields[3*i+1])
-		info := string(fields[3*i+2])
-		attribute2info := name2attribute2info[filename]
-		if attribute2info == nil {
-			attribute2info = make(map[string]string)
+	var name2attribute2info = make(map[string]map[string]string, len(paths))
+	for i, filename := range matrix.Paths {
+		name2attribute2info[filename] = map[string]string{
+			attribute: string(matrix.Values[i][0]),
 		}
-		attribute2info[attribute] = info
-		name2attribute2info[filename] = attribute2info
 	}
-
-	return name2attribute2info, err
+	return name2attribute2info, nil
 }
 
 // GetBranchCommit Gets the commit object of the given branch
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	"code.gitea.io/gitea/models"
 	"code.gitea.io/gitea/modules/cache"
@@ -41,6 +42,7 @@ type UpdateRepoFileOptions struct {
 	Content      string
 	SHA          string
 	IsNewFile    bool
+	Mode         git.EntryMode // zero keeps the mode of an existing file, new files default to git.EntryModeBlob
 	Author       *IdentityOptions
 	Committer    *IdentityOptions
 }
@@ -169,20 +171,15 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 
 	author, committer := GetAuthorAndCommitterUsers(opts.Committer, opts.Author, doer)
 
-	t, err := NewTemporaryUploadRepository(repo)
+	// The file is committed in the repository itself through a temporary index, without a clone
+	gitRepo, err := git.OpenRepository(repo.RepoPath())
 	if err != nil {
-		log.Error("%v", err)
-	}
-	defer t.Close()
-	if err := t.Clone(opts.OldBranch); err != nil {
-		return nil, err
-	}
-	if err := t.SetDefaultIndex(); err != nil {
 		return nil, err
 	}
+	defer gitRepo.Close()
 
 	// Get the commit of the original branch
-	commit, err := t.GetBranchCommit(opts.OldBranch)
+	commit, err := gitRepo.GetBranchCommit(opts.OldBranch)
 	if err != nil {
 		return nil, err // Couldn't get a commit for the branch
 	}
@@ -191,7 +188,7 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 	if opts.LastCommitID == "" {
 		opts.LastCommitID = commit.ID.String()
 	} else {
-		lastCommitID, err := t.gitRepo.ConvertToSHA1(opts.LastCommitID)
+		lastCommitID, err := gitRepo.ConvertToSHA1(opts.LastCommitID)
 		if err != nil {
 			return nil, fmt.Errorf("DeleteRepoFile: Invalid last commit ID: %v", err)
 		}
@@ -201,6 +198,7 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 
 	encoding := "UTF-8"
 	bom := false
+	mode := git.EntryModeBlob
 
 	if !opts.IsNewFile {
 		fromEntry, err := commit.GetTreeEntryByPath(fromTreePath)
@@ -236,6 +234,16 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 			return nil, models.ErrSHAOrCommitIDNotProvided{}
 		}
 		encoding, bom = detectEncodingAndBOM(fromEntry, repo)
+		if fromEntry.IsExecutable() {
+			mode = git.EntryModeExec
+		}
+	}
+
+	if opts.Mode != 0 {
+		if opts.Mode != git.EntryModeBlob && opts.Mode != git.EntryModeExec {
+			return nil, fmt.Errorf("UpdateRepoFile: unsupported file mode: %s", opts.Mode)
+		}
+		mode = opts.Mode
 	}
 
 	// For the path where this file will be created/updated, we need to make
@@ -286,35 +294,7 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 
 	}
 
-	// Get the two paths (might be the same if not moving) from the index if they exist
-	filesInIndex, err := t.LsFiles(opts.TreePath, opts.FromTreePath)
-	if err != nil {
-		return nil, fmt.Errorf("UpdateRepoFile: %v", err)
-	}
-	// If is a new file (not updating) then the given path shouldn't exist
-	if opts.IsNewFile {
-		for _, file := range filesInIndex {
-			if file == opts.TreePath {
-				return nil, models.ErrRepoFileAlreadyExists{
-					Path: opts.TreePath,
-				}
-			}
-		}
-	}
-
-	// Remove the old path from the tree
-	if fromTreePath != treePath && len(filesInIndex) > 0 {
-		for _, file := range filesInIndex {
-			if file == fromTreePath {
-				if err := t.RemoveFilesFromIndex(opts.FromTreePath); err != nil {
-					return nil, err
-				}
-			}
-		}
-	}
-
-	// Check there is no way this can return multiple infos
-	filename2attribute2info, err := t.CheckAttribute("filter", treePath)
+	filter, err := gitRepo.CheckTreeAttribute(commit.Tree.ID, "filter", treePath)
 	if err != nil {
 		return nil, err
 	}
@@ -341,7 +321,7 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 	opts.Content = content
 	var lfsMetaObject *models.LFSMetaObject
 
-	if setting.LFS.StartServer && filename2attribute2info[treePath] != nil && filename2attribute2info[treePath]["filter"] == "lfs" {
+	if setting.LFS.StartServer && filter == "lfs" {
 		// OK so we are supposed to LFS this data!
 		oid, err := models.GenerateLFSOid(strings.NewReader(opts.Content))
 		if err != nil {
@@ -351,29 +331,6 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 		content = lfsMetaObject.Pointer()
 	}
 
-	// Add the object to the database
-	objectHash, err := t.HashObject(strings.NewReader(content))
-	if err != nil {
-		return nil, err
-	}
-
-	// Add the object to the index
-	if err := t.AddObjectToIndex("100644", objectHash, treePath); err != nil {
-		return nil, err
-	}
-
-	// Now write the tree
-	treeHash, err := t.WriteTree()
-	if err != nil {
-		return nil, err
-	}
-
-	// Now commit the tree
-	commitHash, err := t.CommitTree(author, committer, treeHash, message)
-	if err != nil {
-		return nil, err
-	}
-
 	if lfsMetaObject != nil {
 		// We have an LFS object - create it
 		lfsMetaObject, err = models.NewLFSMetaObject(lfsMetaObject)
@@ -391,21 +348,63 @@ func CreateOrUpdateRepoFile(repo *models.Repository, doer *models.User, opts *Up
 		}
 	}
 
-	// Then push this tree to NewBranch
-	if err := t.Push(doer, commitHash, opts.NewBranch); err != nil {
+	file := git.FileOperation{
+		Type:    git.FileOperationUpdate,
+		Path:    treePath,
+		Content: strings.NewReader(content),
+		Mode:    mode,
+	}
+	if opts.IsNewFile {
+		file.Type = git.FileOperationCreate
+	} else if fromTreePath != treePath {
+		file.Type = git.FileOperationRename
+		file.FromPath = fromTreePath
+	}
+
+	now := time.Now()
+	authorSig := author.NewGitSig()
+	authorSig.When = now
+	committerSig := committer.NewGitSig()
+	committerSig.When = now
+
+	// Commit the file on top of the commit checked above and push it to NewBranch, which runs the
+	// hooks of the repository like a push of a clone does
+	commitOpts := git.CreateCommitOptions{
+		Branch:       opts.OldBranch,
+		LastCommitID: commit.ID.String(),
+		Message:      message,
+		Author:       authorSig,
+		Committer:    committerSig,
+		PushEnv:      models.PushingEnvironment(doer, repo),
+	}
+	if opts.NewBranch != opts.OldBranch {
+		commitOpts.NewBranch = opts.NewBranch
+	}
+	commitHash, err := gitRepo.EditFile(file, commitOpts)
+	if err != nil {
+		if git.IsErrFileAlreadyExists(err) {
+			return nil, models.ErrRepoFileAlreadyExists{
+				Path: treePath,
+			}
+		} else if git.IsErrRefChanged(err) {
+			return nil, models.ErrCommitIDDoesNotMatch{
+				GivenCommitID:   opts.LastCommitID,
+				CurrentCommitID: commit.ID.String(),
+			}
+		}
 		return nil, err
 	}
 
-	commit, err = t.GetCommit(commitHash)
+	commit, err = gitRepo.GetCommit(commitHash.String())
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := GetFileResponseFromCommit(repo, commit, opts.NewBranch, treePath)
+	fileResponse, err := GetFileResponseFromCommit(repo, commit, opts.NewBranch, treePath)
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	return fileResponse, nil
 }
 
 // PushUpdate must be called for any push actions in order to
@@ -428,6 +427,7 @@ func PushUpdate(repo *models.Repository, branch string, opts models.PushUpdateOp
 	if err != nil {
 		return fmt.Errorf("OpenRepository: %v", err)
 	}
+	defer gitRepo.Close()
 
 	if err = repo.UpdateSize(); err != nil {
 		log.Error("Failed to update size for repository: %v", err)
@@ -499,6 +499,10 @@ func PushUpdate(repo *models.Repository, branch string, opts models.PushUpdateOp
 
 	go models.AddTestPullRequestTask(pusher, repo.ID, branch, true)
 
+	if !isDelRef && strings.HasPrefix(opts.RefFullName, git.BranchPrefix) {
+		go AddWarmLastCommitCacheTask(repoPath, branch)
+	}
+
 	if opts.RefFullName == git.BranchPrefix+repo.DefaultBranch {
 		models.UpdateRepoIndexer(repo)
 	}
@@ -5,6 +5,7 @@
 package setting
 
 import (
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,6 +21,18 @@ type Cache struct {
 	Interval int
 	Conn     string
 	TTL      time.Duration
+
+	// LastCommit configures the cache of the last commits of tree entries
+	LastCommit struct {
+		Enabled bool
+		TTL     time.Duration
+		// Adapter is either "cache" to use the cache service or "disk" for a database on disk
+		Adapter  string
+		Path     string
+		MaxItems int
+		// WarmDepth is the depth of the directories whose last commits are looked up after a push
+		WarmDepth int
+	}
 }
 
 var (
@@ -42,5 +55,13 @@ func newCacheService() {
 	}
 	CacheService.TTL = sec.Key("ITEM_TTL").MustDuration(16 * time.Hour)
 
+	sec = Cfg.Section("cache.last_commit")
+	CacheService.LastCommit.Enabled = sec.Key("ENABLED").MustBool(true)
+	CacheService.LastCommit.TTL = sec.Key("ITEM_TTL").MustDuration(8760 * time.Hour)
+	CacheService.LastCommit.Adapter = sec.Key("ADAPTER").In("cache", []string{"cache", "disk"})
+	CacheService.LastCommit.Path = sec.Key("PATH").MustString(filepath.Join(AppDataPath, "last_commit.db"))
+	CacheService.LastCommit.MaxItems = sec.Key("MAX_ITEMS").MustInt(1000000)
+	CacheService.LastCommit.WarmDepth = sec.Key("WARM_DEPTH").MustInt(1)
+
 	log.Info("Cache Service Enabled")
 }
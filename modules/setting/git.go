@@ -9,8 +9,6 @@ import (
 
 	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/log"
-
-	version "github.com/mcuadros/go-version"
 )
 
 var (
@@ -23,6 +21,9 @@ var (
 		MaxGitDiffFiles           int
 		GCArgs                    []string `ini:"GC_ARGS" delim:" "`
 		EnableAutoGitWireProtocol bool
+		SerializeWrites           bool
+		AuditCommands             bool
+		SigningKey                string
 		Timeout                   struct {
 			Default int
 			Migrate int
@@ -31,6 +32,23 @@ var (
 			Pull    int
 			GC      int `ini:"GC"`
 		} `ini:"git.timeout"`
+		CommitCache struct {
+			MaxItems  int
+			MaxMemory int64
+		} `ini:"git.commit_cache"`
+		ObjectCache struct {
+			MaxMemory     int64
+			WalkMaxMemory int64
+		} `ini:"git.object_cache"`
+		LastCommit struct {
+			MaxCommits  int
+			MaxDuration time.Duration
+			Deadline    time.Duration
+		} `ini:"git.last_commit"`
+		Retry struct {
+			MaxAttempts int
+			Backoff     time.Duration
+		} `ini:"git.retry"`
 	}{
 		DisableDiffHighlight:      false,
 		MaxGitDiffLines:           1000,
@@ -38,6 +56,7 @@ var (
 		MaxGitDiffFiles:           100,
 		GCArgs:                    []string{},
 		EnableAutoGitWireProtocol: true,
+		SigningKey:                git.SigningKeyDefault,
 		Timeout: struct {
 			Default int
 			Migrate int
@@ -53,6 +72,27 @@ var (
 			Pull:    300,
 			GC:      60,
 		},
+		CommitCache: struct {
+			MaxItems  int
+			MaxMemory int64
+		}{
+			MaxItems:  git.CommitCacheMaxItems,
+			MaxMemory: git.CommitCacheMaxMemory,
+		},
+		ObjectCache: struct {
+			MaxMemory     int64
+			WalkMaxMemory int64
+		}{
+			MaxMemory:     git.ObjectCacheMaxMemory,
+			WalkMaxMemory: git.WalkObjectCacheMaxMemory,
+		},
+		Retry: struct {
+			MaxAttempts int
+			Backoff     time.Duration
+		}{
+			MaxAttempts: git.DefaultRetryPolicy.MaxAttempts,
+			Backoff:     git.DefaultRetryPolicy.Backoff,
+		},
 	}
 )
 
@@ -64,13 +104,29 @@ func newGit() {
 		log.Fatal("Failed to initialize Git settings", err)
 	}
 	git.DefaultCommandExecutionTimeout = time.Duration(Git.Timeout.Default) * time.Second
+	git.SerializeWrites = Git.SerializeWrites
+	git.AuditCommands = Git.AuditCommands
+	git.SigningKey = Git.SigningKey
+	git.DefaultRetryPolicy = git.RetryPolicy{
+		MaxAttempts: Git.Retry.MaxAttempts,
+		Backoff:     Git.Retry.Backoff,
+	}
+	git.CommitCacheMaxItems = Git.CommitCache.MaxItems
+	git.CommitCacheMaxMemory = Git.CommitCache.MaxMemory
+	git.ObjectCacheMaxMemory = Git.ObjectCache.MaxMemory
+	git.WalkObjectCacheMaxMemory = Git.ObjectCache.WalkMaxMemory
+	git.DefaultLastCommitsLimits = git.LastCommitsLimits{
+		MaxCommits:  Git.LastCommit.MaxCommits,
+		MaxDuration: Git.LastCommit.MaxDuration,
+	}
 
 	binVersion, err := git.BinVersion()
 	if err != nil {
 		log.Fatal("Error retrieving git version: %v", err)
 	}
+	gitCaps := git.GetCapabilities()
 
-	if version.Compare(binVersion, "2.9", ">=") {
+	if gitCaps.SupportsEmptyCredentialHelper {
 		// Explicitly disable credential helper, otherwise Git credentials might leak
 		git.GlobalCommandArgs = append(git.GlobalCommandArgs, "-c", "credential.helper=")
 	}
@@ -78,7 +134,7 @@ func newGit() {
 	var format = "Git Version: %s"
 	var args = []interface{}{binVersion}
 	// Since git wire protocol has been released from git v2.18
-	if Git.EnableAutoGitWireProtocol && version.Compare(binVersion, "2.18", ">=") {
+	if Git.EnableAutoGitWireProtocol && gitCaps.SupportsProtocolV2 {
 		git.GlobalCommandArgs = append(git.GlobalCommandArgs, "-c", "protocol.version=2")
 		format += ", Wire Protocol %s Enabled"
 		args = append(args, "Version 2") // for focus color
@@ -30,6 +30,7 @@ var (
 		MaxCreationLimit                        int
 		MirrorQueueLength                       int
 		PullRequestQueueLength                  int
+		CommitGraphQueueLength                  int
 		PreferredLicenses                       []string
 		DisableHTTPGit                          bool
 		AccessControlAllowOrigin                string
@@ -72,6 +73,7 @@ var (
 		MaxCreationLimit:                        -1,
 		MirrorQueueLength:                       1000,
 		PullRequestQueueLength:                  1000,
+		CommitGraphQueueLength:                  1000,
 		PreferredLicenses:                       []string{"Apache License 2.0,MIT License"},
 		DisableHTTPGit:                          false,
 		AccessControlAllowOrigin:                "",
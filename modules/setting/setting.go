@@ -28,7 +28,6 @@ import (
 	"code.gitea.io/gitea/modules/user"
 
 	shellquote "github.com/kballard/go-shellquote"
-	version "github.com/mcuadros/go-version"
 	"github.com/unknwon/cae/zip"
 	"github.com/unknwon/com"
 	ini "gopkg.in/ini.v1"
@@ -326,6 +325,17 @@ var (
 		Token:   "",
 	}
 
+	// Tracing settings
+	Tracing = struct {
+		Enabled     bool
+		Endpoint    string
+		ServiceName string
+	}{
+		Enabled:     false,
+		Endpoint:    "http://localhost:4318",
+		ServiceName: "gitea",
+	}
+
 	// I18n settings
 	Langs     []string
 	Names     []string
@@ -454,12 +464,11 @@ func CheckLFSVersion() {
 		//Disable LFS client hooks if installed for the current OS user
 		//Needs at least git v2.1.2
 
-		binVersion, err := git.BinVersion()
-		if err != nil {
+		if _, err := git.BinVersion(); err != nil {
 			log.Fatal("Error retrieving git version: %v", err)
 		}
 
-		if !version.Compare(binVersion, "2.1.2", ">=") {
+		if !git.GetCapabilities().SupportsLFSFilterOverride {
 			LFS.StartServer = false
 			log.Error("LFS server support needs at least Git v2.1.2")
 		} else {
@@ -900,6 +909,8 @@ func NewContext() {
 		log.Fatal("Failed to map API settings: %v", err)
 	} else if err = Cfg.Section("metrics").MapTo(&Metrics); err != nil {
 		log.Fatal("Failed to map Metrics settings: %v", err)
+	} else if err = Cfg.Section("tracing").MapTo(&Tracing); err != nil {
+		log.Fatal("Failed to map Tracing settings: %v", err)
 	}
 
 	u := *appURL
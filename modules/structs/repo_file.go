@@ -25,6 +25,8 @@ type CreateFileOptions struct {
 	// content must be base64 encoded
 	// required: true
 	Content string `json:"content"`
+	// executable (optional) creates the file with the executable bit set
+	Executable *bool `json:"executable"`
 }
 
 // DeleteFileOptions options for deleting files (used for other File structs below)
@@ -45,6 +47,8 @@ type UpdateFileOptions struct {
 	Content string `json:"content"`
 	// from_path (optional) is the path of the original file which will be moved/renamed to the path in the URL
 	FromPath string `json:"from_path" binding:"MaxSize(500)"`
+	// executable (optional) sets or clears the executable bit of the file, it is kept if not given
+	Executable *bool `json:"executable"`
 }
 
 // FileLinksResponse contains the links for a repo's file
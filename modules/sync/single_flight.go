@@ -0,0 +1,68 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSingleFlightPanic is returned to the callers waiting for a call whose function panicked
+var ErrSingleFlightPanic = errors.New("function of the single flight call panicked")
+
+// SingleFlight deduplicates concurrent calls with the same key: while a call
+// is running, callers with the same key wait for it and get its result instead
+// of running the same function again.
+//
+// This is particularly useful for expensive queries which are often requested
+// at the same time, e.g. by many users viewing a popular repository.
+type SingleFlight struct {
+	lock  sync.Mutex
+	calls map[string]*singleFlightCall
+}
+
+type singleFlightCall struct {
+	done   chan struct{}
+	val    interface{}
+	err    error
+	shared bool
+}
+
+// NewSingleFlight initializes and returns a new SingleFlight object.
+func NewSingleFlight() *SingleFlight {
+	return &SingleFlight{
+		calls: make(map[string]*singleFlightCall),
+	}
+}
+
+// Do runs fn and returns its result, unless a call with the same key is already
+// running, in which case it waits for that call and returns its result.
+// shared reports whether the result has been given to several callers.
+func (s *SingleFlight) Do(key string, fn func() (interface{}, error)) (v interface{}, shared bool, err error) {
+	s.lock.Lock()
+	if call, ok := s.calls[key]; ok {
+		call.shared = true
+		s.lock.Unlock()
+		<-call.done
+		return call.val, true, call.err
+	}
+	call := &singleFlightCall{done: make(chan struct{})}
+	s.calls[key] = call
+	s.lock.Unlock()
+
+	// waiting callers must not hang if fn panics
+	defer func() {
+		s.lock.Lock()
+		delete(s.calls, key)
+		shared = call.shared
+		s.lock.Unlock()
+		close(call.done)
+	}()
+
+	// overwritten unless fn panics
+	call.err = ErrSingleFlightPanic
+	call.val, call.err = fn()
+	return call.val, shared, call.err
+}
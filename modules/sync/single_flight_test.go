@@ -0,0 +1,66 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleFlight(t *testing.T) {
+	flight := NewSingleFlight()
+
+	var calls int32
+	start := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-start
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	shared := make([]bool, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, s, err := flight.Do("key", fn)
+			assert.NoError(t, err)
+			results[i], shared[i] = v, s
+		}(i)
+	}
+	// wait until the other callers wait for the running call
+	for {
+		flight.lock.Lock()
+		call := flight.calls["key"]
+		running := call != nil && call.shared
+		flight.lock.Unlock()
+		if running {
+			break
+		}
+	}
+	close(start)
+	wg.Wait()
+
+	assert.True(t, atomic.LoadInt32(&calls) < 5)
+	for i := range results {
+		assert.Equal(t, "result", results[i])
+	}
+
+	// calls with another key or after the call has finished run again
+	v, s, err := flight.Do("key", func() (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.False(t, s)
+	assert.Equal(t, 1, v)
+
+	assert.Panics(t, func() {
+		_, _, _ = flight.Do("panic", func() (interface{}, error) { panic("panic") })
+	})
+	assert.Empty(t, flight.calls)
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+)
+
+const (
+	// the spans are exported once there are exportBatchSize of them or every exportInterval
+	exportBatchSize = 512
+	exportInterval  = 5 * time.Second
+	// spans ended while the queue is full are dropped
+	exportQueueLength = 4 * exportBatchSize
+
+	scopeName = "code.gitea.io/gitea/modules/git"
+)
+
+// The span kind and status codes of the OTLP protocol
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// OTLPTracer is a git.Tracer which exports the spans in batches to an OpenTelemetry collector
+// with the JSON encoding of the OTLP/HTTP protocol
+type OTLPTracer struct {
+	url      string
+	resource otlpResource
+	client   *http.Client
+
+	spans    chan *otlpSpan
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewOTLPTracer returns a tracer exporting the spans to the OTLP/HTTP receiver at endpoint, e.g.
+// "http://localhost:4318", for the service. Close must be called to export the last spans.
+func NewOTLPTracer(endpoint, serviceName string) *OTLPTracer {
+	t := &OTLPTracer{
+		url: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		resource: otlpResource{Attributes: []otlpAttribute{
+			newOTLPAttribute("service.name", serviceName),
+		}},
+		client:  &http.Client{Timeout: 10 * time.Second},
+		spans:   make(chan *otlpSpan, exportQueueLength),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts a span as a child of the span in ctx, if any
+func (t *OTLPTracer) StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, git.Span) {
+	s := &otlpSpan{
+		tracer:            t,
+		Name:              name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SpanID:            randomID(8),
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(*otlpSpan); ok {
+		s.TraceID = parent.TraceID
+		s.ParentSpanID = parent.SpanID
+	} else {
+		s.TraceID = randomID(16)
+	}
+	for key, value := range attributes {
+		s.Attributes = append(s.Attributes, newOTLPAttribute(key, value))
+	}
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// Close exports the spans which have ended and stops exporting
+func (t *OTLPTracer) Close() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		<-t.stopped
+	})
+}
+
+func (t *OTLPTracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*otlpSpan, 0, exportBatchSize)
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.spans:
+					batch = append(batch, s)
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		}
+		t.export(batch)
+		batch = batch[:0]
+	}
+}
+
+// export posts the spans to the collector, they are dropped if that fails
+func (t *OTLPTracer) export(spans []*otlpSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: t.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: scopeName},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		log.Error("Unable to encode %d spans: %v", len(spans), err)
+		return
+	}
+
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warn("Unable to export %d spans to %s: %v", len(spans), t.url, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Warn("Unable to export %d spans to %s: %s %s", len(spans), t.url, resp.Status, msg)
+	}
+}
+
+// randomID returns a random trace or span ID of size bytes, hex-encoded
+func randomID(size int) string {
+	id := make([]byte, size)
+	// the IDs only need to be unique, a failure leaves them zero, which the collector rejects
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// The types below are the JSON encoding of the ExportTraceServiceRequest of the OTLP protocol,
+// IDs are hex-encoded and times are nanoseconds since the epoch as strings
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func newOTLPAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: value}}
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	tracer  *OTLPTracer
+	endOnce sync.Once
+
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// End finishes the span and queues it for export
+func (s *otlpSpan) End(err error) {
+	s.endOnce.Do(func() {
+		s.EndTimeUnixNano = strconv.FormatInt(time.Now().UnixNano(), 10)
+		if err != nil {
+			s.Status = otlpStatus{Code: statusCodeError, Message: err.Error()}
+		}
+		select {
+		case s.tracer.spans <- s:
+		default:
+			log.Trace("Tracing queue is full, span %s dropped", s.Name)
+		}
+	})
+}
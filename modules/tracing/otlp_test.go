@@ -0,0 +1,77 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"code.gitea.io/gitea/modules/git"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPTracer(t *testing.T) {
+	var lock sync.Mutex
+	var requests []otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		lock.Lock()
+		requests = append(requests, req)
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	tracer := NewOTLPTracer(server.URL+"/", "gitea-test")
+	git.SetTracer(tracer)
+	defer git.SetTracer(nil)
+
+	ctx, span := tracer.StartSpan(context.Background(), "view", map[string]string{"page": "home"})
+	_, err := git.NewCommand("version").RunInDirTimeoutEnvWithContext(ctx, nil, -1, "")
+	require.NoError(t, err)
+	_, err = git.NewCommand("rev-parse", "--verify", "no-such-revision").RunInDirTimeoutEnvWithContext(ctx, nil, -1, "")
+	require.Error(t, err)
+	span.End(errors.New("not found"))
+	span.End(nil)
+	tracer.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].ResourceSpans, 1)
+	assert.Equal(t, []otlpAttribute{newOTLPAttribute("service.name", "gitea-test")}, requests[0].ResourceSpans[0].Resource.Attributes)
+	require.Len(t, requests[0].ResourceSpans[0].ScopeSpans, 1)
+	spans := requests[0].ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 3)
+
+	version, revParse, view := spans[0], spans[1], spans[2]
+	assert.Equal(t, "view", view.Name)
+	assert.Len(t, view.TraceID, 32)
+	assert.Len(t, view.SpanID, 16)
+	assert.Empty(t, view.ParentSpanID)
+	assert.Equal(t, []otlpAttribute{newOTLPAttribute("page", "home")}, view.Attributes)
+	assert.Equal(t, otlpStatus{Code: statusCodeError, Message: "not found"}, view.Status)
+	assert.NotEmpty(t, view.StartTimeUnixNano)
+	assert.NotEmpty(t, view.EndTimeUnixNano)
+
+	assert.Equal(t, "git version", version.Name)
+	assert.Equal(t, view.TraceID, version.TraceID)
+	assert.Equal(t, view.SpanID, version.ParentSpanID)
+	assert.NotEqual(t, view.SpanID, version.SpanID)
+	assert.Equal(t, otlpStatus{}, version.Status)
+
+	assert.Equal(t, "git rev-parse", revParse.Name)
+	assert.Equal(t, view.SpanID, revParse.ParentSpanID)
+	assert.Equal(t, statusCodeError, revParse.Status.Code)
+}
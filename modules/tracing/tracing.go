@@ -0,0 +1,38 @@
+// Copyright 2019 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"sync"
+
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+)
+
+var (
+	lock    sync.Mutex
+	current *OTLPTracer
+)
+
+// Init sets the tracer of the git operations configured in the tracing settings. The tracer set
+// by an earlier call is closed.
+func Init() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if current != nil {
+		git.SetTracer(nil)
+		current.Close()
+		current = nil
+	}
+	if !setting.Tracing.Enabled {
+		return
+	}
+
+	current = NewOTLPTracer(setting.Tracing.Endpoint, setting.Tracing.ServiceName)
+	git.SetTracer(current)
+	log.Info("Tracing of git operations enabled, exporting to %s", setting.Tracing.Endpoint)
+}
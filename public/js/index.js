@@ -1953,6 +1953,33 @@ function initWipTitle() {
     });
 }
 
+function initLastCommits() {
+    const $table = $('#repo-files-table[data-last-commits-link]');
+    if ($table.length === 0) {
+        return;
+    }
+
+    const $rows = $table.find('tr.last-commit-unknown');
+    $.ajax({
+        url: $table.attr('data-last-commits-link'),
+        data: {entry: $rows.map(function () { return $(this).attr('data-entry'); }).get()},
+        traditional: true,
+        dataType: 'json'
+    }).done(function (commits) {
+        $rows.each(function () {
+            const $row = $(this);
+            const commit = commits[$row.attr('data-entry')];
+            if (!commit) {
+                return;
+            }
+            const $link = $('<a>').attr('href', commit.link).attr('title', commit.summary).text(commit.summary);
+            $row.find('td.message .truncate').empty().append($link);
+            $row.find('td.age').html(commit.age);
+            $row.removeClass('last-commit-unknown');
+        });
+    });
+}
+
 $(document).ready(function () {
     csrf = $('meta[name=_csrf]').attr("content");
     suburl = $('meta[name=_suburl]').attr("content");
@@ -2192,6 +2219,7 @@ $(document).ready(function () {
     initIssueList();
     initWipTitle();
     initPullRequestReview();
+    initLastCommits();
 
     // Repo clone url.
     if ($('#repo-clone-url').length > 0) {
@@ -89,7 +89,7 @@ func GetArchive(ctx *context.APIContext) {
 	//   200:
 	//     description: success
 	repoPath := models.RepoPath(ctx.Params(":username"), ctx.Params(":reponame"))
-	gitRepo, err := git.OpenRepository(repoPath)
+	gitRepo, err := git.Repositories.Open(repoPath)
 	if err != nil {
 		ctx.Error(http.StatusInternalServerError, "OpenRepository", err)
 		return
@@ -203,6 +203,7 @@ func CreateFile(ctx *context.APIContext, apiOpts api.CreateFileOptions) {
 			Name:  apiOpts.Author.Name,
 			Email: apiOpts.Author.Email,
 		},
+		Mode: fileMode(apiOpts.Executable),
 	}
 
 	if opts.Message == "" {
@@ -267,6 +268,7 @@ func UpdateFile(ctx *context.APIContext, apiOpts api.UpdateFileOptions) {
 			Name:  apiOpts.Author.Name,
 			Email: apiOpts.Author.Email,
 		},
+		Mode: fileMode(apiOpts.Executable),
 	}
 
 	if opts.Message == "" {
@@ -280,6 +282,17 @@ func UpdateFile(ctx *context.APIContext, apiOpts api.UpdateFileOptions) {
 	}
 }
 
+// fileMode returns the mode of a file which is made executable or not, zero keeps the mode if executable is not given
+func fileMode(executable *bool) git.EntryMode {
+	if executable == nil {
+		return 0
+	}
+	if *executable {
+		return git.EntryModeExec
+	}
+	return git.EntryModeBlob
+}
+
 // Called from both CreateFile or UpdateFile to handle both
 func createOrUpdateFile(ctx *context.APIContext, opts *repofiles.UpdateRepoFileOptions) (*api.FileResponse, error) {
 	if !CanWriteFiles(ctx.Repo) {
@@ -19,8 +19,10 @@ import (
 	"code.gitea.io/gitea/modules/mailer"
 	"code.gitea.io/gitea/modules/markup"
 	"code.gitea.io/gitea/modules/markup/external"
+	"code.gitea.io/gitea/modules/repofiles"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/ssh"
+	"code.gitea.io/gitea/modules/tracing"
 
 	"gitea.com/macaron/macaron"
 )
@@ -68,6 +70,7 @@ func GlobalInit() {
 	if err := git.Init(); err != nil {
 		log.Fatal("Git module init failed: %v", err)
 	}
+	tracing.Init()
 	setting.CheckLFSVersion()
 	log.Trace("AppPath: %s", setting.AppPath)
 	log.Trace("AppWorkPath: %s", setting.AppWorkPath)
@@ -101,6 +104,8 @@ func GlobalInit() {
 		models.InitSyncMirrors()
 		models.InitDeliverHooks()
 		models.InitTestPullRequests()
+		repofiles.InitWarmLastCommitCaches()
+		repofiles.InitWriteCommitGraphs()
 	}
 	if setting.EnableSQLite3 {
 		log.Info("SQLite3 Supported")
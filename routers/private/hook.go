@@ -8,13 +8,11 @@ package private
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
 	"code.gitea.io/gitea/models"
 	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/log"
-	"code.gitea.io/gitea/modules/private"
 	"code.gitea.io/gitea/modules/repofiles"
 	"code.gitea.io/gitea/modules/util"
 
@@ -64,21 +62,14 @@ func HookPreReceive(ctx *macaron.Context) {
 
 		// detect force push
 		if git.EmptySHA != oldCommitID {
-			env := os.Environ()
-			if gitAlternativeObjectDirectories != "" {
-				env = append(env,
-					private.GitAlternativeObjectDirectories+"="+gitAlternativeObjectDirectories)
-			}
-			if gitObjectDirectory != "" {
-				env = append(env,
-					private.GitObjectDirectory+"="+gitObjectDirectory)
-			}
-			if gitQuarantinePath != "" {
-				env = append(env,
-					private.GitQuarantinePath+"="+gitQuarantinePath)
-			}
-
-			output, err := git.NewCommand("rev-list", "--max-count=1", oldCommitID, "^"+newCommitID).RunInDirWithEnv(repo.RepoPath(), env)
+			// the pushed objects are still in the quarantine of the push
+			output, err := git.NewCommand("rev-list", "--max-count=1", oldCommitID, "^"+newCommitID).
+				SetEnv(git.CommandEnvOptions{
+					ObjectDirectory:            gitObjectDirectory,
+					AlternateObjectDirectories: gitAlternativeObjectDirectories,
+					QuarantinePath:             gitQuarantinePath,
+				}).
+				RunInDir(repo.RepoPath())
 			if err != nil {
 				log.Error("Unable to detect force push between: %s and %s in %-v Error: %v", oldCommitID, newCommitID, repo, err)
 				ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
@@ -133,6 +124,9 @@ func HookPostReceive(ctx *macaron.Context) {
 	userID := ctx.QueryInt64("userID")
 	userName := ctx.Query("username")
 
+	// the pushed objects may be in new packfiles which pooled repositories do not know about
+	git.Repositories.Invalidate(models.RepoPath(ownerName, repoName))
+
 	branch := refFullName
 	if strings.HasPrefix(refFullName, git.BranchPrefix) {
 		branch = strings.TrimPrefix(refFullName, git.BranchPrefix)
@@ -242,3 +236,15 @@ func HookPostReceive(ctx *macaron.Context) {
 		"message": false,
 	})
 }
+
+// HookPushDone runs the tasks which are done once for all refs updated by a push
+func HookPushDone(ctx *macaron.Context) {
+	ownerName := ctx.Params(":owner")
+	repoName := ctx.Params(":repo")
+
+	// keep the commit-graph up to date, so walking the history of the new commits is fast. The
+	// push does not wait for the queue, which may be full while large repositories are written.
+	go repofiles.AddWriteCommitGraphTask(models.RepoPath(ownerName, repoName))
+
+	ctx.PlainText(http.StatusOK, []byte("ok"))
+}
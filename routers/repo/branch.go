@@ -168,6 +168,16 @@ func loadBranches(ctx *context.Context) []*Branch {
 		return nil
 	}
 
+	branchInfos, _, err := ctx.Repo.GitRepo.GetBranchesSortedAgainst(git.BranchPrefix+ctx.Repo.Repository.DefaultBranch, 0, 0, git.BranchSortName)
+	if err != nil {
+		ctx.ServerError("GetBranchesSortedAgainst", err)
+		return nil
+	}
+	divergences := make(map[string]*git.BranchInfo, len(branchInfos))
+	for _, info := range branchInfos {
+		divergences[info.Name] = info
+	}
+
 	branches := make([]*Branch, len(rawBranches))
 	for i := range rawBranches {
 		commit, err := rawBranches[i].GetCommit()
@@ -185,10 +195,9 @@ func loadBranches(ctx *context.Context) []*Branch {
 			}
 		}
 
-		divergence, divergenceError := repofiles.CountDivergingCommits(ctx.Repo.Repository, branchName)
-		if divergenceError != nil {
-			ctx.ServerError("CountDivergingCommits", divergenceError)
-			return nil
+		divergence, ok := divergences[branchName]
+		if !ok {
+			divergence = &git.BranchInfo{}
 		}
 
 		pr, err := models.GetLatestPullRequestByHeadInfo(ctx.Repo.Repository.ID, branchName)
@@ -375,22 +375,14 @@ func RetrieveRepoMetas(ctx *context.Context, repo *models.Repository) []*models.
 func getFileContentFromDefaultBranch(ctx *context.Context, filename string) (string, bool) {
 	var bytes []byte
 
-	if ctx.Repo.Commit == nil {
-		var err error
-		ctx.Repo.Commit, err = ctx.Repo.GitRepo.GetBranchCommit(ctx.Repo.Repository.DefaultBranch)
-		if err != nil {
-			return "", false
-		}
-	}
-
-	entry, err := ctx.Repo.Commit.GetTreeEntryByPath(filename)
+	blob, err := ctx.Repo.GitRepo.GetBlobByRefPath(git.BranchPrefix+ctx.Repo.Repository.DefaultBranch, filename)
 	if err != nil {
 		return "", false
 	}
-	if entry.Blob().Size() >= setting.UI.MaxDisplayFileSize {
+	if blob.Size() >= setting.UI.MaxDisplayFileSize {
 		return "", false
 	}
-	r, err := entry.Blob().DataAsync()
+	r, err := blob.DataAsync()
 	if err != nil {
 		return "", false
 	}
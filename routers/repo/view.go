@@ -7,15 +7,18 @@ package repo
 
 import (
 	"bytes"
+	gocontext "context"
 	"encoding/base64"
 	"fmt"
 	gotemplate "html/template"
 	"io/ioutil"
 	"path"
 	"strings"
+	"time"
 
 	"code.gitea.io/gitea/models"
 	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/cache"
 	"code.gitea.io/gitea/modules/charset"
 	"code.gitea.io/gitea/modules/context"
 	"code.gitea.io/gitea/modules/git"
@@ -23,7 +26,10 @@ import (
 	"code.gitea.io/gitea/modules/lfs"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/markup"
+	"code.gitea.io/gitea/modules/repofiles"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
 )
 
 const (
@@ -45,42 +51,50 @@ func renderDirectory(ctx *context.Context, treeLink string) {
 		ctx.ServerError("ListEntries", err)
 		return
 	}
-	entries.CustomSort(base.NaturalSortLess)
+	entries.SortWithOptions(git.EntriesSortOptions{
+		DirectoriesFirst:     true,
+		Comparer:             base.NaturalSortLess,
+		IgnoreCaseAndAccents: true,
+	})
+
+	// Don't let huge histories keep the request busy for longer than any other git operation
+	commitsCtx, cancel := gocontext.WithTimeout(ctx.Req.Request.Context(), time.Duration(setting.Git.Timeout.Default)*time.Second)
+	defer cancel()
 
-	var latestCommit *git.Commit
-	ctx.Data["Files"], latestCommit, err = entries.GetCommitsInfo(ctx.Repo.Commit, ctx.Repo.TreePath, nil)
+	limits := git.DefaultLastCommitsLimits
+	if setting.Git.LastCommit.Deadline > 0 {
+		limits.Deadline = time.Now().Add(setting.Git.LastCommit.Deadline)
+	}
+	files, latestCommit, err := entries.GetCommitsInfoLimits(commitsCtx, ctx.Repo.Commit, ctx.Repo.TreePath, nil, cache.NewLastCommitCache(ctx.Repo.GitRepo), limits)
 	if err != nil {
 		ctx.ServerError("GetCommitsInfo", err)
 		return
 	}
-
-	// 3 for the extensions in exts[] in order
-	// the last one is for a readme that doesn't
-	// strictly match an extension
-	var readmeFiles [4]*git.Blob
-	var exts = []string{".md", ".txt", ""} // sorted by priority
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	ctx.Data["Files"] = files
+	for _, file := range files {
+		if file.Unknown {
+			// the remaining last commits are looked up in the background and loaded by the browser
+			repofiles.AddCompleteLastCommitsTask(ctx.Repo.GitRepo.Path, ctx.Repo.Commit.ID.String(), ctx.Repo.TreePath)
+			ctx.Data["LastCommitsLink"] = ctx.Repo.RepoLink + "/lastcommits/" + ctx.Repo.BranchNameSubURL() + "/" + util.PathEscapeSegments(ctx.Repo.TreePath)
+			break
 		}
-
-		for i, ext := range exts {
-			if markup.IsReadmeFile(entry.Name(), ext) {
-				readmeFiles[i] = entry.Blob()
-			}
+	}
+	if latestCommit == nil {
+		if latestCommit, err = ctx.Repo.Commit.GetCommitByPath(ctx.Repo.TreePath); err != nil {
+			ctx.ServerError("GetCommitByPath", err)
+			return
 		}
+	}
 
-		if markup.IsReadmeFile(entry.Name()) {
-			readmeFiles[3] = entry.Blob()
-		}
+	wellKnownFiles, err := tree.FindWellKnownFiles()
+	if err != nil {
+		ctx.ServerError("FindWellKnownFiles", err)
+		return
 	}
 
 	var readmeFile *git.Blob
-	for _, f := range readmeFiles {
-		if f != nil {
-			readmeFile = f
-			break
-		}
+	if wellKnownFiles.Readme != nil {
+		readmeFile = wellKnownFiles.Readme.Blob()
 	}
 
 	if readmeFile != nil {
@@ -415,6 +429,13 @@ func renderCode(ctx *context.Context) {
 	// Get current entry user currently looking at.
 	entry, err := ctx.Repo.Commit.GetTreeEntryByPath(ctx.Repo.TreePath)
 	if err != nil {
+		if git.IsErrNotExist(err) {
+			// The path might have been typed with the wrong case, redirect to the canonical one
+			if _, canonicalPath, err := ctx.Repo.Commit.FindEntryFold(ctx.Repo.TreePath); err == nil {
+				ctx.Redirect(branchLink + "/" + util.PathEscapeSegments(canonicalPath))
+				return
+			}
+		}
 		ctx.NotFoundOrServerError("Repo.Commit.GetTreeEntryByPath", git.IsErrNotExist, err)
 		return
 	}
@@ -449,6 +470,49 @@ func renderCode(ctx *context.Context) {
 	ctx.HTML(200, tplRepoHome)
 }
 
+// LastCommits returns the last commits of the entries of a directory, given by the entry query
+// parameters or all of them, for the tree views rendered before they were found
+func LastCommits(ctx *context.Context) {
+	tree, err := ctx.Repo.Commit.SubTree(ctx.Repo.TreePath)
+	if err != nil {
+		ctx.NotFoundOrServerError("Repo.Commit.SubTree", git.IsErrNotExist, err)
+		return
+	}
+
+	entries, err := tree.ListEntries()
+	if err != nil {
+		ctx.ServerError("ListEntries", err)
+		return
+	}
+
+	commitsCtx, cancel := gocontext.WithTimeout(ctx.Req.Request.Context(), time.Duration(setting.Git.Timeout.Default)*time.Second)
+	defer cancel()
+
+	names := ctx.QueryStrings("entry")
+	if len(names) == 0 {
+		names = nil
+	}
+	files, _, err := entries.GetCommitsInfoLimits(commitsCtx, ctx.Repo.Commit, ctx.Repo.TreePath, names, cache.NewLastCommitCache(ctx.Repo.GitRepo), git.LastCommitsLimits{})
+	if err != nil {
+		ctx.ServerError("GetCommitsInfo", err)
+		return
+	}
+
+	commits := make(map[string]interface{}, len(files))
+	for _, file := range files {
+		if file.Commit == nil {
+			continue
+		}
+		commits[file.Entry.Name()] = map[string]interface{}{
+			"id":      file.Commit.ID.String(),
+			"summary": file.Commit.Summary(),
+			"link":    ctx.Repo.RepoLink + "/commit/" + file.Commit.ID.String(),
+			"age":     timeutil.TimeSince(file.Commit.Committer.When, ctx.Locale.Language()),
+		}
+	}
+	ctx.JSON(200, commits)
+}
+
 // RenderUserCards render a page show users according the input templaet
 func RenderUserCards(ctx *context.Context, total int, getter func(page int) ([]*models.User, error), tpl base.TplName) {
 	page := ctx.QueryInt("page")
@@ -16,6 +16,7 @@ import (
 	"code.gitea.io/gitea/models"
 	"code.gitea.io/gitea/modules/auth"
 	"code.gitea.io/gitea/modules/context"
+	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/gzip"
 	"code.gitea.io/gitea/modules/lfs"
 	"code.gitea.io/gitea/modules/log"
@@ -884,6 +885,12 @@ func RegisterRoutes(m *macaron.Macaron) {
 			m.Get("/*", context.RepoRefByType(context.RepoRefLegacy), repo.RefCommits)
 		}, repo.MustBeNotEmpty, reqRepoCodeReader)
 
+		m.Group("/lastcommits", func() {
+			m.Get("/branch/*", context.RepoRefByType(context.RepoRefBranch), repo.LastCommits)
+			m.Get("/tag/*", context.RepoRefByType(context.RepoRefTag), repo.LastCommits)
+			m.Get("/commit/*", context.RepoRefByType(context.RepoRefCommit), repo.LastCommits)
+		}, repo.MustBeNotEmpty, reqRepoCodeReader)
+
 		m.Group("/blame", func() {
 			m.Get("/branch/*", context.RepoRefByType(context.RepoRefBranch), repo.RefBlame)
 			m.Get("/tag/*", context.RepoRefByType(context.RepoRefTag), repo.RefBlame)
@@ -990,6 +997,9 @@ func RegisterRoutes(m *macaron.Macaron) {
 	if setting.Metrics.Enabled {
 		c := metrics.NewCollector()
 		prometheus.MustRegister(c)
+		gitCollector := metrics.NewGitCommandCollector()
+		prometheus.MustRegister(gitCollector)
+		git.SetCommandObserver(gitCollector.Observe)
 
 		m.Get("/metrics", routers.Metrics)
 	}